@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"owenvi.com/fleetsim/internal/conditions"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
+	"owenvi.com/fleetsim/internal/gridloader"
+	"owenvi.com/fleetsim/internal/livestate"
+	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/fleetsim/internal/spawning"
+	"owenvi.com/fleetsim/internal/storage"
+	"owenvi.com/fleetsim/internal/telemetry"
+	"owenvi.com/fleetsim/internal/traffic"
+	"owenvi.com/fleetsim/internal/wsserver"
+	"owenvi.com/roadgraph"
+	"owenvi.com/roadgraph/simclock"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	width := flag.Int64("width", 30, "grid width in cells")
+	height := flag.Int64("height", 30, "grid height in cells")
+	vehicleCount := flag.Int("vehicles", 10, "vehicles to spawn at startup")
+	seed := flag.Int64("seed", 99, "seed for grid generation and spawning")
+	redisAddr := flag.String("redis-addr", "", "Redis address for live vehicle and road state; disabled when empty")
+	databaseDSN := flag.String("db-dsn", "", "Postgres connection string for stored grids and fleets")
+	gridID := flag.Int64("grid-id", 0, "run on this stored grid instead of generating one (needs -db-dsn)")
+	saveGrid := flag.String("save-grid", "", "store the generated grid and fleet under this name (needs -db-dsn)")
+	graphPath := flag.String("graph", "", "run on this road graph, such as one exported by simsim, instead of generating a grid")
+	cellKM := flag.Float64("cell-km", 0, "cell size in km to lay -graph out on; zero picks one")
+	exportGraph := flag.String("export-graph", "", "write the grid as a road graph to this file, for simsim")
+	geoJSONPath := flag.String("geojson", "", "run on this GeoJSON grid, as written by -export-geojson, instead of generating one")
+	exportGeoJSON := flag.String("export-geojson", "", "write the grid as GeoJSON to this file, for GIS tools")
+	origin := flag.String("origin", "0,0", "lon,lat in degrees where -export-geojson places the grid's corner")
+	telemetryDSN := flag.String("telemetry-dsn", "", "Postgres/TimescaleDB connection string for telemetry; disabled when empty")
+	clockMode := flag.String("clock", "", "simulation clock: realtime, accelerated or afap; the config default when empty")
+	workers := flag.Int("workers", runtime.GOMAXPROCS(0), "goroutines sharing each movement step")
+	oneWay := flag.Bool("oneway", false, "make the streets off the main arteries one-way")
+	arterialLanes := flag.Int64("arterial-lanes", 0, "lanes each way on the main arteries; one when 0")
+	flag.Parse()
+
+	cfg := config.Config()
+	if *clockMode != "" {
+		cfg.ClockMode = constants.ClockMode(*clockMode)
+	}
+	if err := cfg.ValidateConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var store *storage.Store
+	if *databaseDSN != "" {
+		var err error
+		store, err = storage.NewStore(ctx, *databaseDSN)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		if err := store.Migrate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "grid database migration failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if store == nil && (*gridID > 0 || *saveGrid != "") {
+		fmt.Fprintln(os.Stderr, "-grid-id and -save-grid need -db-dsn")
+		os.Exit(1)
+	}
+	sources := 0
+	for _, set := range []bool{*gridID > 0, *graphPath != "", *geoJSONPath != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		fmt.Fprintln(os.Stderr, "only one of -grid-id, -graph and -geojson can be used")
+		os.Exit(1)
+	}
+	projection, err := roadgraph.ParseProjection(*origin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -origin: %v\n", err)
+		os.Exit(1)
+	}
+
+	clock, err := simclock.New(cfg.ClockMode, cfg.SimulationSpeedMultiplier, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid clock: %v\n", err)
+		os.Exit(1)
+	}
+
+	gridLoader := gridloader.NewGridLoader()
+	gridLoader.ConfigureForTesting(*width, *height, *seed, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	gridLoader.BaseRoadConditions = cfg.BaseRoadConditions
+	gridLoader.OneWayStreets = *oneWay
+	gridLoader.ArterialLanes = *arterialLanes
+	gridLoader.SetLogOutput(os.Stdout)
+	vehicleSpawner := gridloader.NewVehicleSpawner(cfg, *seed)
+	vehicleSpawner.SetClock(clock)
+	vehicleSpawner.SetLogOutput(os.Stdout)
+
+	var grid *domainmodels.Grid
+	var vehicles []domainmodels.Vehicle
+	if *gridID > 0 {
+		var err error
+		grid, err = store.LoadGrid(ctx, *gridID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load grid %d: %v\n", *gridID, err)
+			os.Exit(1)
+		}
+		vehicles, err = store.LoadVehicles(ctx, *gridID, grid)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load vehicles of grid %d: %v\n", *gridID, err)
+			os.Exit(1)
+		}
+		if len(vehicles) == 0 {
+			vehicles, err = vehicleSpawner.SpawnRandomVehicles(grid, *vehicleCount)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "vehicle spawning failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	} else if *graphPath != "" || *geoJSONPath != "" {
+		if *graphPath != "" {
+			g, err := roadgraph.Load(*graphPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			grid, err = gridLoader.ImportGraph(g, *cellKM)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to import road graph %s: %v\n", *graphPath, err)
+				os.Exit(1)
+			}
+		} else {
+			grid, err = gridLoader.LoadFromGeoJSON(*geoJSONPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to load GeoJSON grid: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		vehicles, err = vehicleSpawner.SpawnRandomVehicles(grid, *vehicleCount)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vehicle spawning failed: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		world, err := gridLoader.CreateDemoGrid(*vehicleCount, vehicleSpawner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "grid generation failed: %v\n", err)
+			os.Exit(1)
+		}
+		grid, vehicles = world.Grid, world.Vehicles
+
+		if *saveGrid != "" {
+			savedID, err := store.SaveGrid(ctx, grid, *saveGrid)
+			if err == nil {
+				fleet := make([]*domainmodels.Vehicle, len(vehicles))
+				for i := range vehicles {
+					fleet[i] = &vehicles[i]
+				}
+				err = store.SaveVehicles(ctx, savedID, fleet)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to save grid: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Grid saved as %d; rerun with -grid-id %d to reuse it\n", savedID, savedID)
+		}
+	}
+
+	if *exportGraph != "" {
+		if err := graphconv.ToGraph(grid).Save(*exportGraph); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export road graph: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Road graph written to %s\n", *exportGraph)
+	}
+	if *exportGeoJSON != "" {
+		if err := gridloader.SaveGeoJSON(grid, *exportGeoJSON, projection); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export GeoJSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("GeoJSON written to %s\n", *exportGeoJSON)
+	}
+
+	manager := movement.NewVehicleLifecycleManager(grid, vehicles, clock)
+	manager.SetWorkers(*workers)
+	if cfg.BackgroundPeakUtilization > 0 {
+		manager.SetBackgroundTraffic(traffic.NewBackgroundTraffic(cfg, *seed))
+	}
+	roadConditions := conditions.NewEngine(grid, cfg, *seed)
+	roadConditions.SetLogOutput(os.Stdout)
+	manager.SetRoadConditions(roadConditions)
+	spawnProcessor := spawning.NewSpawnRequestProcessor(cfg, manager, vehicleSpawner)
+	server := wsserver.NewServer(cfg, manager, spawnProcessor)
+
+	if *redisAddr != "" {
+		client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+		defer client.Close()
+		if err := client.Ping(ctx).Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to reach redis at %s: %v\n", *redisAddr, err)
+			os.Exit(1)
+		}
+		server.SetLiveState(livestate.NewPublisher(client, cfg, "fleetsim"))
+	}
+
+	if *telemetryDSN != "" {
+		store, err := telemetry.NewPostgresStore(ctx, *telemetryDSN)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "telemetry disabled: %v\n", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		if err := store.Migrate(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "telemetry migration failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		writer := telemetry.NewWriter(store, cfg, uuid.New())
+		writer.SetClock(clock)
+		fmt.Printf("Recording telemetry for run %s\n", writer.RunID())
+		server.SetTelemetry(writer)
+
+		flushed := make(chan struct{})
+		go func() {
+			writer.Run(ctx)
+			close(flushed)
+		}()
+		defer func() { <-flushed }()
+	}
+
+	if err := server.ListenAndServe(ctx, *addr); err != nil {
+		fmt.Fprintf(os.Stderr, "server error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/gridloader"
+)
+
+func TestUpgradeIndexedGrids(t *testing.T) {
+	cfg := config.Config()
+	for _, name := range []string{"gridTest1.json", "gridTest2.json"} {
+		t.Run(name, func(t *testing.T) {
+			original, err := os.ReadFile(filepath.Join("../../internal/testData", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, original, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := upgrade(cfg, path, path, false, false)
+			if err != nil {
+				t.Fatalf("checking: %v", err)
+			}
+			if !strings.Contains(report, "format 1") || !strings.HasSuffix(report, "needs upgrading") {
+				t.Errorf("check reported %q", report)
+			}
+			if data, _ := os.ReadFile(path); string(data) != string(original) {
+				t.Error("checking rewrote the file")
+			}
+
+			report, err = upgrade(cfg, path, path, true, false)
+			if err != nil {
+				t.Fatalf("upgrading: %v", err)
+			}
+			if !strings.HasSuffix(report, "in format 2") {
+				t.Errorf("upgrade reported %q", report)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version, err := gridloader.DetectGridFormat(data); err != nil || version != gridloader.CurrentGridFormat {
+				t.Errorf("upgraded file is format %d, %v", version, err)
+			}
+			if info, err := os.Stat(path); err != nil {
+				t.Error(err)
+			} else if info.Mode().Perm() != 0o600 {
+				t.Errorf("upgraded file has mode %v, want the original's 0600", info.Mode().Perm())
+			}
+
+			report, err = upgrade(cfg, path, path, true, false)
+			if err != nil {
+				t.Fatalf("upgrading again: %v", err)
+			}
+			if !strings.HasSuffix(report, "already current") {
+				t.Errorf("second upgrade reported %q", report)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+				t.Errorf("upgrade left %d files behind, want only the grid", len(entries))
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	"owenvi.com/fleetsim/internal/domainmodels"
 	"owenvi.com/fleetsim/internal/gridloader"
 	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/fleetsim/internal/simcontrol"
+	"owenvi.com/roadgraph/simclock"
 )
 
 func main() {
@@ -19,6 +22,7 @@ func main() {
 	config := config.Config()
 
 	gridLoader := gridloader.NewGridLoader()
+	gridLoader.SetLogOutput(os.Stdout)
 	args := os.Args[1:]
 
 	dimX := args[0]
@@ -36,10 +40,14 @@ func main() {
 	//gridLoader.ConfigureForTesting(int64(width), int64(height), 42, 0, 0.02, 0, 0.2, 0.3, 0.1)
 	//gridLoader.ConfigureForTesting(int64(width), int64(height), 42, 0.03, 0.01, 0.02, 0.6, 0.2, 0.1)
 	gridLoader.ConfigureForTesting(int64(width), int64(height), 99, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	gridLoader.BaseRoadConditions = config.BaseRoadConditions
 	// gridloader.ConfigureForTesting(int64(width), int64(height),, 1337, 0.08, 0.03, 0.02, 0.85, 0.5, 0.05)
 	// gridloader.ConfigureForTesting(int64(width), int64(height), 7, 0.02, 0.01, 0.08, 0.4, 0.15, 0.25)
 	// gridLoader.ConfigureForTesting(int64(width), int64(height), 12345, 0.05, 0.02, 0.05, 0.7, 0.35, 0.1)
+	clock := simclock.NewAsFastAsPossible(time.Now())
 	vehicleSpawner := gridloader.NewVehicleSpawner(config, 42)
+	vehicleSpawner.SetClock(clock)
+	vehicleSpawner.SetLogOutput(os.Stdout)
 
 	fmt.Printf("Generating %s x %s grid with roads and special locations...", dimX, dimY)
 
@@ -131,27 +139,40 @@ func main() {
 
 	fmt.Println("\n=== TESTING VEHICLE MOVEMENT ===")
 
-	vehicleManager := movement.NewVehicleLifecycleManager(demoWorld.Grid, demoWorld.Vehicles)
+	vehicleManager := movement.NewVehicleLifecycleManager(demoWorld.Grid, demoWorld.Vehicles, clock)
+	vehicleManager.SetLogOutput(os.Stdout)
+
+	// Each step covers half a simulated second and is followed by a traffic
+	// tick.
+	controller := simcontrol.NewController(vehicleManager.Clock(),
+		simcontrol.ManagerEngine{Manager: vehicleManager}, 500*time.Millisecond, 500*time.Millisecond)
+	controller.SetLogOutput(os.Stdout)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go controller.Run(ctx)
 
 	for step := 0; step < 10; step++ {
 		fmt.Printf("\n--- Simulation Step %d ---\n", step+1)
 
-		vehicleManager.UpdateAllVehicles(0.5)
+		if err := controller.Step(1); err != nil {
+			fmt.Printf("Simulation step failed: %v\n", err)
+			break
+		}
 
 		activeVehicles := vehicleManager.GetActiveVehicles()
 		fmt.Printf("Active vehicles: %d\n", len(activeVehicles))
 
 		for _, vehicle := range activeVehicles {
-    if vehicle.CurrentSegment != nil && vehicle.CurrentCell != nil && vehicle.DestinationCell != nil {
-        fmt.Printf("  %s: (%.1f,%.1f) -> (%.1f,%.1f) Speed: %.1f km/h Fuel: %.1fL\n",
-            vehicle.ID,
-            float64(vehicle.CurrentCell.Xpos), float64(vehicle.CurrentCell.Ypos),
-            float64(vehicle.DestinationCell.Xpos), float64(vehicle.DestinationCell.Ypos),
-            vehicle.CurrentSpeedKPH, vehicle.FuelLevel)
-    } else {
-        fmt.Printf("  %s: incomplete vehicle state (missing cell or destination)\n", vehicle.ID)
-    }
-}
+			if vehicle.CurrentSegment != nil && vehicle.CurrentCell != nil && vehicle.DestinationCell != nil {
+				fmt.Printf("  %s: (%.1f,%.1f) -> (%.1f,%.1f) Speed: %.1f km/h Fuel: %.1fL\n",
+					vehicle.ID,
+					float64(vehicle.CurrentCell.Xpos), float64(vehicle.CurrentCell.Ypos),
+					float64(vehicle.DestinationCell.Xpos), float64(vehicle.DestinationCell.Ypos),
+					vehicle.CurrentSpeedKPH, vehicle.FuelLevel)
+			} else {
+				fmt.Printf("  %s: incomplete vehicle state (missing cell or destination)\n", vehicle.ID)
+			}
+		}
 
 		if step%10 == 0 {
 			fmt.Printf("\nGrid at step %d:\n", step+1)
@@ -0,0 +1,282 @@
+package conditions
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const (
+	SeverityMinor    = "minor"
+	SeverityModerate = "moderate"
+	SeveritySevere   = "severe"
+)
+
+// weatherRadiusCells is how far from its centre an area-wide incident reaches.
+const weatherRadiusCells = 3
+
+// Engine raises temporary incidents on the road network and expires them once
+// their time is up. Segments with a severe incident are closed until it clears.
+type Engine struct {
+	grid   *domainmodels.Grid
+	config *config.SimulationConfig
+	rng    *rand.Rand
+
+	// areaWide holds the incident templates that hit every segment around a
+	// point rather than a single segment.
+	areaWide map[string]bool
+
+	incidentCounter int64
+	logOutput       io.Writer
+}
+
+func NewEngine(grid *domainmodels.Grid, cfg *config.SimulationConfig, seed int64) *Engine {
+	areaWide := make(map[string]bool, len(cfg.AreaWideIncidents))
+	for _, id := range cfg.AreaWideIncidents {
+		areaWide[id] = true
+	}
+	return &Engine{
+		grid:     grid,
+		config:   cfg,
+		rng:      rand.New(rand.NewSource(seed)),
+		areaWide: areaWide,
+	}
+}
+
+// SetLogOutput makes the engine write a line to w for every incident it
+// raises. It is silent by default.
+func (e *Engine) SetLogOutput(w io.Writer) {
+	e.logOutput = w
+}
+
+func (e *Engine) logf(format string, args ...any) {
+	if e.logOutput == nil {
+		return
+	}
+	fmt.Fprintf(e.logOutput, format+"\n", args...)
+}
+
+// Update expires conditions that ran out by now and, with the configured
+// probability, raises a new incident. It returns one message per segment whose
+// conditions changed, ordered by segment ID.
+func (e *Engine) Update(now time.Time) []domainmodels.ConditionUpdateMessage {
+	changes := make(map[int64]*domainmodels.ConditionUpdateMessage)
+	segmentIDs := e.segmentIDs()
+
+	e.expire(segmentIDs, now, changes)
+	if len(e.config.IncidentConditions) > 0 && e.rng.Float64() < e.config.RandomConditionProbability {
+		e.raiseIncident(segmentIDs, now, changes)
+	}
+
+	messages := make([]domainmodels.ConditionUpdateMessage, 0, len(changes))
+	for _, segmentID := range segmentIDs {
+		change, ok := changes[segmentID]
+		if !ok {
+			continue
+		}
+		segment := e.grid.GetSegment(segmentID)
+		segment.VisualState = VisualStateFor(segment)
+		e.copyToEveryCell(segment)
+		change.NewVisualState = segment.VisualState
+		messages = append(messages, *change)
+	}
+	return messages
+}
+
+func (e *Engine) expire(segmentIDs []int64, now time.Time, changes map[int64]*domainmodels.ConditionUpdateMessage) {
+	for _, segmentID := range segmentIDs {
+		segment := e.grid.GetSegment(segmentID)
+		if segment == nil || len(segment.TemporaryConditions) == 0 {
+			continue
+		}
+
+		remaining := segment.TemporaryConditions[:0]
+		for _, condition := range segment.TemporaryConditions {
+			if condition.ExpiresAt != nil && !now.Before(*condition.ExpiresAt) {
+				change := changeFor(changes, segmentID)
+				change.RemovedConditions = append(change.RemovedConditions, condition.ID)
+				continue
+			}
+			remaining = append(remaining, condition)
+		}
+		segment.TemporaryConditions = remaining
+
+		if _, changed := changes[segmentID]; changed {
+			segment.IsOpen = !hasSevereCondition(segment)
+		}
+	}
+}
+
+func (e *Engine) raiseIncident(segmentIDs []int64, now time.Time, changes map[int64]*domainmodels.ConditionUpdateMessage) {
+	if len(segmentIDs) == 0 {
+		return
+	}
+
+	templateIDs := make([]string, 0, len(e.config.IncidentConditions))
+	for id := range e.config.IncidentConditions {
+		templateIDs = append(templateIDs, id)
+	}
+	sort.Strings(templateIDs)
+	template := e.config.IncidentConditions[templateIDs[e.rng.Intn(len(templateIDs))]]
+
+	target := e.grid.GetSegment(segmentIDs[e.rng.Intn(len(segmentIDs))])
+	if target == nil || !target.IsOpen {
+		return
+	}
+
+	minSeconds, maxSeconds := e.config.ConditionDurationRange[0], e.config.ConditionDurationRange[1]
+	duration := time.Duration(minSeconds+e.rng.Int63n(maxSeconds-minSeconds+1)) * time.Second
+	expiresAt := now.Add(duration)
+
+	e.incidentCounter++
+	incident := template
+	incident.ID = fmt.Sprintf("%s_%d", template.ID, e.incidentCounter)
+	incident.IsTemporary = true
+	incident.ExpiresAt = &expiresAt
+
+	affected := []*domainmodels.RoadSegment{target}
+	if e.areaWide[template.ID] {
+		affected = e.segmentsAround(segmentIDs, target)
+	}
+
+	for _, segment := range affected {
+		if hasConditionFrom(segment, template.ID) {
+			continue
+		}
+		segment.TemporaryConditions = append(segment.TemporaryConditions, incident)
+		if incident.Severity == SeveritySevere {
+			segment.IsOpen = false
+		}
+		change := changeFor(changes, segment.ID)
+		change.AddedConditions = append(change.AddedConditions, incident)
+	}
+
+	e.logf("Condition %s raised around segment %d for %v", incident.ID, target.ID, duration)
+}
+
+// copyToEveryCell gives every other cell's copy of segment its conditions,
+// open state and look. Each cell a segment touches holds it by value, and
+// movement checks read the copy in the cell they start from, so a closure must
+// reach all of them, the way base conditions do.
+func (e *Engine) copyToEveryCell(segment *domainmodels.RoadSegment) {
+	for _, end := range [][2]int64{{segment.StartX, segment.StartY}, {segment.EndX, segment.EndY}} {
+		cell := e.grid.CoordIndex[end]
+		if cell == nil {
+			continue
+		}
+		for i := range cell.RoadSegments {
+			other := &cell.RoadSegments[i].RoadSegment
+			if cell.RoadSegments[i].RoadSegmentID != segment.ID || other == segment {
+				continue
+			}
+			other.TemporaryConditions = slices.Clone(segment.TemporaryConditions)
+			other.IsOpen = segment.IsOpen
+			other.VisualState = segment.VisualState
+		}
+	}
+}
+
+func (e *Engine) segmentsAround(segmentIDs []int64, center *domainmodels.RoadSegment) []*domainmodels.RoadSegment {
+	var around []*domainmodels.RoadSegment
+	for _, segmentID := range segmentIDs {
+		segment := e.grid.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+		dx := segment.StartX - center.StartX
+		dy := segment.StartY - center.StartY
+		if dx*dx+dy*dy <= weatherRadiusCells*weatherRadiusCells {
+			around = append(around, segment)
+		}
+	}
+	return around
+}
+
+func (e *Engine) segmentIDs() []int64 {
+	ids := make([]int64, 0, len(e.grid.SegmentIndex))
+	for segmentID := range e.grid.SegmentIndex {
+		ids = append(ids, segmentID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func changeFor(changes map[int64]*domainmodels.ConditionUpdateMessage, segmentID int64) *domainmodels.ConditionUpdateMessage {
+	change, ok := changes[segmentID]
+	if !ok {
+		change = &domainmodels.ConditionUpdateMessage{SegmentID: segmentID}
+		changes[segmentID] = change
+	}
+	return change
+}
+
+// hasConditionFrom reports whether segment already carries an incident raised
+// from templateID, so the same incident type never stacks on one segment.
+func hasConditionFrom(segment *domainmodels.RoadSegment, templateID string) bool {
+	for _, condition := range segment.TemporaryConditions {
+		if strings.HasPrefix(condition.ID, templateID+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSevereCondition(segment *domainmodels.RoadSegment) bool {
+	for _, condition := range segment.TemporaryConditions {
+		if condition.Severity == SeveritySevere {
+			return true
+		}
+	}
+	return false
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeveritySevere:
+		return 3
+	case SeverityModerate:
+		return 2
+	case SeverityMinor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// VisualStateFor colours a segment by its worst temporary condition, falling
+// back to its base condition, and dims it while closed.
+func VisualStateFor(segment *domainmodels.RoadSegment) domainmodels.SegmentVisualState {
+	state := domainmodels.SegmentVisualState{Opacity: 1.0}
+	if len(segment.BaseConditions) > 0 {
+		state.PrimaryColor = segment.BaseConditions[0].VisualColor
+	}
+	if !segment.IsOpen {
+		state.Opacity = 0.5
+	}
+	if len(segment.TemporaryConditions) == 0 {
+		return state
+	}
+
+	worst := segment.TemporaryConditions[0]
+	names := make([]string, 0, len(segment.TemporaryConditions))
+	for _, condition := range segment.TemporaryConditions {
+		if severityRank(condition.Severity) > severityRank(worst.Severity) {
+			worst = condition
+		}
+		names = append(names, condition.Name)
+	}
+
+	state.SecondaryColor = state.PrimaryColor
+	state.PrimaryColor = worst.VisualColor
+	state.AnimationSpeed = 1.0
+	state.ShowWarning = true
+	state.WarningMessage = strings.Join(names, ", ")
+	return state
+}
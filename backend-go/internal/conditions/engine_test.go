@@ -0,0 +1,89 @@
+package conditions
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/runtime"
+)
+
+// twoCellGrid is one segment joining (0,0) and (1,0), held by value in both
+// cells the way generated grids hold it.
+func twoCellGrid() *domainmodels.Grid {
+	segment := domainmodels.RoadSegment{ID: 1, StartX: 0, StartY: 0, EndX: 1, EndY: 0, IsOpen: true}
+	grid := &domainmodels.Grid{
+		DimX: 2,
+		DimY: 1,
+		Cells: []domainmodels.Cell{
+			{Xpos: 0, Ypos: 0, CellType: domainmodels.CellTypeNormal},
+			{Xpos: 1, Ypos: 0, CellType: domainmodels.CellTypeNormal},
+		},
+		CoordIndex:   make(map[[2]int64]*domainmodels.Cell),
+		SegmentIndex: make(map[int64]*domainmodels.Cell),
+	}
+	for i := range grid.Cells {
+		cell := &grid.Cells[i]
+		cell.RoadSegments = []domainmodels.CellRoad{{RoadSegmentID: segment.ID, RoadSegment: segment}}
+		grid.CoordIndex[[2]int64{cell.Xpos, cell.Ypos}] = cell
+	}
+	grid.SegmentIndex[segment.ID] = &grid.Cells[0]
+	return grid
+}
+
+func TestSevereIncidentClosesEveryCopyOfTheSegment(t *testing.T) {
+	cfg := config.Config()
+	cfg.IncidentConditions = map[string]domainmodels.RoadCondition{
+		"accident": cfg.IncidentConditions["accident"],
+	}
+	cfg.AreaWideIncidents = nil
+	cfg.RandomConditionProbability = 1
+	cfg.ConditionDurationRange = [2]int64{60, 60}
+
+	grid := twoCellGrid()
+	engine := NewEngine(grid, cfg, 1)
+	var log strings.Builder
+	engine.SetLogOutput(&log)
+	validator := runtime.NewMovementValidator(grid)
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	move := runtime.MoveRequest{FromCell: &grid.Cells[0], ToCell: &grid.Cells[1], SegmentID: 1}
+	reverse := runtime.MoveRequest{FromCell: &grid.Cells[1], ToCell: &grid.Cells[0], SegmentID: 1}
+
+	if updates := engine.Update(start); len(updates) != 1 {
+		t.Fatalf("got %d condition updates, want 1", len(updates))
+	}
+	if !strings.HasPrefix(log.String(), "Condition accident_1 raised around segment 1") {
+		t.Errorf("logged %q", log.String())
+	}
+	for i := range grid.Cells {
+		held := grid.Cells[i].RoadSegments[0].RoadSegment
+		if held.IsOpen || len(held.TemporaryConditions) != 1 {
+			t.Errorf("copy in cell %d: open %v with %d conditions, want closed with 1",
+				i, held.IsOpen, len(held.TemporaryConditions))
+		}
+	}
+	if validator.ValidateMove(move) || validator.ValidateMove(reverse) {
+		t.Error("moves along a closed segment validate")
+	}
+
+	cfg.RandomConditionProbability = 0
+	engine.Update(start.Add(time.Minute))
+	for i := range grid.Cells {
+		if held := grid.Cells[i].RoadSegments[0].RoadSegment; !held.IsOpen || len(held.TemporaryConditions) != 0 {
+			t.Errorf("copy in cell %d still closed after the incident expired", i)
+		}
+	}
+	if !validator.ValidateMove(move) || !validator.ValidateMove(reverse) {
+		t.Error("moves along the reopened segment do not validate")
+	}
+}
+
+func TestAreaWideIncidentsComeFromConfig(t *testing.T) {
+	cfg := config.Config()
+	cfg.AreaWideIncidents = []string{"snow"}
+	if err := cfg.ValidateConfig(); err == nil {
+		t.Error("config with an unknown area-wide incident validates")
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 
+	"owenvi.com/fleetsim/internal/constants"
 	"owenvi.com/fleetsim/internal/domainmodels"
 )
 
@@ -10,10 +11,11 @@ type SimulationConfig struct {
 	MaxActiveVehicles      int `json:"max_active_vehicles"`
 	MaxSpawnRequestsPerMin int `json:"max_spawn_requests_per_min"`
 
-	SimulationSpeedMultiplier float64 `json:"simulation_speed_multiplier"`
-	TrafficUpdateInterval     int64   `json:"traffic_update_interval_ms"`
-	VehicleCleanupInterval    int64   `json:"vehicle_cleanup_interval_ms"`
-	MovementUpdateInterval    int64   `json:"movement_update_interval_ms"`
+	ClockMode                 constants.ClockMode `json:"clock_mode"`
+	SimulationSpeedMultiplier float64             `json:"simulation_speed_multiplier"`
+	TrafficUpdateInterval     int64               `json:"traffic_update_interval_ms"`
+	VehicleCleanupInterval    int64               `json:"vehicle_cleanup_interval_ms"`
+	MovementUpdateInterval    int64               `json:"movement_update_interval_ms"`
 
 	DefaultFuelRange        [2]float64         `json:"default_fuel_range"`
 	DefaultSpeedVariation   float64            `json:"default_speed_variation"`
@@ -26,12 +28,19 @@ type SimulationConfig struct {
 	MinDistanceFromOthers  int64  `json:"min_distance_from_others"`
 	RequireFuelStop        bool   `json:"require_fuel_stop"`
 
+	BackgroundPeakUtilization float64 `json:"background_peak_utilization"`
+	SimulationStartHour       float64 `json:"simulation_start_hour"`
+
 	BaseRoadConditions         map[string]domainmodels.RoadCondition `json:"base_road_conditions"`
+	IncidentConditions         map[string]domainmodels.RoadCondition `json:"incident_conditions"`
+	AreaWideIncidents          []string                              `json:"area_wide_incidents"`
 	RandomConditionProbability float64                               `json:"random_condition_probability"`
 	ConditionDurationRange     [2]int64                              `json:"condition_duration_range"`
 
-	RedisCommandBudgetPerHour int `json:"redis_command_budget_per_hour"`
-	TimescaleRetentionHours   int `json:"timescale_retention_hours"`
+	RedisCommandBudgetPerHour int   `json:"redis_command_budget_per_hour"`
+	TimescaleRetentionHours   int   `json:"timescale_retention_hours"`
+	TelemetryBatchSize        int   `json:"telemetry_batch_size"`
+	TelemetryFlushInterval    int64 `json:"telemetry_flush_interval_ms"`
 }
 
 func Config() *SimulationConfig {
@@ -39,6 +48,7 @@ func Config() *SimulationConfig {
 		MaxActiveVehicles:      50,
 		MaxSpawnRequestsPerMin: 30,
 
+		ClockMode:                 constants.ClockModeAccelerated,
 		SimulationSpeedMultiplier: 2.0,
 		TrafficUpdateInterval:     2000,
 		VehicleCleanupInterval:    30000,
@@ -60,6 +70,9 @@ func Config() *SimulationConfig {
 		MinDistanceFromOthers:  2,
 		RequireFuelStop:        false,
 
+		BackgroundPeakUtilization: 0.6,
+		SimulationStartHour:       8.0,
+
 		BaseRoadConditions: map[string]domainmodels.RoadCondition{
 			"urban_street": {
 				ID:              "urban_street",
@@ -85,11 +98,50 @@ func Config() *SimulationConfig {
 			},
 		},
 
+		IncidentConditions: map[string]domainmodels.RoadCondition{
+			"accident": {
+				ID:              "accident",
+				Name:            "Accident",
+				Description:     "Collision blocking the road until it is cleared",
+				SpeedMultiplier: 0.2,
+				FuelMultiplier:  1.3,
+				IsTemporary:     true,
+				Severity:        "severe",
+				VisualColor:     "#D0021B",
+				VisualPattern:   "dashed",
+			},
+			"roadworks": {
+				ID:              "roadworks",
+				Name:            "Roadworks",
+				Description:     "Lane closures for maintenance work",
+				SpeedMultiplier: 0.5,
+				FuelMultiplier:  1.15,
+				IsTemporary:     true,
+				Severity:        "moderate",
+				VisualColor:     "#F5A623",
+				VisualPattern:   "striped",
+			},
+			"heavy_rain": {
+				ID:              "heavy_rain",
+				Name:            "Heavy Rain",
+				Description:     "Reduced visibility and grip across the area",
+				SpeedMultiplier: 0.75,
+				FuelMultiplier:  1.1,
+				IsTemporary:     true,
+				Severity:        "minor",
+				VisualColor:     "#7FB3D5",
+				VisualPattern:   "dotted",
+			},
+		},
+
+		AreaWideIncidents:          []string{"heavy_rain"},
 		RandomConditionProbability: 0.05,
 		ConditionDurationRange:     [2]int64{60, 300},
 
 		RedisCommandBudgetPerHour: 7000,
 		TimescaleRetentionHours:   2,
+		TelemetryBatchSize:        500,
+		TelemetryFlushInterval:    1000,
 	}
 }
 
@@ -113,9 +165,48 @@ func (config *SimulationConfig) ValidateConfig() error {
 		return fmt.Errorf("fuel range minimum must be less than maximum")
 	}
 
+	if config.BackgroundPeakUtilization < 0.0 || config.BackgroundPeakUtilization > 1.0 {
+		return fmt.Errorf("background peak utilization must be between 0.0 and 1.0, got %.2f", config.BackgroundPeakUtilization)
+	}
+	if config.SimulationStartHour < 0.0 || config.SimulationStartHour >= 24.0 {
+		return fmt.Errorf("simulation start hour must be between 0 and 24, got %.2f", config.SimulationStartHour)
+	}
+
+	if config.RandomConditionProbability < 0.0 || config.RandomConditionProbability > 1.0 {
+		return fmt.Errorf("random condition probability must be between 0.0 and 1.0, got %.2f", config.RandomConditionProbability)
+	}
+	for _, id := range config.AreaWideIncidents {
+		if _, ok := config.IncidentConditions[id]; !ok {
+			return fmt.Errorf("area-wide incident %q is not an incident condition", id)
+		}
+	}
+	if config.ConditionDurationRange[0] <= 0 || config.ConditionDurationRange[0] > config.ConditionDurationRange[1] {
+		return fmt.Errorf("condition duration range must be positive with minimum <= maximum")
+	}
+
+	if config.RedisCommandBudgetPerHour <= 0 {
+		return fmt.Errorf("redis command budget must be positive, got %d", config.RedisCommandBudgetPerHour)
+	}
+	if config.TimescaleRetentionHours <= 0 {
+		return fmt.Errorf("timescale retention must be at least one hour, got %d", config.TimescaleRetentionHours)
+	}
+	if config.TelemetryBatchSize <= 0 || config.TelemetryFlushInterval <= 0 {
+		return fmt.Errorf("telemetry batch size and flush interval must be positive")
+	}
+
 	if config.TrafficUpdateInterval <= 0 || config.MovementUpdateInterval <= 0 {
 		return fmt.Errorf("update intervals must be positive")
 	}
 
+	switch config.ClockMode {
+	case constants.ClockModeRealTime:
+	case constants.ClockModeAccelerated, constants.ClockModeAsFastAsPossible:
+		if config.SimulationSpeedMultiplier <= 0 {
+			return fmt.Errorf("simulation speed multiplier must be positive, got %.2f", config.SimulationSpeedMultiplier)
+		}
+	default:
+		return fmt.Errorf("unknown clock mode %q", config.ClockMode)
+	}
+
 	return nil
 }
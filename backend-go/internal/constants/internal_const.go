@@ -1,5 +1,7 @@
 package constants
 
+import "owenvi.com/roadgraph/simclock"
+
 type SpawnRequestStatus string
 
 const (
@@ -59,4 +61,39 @@ const (
 	WSMsgVehicleRemove    WSMessageType = "vehicle_remove"
 	WSMsgUserVehiclesList WSMessageType = "user_vehicles_list"
 	WSMsgConditionUpdate  WSMessageType = "condition_update"
+
+	WSMsgSimulationControl WSMessageType = "simulation_control"
+	WSMsgSimulationStatus  WSMessageType = "simulation_status"
+	WSMsgSimulationPause   WSMessageType = "simulation_pause"
+	WSMsgSimulationResume  WSMessageType = "simulation_resume"
+	WSMsgSimulationStep    WSMessageType = "simulation_step"
+	WSMsgSimulationSpeed   WSMessageType = "simulation_speed"
+)
+
+type SimulationState string
+
+const (
+	SimulationStateIdle    SimulationState = "idle"
+	SimulationStateRunning SimulationState = "running"
+	SimulationStatePaused  SimulationState = "paused"
+	SimulationStateStopped SimulationState = "stopped"
+)
+
+type SimulationAction string
+
+const (
+	SimulationActionStart    SimulationAction = "start"
+	SimulationActionPause    SimulationAction = "pause"
+	SimulationActionResume   SimulationAction = "resume"
+	SimulationActionStep     SimulationAction = "step"
+	SimulationActionSetSpeed SimulationAction = "set_speed"
+)
+
+// ClockMode is the shared simulation clock's mode.
+type ClockMode = simclock.Mode
+
+const (
+	ClockModeRealTime         = simclock.RealTime
+	ClockModeAccelerated      = simclock.Accelerated
+	ClockModeAsFastAsPossible = simclock.AsFastAsPossible
 )
@@ -0,0 +1,55 @@
+package dbmigrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration is one schema change. Versions only need to be unique and
+// increasing within a component.
+type Migration struct {
+	Version   int
+	Statement string
+}
+
+// Apply runs every migration of component that has not been recorded in
+// schema_migrations yet, each in its own transaction. Components share the
+// table, so telemetry and grid storage can live in the same database.
+func Apply(ctx context.Context, pool *pgxpool.Pool, component string, migrations []Migration) error {
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		component  TEXT        NOT NULL,
+		version    INTEGER     NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (component, version)
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		var applied bool
+		if err := pool.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE component = $1 AND version = $2)`,
+			component, m.Version).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check %s migration %d: %w", component, m.Version, err)
+		}
+		if applied {
+			continue
+		}
+
+		err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.Statement); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx,
+				`INSERT INTO schema_migrations (component, version) VALUES ($1, $2)`, component, m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s migration %d failed: %w", component, m.Version, err)
+		}
+	}
+	return nil
+}
@@ -19,6 +19,15 @@ type GraphNode struct {
 	IsOpen   bool   `json:"is_open"`
 }
 
+// SegmentDirection is which way a road segment may be driven.
+type SegmentDirection string
+
+const (
+	BothWays     SegmentDirection = ""
+	ForwardOnly  SegmentDirection = "forward"  // from start to end only
+	BackwardOnly SegmentDirection = "backward" // from end to start only
+)
+
 type GraphEdge struct {
 	From int64 `json:"from"`
 	To   int64 `json:"to"`
@@ -93,33 +102,154 @@ func (g *Grid) GetGraphEdges() []GraphEdge {
 		return edges
 	}
 
+	// Adjacency runs one way only where segments are one-way, so each pair
+	// of segments is listed once whichever way it is found.
+	seen := make(map[[2]int64]bool)
 	for fromID, neighbors := range g.RoadGraph.Adjacency {
 		for _, toID := range neighbors {
-			if fromID < toID {
-				edges = append(edges, GraphEdge{
-					From: fromID,
-					To:   toID,
-				})
+			pair := [2]int64{min(fromID, toID), max(fromID, toID)}
+			if fromID == toID || seen[pair] {
+				continue
 			}
+			seen[pair] = true
+			edges = append(edges, GraphEdge{
+				From: pair[0],
+				To:   pair[1],
+			})
 		}
 	}
 	return edges
 }
 
-func (segment *RoadSegment) AddVehicle() {
-	segment.CurrentTrafficLoad.VehicleCount++
-	if segment.Capacity != nil {
+// AddVehicle counts a vehicle onto the segment, driving it forward, from
+// start to end, or back.
+func (segment *RoadSegment) AddVehicle(forward bool) {
+	load := &segment.CurrentTrafficLoad
+	load.VehicleCount++
+	if forward {
+		load.ForwardCount++
+	} else {
+		load.BackwardCount++
+	}
+	segment.updateUtilization()
+}
+
+// RemoveVehicle takes a vehicle driving the segment forward or back off it.
+func (segment *RoadSegment) RemoveVehicle(forward bool) {
+	load := &segment.CurrentTrafficLoad
+	if load.VehicleCount == 0 {
+		return
+	}
+	load.VehicleCount--
+	if forward {
+		load.ForwardCount = max(load.ForwardCount-1, 0)
+	} else {
+		load.BackwardCount = max(load.BackwardCount-1, 0)
+	}
+	segment.updateUtilization()
+}
+
+func (segment *RoadSegment) updateUtilization() {
+	if capacity := segment.EffectiveCapacity(); capacity != nil {
 		segment.CurrentTrafficLoad.CapacityUtilization =
-			float64(segment.CurrentTrafficLoad.VehicleCount) / float64(*segment.Capacity)
+			float64(segment.CurrentTrafficLoad.VehicleCount+segment.CurrentTrafficLoad.BackgroundCount) / float64(*capacity)
 	}
 }
 
-func (segment *RoadSegment) RemoveVehicle() {
-	if segment.CurrentTrafficLoad.VehicleCount > 0 {
-		segment.CurrentTrafficLoad.VehicleCount--
-		if segment.Capacity != nil {
-			segment.CurrentTrafficLoad.CapacityUtilization =
-				float64(segment.CurrentTrafficLoad.VehicleCount) / float64(*segment.Capacity)
+func (g *Grid) GetSegment(segmentID int64) *RoadSegment {
+	cell, ok := g.SegmentIndex[segmentID]
+	if !ok {
+		return nil
+	}
+	for i := range cell.RoadSegments {
+		if cell.RoadSegments[i].RoadSegmentID == segmentID {
+			return &cell.RoadSegments[i].RoadSegment
 		}
 	}
+	return nil
+}
+
+func (segment *RoadSegment) HasEndpoint(x, y int64) bool {
+	return (segment.StartX == x && segment.StartY == y) ||
+		(segment.EndX == x && segment.EndY == y)
+}
+
+func (segment *RoadSegment) OtherEndpoint(x, y int64) (int64, int64) {
+	if segment.StartX == x && segment.StartY == y {
+		return segment.EndX, segment.EndY
+	}
+	return segment.StartX, segment.StartY
+}
+
+func (segment *RoadSegment) OneWay() bool {
+	return segment.Direction == ForwardOnly || segment.Direction == BackwardOnly
+}
+
+// CanLeave reports whether the segment may be driven away from the cell at
+// (x,y), which must be one of its ends.
+func (segment *RoadSegment) CanLeave(x, y int64) bool {
+	switch {
+	case segment.StartX == x && segment.StartY == y:
+		return segment.Direction != BackwardOnly
+	case segment.EndX == x && segment.EndY == y:
+		return segment.Direction != ForwardOnly
+	}
+	return false
+}
+
+// LanesFrom is how many lanes lead away from the end of the segment at
+// (x,y), or zero when it may not be driven that way.
+func (segment *RoadSegment) LanesFrom(x, y int64) int64 {
+	if !segment.CanLeave(x, y) {
+		return 0
+	}
+	lanes := segment.LanesBackward
+	if segment.StartX == x && segment.StartY == y {
+		lanes = segment.LanesForward
+	}
+	return max(lanes, 1)
+}
+
+// EffectiveCapacity is Capacity scaled from one lane each way to the lanes
+// the segment has, both ways together, or nil when it has no capacity.
+func (segment *RoadSegment) EffectiveCapacity() *int64 {
+	if segment.Capacity == nil {
+		return nil
+	}
+	lanes := segment.lanesBothWays()
+	capacity := max((*segment.Capacity*lanes+1)/2, 1)
+	return &capacity
+}
+
+// CapacityFrom is how many vehicles fit on the segment driving away from the
+// end at (x,y): half of Capacity for each lane that way, and zero when it may
+// not be driven that way. It is nil when the segment has no capacity.
+func (segment *RoadSegment) CapacityFrom(x, y int64) *int64 {
+	if segment.Capacity == nil {
+		return nil
+	}
+	var capacity int64
+	if lanes := segment.LanesFrom(x, y); lanes > 0 {
+		capacity = max((*segment.Capacity*lanes+1)/2, 1)
+	}
+	return &capacity
+}
+
+// LoadFrom is the traffic driving away from the end at (x,y): the fleet
+// vehicles going that way and that way's share of background traffic, which
+// spreads over the lanes of both ways.
+func (segment *RoadSegment) LoadFrom(x, y int64) int {
+	load := segment.CurrentTrafficLoad
+	count := load.BackwardCount
+	if segment.StartX == x && segment.StartY == y {
+		count = load.ForwardCount
+	}
+	if lanes := segment.lanesBothWays(); lanes > 0 && load.BackgroundCount > 0 {
+		count += int((int64(load.BackgroundCount)*segment.LanesFrom(x, y) + lanes/2) / lanes)
+	}
+	return count
+}
+
+func (segment *RoadSegment) lanesBothWays() int64 {
+	return segment.LanesFrom(segment.StartX, segment.StartY) + segment.LanesFrom(segment.EndX, segment.EndY)
 }
@@ -4,6 +4,7 @@ import "time"
 
 type GridDB struct {
 	ID        int64     `json:"id" db:"id"` // PK
+	Name      string    `json:"name" db:"name"`
 	DimX      int64     `json:"dimX" db:"dim_x"`
 	DimY      int64     `json:"dimY" db:"dim_y"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -22,24 +23,34 @@ type CellDB struct {
 }
 
 type RoadSegmentDB struct {
-	ID     int64 `json:"id" db:"id"`
+	ID     int64 `json:"id" db:"id"`           // PK together with GridID
+	GridID int64 `json:"grid_id" db:"grid_id"` // FK
 	StartX int64 `json:"start_x" db:"start_x"`
 	StartY int64 `json:"start_y" db:"start_y"`
 	EndX   int64 `json:"end_x" db:"end_x"`
 	EndY   int64 `json:"end_y" db:"end_y"`
 
+	LengthKM       float64         `json:"length_km" db:"length_km"`
+	BaseSpeedKPH   float64         `json:"base_speed_kph" db:"base_speed_kph"`
+	BaseConditions []RoadCondition `json:"base_conditions" db:"base_conditions"` // jsonb
+
 	//these are optional so can be null or ommited
 	SpeedLimit *int64   `json:"speed_limit,omitempty" db:"speed_limit"`
 	Capacity   *int64   `json:"capacity,omitempty" db:"capacity"`
 	Weather    []string `json:"weather_conditions,omitempty" db:"weather_conditions"`
 	IsOpen     bool     `json:"is_open" db:"is_open"`
 
+	Direction     SegmentDirection `json:"direction" db:"direction"`
+	LanesForward  int64            `json:"lanes_forward" db:"lanes_forward"`
+	LanesBackward int64            `json:"lanes_backward" db:"lanes_backward"`
+
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type CellRoadDB struct {
 	ID            int64     `json:"id" db:"id"`
+	GridID        int64     `json:"grid_id" db:"grid_id"`                 // FK
 	CellID        int64     `json:"cell_id" db:"cell_id"`                 // FK
 	RoadSegmentID int64     `json:"road_segment_id" db:"road_segment_id"` // FK
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
@@ -19,7 +19,12 @@ type RoadCondition struct {
 }
 
 type TrafficLoadState struct {
-	VehicleCount        int       `json:"vehicle_count"`
+	VehicleCount int `json:"vehicle_count"`
+	// ForwardCount and BackwardCount split VehicleCount by the way the
+	// vehicles drive: from the segment's start to its end, and back.
+	ForwardCount        int       `json:"forward_count"`
+	BackwardCount       int       `json:"backward_count"`
+	BackgroundCount     int       `json:"background_count"`
 	CapacityUtilization float64   `json:"capacity_utilization"`
 	AverageSpeed        float64   `json:"average_speed"`
 	LastUpdated         time.Time `json:"last_updated"`
@@ -45,8 +50,17 @@ type RoadSegment struct {
 	BaseSpeedKPH float64 `json:"base_speed_kph"`
 
 	SpeedLimit *int64 `json:"speed_limit,omitempty"`
-	Capacity   *int64 `json:"capacity,omitempty"`
-	IsOpen     bool   `json:"is_open"`
+	// Capacity is what the segment holds as a road of one lane each way;
+	// EffectiveCapacity scales it to the segment's lanes.
+	Capacity *int64 `json:"capacity,omitempty"`
+	IsOpen   bool   `json:"is_open"`
+
+	// Direction is which way the segment may be driven. LanesForward and
+	// LanesBackward are the lanes driven from start to end and back; zero
+	// means one lane wherever Direction allows driving that way.
+	Direction     SegmentDirection `json:"direction,omitempty"`
+	LanesForward  int64            `json:"lanes_forward,omitempty"`
+	LanesBackward int64            `json:"lanes_backward,omitempty"`
 
 	BaseConditions      []RoadCondition `json:"base_conditions"`
 	TemporaryConditions []RoadCondition `json:"temporary_conditions"`
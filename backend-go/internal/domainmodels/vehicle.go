@@ -25,6 +25,7 @@ type MovementResult struct {
 	ReachedSegmentEnd  bool    `json:"reached_segment_end"`
 	RemainingFuel      float64 `json:"remaining_fuel"`
 	ReachedDestination bool    `json:"reached_destination"`
+	OutOfFuel          bool    `json:"out_of_fuel"`
 	Error              string  `json:"error,omitempty"`
 }
 
@@ -37,6 +38,7 @@ type Vehicle struct {
 	CurrentCell     *Cell        `json:"current_cell,omitempty"`
 	CurrentSegment  *RoadSegment `json:"current_segment,omitempty"`
 	SegmentProgress float64      `json:"segment_progress"`
+	TravelDirection int64        `json:"travel_direction"`
 
 	CurrentSpeedKPH    float64 `json:"current_speed_kph"`
 	TargetSpeedKPH     float64 `json:"target_speed_kph"`
@@ -46,6 +48,8 @@ type Vehicle struct {
 	NextDecisionAt  int64   `json:"next_decision_at,omitempty"`
 	OriginCell      *Cell   `json:"origin_cell,omitempty"`
 	DestinationCell *Cell   `json:"destination_cell,omitempty"`
+	RefuelStop      *Cell   `json:"refuel_stop,omitempty"`
+	RequireFuelStop bool    `json:"require_fuel_stop,omitempty"`
 	ProximityLOD    bool
 	Progress        float64
 
@@ -57,6 +61,10 @@ type Vehicle struct {
 	LastDecisionAt int64      `json:"last_decision_at,omitempty"`
 	FailureReason  *string    `json:"failure_reason,omitempty"`
 
+	UserSessionID  *string `json:"user_session_id,omitempty"`
+	SpawnRequestID *string `json:"spawn_request_id,omitempty"`
+	CustomName     *string `json:"custom_name,omitempty"`
+
 	TotalDistanceTraveled float64 `json:"total_distance_traveled"`
 	TotalFuelConsumed     float64 `json:"total_fuel_consumed"`
 }
@@ -84,6 +92,28 @@ func (v *Vehicle) GetFuelRange() float64 {
 	return (v.Profile.TankLiters / v.Profile.ConsumptionL100KM) * 100
 }
 
+// RemainingRangeKM is how far the fuel currently in the tank lasts at the
+// profile's base consumption.
+func (v *Vehicle) RemainingRangeKM() float64 {
+	if v.Profile.ConsumptionL100KM <= 0 {
+		return 0
+	}
+	return (v.FuelLevel / v.Profile.ConsumptionL100KM) * 100
+}
+
+// Refuel adds up to liters to the tank and returns how much actually fit.
+func (v *Vehicle) Refuel(liters float64) float64 {
+	space := v.Profile.TankLiters - v.FuelLevel
+	if liters > space {
+		liters = space
+	}
+	if liters <= 0 {
+		return 0
+	}
+	v.FuelLevel += liters
+	return liters
+}
+
 func (v *Vehicle) GetFuelPercentage() float64 {
 	if v.Profile.TankLiters == 0 {
 		return 0
@@ -128,29 +158,35 @@ func (v *Vehicle) CalculateMovementForTimeStep(timeStepSeconds float64, currentS
 	}
 }
 
-func (v *Vehicle) calculateTrafficMultipliers(load TrafficLoadState) (speedMultiplier, fuelMultiplier float64) {
-	if load.CapacityUtilization <= 0 {
+// calculateTrafficMultipliers scales a vehicle against the traffic stream.
+// freeSpeed is what the vehicle would drive on the segment with no traffic, so
+// a vehicle is only held back when the stream is slower than it wants to go.
+func (v *Vehicle) calculateTrafficMultipliers(load TrafficLoadState, freeSpeed float64) (speedMultiplier, fuelMultiplier float64) {
+	if load.CapacityUtilization <= 0 || freeSpeed <= 0 {
 		return 1.0, 1.0
 	}
 
 	if load.CapacityUtilization >= 1.0 {
 		speedMultiplier = 0.3
 	} else {
-		avgSpeedFactor := math.Min(1.0, load.AverageSpeed/float64(v.Profile.MaxSpeedKPH))
+		avgSpeedFactor := math.Min(1.0, load.AverageSpeed/freeSpeed)
 		congestionEffect := 1.0 - 0.6*math.Pow(load.CapacityUtilization, 2)
 		speedMultiplier = math.Min(avgSpeedFactor, congestionEffect)
 	}
 
 	trafficFactor := 1.0 + 0.5*math.Pow(load.CapacityUtilization, 1.5)
 	speedFactor := 1.0
-	if load.AverageSpeed < float64(v.Profile.MaxSpeedKPH)*0.5 {
-		speedFactor += 0.2 * (1.0 - load.AverageSpeed/(float64(v.Profile.MaxSpeedKPH)*0.5))
+	if load.AverageSpeed < freeSpeed*0.5 {
+		speedFactor += 0.2 * (1.0 - load.AverageSpeed/(freeSpeed*0.5))
 	}
 	fuelMultiplier = trafficFactor * speedFactor
 
 	return speedMultiplier, fuelMultiplier
 }
-func (v *Vehicle) calculateEffectiveSpeed(segment *RoadSegment) float64 {
+
+// FreeFlowSpeed is the speed the vehicle reaches on segment when it has the
+// road to itself: its own top speed, the segment limit and road conditions.
+func (v *Vehicle) FreeFlowSpeed(segment *RoadSegment) float64 {
 	maxSpeed := float64(v.Profile.MaxSpeedKPH) * v.SpeedMultiplier
 	segmentLimit := segment.BaseSpeedKPH
 	if segment.SpeedLimit != nil {
@@ -164,12 +200,23 @@ func (v *Vehicle) calculateEffectiveSpeed(segment *RoadSegment) float64 {
 	for _, condition := range segment.TemporaryConditions {
 		speedMultiplier *= condition.SpeedMultiplier
 	}
-	trafficMultiplier, _ := v.calculateTrafficMultipliers(segment.CurrentTrafficLoad)
-	return baseSpeed * speedMultiplier * trafficMultiplier
+	return baseSpeed * speedMultiplier
+}
+
+func (v *Vehicle) calculateEffectiveSpeed(segment *RoadSegment) float64 {
+	freeSpeed := v.FreeFlowSpeed(segment)
+	trafficMultiplier, _ := v.calculateTrafficMultipliers(segment.CurrentTrafficLoad, freeSpeed)
+	return freeSpeed * trafficMultiplier
 }
 
 func (v *Vehicle) calculateFuelConsumption(distanceKM float64, segment *RoadSegment) float64 {
-	baseFuelPer100KM := v.Profile.ConsumptionL100KM
+	effectiveConsumption := v.Profile.ConsumptionL100KM * v.FuelMultiplier(segment)
+	return (effectiveConsumption * distanceKM) / 100.0
+}
+
+// FuelMultiplier scales the vehicle's base consumption for segment's road
+// conditions and traffic.
+func (v *Vehicle) FuelMultiplier(segment *RoadSegment) float64 {
 	fuelMultiplier := 1.0
 	for _, condition := range segment.BaseConditions {
 		fuelMultiplier *= condition.FuelMultiplier
@@ -177,20 +224,20 @@ func (v *Vehicle) calculateFuelConsumption(distanceKM float64, segment *RoadSegm
 	for _, condition := range segment.TemporaryConditions {
 		fuelMultiplier *= condition.FuelMultiplier
 	}
-	_, trafficFuelMultiplier := v.calculateTrafficMultipliers(segment.CurrentTrafficLoad)
-	effectiveConsumption := baseFuelPer100KM * fuelMultiplier * trafficFuelMultiplier
-	return (effectiveConsumption * distanceKM) / 100.0
+	_, trafficFuelMultiplier := v.calculateTrafficMultipliers(segment.CurrentTrafficLoad, v.FreeFlowSpeed(segment))
+	return fuelMultiplier * trafficFuelMultiplier
 }
 
-func (v *Vehicle) CanEnterSegment(segment *RoadSegment) bool {
-
-	if !segment.IsOpen {
+// CanEnterSegment reports whether the vehicle may drive onto segment from its
+// end at (fromX,fromY): the segment is open, may be driven that way, and has
+// room on the lanes going that way.
+func (v *Vehicle) CanEnterSegment(segment *RoadSegment, fromX, fromY int64) bool {
+	if !segment.IsOpen || !segment.CanLeave(fromX, fromY) {
 		return false
 	}
 
-	if segment.Capacity != nil {
-		currentCount := segment.CurrentTrafficLoad.VehicleCount
-		if currentCount >= int(*segment.Capacity) {
+	if capacity := segment.CapacityFrom(fromX, fromY); capacity != nil {
+		if segment.LoadFrom(fromX, fromY) >= int(*capacity) {
 			return false
 		}
 	}
@@ -207,24 +254,69 @@ func (v *Vehicle) HasReachedDestination() bool {
 		v.CurrentCell.Ypos == v.DestinationCell.Ypos
 }
 
+// exitsAtDestination reports whether the vehicle leaves its current segment at
+// its destination. Routes always end at a segment end, so that is where the
+// destination is reached, not wherever the rounded position first lands on it.
+func (v *Vehicle) exitsAtDestination() bool {
+	if v.DestinationCell == nil || v.CurrentSegment == nil {
+		return false
+	}
+	exitX, exitY := v.GetSegmentExitPoint()
+	return exitX == v.DestinationCell.Xpos && exitY == v.DestinationCell.Ypos
+}
+
 func (v *Vehicle) UpdatePosition(timeStepSeconds float64, grid *Grid) MovementResult {
 	if v.CurrentSegment == nil {
 		return MovementResult{Error: "vehicle not on any segment"}
 	}
 
 	currentSpeed, distanceTraveled, progressIncrement := v.calculateMovementStep(timeStepSeconds, v.CurrentSegment)
+	fuelConsumed := v.calculateFuelConsumption(distanceTraveled, v.CurrentSegment)
+
+	// A vehicle that cannot afford the whole step only covers the distance its
+	// remaining fuel allows.
+	outOfFuel := false
+	if fuelConsumed > v.FuelLevel {
+		fraction := 0.0
+		if fuelConsumed > 0 {
+			fraction = v.FuelLevel / fuelConsumed
+		}
+		distanceTraveled *= fraction
+		progressIncrement *= fraction
+		fuelConsumed = v.FuelLevel
+		outOfFuel = true
+	}
 
 	v.CurrentSpeedKPH = currentSpeed
+	v.FuelLevel -= fuelConsumed
+	v.TotalDistanceTraveled += distanceTraveled
+	v.TotalFuelConsumed += fuelConsumed
 
 	v.Progress += progressIncrement
+	v.SegmentProgress = v.Progress
 
 	v.updateCurrentCellFromProgress(grid)
 
-	if v.HasReachedDestination() {
+	if outOfFuel {
+		v.CurrentSpeedKPH = 0
+		return MovementResult{
+			NewProgress:      v.Progress,
+			DistanceTraveled: distanceTraveled,
+			FuelConsumed:     fuelConsumed,
+			EffectiveSpeed:   currentSpeed,
+			RemainingFuel:    v.FuelLevel,
+			OutOfFuel:        true,
+		}
+	}
+
+	if v.Progress >= 1.0 && v.exitsAtDestination() {
 		v.Status = constants.VehicleStatusCompleted
 		return MovementResult{
 			NewProgress:        v.Progress,
 			DistanceTraveled:   distanceTraveled,
+			FuelConsumed:       fuelConsumed,
+			EffectiveSpeed:     currentSpeed,
+			RemainingFuel:      v.FuelLevel,
 			ReachedDestination: true,
 		}
 	}
@@ -232,6 +324,9 @@ func (v *Vehicle) UpdatePosition(timeStepSeconds float64, grid *Grid) MovementRe
 	return MovementResult{
 		NewProgress:       v.Progress,
 		DistanceTraveled:  distanceTraveled,
+		FuelConsumed:      fuelConsumed,
+		EffectiveSpeed:    currentSpeed,
+		RemainingFuel:     v.FuelLevel,
 		ReachedSegmentEnd: v.Progress >= 1.0,
 	}
 }
@@ -245,9 +340,14 @@ func (v *Vehicle) updateCurrentCellFromProgress(grid *Grid) {
 	startY := float64(v.CurrentSegment.StartY)
 	endX := float64(v.CurrentSegment.EndX)
 	endY := float64(v.CurrentSegment.EndY)
+	if v.TravelDirection < 0 {
+		startX, endX = endX, startX
+		startY, endY = endY, startY
+	}
 
-	currentX := startX + v.Progress*(endX-startX)
-	currentY := startY + v.Progress*(endY-startY)
+	progress := math.Min(v.Progress, 1.0)
+	currentX := startX + progress*(endX-startX)
+	currentY := startY + progress*(endY-startY)
 
 	cellX := int64(math.Round(currentX))
 	cellY := int64(math.Round(currentY))
@@ -263,3 +363,24 @@ func (v *Vehicle) calculateMovementStep(timeStepSeconds float64, segment *RoadSe
 	progressIncrement = distanceTraveled / segment.LengthKM
 	return
 }
+
+func (v *Vehicle) EnterSegment(segment *RoadSegment, fromX, fromY int64) {
+	v.CurrentSegment = segment
+	v.Progress = 0.0
+	v.SegmentProgress = 0.0
+	if segment.EndX == fromX && segment.EndY == fromY {
+		v.TravelDirection = -1
+	} else {
+		v.TravelDirection = 1
+	}
+}
+
+func (v *Vehicle) GetSegmentExitPoint() (int64, int64) {
+	if v.CurrentSegment == nil {
+		return 0, 0
+	}
+	if v.TravelDirection < 0 {
+		return v.CurrentSegment.StartX, v.CurrentSegment.StartY
+	}
+	return v.CurrentSegment.EndX, v.CurrentSegment.EndY
+}
@@ -20,6 +20,7 @@ type VehicleProfileDB struct {
 
 type VehicleDB struct {
 	ID               string     `db:"id"`
+	GridID           int64      `db:"grid_id"` // FK -> grids.id
 	VehicleClass     string     `db:"vehicle_class"`
 	FleetID          *uuid.UUID `db:"fleet_id"`
 	VehicleProfileID int64      `db:"vehicle_profile_id"`
@@ -38,11 +38,12 @@ type SpawnRequestMessage struct {
 }
 
 type SpawnResponseMessage struct {
-	RequestID        string   `json:"request_id"`
-	Success          bool     `json:"success"`
-	SpawnedVehicleID *string  `json:"spawned_vehicle_id,omitempty"`
-	ErrorMessage     *string  `json:"error_message,omitempty"`
-	ValidationErrors []string `json:"validation_errors,omitempty"`
+	RequestID        string                       `json:"request_id"`
+	Success          bool                         `json:"success"`
+	Status           constants.SpawnRequestStatus `json:"status,omitempty"`
+	SpawnedVehicleID *string                      `json:"spawned_vehicle_id,omitempty"`
+	ErrorMessage     *string                      `json:"error_message,omitempty"`
+	ValidationErrors []string                     `json:"validation_errors,omitempty"`
 }
 
 type UserVehiclesListMessage struct {
@@ -71,3 +72,20 @@ type TrafficUpdate struct {
 	CongestionLevel     string   `json:"congestion_level"`
 	VisualColor         string   `json:"visual_color"`
 }
+
+// SimulationControlMessage asks the simulation controller for a transition.
+// Steps is read by the step action and Speed by set_speed.
+type SimulationControlMessage struct {
+	Action constants.SimulationAction `json:"action"`
+	Steps  int                        `json:"steps,omitempty"`
+	Speed  float64                    `json:"speed,omitempty"`
+}
+
+type SimulationStatusMessage struct {
+	State           constants.SimulationState `json:"state"`
+	ClockMode       constants.ClockMode       `json:"clock_mode"`
+	SpeedMultiplier float64                   `json:"speed_multiplier"`
+	SimulatedAt     time.Time                 `json:"simulated_at"`
+	SimulatedTimeMs int64                     `json:"simulated_time_ms"`
+	Ticks           int64                     `json:"ticks"`
+}
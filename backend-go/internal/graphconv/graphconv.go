@@ -0,0 +1,224 @@
+// Package graphconv converts cell grids to and from the road graph shared
+// with simsim.
+package graphconv
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/roadgraph"
+)
+
+// CellKM is how wide a cell of a grid is in the road graph, matching the
+// lengths the procedural generator gives segments.
+const CellKM = roadgraph.DefaultCellKM
+
+// ToGraph lays grid out as a road graph on a lattice of CellKM cells. Every
+// cell that ends a segment or is not a normal cell becomes a node, numbered
+// from 1 in cell order; every segment joins the nodes of its end cells, from
+// the end a one-way segment is driven away from. Road conditions and traffic
+// are state of a running simulation and are left out.
+func ToGraph(grid *domainmodels.Grid) *roadgraph.Graph {
+	g := &roadgraph.Graph{
+		WidthKM:  float64(grid.DimX) * CellKM,
+		HeightKM: float64(grid.DimY) * CellKM,
+		CellKM:   CellKM,
+	}
+
+	var segments []domainmodels.RoadSegment
+	seen := make(map[int64]bool)
+	endpoints := make(map[[2]int64]bool)
+	for _, cell := range grid.Cells {
+		for _, cellRoad := range cell.RoadSegments {
+			segment := cellRoad.RoadSegment
+			if seen[segment.ID] {
+				continue
+			}
+			seen[segment.ID] = true
+			segments = append(segments, segment)
+			endpoints[[2]int64{segment.StartX, segment.StartY}] = true
+			endpoints[[2]int64{segment.EndX, segment.EndY}] = true
+		}
+	}
+	slices.SortFunc(segments, func(a, b domainmodels.RoadSegment) int { return cmp.Compare(a.ID, b.ID) })
+
+	nodeAt := make(map[[2]int64]int64)
+	for _, cell := range grid.Cells {
+		coords := [2]int64{cell.Xpos, cell.Ypos}
+		if !endpoints[coords] && cell.CellType == domainmodels.CellTypeNormal {
+			continue
+		}
+		nodeID := cell.Ypos*grid.DimX + cell.Xpos + 1
+		nodeAt[coords] = nodeID
+		g.Nodes = append(g.Nodes, roadgraph.Node{
+			ID:           nodeID,
+			X:            float64(cell.Xpos) * CellKM,
+			Y:            float64(cell.Ypos) * CellKM,
+			Kind:         nodeKind(cell.CellType),
+			RefuelAmount: clonePtr(cell.RefuelAmount),
+		})
+	}
+	slices.SortFunc(g.Nodes, func(a, b roadgraph.Node) int { return cmp.Compare(a.ID, b.ID) })
+
+	for _, segment := range segments {
+		s := roadgraph.Segment{
+			ID:            segment.ID,
+			From:          nodeAt[[2]int64{segment.StartX, segment.StartY}],
+			To:            nodeAt[[2]int64{segment.EndX, segment.EndY}],
+			LengthKM:      segment.LengthKM,
+			SpeedKPH:      segment.BaseSpeedKPH,
+			SpeedLimit:    clonePtr(segment.SpeedLimit),
+			Capacity:      clonePtr(segment.Capacity),
+			Closed:        !segment.IsOpen,
+			OneWay:        segment.OneWay(),
+			LanesForward:  int(segment.LanesForward),
+			LanesBackward: int(segment.LanesBackward),
+		}
+		if segment.Direction == domainmodels.BackwardOnly {
+			s.From, s.To = s.To, s.From
+			s.LanesForward = int(segment.LanesBackward)
+		}
+		if s.OneWay {
+			s.LanesBackward = 0
+		}
+		g.Segments = append(g.Segments, s)
+	}
+	return g
+}
+
+// FromGraph builds a cell grid from a road graph laid out on a lattice, as
+// ToGraph and roadgraph.Rasterize leave it: each node fills the cell at its
+// lattice point and each segment is stored in the cells of both its ends.
+// Grids number segments from 1, so a graph numbering them from 0 or below,
+// as simsim does, has every ID shifted up by the same amount. Segment
+// attributes the graph leaves unset stay zero for the caller to fill in; the
+// grid is not validated or indexed.
+func FromGraph(g *roadgraph.Graph) (*domainmodels.Grid, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	if g.CellKM <= 0 {
+		return nil, fmt.Errorf("road graph is not laid out on a lattice of cells")
+	}
+
+	cells := make(map[int64][2]int64, len(g.Nodes))
+	dimX := int64(math.Round(g.WidthKM / g.CellKM))
+	dimY := int64(math.Round(g.HeightKM / g.CellKM))
+	for _, node := range g.Nodes {
+		x, y := math.Round(node.X/g.CellKM), math.Round(node.Y/g.CellKM)
+		if math.Abs(node.X/g.CellKM-x) > 1e-6 || math.Abs(node.Y/g.CellKM-y) > 1e-6 || x < 0 || y < 0 {
+			return nil, fmt.Errorf("node %d at (%g, %g) km is off the %g km lattice", node.ID, node.X, node.Y, g.CellKM)
+		}
+		cells[node.ID] = [2]int64{int64(x), int64(y)}
+		dimX, dimY = max(dimX, int64(x)+1), max(dimY, int64(y)+1)
+	}
+
+	grid := &domainmodels.Grid{
+		DimX:  dimX,
+		DimY:  dimY,
+		Cells: make([]domainmodels.Cell, 0, dimX*dimY),
+	}
+	for y := int64(0); y < dimY; y++ {
+		for x := int64(0); x < dimX; x++ {
+			grid.Cells = append(grid.Cells, domainmodels.Cell{
+				Xpos:         x,
+				Ypos:         y,
+				CellType:     domainmodels.CellTypeNormal,
+				RoadSegments: make([]domainmodels.CellRoad, 0),
+			})
+		}
+	}
+	cellAt := func(coords [2]int64) *domainmodels.Cell {
+		return &grid.Cells[coords[1]*dimX+coords[0]]
+	}
+
+	occupied := make(map[[2]int64]int64, len(g.Nodes))
+	for _, node := range g.Nodes {
+		coords := cells[node.ID]
+		if other, taken := occupied[coords]; taken {
+			return nil, fmt.Errorf("nodes %d and %d share cell (%d,%d)", other, node.ID, coords[0], coords[1])
+		}
+		occupied[coords] = node.ID
+
+		cellType, err := cellTypeOf(node.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", node.ID, err)
+		}
+		cell := cellAt(coords)
+		cell.CellType = cellType
+		cell.RefuelAmount = clonePtr(node.RefuelAmount)
+	}
+
+	segments := slices.Clone(g.Segments)
+	slices.SortFunc(segments, func(a, b roadgraph.Segment) int { return cmp.Compare(a.ID, b.ID) })
+	var idShift int64
+	if len(segments) > 0 && segments[0].ID <= 0 {
+		idShift = 1 - segments[0].ID
+	}
+	for _, s := range segments {
+		start, end := cells[s.From], cells[s.To]
+		segment := domainmodels.RoadSegment{
+			ID:           s.ID + idShift,
+			StartX:       start[0],
+			StartY:       start[1],
+			EndX:         end[0],
+			EndY:         end[1],
+			LengthKM:     s.LengthKM,
+			BaseSpeedKPH: s.SpeedKPH,
+			SpeedLimit:   clonePtr(s.SpeedLimit),
+			Capacity:     clonePtr(s.Capacity),
+			IsOpen:       !s.Closed,
+
+			LanesForward:  int64(s.LanesForward),
+			LanesBackward: int64(s.LanesBackward),
+		}
+		if s.OneWay {
+			segment.Direction = domainmodels.ForwardOnly
+		}
+		for _, coords := range [2][2]int64{start, end} {
+			cell := cellAt(coords)
+			cell.RoadSegments = append(cell.RoadSegments, domainmodels.CellRoad{
+				RoadSegmentID: segment.ID,
+				RoadSegment:   segment,
+			})
+		}
+	}
+	return grid, nil
+}
+
+func nodeKind(cellType domainmodels.CellType) roadgraph.NodeKind {
+	switch cellType {
+	case domainmodels.CellTypeRefuel:
+		return roadgraph.KindRefuel
+	case domainmodels.CellTypeDepot:
+		return roadgraph.KindDepot
+	case domainmodels.CellTypeBlocked:
+		return roadgraph.KindBlocked
+	}
+	return roadgraph.KindJunction
+}
+
+func cellTypeOf(kind roadgraph.NodeKind) (domainmodels.CellType, error) {
+	switch kind {
+	case roadgraph.KindJunction:
+		return domainmodels.CellTypeNormal, nil
+	case roadgraph.KindRefuel:
+		return domainmodels.CellTypeRefuel, nil
+	case roadgraph.KindDepot:
+		return domainmodels.CellTypeDepot, nil
+	case roadgraph.KindBlocked:
+		return domainmodels.CellTypeBlocked, nil
+	}
+	return "", fmt.Errorf("unknown node kind %q", kind)
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -2,25 +2,26 @@ package gridloader
 
 import (
 	"fmt"
+	"math/rand"
 	"time"
 
 	"owenvi.com/fleetsim/internal/domainmodels"
 	"owenvi.com/fleetsim/internal/utils"
 )
 
-func (gl *GridLoader) validateAndRepairConnectivity(grid *domainmodels.Grid) error {
-	fmt.Printf("Validating and repairing network connectivity...\n")
+func (gl *GridLoader) validateAndRepairConnectivity(grid *domainmodels.Grid, rng *rand.Rand) error {
+	gl.logf("Validating and repairing network connectivity...")
 
 	components := gl.findConnectedComponents(grid)
 
 	if len(components) <= 1 {
-		fmt.Printf("Network is fully connected (%d components)\n", len(components))
+		gl.logf("Network is fully connected (%d components)", len(components))
 		return nil
 	}
 
-	fmt.Printf("Found %d disconnected components, attempting repair...\n", len(components))
+	gl.logf("Found %d disconnected components, attempting repair...", len(components))
 
-	connectionsAdded := gl.connectDisconnectedComponents(grid, components)
+	connectionsAdded := gl.connectDisconnectedComponents(grid, components, rng)
 
 	componentsAfterRepair := gl.findConnectedComponents(grid)
 
@@ -29,7 +30,7 @@ func (gl *GridLoader) validateAndRepairConnectivity(grid *domainmodels.Grid) err
 			len(componentsAfterRepair), connectionsAdded)
 	}
 
-	fmt.Printf("Connectivity repair successful: added %d bridge connections\n", connectionsAdded)
+	gl.logf("Connectivity repair successful: added %d bridge connections", connectionsAdded)
 	return nil
 }
 
@@ -43,18 +44,20 @@ func (gl *GridLoader) findConnectedComponents(grid *domainmodels.Grid) [][]int64
 	}
 
 	adjacency := make(map[int64][]int64)
-	allSegments := make(map[int64]bool)
+	// allSegments keeps first-appearance order so components, and the
+	// bridges built between them, come out the same for the same seed.
+	var allSegments []int64
 	processedSegments := make(map[int64]bool)
 
 	for _, cell := range grid.Cells {
 		for _, cellRoad := range cell.RoadSegments {
 			segment := cellRoad.RoadSegment
-			allSegments[segment.ID] = true
 
 			if processedSegments[segment.ID] {
 				continue
 			}
 			processedSegments[segment.ID] = true
+			allSegments = append(allSegments, segment.ID)
 
 			connections := utils.FindConnectedSegmentsFast(segment, endpointIndex)
 			adjacency[segment.ID] = connections
@@ -68,7 +71,7 @@ func (gl *GridLoader) findConnectedComponents(grid *domainmodels.Grid) [][]int64
 	visited := make(map[int64]bool)
 	var components [][]int64
 
-	for segmentID := range allSegments {
+	for _, segmentID := range allSegments {
 		if !visited[segmentID] {
 			component := []int64{}
 			utils.DfsCollectComponent(segmentID, adjacency, visited, &component)
@@ -78,7 +81,7 @@ func (gl *GridLoader) findConnectedComponents(grid *domainmodels.Grid) [][]int64
 
 	return components
 }
-func (gl *GridLoader) connectDisconnectedComponents(grid *domainmodels.Grid, components [][]int64) int {
+func (gl *GridLoader) connectDisconnectedComponents(grid *domainmodels.Grid, components [][]int64, rng *rand.Rand) int {
 	if len(components) <= 1 {
 		return 0
 	}
@@ -96,9 +99,9 @@ func (gl *GridLoader) connectDisconnectedComponents(grid *domainmodels.Grid, com
 
 		if bridgeConnection != nil {
 
-			if gl.createBridgeSegment(grid, bridgeConnection) {
+			if gl.createBridgeSegment(grid, bridgeConnection, rng) {
 				connectionsAdded++
-				fmt.Printf("Added bridge connection: (%d,%d) -> (%d,%d)\n",
+				gl.logf("Added bridge connection: (%d,%d) -> (%d,%d)",
 					bridgeConnection.FromX, bridgeConnection.FromY,
 					bridgeConnection.ToX, bridgeConnection.ToY)
 			}
@@ -218,15 +221,18 @@ func (gl *GridLoader) isValidBridgeConnection(grid *domainmodels.Grid, connectio
 	return false
 }
 
-func (gl *GridLoader) createBridgeSegment(grid *domainmodels.Grid, connection *BridgeConnection) bool {
+func (gl *GridLoader) createBridgeSegment(grid *domainmodels.Grid, connection *BridgeConnection, rng *rand.Rand) bool {
 	segment := domainmodels.RoadSegment{
 		ID:       gl.SegmentIDCounter,
 		StartX:   connection.FromX,
 		StartY:   connection.FromY,
 		EndX:     connection.ToX,
 		EndY:     connection.ToY,
-		IsOpen:   true,
-		Capacity: gl.getDefaultCapacityForSegment(),
+		LengthKM: gl.calculateSegmentLength(connection.FromX, connection.FromY, connection.ToX, connection.ToY, rng),
+
+		BaseSpeedKPH: gl.getBaseSpeedForSegment(connection.FromX, connection.FromY, connection.ToX, connection.ToY),
+		IsOpen:       true,
+		Capacity:     gl.getDefaultCapacityForSegment(),
 	}
 
 	gl.addSegmentToCell(grid, connection.FromX, connection.FromY, segment)
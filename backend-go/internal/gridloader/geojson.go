@@ -0,0 +1,187 @@
+package gridloader
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
+	"owenvi.com/roadgraph"
+)
+
+// geoJSONGrid is the foreign member of a grid's GeoJSON.
+type geoJSONGrid struct {
+	DimX   int64                `json:"dimX"`
+	DimY   int64                `json:"dimY"`
+	CellKM float64              `json:"cell_km"`
+	Origin roadgraph.Projection `json:"origin"`
+}
+
+type geoJSONCell struct {
+	Kind         string                `json:"kind"`
+	Xpos         int64                 `json:"xpos"`
+	Ypos         int64                 `json:"ypos"`
+	CellType     domainmodels.CellType `json:"cell_type"`
+	RefuelAmount *float64              `json:"refuel_amount,omitempty"`
+}
+
+type geoJSONSegment struct {
+	Kind string `json:"kind"`
+	domainmodels.RoadSegment
+}
+
+// SaveGeoJSON writes grid as GeoJSON for GIS tools, placed on the globe by
+// projection with cells graphconv.CellKM wide. Every cell is a Polygon
+// carrying its type and refuel amount, and every segment a LineString between
+// the centres of its end cells carrying the whole segment: speeds, capacity,
+// conditions and traffic.
+func SaveGeoJSON(grid *domainmodels.Grid, path string, projection roadgraph.Projection) error {
+	fc, err := roadgraph.NewFeatureCollection(geoJSONGrid{
+		DimX:   grid.DimX,
+		DimY:   grid.DimY,
+		CellKM: graphconv.CellKM,
+		Origin: projection,
+	})
+	if err != nil {
+		return err
+	}
+	at := func(x, y float64) [2]float64 {
+		return projection.ToLonLat(x*graphconv.CellKM, y*graphconv.CellKM)
+	}
+
+	var segments []domainmodels.RoadSegment
+	seen := make(map[int64]bool)
+	for _, cell := range grid.Cells {
+		x, y := float64(cell.Xpos), float64(cell.Ypos)
+		square := roadgraph.Polygon(at(x-0.5, y-0.5), at(x+0.5, y-0.5), at(x+0.5, y+0.5), at(x-0.5, y+0.5))
+		if err := fc.Add(square, geoJSONCell{
+			Kind:         "cell",
+			Xpos:         cell.Xpos,
+			Ypos:         cell.Ypos,
+			CellType:     cell.CellType,
+			RefuelAmount: cell.RefuelAmount,
+		}); err != nil {
+			return err
+		}
+
+		for _, cellRoad := range cell.RoadSegments {
+			if !seen[cellRoad.RoadSegment.ID] {
+				seen[cellRoad.RoadSegment.ID] = true
+				segments = append(segments, cellRoad.RoadSegment)
+			}
+		}
+	}
+	slices.SortFunc(segments, func(a, b domainmodels.RoadSegment) int { return cmp.Compare(a.ID, b.ID) })
+
+	for _, segment := range segments {
+		line := roadgraph.LineString(
+			at(float64(segment.StartX), float64(segment.StartY)),
+			at(float64(segment.EndX), float64(segment.EndY)))
+		if err := fc.Add(line, geoJSONSegment{Kind: "segment", RoadSegment: segment}); err != nil {
+			return err
+		}
+	}
+	return fc.Save(path)
+}
+
+// LoadFromGeoJSON reads a grid written by SaveGeoJSON. Cells and segments are
+// placed by the coordinates in their properties rather than their geometry, so
+// a file re-saved by a GIS tool reads back the same; cells it dropped come back
+// as normal cells without roads. Features of other kinds are ignored. The grid
+// is validated and its indexes and road graph rebuilt as for LoadFromJSON.
+func (gl *GridLoader) LoadFromGeoJSON(path string) (*domainmodels.Grid, error) {
+	startTime := time.Now()
+
+	fc, err := roadgraph.LoadGeoJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta geoJSONGrid
+	if len(fc.Properties) > 0 {
+		if err := json.Unmarshal(fc.Properties, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse grid properties of %s: %w", path, err)
+		}
+	}
+
+	var cells []geoJSONCell
+	var segments []domainmodels.RoadSegment
+	dimX, dimY := meta.DimX, meta.DimY
+	for i, feature := range fc.Features {
+		switch feature.Kind() {
+		case "cell":
+			var cell geoJSONCell
+			if err := json.Unmarshal(feature.Properties, &cell); err != nil {
+				return nil, fmt.Errorf("feature %d of %s: %w", i, path, err)
+			}
+			cells = append(cells, cell)
+			dimX, dimY = max(dimX, cell.Xpos+1), max(dimY, cell.Ypos+1)
+		case "segment":
+			var segment geoJSONSegment
+			if err := json.Unmarshal(feature.Properties, &segment); err != nil {
+				return nil, fmt.Errorf("feature %d of %s: %w", i, path, err)
+			}
+			segments = append(segments, segment.RoadSegment)
+			dimX = max(dimX, segment.StartX+1, segment.EndX+1)
+			dimY = max(dimY, segment.StartY+1, segment.EndY+1)
+		}
+	}
+	if dimX <= 0 || dimY <= 0 {
+		return nil, fmt.Errorf("%s has no cells or road segments", path)
+	}
+
+	grid := &domainmodels.Grid{
+		DimX:  dimX,
+		DimY:  dimY,
+		Cells: make([]domainmodels.Cell, 0, dimX*dimY),
+	}
+	for y := int64(0); y < dimY; y++ {
+		for x := int64(0); x < dimX; x++ {
+			grid.Cells = append(grid.Cells, domainmodels.Cell{
+				Xpos:         x,
+				Ypos:         y,
+				CellType:     domainmodels.CellTypeNormal,
+				RoadSegments: make([]domainmodels.CellRoad, 0),
+			})
+		}
+	}
+	cellAt := func(x, y int64) (*domainmodels.Cell, error) {
+		if x < 0 || y < 0 {
+			return nil, fmt.Errorf("cell (%d,%d) is outside the grid", x, y)
+		}
+		return &grid.Cells[y*dimX+x], nil
+	}
+
+	for _, c := range cells {
+		cell, err := cellAt(c.Xpos, c.Ypos)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cell.CellType = c.CellType
+		cell.RefuelAmount = c.RefuelAmount
+	}
+
+	slices.SortFunc(segments, func(a, b domainmodels.RoadSegment) int { return cmp.Compare(a.ID, b.ID) })
+	for i, segment := range segments {
+		if i > 0 && segments[i-1].ID == segment.ID {
+			return nil, fmt.Errorf("%s has road segment %d twice", path, segment.ID)
+		}
+		for _, end := range [2][2]int64{{segment.StartX, segment.StartY}, {segment.EndX, segment.EndY}} {
+			cell, err := cellAt(end[0], end[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: road segment %d: %w", path, segment.ID, err)
+			}
+			cell.RoadSegments = append(cell.RoadSegments, domainmodels.CellRoad{
+				RoadSegmentID: segment.ID,
+				RoadSegment:   segment,
+			})
+		}
+	}
+
+	if err := gl.PrepareImportedGrid(grid, path, startTime); err != nil {
+		return nil, err
+	}
+	return grid, nil
+}
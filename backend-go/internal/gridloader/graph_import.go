@@ -0,0 +1,77 @@
+package gridloader
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
+	"owenvi.com/roadgraph"
+)
+
+// ImportGraph builds a grid from a road graph, such as one exported by simsim.
+// Only the largest connected part of the road network is kept, rasterized
+// onto cells cellKM wide; zero keeps a graph already laid out on cells as it
+// is and picks a cell size that keeps intersections apart otherwise.
+//
+// The grid is then finished the way a generated one is: segments without a
+// speed or capacity get the generator's, a graph without refuel stations or
+// depots gets them placed from the loader's seed, and base conditions are
+// assigned by road class. It comes back validated and indexed.
+func (gl *GridLoader) ImportGraph(g *roadgraph.Graph, cellKM float64) (*domainmodels.Grid, error) {
+	startTime := time.Now()
+
+	lattice, err := roadgraph.Rasterize(g.LargestComponent(), cellKM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lay road graph out on cells: %w", err)
+	}
+	grid, err := graphconv.FromGraph(lattice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert road graph: %w", err)
+	}
+	gl.logf("Road graph of %d nodes and %d segments laid out as a %dx%d grid of %g km cells with %d segments",
+		len(g.Nodes), len(g.Segments), grid.DimX, grid.DimY, lattice.CellKM, len(lattice.Segments))
+
+	gl.Width, gl.Height = grid.DimX, grid.DimY
+	gl.fillSegmentDefaults(grid)
+
+	if !hasStations(grid) {
+		rng := rand.New(rand.NewSource(gl.Seed))
+		if err := gl.placeSpecialLocationsHybrid(grid, rng); err != nil {
+			return nil, err
+		}
+	}
+	gl.assignBaseConditions(grid)
+
+	if err := gl.PrepareImportedGrid(grid, "road graph", startTime); err != nil {
+		return nil, err
+	}
+	gl.currentGrid = grid
+	return grid, nil
+}
+
+// fillSegmentDefaults gives segments the road graph left without a speed or
+// a capacity the ones the generator would have.
+func (gl *GridLoader) fillSegmentDefaults(grid *domainmodels.Grid) {
+	for i := range grid.Cells {
+		for j := range grid.Cells[i].RoadSegments {
+			segment := &grid.Cells[i].RoadSegments[j].RoadSegment
+			if segment.BaseSpeedKPH <= 0 {
+				segment.BaseSpeedKPH = gl.getBaseSpeedForSegment(segment.StartX, segment.StartY, segment.EndX, segment.EndY)
+			}
+			if segment.Capacity == nil {
+				segment.Capacity = gl.getDefaultCapacityForSegment()
+			}
+		}
+	}
+}
+
+func hasStations(grid *domainmodels.Grid) bool {
+	for _, cell := range grid.Cells {
+		if cell.CellType == domainmodels.CellTypeRefuel || cell.CellType == domainmodels.CellTypeDepot {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package gridloader
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
+	"owenvi.com/roadgraph"
+)
+
+func newTestLoader(width, height, seed int64) *GridLoader {
+	gridLoader := NewGridLoader()
+	gridLoader.ConfigureForTesting(width, height, seed, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	gridLoader.BaseRoadConditions = config.Config().BaseRoadConditions
+	return gridLoader
+}
+
+// checkSameGrid fails unless got encodes exactly as want does and has the
+// same indexes and road graph adjacency.
+func checkSameGrid(t *testing.T, want, got *domainmodels.Grid) {
+	t.Helper()
+	a, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		if want.DimX != got.DimX || want.DimY != got.DimY {
+			t.Fatalf("%dx%d grid came back as %dx%d", want.DimX, want.DimY, got.DimX, got.DimY)
+		}
+		for i := range want.Cells {
+			a, _ := json.Marshal(want.Cells[i])
+			b, _ := json.Marshal(got.Cells[i])
+			if !bytes.Equal(a, b) {
+				t.Fatalf("cell (%d,%d) was %s, came back as %s", want.Cells[i].Xpos, want.Cells[i].Ypos, a, b)
+			}
+		}
+		t.Fatal("cells match but the grid does not")
+	}
+	if len(got.CoordIndex) != len(want.CoordIndex) || len(got.SegmentIndex) != len(want.SegmentIndex) {
+		t.Fatalf("indexes came back with %d cells and %d segments, not %d and %d",
+			len(got.CoordIndex), len(got.SegmentIndex), len(want.CoordIndex), len(want.SegmentIndex))
+	}
+	if !reflect.DeepEqual(got.GetAdjacencyData(), want.GetAdjacencyData()) {
+		t.Fatal("road graph adjacency changed")
+	}
+}
+
+func TestGeneratedGridsRoundTrip(t *testing.T) {
+	worlds := map[string]struct {
+		seed          int64
+		oneWay        bool
+		arterialLanes int64
+	}{
+		"two-way":            {seed: 99},
+		"one-way with lanes": {seed: 7, oneWay: true, arterialLanes: 2},
+	}
+	for name, w := range worlds {
+		t.Run(name, func(t *testing.T) {
+			generator := newTestLoader(20, 20, w.seed)
+			generator.OneWayStreets = w.oneWay
+			generator.ArterialLanes = w.arterialLanes
+			generated, err := generator.GenerateProcedural()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("road graph", func(t *testing.T) {
+				data, err := json.Marshal(graphconv.ToGraph(generated))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var g roadgraph.Graph
+				if err := json.Unmarshal(data, &g); err != nil {
+					t.Fatal(err)
+				}
+				imported, err := newTestLoader(20, 20, w.seed).ImportGraph(&g, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkSameGrid(t, generated, imported)
+			})
+
+			t.Run("GeoJSON", func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "grid.geojson")
+				if err := SaveGeoJSON(generated, path, roadgraph.Projection{OriginLon: 13.4, OriginLat: 52.5}); err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := newTestLoader(0, 0, 0).LoadFromGeoJSON(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkSameGrid(t, generated, loaded)
+			})
+		})
+	}
+}
+
+// freeGraph is a small road network with free positions, like one exported
+// by simsim, and a stray road off to the side.
+func freeGraph() *roadgraph.Graph {
+	return &roadgraph.Graph{
+		WidthKM:  3,
+		HeightKM: 2,
+		Nodes: []roadgraph.Node{
+			{ID: 1, X: 0.1, Y: 0.1},
+			{ID: 2, X: 1.3, Y: 0.2},
+			{ID: 3, X: 2.6, Y: 0.4},
+			{ID: 4, X: 2.4, Y: 1.7},
+			{ID: 5, X: 0.3, Y: 1.5},
+			{ID: 6, X: 2.9, Y: 1.9},
+			{ID: 7, X: 2.95, Y: 1.95},
+		},
+		Segments: []roadgraph.Segment{
+			{ID: 10, From: 1, To: 2, LengthKM: 1.2},
+			{ID: 11, From: 2, To: 3, LengthKM: 1.3},
+			{ID: 12, From: 3, To: 4, LengthKM: 1.3, OneWay: true},
+			{ID: 13, From: 4, To: 5, LengthKM: 2.1},
+			{ID: 14, From: 5, To: 1, LengthKM: 1.4},
+			{ID: 15, From: 2, To: 4, LengthKM: 1.8},
+			{ID: 16, From: 6, To: 7, LengthKM: 0.1},
+		},
+	}
+}
+
+// A road graph imported as a grid must come back, converted to a graph again,
+// with its segments where the import laid them out on cells, forming one
+// connected network.
+func TestImportedGraphKeepsItsLayout(t *testing.T) {
+	g := freeGraph()
+	const cellKM = 0.25
+	grid, err := newTestLoader(0, 0, 1).ImportGraph(g, cellKM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lattice, err := roadgraph.Rasterize(g.LargestComponent(), cellKM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := graphconv.ToGraph(grid)
+
+	if len(converted.Segments) != len(lattice.Segments) {
+		t.Fatalf("%d segments laid out, %d came back", len(lattice.Segments), len(converted.Segments))
+	}
+	// Segments keep their order but may be renumbered to start from 1.
+	laid, back := segmentsByID(lattice), segmentsByID(converted)
+	laidCells, backCells := nodeCells(lattice), nodeCells(converted)
+	for i, segment := range laid {
+		other := back[i]
+		if laidCells[segment.From] != backCells[other.From] || laidCells[segment.To] != backCells[other.To] {
+			t.Errorf("segment %d moved", segment.ID)
+		}
+		if other.LengthKM != segment.LengthKM {
+			t.Errorf("segment %d changed length from %g to %g", segment.ID, segment.LengthKM, other.LengthKM)
+		}
+		if other.OneWay != segment.OneWay {
+			t.Errorf("segment %d one-way %v came back %v", segment.ID, segment.OneWay, other.OneWay)
+		}
+	}
+	if components := len(roadComponents(converted)); components != 1 {
+		t.Errorf("road network came back in %d parts", components)
+	}
+}
+
+func segmentsByID(g *roadgraph.Graph) []roadgraph.Segment {
+	segments := slices.Clone(g.Segments)
+	slices.SortFunc(segments, func(a, b roadgraph.Segment) int { return cmp.Compare(a.ID, b.ID) })
+	return segments
+}
+
+// nodeCells is the cell each node of a lattice graph sits in. A grid does
+// not keep its cell size, so a graph converted from it has cells of
+// graphconv.CellKM whatever size it was imported at.
+func nodeCells(g *roadgraph.Graph) map[int64][2]int64 {
+	cells := make(map[int64][2]int64, len(g.Nodes))
+	for _, node := range g.Nodes {
+		cells[node.ID] = [2]int64{int64(math.Round(node.X / g.CellKM)), int64(math.Round(node.Y / g.CellKM))}
+	}
+	return cells
+}
+
+// roadComponents groups the graph's nodes that have roads by how they
+// connect, leaving out stations and blocked cells off the roads.
+func roadComponents(g *roadgraph.Graph) [][]int64 {
+	onRoad := make(map[int64]bool)
+	for _, segment := range g.Segments {
+		onRoad[segment.From], onRoad[segment.To] = true, true
+	}
+	roads := *g
+	roads.Nodes = nil
+	for _, node := range g.Nodes {
+		if onRoad[node.ID] {
+			roads.Nodes = append(roads.Nodes, node)
+		}
+	}
+	return roads.Components()
+}
@@ -0,0 +1,217 @@
+package gridloader
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
+)
+
+// Grid file formats LoadFromJSON reads. A file names its format in
+// format_version; files from before formats were versioned are told apart by
+// their keys.
+const (
+	// GridFormatIndexed is the early schema of the files in testData: cells
+	// keyed "[x,y]" in cellIndex.byCoord, segments between cells in
+	// roadSegmentIndex.byID, and precomputed adjacency. It has no cell types,
+	// lengths or speeds.
+	GridFormatIndexed = 1
+	// GridFormatCells is the grid as domainmodels.Grid marshals it: dimX,
+	// dimY and cells holding their road segments.
+	GridFormatCells = 2
+
+	// CurrentGridFormat is the format SaveJSON writes.
+	CurrentGridFormat = GridFormatCells
+)
+
+// gridFile is a grid as SaveJSON writes it.
+type gridFile struct {
+	FormatVersion int `json:"format_version"`
+	*domainmodels.Grid
+}
+
+type indexedGridFile struct {
+	CellIndex struct {
+		ByCoord map[string]struct {
+			X *int64 `json:"x"`
+			Y *int64 `json:"y"`
+		} `json:"byCoord"`
+	} `json:"cellIndex"`
+	RoadSegmentIndex struct {
+		ByID map[string]indexedSegment `json:"byID"`
+	} `json:"roadSegmentIndex"`
+}
+
+// indexedSegment is a segment of a GridFormatIndexed file, whose files name
+// its ends either from and to or fromCell and toCell.
+type indexedSegment struct {
+	ID       int64     `json:"id"`
+	From     *[2]int64 `json:"from"`
+	To       *[2]int64 `json:"to"`
+	FromCell *[2]int64 `json:"fromCell"`
+	ToCell   *[2]int64 `json:"toCell"`
+	Capacity *int64    `json:"capacity"`
+}
+
+// DetectGridFormat reports which format a grid file is in.
+func DetectGridFormat(data []byte) (int, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return 0, err
+	}
+
+	if raw, versioned := keys["format_version"]; versioned {
+		var version int
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return 0, fmt.Errorf("invalid format_version %s", raw)
+		}
+		if version < GridFormatIndexed || version > CurrentGridFormat {
+			return 0, fmt.Errorf("unsupported grid format version %d (formats %d to %d are supported)",
+				version, GridFormatIndexed, CurrentGridFormat)
+		}
+		return version, nil
+	}
+	switch {
+	case keys["cells"] != nil || keys["dimX"] != nil:
+		return GridFormatCells, nil
+	case keys["cellIndex"] != nil || keys["roadSegmentIndex"] != nil:
+		return GridFormatIndexed, nil
+	}
+	return 0, fmt.Errorf("not a grid file: it has neither cells nor a cellIndex")
+}
+
+// ParseGridFile reads a grid file in any supported format and reports the
+// format it was in. The grid is neither validated nor indexed.
+func (gl *GridLoader) ParseGridFile(data []byte) (*domainmodels.Grid, int, error) {
+	version, err := DetectGridFormat(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var grid *domainmodels.Grid
+	switch version {
+	case GridFormatIndexed:
+		grid, err = gl.parseIndexedGrid(data)
+	default:
+		grid = &domainmodels.Grid{}
+		err = json.Unmarshal(data, grid)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return grid, version, nil
+}
+
+// SaveJSON writes grid to a file in CurrentGridFormat.
+func SaveJSON(grid *domainmodels.Grid, path string) error {
+	data, err := json.MarshalIndent(gridFile{FormatVersion: CurrentGridFormat, Grid: grid}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// parseIndexedGrid builds a grid from a GridFormatIndexed file. Every cell
+// is a normal cell, and the grid spans the cells and segment ends the file
+// names. Segments are as long as the distance between their cells, and
+// speeds, missing capacities and base conditions are the generator's. The
+// file's adjacency and cell-to-segment index are left for the loader to
+// rebuild.
+func (gl *GridLoader) parseIndexedGrid(data []byte) (*domainmodels.Grid, error) {
+	var file indexedGridFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var dimX, dimY int64
+	extend := func(coords [2]int64) error {
+		if coords[0] < 0 || coords[1] < 0 {
+			return fmt.Errorf("cell (%d,%d) has a negative coordinate", coords[0], coords[1])
+		}
+		dimX, dimY = max(dimX, coords[0]+1), max(dimY, coords[1]+1)
+		return nil
+	}
+
+	for key, cell := range file.CellIndex.ByCoord {
+		var coords [2]int64
+		if err := json.Unmarshal([]byte(key), &coords); err != nil {
+			return nil, fmt.Errorf("cell key %q is not [x,y]", key)
+		}
+		if (cell.X != nil && *cell.X != coords[0]) || (cell.Y != nil && *cell.Y != coords[1]) {
+			return nil, fmt.Errorf("cell %s has different coordinates inside", key)
+		}
+		if err := extend(coords); err != nil {
+			return nil, err
+		}
+	}
+
+	segments := make([]domainmodels.RoadSegment, 0, len(file.RoadSegmentIndex.ByID))
+	for key, s := range file.RoadSegmentIndex.ByID {
+		id := s.ID
+		if id == 0 {
+			parsed, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("road segment %q has no ID", key)
+			}
+			id = parsed
+		}
+		from, to := cmp.Or(s.From, s.FromCell), cmp.Or(s.To, s.ToCell)
+		if from == nil || to == nil {
+			return nil, fmt.Errorf("road segment %d is missing an end", id)
+		}
+		for _, end := range []*[2]int64{from, to} {
+			if err := extend(*end); err != nil {
+				return nil, fmt.Errorf("road segment %d: %w", id, err)
+			}
+		}
+		segments = append(segments, domainmodels.RoadSegment{
+			ID:       id,
+			StartX:   from[0],
+			StartY:   from[1],
+			EndX:     to[0],
+			EndY:     to[1],
+			LengthKM: math.Hypot(float64(to[0]-from[0]), float64(to[1]-from[1])) * graphconv.CellKM,
+			Capacity: s.Capacity,
+			IsOpen:   true,
+		})
+	}
+	if dimX == 0 || dimY == 0 {
+		return nil, fmt.Errorf("grid file has no cells")
+	}
+	slices.SortFunc(segments, func(a, b domainmodels.RoadSegment) int { return cmp.Compare(a.ID, b.ID) })
+
+	grid := &domainmodels.Grid{
+		DimX:  dimX,
+		DimY:  dimY,
+		Cells: make([]domainmodels.Cell, 0, dimX*dimY),
+	}
+	for y := int64(0); y < dimY; y++ {
+		for x := int64(0); x < dimX; x++ {
+			grid.Cells = append(grid.Cells, domainmodels.Cell{
+				Xpos:         x,
+				Ypos:         y,
+				CellType:     domainmodels.CellTypeNormal,
+				RoadSegments: make([]domainmodels.CellRoad, 0),
+			})
+		}
+	}
+	for _, segment := range segments {
+		for _, end := range [2][2]int64{{segment.StartX, segment.StartY}, {segment.EndX, segment.EndY}} {
+			cell := &grid.Cells[end[1]*dimX+end[0]]
+			cell.RoadSegments = append(cell.RoadSegments, domainmodels.CellRoad{
+				RoadSegmentID: segment.ID,
+				RoadSegment:   segment,
+			})
+		}
+	}
+
+	gl.fillSegmentDefaults(grid)
+	gl.assignBaseConditions(grid)
+	return grid, nil
+}
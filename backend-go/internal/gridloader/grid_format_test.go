@@ -0,0 +1,84 @@
+package gridloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The testData grids are in the indexed format the first loader read.
+var indexedGrids = map[string]struct {
+	dimX, dimY int64
+	segments   int
+}{
+	"../testData/gridTest1.json": {dimX: 3, dimY: 2, segments: 5},
+	"../testData/gridTest2.json": {dimX: 2, dimY: 2, segments: 4},
+}
+
+func TestIndexedGridsUpgrade(t *testing.T) {
+	for path, want := range indexedGrids {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version, err := DetectGridFormat(data); err != nil || version != GridFormatIndexed {
+				t.Fatalf("DetectGridFormat = %d, %v, want %d", version, err, GridFormatIndexed)
+			}
+
+			grid, err := newTestLoader(0, 0, 0).LoadFromJSON(path)
+			if err != nil {
+				t.Fatalf("LoadFromJSON: %v", err)
+			}
+			if grid.DimX != want.dimX || grid.DimY != want.dimY || len(grid.SegmentIndex) != want.segments {
+				t.Errorf("loaded a %dx%d grid with %d segments, want %dx%d with %d",
+					grid.DimX, grid.DimY, len(grid.SegmentIndex), want.dimX, want.dimY, want.segments)
+			}
+			for id := range grid.SegmentIndex {
+				if segment := grid.GetSegment(id); segment.LengthKM <= 0 || segment.Capacity == nil {
+					t.Errorf("segment %d was left without a length or capacity", id)
+				}
+			}
+
+			upgraded := filepath.Join(t.TempDir(), "grid.json")
+			if err := SaveJSON(grid, upgraded); err != nil {
+				t.Fatalf("SaveJSON: %v", err)
+			}
+			data, err = os.ReadFile(upgraded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version, err := DetectGridFormat(data); err != nil || version != CurrentGridFormat {
+				t.Fatalf("saved grid detected as %d, %v, want %d", version, err, CurrentGridFormat)
+			}
+			reloaded, err := newTestLoader(0, 0, 0).LoadFromJSON(upgraded)
+			if err != nil {
+				t.Fatalf("reloading: %v", err)
+			}
+			checkSameGrid(t, grid, reloaded)
+		})
+	}
+}
+
+func TestDetectGridFormat(t *testing.T) {
+	for data, want := range map[string]int{
+		`{"format_version": 2, "dimX": 1}`:    GridFormatCells,
+		`{"format_version": 1}`:               GridFormatIndexed,
+		`{"dimX": 1, "dimY": 1, "cells": []}`: GridFormatCells,
+		`{"cellIndex": {}}`:                   GridFormatIndexed,
+	} {
+		if got, err := DetectGridFormat([]byte(data)); err != nil || got != want {
+			t.Errorf("DetectGridFormat(%s) = %d, %v, want %d", data, got, err, want)
+		}
+	}
+	for _, data := range []string{
+		`{"format_version": 3}`,
+		`{"format_version": "two"}`,
+		`{"nodes": []}`,
+		`[]`,
+	} {
+		if got, err := DetectGridFormat([]byte(data)); err == nil {
+			t.Errorf("DetectGridFormat(%s) = %d, want an error", data, got)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package gridloader
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
@@ -9,11 +8,12 @@ import (
 	"time"
 
 	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/graphconv"
 	"owenvi.com/fleetsim/internal/utils"
 )
 
 func (gl *GridLoader) buildSpatialIndexes(grid *domainmodels.Grid) {
-	fmt.Printf("Building spatial indexes for %d cells...\n", len(grid.Cells))
+	gl.logf("Building spatial indexes for %d cells...", len(grid.Cells))
 
 	startTime := time.Now()
 
@@ -43,11 +43,11 @@ func (gl *GridLoader) buildSpatialIndexes(grid *domainmodels.Grid) {
 
 	indexingTime := time.Since(startTime)
 
-	fmt.Printf("Spatial indexing completed in %v:\n", indexingTime)
-	fmt.Printf("  • %d coordinate mappings\n", len(grid.CoordIndex))
-	fmt.Printf("  • %d road segments indexed\n", len(grid.SegmentIndex))
-	fmt.Printf("  • %d grid-based adjacency entries\n", len(gridBasedAdjacency))
-	fmt.Printf("  • %d geometric adjacency entries\n", len(geometricAdjacency))
+	gl.logf("Spatial indexing completed in %v:", indexingTime)
+	gl.logf("  • %d coordinate mappings", len(grid.CoordIndex))
+	gl.logf("  • %d road segments indexed", len(grid.SegmentIndex))
+	gl.logf("  • %d grid-based adjacency entries", len(gridBasedAdjacency))
+	gl.logf("  • %d geometric adjacency entries", len(geometricAdjacency))
 
 	// gl.compareConnectivityMethods(grid, gridBasedAdjacency, geometricAdjacency)
 
@@ -56,14 +56,14 @@ func (gl *GridLoader) buildSpatialIndexes(grid *domainmodels.Grid) {
 
 func (gl *GridLoader) useGeometricAdjacency(grid *domainmodels.Grid) {
 	if gl.endpointIndex == nil {
-		fmt.Println("Endpoint index not built - cannot switch to geometric adjacency")
+		gl.logf("Endpoint index not built - cannot switch to geometric adjacency")
 		return
 	}
 
-	fmt.Println("Switching to geometric adjacency...")
+	gl.logf("Switching to geometric adjacency...")
 	geometricAdjacency := gl.buildGeometricAdjacency(grid)
 	grid.RoadGraph = &domainmodels.RoadGraph{Adjacency: geometricAdjacency}
-	fmt.Printf("Switched to geometric adjacency: %d connections\n", len(geometricAdjacency))
+	gl.logf("Switched to geometric adjacency: %d connections", len(geometricAdjacency))
 }
 func (gl *GridLoader) buildGridBasedAdjacency(grid *domainmodels.Grid) map[int64][]int64 {
 	adjacency := make(map[int64][]int64)
@@ -116,8 +116,8 @@ func (gl *GridLoader) compareConnectivityMethods(grid *domainmodels.Grid, gridBa
 		return
 	}
 
-	fmt.Printf("\nCONNECTIVITY METHOD COMPARISON\n")
-	fmt.Printf("==============================\n")
+	gl.logf("\nCONNECTIVITY METHOD COMPARISON")
+	gl.logf("==============================")
 
 	totalGridConnections := 0
 	totalGeometricConnections := 0
@@ -146,19 +146,19 @@ func (gl *GridLoader) compareConnectivityMethods(grid *domainmodels.Grid, gridBa
 		}
 	}
 
-	fmt.Printf("Grid-based method: %d total connections\n", totalGridConnections)
-	fmt.Printf("Geometric method: %d total connections\n", totalGeometricConnections)
-	fmt.Printf("Segments with matching connection count: %d\n", matchingSegments)
-	fmt.Printf("Segments with differing connection count: %d\n", differingSegments)
+	gl.logf("Grid-based method: %d total connections", totalGridConnections)
+	gl.logf("Geometric method: %d total connections", totalGeometricConnections)
+	gl.logf("Segments with matching connection count: %d", matchingSegments)
+	gl.logf("Segments with differing connection count: %d", differingSegments)
 
 	if len(allSegments) > 0 {
 		avgGridConnections := float64(totalGridConnections) / float64(len(allSegments))
 		avgGeometricConnections := float64(totalGeometricConnections) / float64(len(allSegments))
-		fmt.Printf("Average connections per segment (grid): %.1f\n", avgGridConnections)
-		fmt.Printf("Average connections per segment (geometric): %.1f\n", avgGeometricConnections)
+		gl.logf("Average connections per segment (grid): %.1f", avgGridConnections)
+		gl.logf("Average connections per segment (geometric): %.1f", avgGeometricConnections)
 	}
 
-	fmt.Printf("\nExample differences:\n")
+	gl.logf("\nExample differences:")
 	count := 0
 	for segmentID := range allSegments {
 		if count >= 3 {
@@ -169,13 +169,15 @@ func (gl *GridLoader) compareConnectivityMethods(grid *domainmodels.Grid, gridBa
 		geomConns := geometric[segmentID]
 
 		if len(gridConns) != len(geomConns) {
-			fmt.Printf("Segment %d: grid=%d connections, geometric=%d connections\n",
+			gl.logf("Segment %d: grid=%d connections, geometric=%d connections",
 				segmentID, len(gridConns), len(geomConns))
 			count++
 		}
 	}
 }
 
+// LoadFromJSON reads a grid file in any supported format, detecting which,
+// and validates and indexes the grid.
 func (gl *GridLoader) LoadFromJSON(filepath string) (*domainmodels.Grid, error) {
 	startTime := time.Now()
 
@@ -184,28 +186,42 @@ func (gl *GridLoader) LoadFromJSON(filepath string) (*domainmodels.Grid, error)
 		return nil, fmt.Errorf("failed to read grid file %s: %w", filepath, err)
 	}
 
-	var grid domainmodels.Grid
-	if err := json.Unmarshal(data, &grid); err != nil {
+	grid, version, err := gl.ParseGridFile(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse grid JSON from %s: %w", filepath, err)
 	}
+	if version != CurrentGridFormat {
+		gl.logf("Grid file %s is in format version %d; gridupgrade converts it to version %d",
+			filepath, version, CurrentGridFormat)
+	}
 
-	if err := gl.validateImportedGrid(&grid); err != nil {
-		return nil, fmt.Errorf("imported grid from %s failed validation: %w", filepath, err)
+	if err := gl.PrepareImportedGrid(grid, filepath, startTime); err != nil {
+		return nil, err
 	}
+	return grid, nil
+}
 
-	gl.buildSpatialIndexes(&grid)
+// PrepareImportedGrid validates a grid that was read from outside the loader
+// (a JSON file, a database) and rebuilds its indexes and road graph. source
+// names where it came from in errors and logs.
+func (gl *GridLoader) PrepareImportedGrid(grid *domainmodels.Grid, source string, startTime time.Time) error {
+	if err := gl.validateImportedGrid(grid); err != nil {
+		return fmt.Errorf("imported grid from %s failed validation: %w", source, err)
+	}
+
+	gl.buildSpatialIndexes(grid)
 
 	gl.GenerationStatsSu = &GenerationStats{
 		TotalCells:       len(grid.Cells),
-		TotalSegments:    gl.countRoadSegments(&grid),
+		TotalSegments:    gl.countRoadSegments(grid),
 		GenerationTimeMs: time.Since(startTime).Milliseconds(),
 	}
 
-	fmt.Printf("Successfully loaded %dx%d grid from %s\n", grid.DimX, grid.DimY, filepath)
-	fmt.Printf("Grid contains %d cells with %d road segments\n",
+	gl.logf("Successfully loaded %dx%d grid from %s", grid.DimX, grid.DimY, source)
+	gl.logf("Grid contains %d cells with %d road segments",
 		gl.GenerationStatsSu.TotalCells, gl.GenerationStatsSu.TotalSegments)
 
-	return &grid, nil
+	return nil
 }
 
 func (gl *GridLoader) GetGenerationStats() *GenerationStats {
@@ -259,7 +275,7 @@ func (gl *GridLoader) createCellStateBackup(grid *domainmodels.Grid) *CellStateB
 		}
 	}
 
-	fmt.Printf("Created backup of %d cell states\n", len(backup.cellStates))
+	gl.logf("Created backup of %d cell states", len(backup.cellStates))
 	return backup
 }
 
@@ -282,11 +298,11 @@ func (gl *GridLoader) restoreCellStateFromBackup(grid *domainmodels.Grid, backup
 
 			restoredCount++
 		} else {
-			fmt.Printf("Warning: No backup data found for cell at (%d,%d)\n", cell.Xpos, cell.Ypos)
+			gl.logf("Warning: No backup data found for cell at (%d,%d)", cell.Xpos, cell.Ypos)
 		}
 	}
 
-	fmt.Printf("Restored %d cells to their backed-up state\n", restoredCount)
+	gl.logf("Restored %d cells to their backed-up state", restoredCount)
 }
 func (gl *GridLoader) placeSpecialLocationsHybrid(grid *domainmodels.Grid, rng *rand.Rand) error {
 	_, _, _ = gl.calculateSafeParameters(grid)
@@ -301,13 +317,13 @@ func (gl *GridLoader) placeSpecialLocationsHybrid(grid *domainmodels.Grid, rng *
 		if attempt == 0 {
 			targetRefuel = originalRefuel
 			targetDepot = originalDepot
-			fmt.Printf("Attempt 1: Trying optimistic parameters (refuel=%.3f, depot=%.3f)\n",
+			gl.logf("Attempt 1: Trying optimistic parameters (refuel=%.3f, depot=%.3f)",
 				targetRefuel, targetDepot)
 		} else {
 			aggressiveness := 1.0 - (float64(attempt-1) * 0.3)
 			targetRefuel = originalRefuel * (0.9 + aggressiveness*0.1)
 			targetDepot = originalDepot * (0.9 + aggressiveness*0.1)
-			fmt.Printf("Attempt %d: Trying conservative parameters (refuel=%.3f, depot=%.3f)\n",
+			gl.logf("Attempt %d: Trying conservative parameters (refuel=%.3f, depot=%.3f)",
 				attempt+1, targetRefuel, targetDepot)
 		}
 
@@ -320,7 +336,7 @@ func (gl *GridLoader) placeSpecialLocationsHybrid(grid *domainmodels.Grid, rng *
 		if err == nil {
 			gl.RefuelCellsAllotment = originalRefuel
 			gl.DepotCellsAllotment = originalDepot
-			fmt.Printf("Hybrid placement succeeded on attempt %d\n", attempt+1)
+			gl.logf("Hybrid placement succeeded on attempt %d", attempt+1)
 			return nil
 		}
 
@@ -441,7 +457,7 @@ func (gl *GridLoader) placeSpecialLocationsWithRetry(grid *domainmodels.Grid, rn
 	originalDepotAllotment := gl.DepotCellsAllotment
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
-		fmt.Printf("Special location placement attempt %d/%d (blocked: %.3f, refuel: %.3f, depot: %.3f)\n",
+		gl.logf("Special location placement attempt %d/%d (blocked: %.3f, refuel: %.3f, depot: %.3f)",
 			attempt+1, maxAttempts, gl.BlockedCellsAllotment, gl.RefuelCellsAllotment, gl.DepotCellsAllotment)
 
 		cellStateBackup := make(map[[2]int64]struct {
@@ -465,11 +481,11 @@ func (gl *GridLoader) placeSpecialLocationsWithRetry(grid *domainmodels.Grid, rn
 			gl.BlockedCellsAllotment = originalBlockedAllotment
 			gl.RefuelCellsAllotment = originalRefuelAllotment
 			gl.DepotCellsAllotment = originalDepotAllotment
-			fmt.Printf("Special location placement succeeded on attempt %d\n", attempt+1)
+			gl.logf("Special location placement succeeded on attempt %d", attempt+1)
 			return nil
 		}
 
-		fmt.Printf("Attempt %d failed: %v\n", attempt+1, err)
+		gl.logf("Attempt %d failed: %v", attempt+1, err)
 		for i := range grid.Cells {
 			cell := &grid.Cells[i]
 			coords := [2]int64{cell.Xpos, cell.Ypos}
@@ -513,20 +529,29 @@ func (gl *GridLoader) GenerateProcedural() (*domainmodels.Grid, error) {
 			continue
 		}
 
-		if err := gl.placeSpecialLocationsHybrid(grid, rng); err != nil {
+		// Repair before placing special locations: placement checks that the
+		// network is connected and would otherwise reject every disconnected
+		// road network outright, retrying until generation all but hangs.
+		// Special locations land on the repaired network, so a seed gives a
+		// different grid than it did with placement first.
+		if err := gl.validateAndRepairConnectivity(grid, rng); err != nil {
 			if attempt == maxRetries-1 {
-				return nil, err
+				return nil, fmt.Errorf("connectivity validation failed: %w", err)
 			}
 			continue
 		}
+		if opened := gl.openStrandingOneWays(grid); opened > 0 {
+			gl.logf("Opened %d one-way segments both ways so no vehicle is stranded", opened)
+		}
 
-		if err := gl.validateAndRepairConnectivity(grid); err != nil {
+		if err := gl.placeSpecialLocationsHybrid(grid, rng); err != nil {
 			if attempt == maxRetries-1 {
-				return nil, fmt.Errorf("connectivity validation failed: %w", err)
+				return nil, err
 			}
 			continue
 		}
 
+		gl.assignBaseConditions(grid)
 		gl.buildSpatialIndexes(grid)
 
 		if gl.GenerationStatsSu == nil {
@@ -568,13 +593,13 @@ func (gl *GridLoader) initializeEmptyGrid() *domainmodels.Grid {
 }
 
 func (gl *GridLoader) generateRoadNetwork(grid *domainmodels.Grid, rng *rand.Rand) error {
-	fmt.Printf("Generating road network with density %.2f...\n", gl.RoadDensity)
+	gl.logf("Generating road network with density %.2f...", gl.RoadDensity)
 
 	mainArteriesCreated := gl.createMainArteries(grid, rng)
 	secondaryRoadsCreated := gl.createSecondaryRoads(grid, rng)
 
 	if gl.BlockedCellsAllotment > 0 {
-		fmt.Println("Placing blocked areas before final connectivity fill...")
+		gl.logf("Placing blocked areas before final connectivity fill...")
 		_ = gl.placeBlockedAreas(grid, gl.findEligibleCells(grid),
 			int(float64(len(grid.Cells))*gl.BlockedCellsAllotment), rng)
 	}
@@ -584,7 +609,7 @@ func (gl *GridLoader) generateRoadNetwork(grid *domainmodels.Grid, rng *rand.Ran
 	gl.GenerationStatsSu.MainArteries = mainArteriesCreated
 	gl.GenerationStatsSu.SecondaryRoads = secondaryRoadsCreated
 
-	fmt.Printf("Created %d main arteries, %d secondary roads, %d connectivity segments\n",
+	gl.logf("Created %d main arteries, %d secondary roads, %d connectivity segments",
 		mainArteriesCreated, secondaryRoadsCreated, connectivityRoadsCreated)
 
 	return nil
@@ -595,20 +620,20 @@ func (gl *GridLoader) createMainArteries(grid *domainmodels.Grid, rng *rand.Rand
 
 	min, max := 2, 20
 
-	b := rand.Intn(max-min+1) + min
+	b := rng.Intn(max-min+1) + min
 
-	a := rand.Intn(max-b+1) + b
+	a := rng.Intn(max-b+1) + b
 
 	horizontalArteries := gl.selectMainRoadPositions(gl.Height, b, a, rng)
 	for _, y := range horizontalArteries {
-		if gl.createHorizontalRoad(grid, y) {
+		if gl.createHorizontalRoad(grid, y, rng) {
 			arteriesCreated++
 		}
 	}
 
 	verticalArteries := gl.selectMainRoadPositions(gl.Width, b, a, rng)
 	for _, x := range verticalArteries {
-		if gl.createVerticalRoad(grid, x) {
+		if gl.createVerticalRoad(grid, x, rng) {
 			arteriesCreated++
 		}
 	}
@@ -645,7 +670,7 @@ func (gl *GridLoader) selectMainRoadPositions(dimension int64, minRoads, maxRoad
 	return positions
 }
 
-func (gl *GridLoader) createHorizontalRoad(grid *domainmodels.Grid, y int64) bool {
+func (gl *GridLoader) createHorizontalRoad(grid *domainmodels.Grid, y int64, rng *rand.Rand) bool {
 	segmentsCreated := 0
 
 	for x := int64(0); x < gl.Width-1; x++ {
@@ -655,8 +680,14 @@ func (gl *GridLoader) createHorizontalRoad(grid *domainmodels.Grid, y int64) boo
 			StartY:   y,
 			EndX:     x + 1,
 			EndY:     y,
-			IsOpen:   true,
-			Capacity: gl.getDefaultCapacityForSegment(),
+			LengthKM: gl.calculateSegmentLength(x, y, x+1, y, rng),
+
+			BaseSpeedKPH: gl.getMainArterySpeed(),
+			IsOpen:       true,
+			Capacity:     gl.getDefaultCapacityForSegment(),
+
+			LanesForward:  gl.ArterialLanes,
+			LanesBackward: gl.ArterialLanes,
 		}
 
 		gl.addSegmentToCell(grid, x, y, segment)
@@ -669,7 +700,7 @@ func (gl *GridLoader) createHorizontalRoad(grid *domainmodels.Grid, y int64) boo
 	return segmentsCreated > 0
 }
 
-func (gl *GridLoader) createVerticalRoad(grid *domainmodels.Grid, x int64) bool {
+func (gl *GridLoader) createVerticalRoad(grid *domainmodels.Grid, x int64, rng *rand.Rand) bool {
 	segmentsCreated := 0
 
 	for y := int64(0); y < gl.Height-1; y++ {
@@ -679,8 +710,14 @@ func (gl *GridLoader) createVerticalRoad(grid *domainmodels.Grid, x int64) bool
 			StartY:   y,
 			EndX:     x,
 			EndY:     y + 1,
-			IsOpen:   true,
-			Capacity: gl.getDefaultCapacityForSegment(),
+			LengthKM: gl.calculateSegmentLength(x, y, x, y+1, rng),
+
+			BaseSpeedKPH: gl.getMainArterySpeed(),
+			IsOpen:       true,
+			Capacity:     gl.getDefaultCapacityForSegment(),
+
+			LanesForward:  gl.ArterialLanes,
+			LanesBackward: gl.ArterialLanes,
 		}
 
 		gl.addSegmentToCell(grid, x, y, segment)
@@ -800,7 +837,7 @@ func (gl *GridLoader) addLocalRoadConnections(grid *domainmodels.Grid, cell *dom
 		targetY := cell.Ypos + dir.dy
 
 		if targetX >= 0 && targetX < gl.Width && targetY >= 0 && targetY < gl.Height {
-			if gl.createConnectionSegment(grid, cell.Xpos, cell.Ypos, targetX, targetY) {
+			if gl.createConnectionSegment(grid, cell.Xpos, cell.Ypos, targetX, targetY, rng) {
 				connectionsAdded++
 			}
 		}
@@ -811,19 +848,25 @@ func (gl *GridLoader) addLocalRoadConnections(grid *domainmodels.Grid, cell *dom
 	return connectionsAdded
 }
 
-func (gl *GridLoader) createConnectionSegment(grid *domainmodels.Grid, fromX, fromY, toX, toY int64) bool {
+func (gl *GridLoader) createConnectionSegment(grid *domainmodels.Grid, fromX, fromY, toX, toY int64, rng *rand.Rand) bool {
 	segment := domainmodels.RoadSegment{
 		ID:       gl.SegmentIDCounter,
 		StartX:   fromX,
 		StartY:   fromY,
 		EndX:     toX,
 		EndY:     toY,
-		LengthKM: gl.calculateSegmentLength(fromX, fromY, toX, toY),
+		LengthKM: gl.calculateSegmentLength(fromX, fromY, toX, toY, rng),
 
 		BaseSpeedKPH: gl.getBaseSpeedForSegment(fromX, fromY, toX, toY),
 		IsOpen:       true,
 		Capacity:     gl.getDefaultCapacityForSegment(),
 	}
+	if gl.OneWayStreets {
+		segment.Direction = domainmodels.ForwardOnly
+		if !streetRunsForward(fromX, fromY, toX, toY) {
+			segment.StartX, segment.StartY, segment.EndX, segment.EndY = toX, toY, fromX, fromY
+		}
+	}
 
 	gl.addSegmentToCell(grid, fromX, fromY, segment)
 	gl.addSegmentToCell(grid, toX, toY, segment)
@@ -831,6 +874,40 @@ func (gl *GridLoader) createConnectionSegment(grid *domainmodels.Grid, fromX, fr
 	gl.SegmentIDCounter++
 	return true
 }
+
+// streetRunsForward reports whether a one-way street between neighbouring
+// cells runs from the first to the second: streets run east along even rows
+// and west along odd ones, south down even columns and north up odd ones.
+func streetRunsForward(fromX, fromY, toX, toY int64) bool {
+	if fromY == toY {
+		return (toX > fromX) == (fromY%2 == 0)
+	}
+	return (toY > fromY) == (fromX%2 == 0)
+}
+
+// openStrandingOneWays opens both ways the one-way segments a vehicle could
+// take and never drive back from, and reports how many it opened.
+func (gl *GridLoader) openStrandingOneWays(grid *domainmodels.Grid) int {
+	stranding := graphconv.ToGraph(grid).StrandingOneWays()
+	if len(stranding) == 0 {
+		return 0
+	}
+	open := make(map[int64]bool, len(stranding))
+	for _, segmentID := range stranding {
+		open[segmentID] = true
+	}
+	for i := range grid.Cells {
+		for j := range grid.Cells[i].RoadSegments {
+			segment := &grid.Cells[i].RoadSegments[j].RoadSegment
+			if open[segment.ID] {
+				lanes := max(segment.LanesForward, segment.LanesBackward)
+				segment.Direction = domainmodels.BothWays
+				segment.LanesForward, segment.LanesBackward = lanes, lanes
+			}
+		}
+	}
+	return len(stranding)
+}
 func (gl *GridLoader) getBaseSpeedForSegment(fromX, fromY, toX, toY int64) float64 {
 	if fromX == toX || fromY == toY {
 		return 50.0
@@ -839,6 +916,10 @@ func (gl *GridLoader) getBaseSpeedForSegment(fromX, fromY, toX, toY int64) float
 	return 30.0
 }
 
+func (gl *GridLoader) getMainArterySpeed() float64 {
+	return 70.0
+}
+
 func (gl *GridLoader) fillConnectivityGaps(grid *domainmodels.Grid, rng *rand.Rand) int {
 	connectivitySegmentsAdded := 0
 
@@ -851,7 +932,7 @@ func (gl *GridLoader) fillConnectivityGaps(grid *domainmodels.Grid, rng *rand.Ra
 		return 0
 	}
 
-	fmt.Printf("Need %d more connections to reach target density\n", connectionsNeeded)
+	gl.logf("Need %d more connections to reach target density", connectionsNeeded)
 
 	attempts := 0
 	maxAttempts := connectionsNeeded * 3
@@ -886,7 +967,7 @@ func (gl *GridLoader) addRandomConnection(grid *domainmodels.Grid, cell *domainm
 		if targetX >= 0 && targetX < gl.Width && targetY >= 0 && targetY < gl.Height {
 
 			if !gl.connectionExists(grid, cell.Xpos, cell.Ypos, targetX, targetY) {
-				return gl.createConnectionSegment(grid, cell.Xpos, cell.Ypos, targetX, targetY)
+				return gl.createConnectionSegment(grid, cell.Xpos, cell.Ypos, targetX, targetY, rng)
 			}
 		}
 	}
@@ -922,6 +1003,24 @@ func (gl *GridLoader) connectionExists(grid *domainmodels.Grid, fromX, fromY, to
 	return false
 }
 
+// leadsOnto reports whether a vehicle on a may go on along b. Segments in
+// neighbouring cells that share no end are kept together as before; at a
+// shared end, a must be driven towards it and b away from it.
+func leadsOnto(a, b *domainmodels.RoadSegment) bool {
+	shared := false
+	for _, end := range [2][2]int64{{b.StartX, b.StartY}, {b.EndX, b.EndY}} {
+		if !a.HasEndpoint(end[0], end[1]) {
+			continue
+		}
+		shared = true
+		fromX, fromY := a.OtherEndpoint(end[0], end[1])
+		if a.CanLeave(fromX, fromY) && b.CanLeave(end[0], end[1]) {
+			return true
+		}
+	}
+	return !shared
+}
+
 func (gl *GridLoader) getDefaultCapacityForSegment() *int64 {
 	capacity := int64(15)
 	return &capacity
@@ -939,13 +1038,16 @@ func (gl *GridLoader) buildGridBasedAdjacencyOptimized(grid *domainmodels.Grid)
 
 	processedPairs := make(map[string]bool)
 
+	segmentsByID := make(map[int64]*domainmodels.RoadSegment)
 	cellSegments := make(map[[2]int64][]int64)
-	for _, cell := range grid.Cells {
+	for i := range grid.Cells {
+		cell := &grid.Cells[i]
 		cellPos := [2]int64{cell.Xpos, cell.Ypos}
 		segments := make([]int64, 0, len(cell.RoadSegments))
 
-		for _, road := range cell.RoadSegments {
+		for j, road := range cell.RoadSegments {
 			segments = append(segments, road.RoadSegmentID)
+			segmentsByID[road.RoadSegmentID] = &cell.RoadSegments[j].RoadSegment
 		}
 
 		if len(segments) > 0 {
@@ -953,8 +1055,12 @@ func (gl *GridLoader) buildGridBasedAdjacencyOptimized(grid *domainmodels.Grid)
 		}
 	}
 
-	for cellPos, currentSegments := range cellSegments {
-		currentX, currentY := cellPos[0], cellPos[1]
+	for _, cell := range grid.Cells {
+		currentX, currentY := cell.Xpos, cell.Ypos
+		currentSegments, ok := cellSegments[[2]int64{currentX, currentY}]
+		if !ok {
+			continue
+		}
 
 		for _, direction := range directions {
 			neighborX := currentX + direction[0]
@@ -979,7 +1085,11 @@ func (gl *GridLoader) buildGridBasedAdjacencyOptimized(grid *domainmodels.Grid)
 					adjacency[currentSegmentID] = make([]int64, 0, 8)
 				}
 
-				adjacency[currentSegmentID] = append(adjacency[currentSegmentID], neighborSegments...)
+				for _, neighborSegmentID := range neighborSegments {
+					if leadsOnto(segmentsByID[currentSegmentID], segmentsByID[neighborSegmentID]) {
+						adjacency[currentSegmentID] = append(adjacency[currentSegmentID], neighborSegmentID)
+					}
+				}
 			}
 
 			for _, neighborSegmentID := range neighborSegments {
@@ -987,7 +1097,11 @@ func (gl *GridLoader) buildGridBasedAdjacencyOptimized(grid *domainmodels.Grid)
 					adjacency[neighborSegmentID] = make([]int64, 0, 8)
 				}
 
-				adjacency[neighborSegmentID] = append(adjacency[neighborSegmentID], currentSegments...)
+				for _, currentSegmentID := range currentSegments {
+					if leadsOnto(segmentsByID[neighborSegmentID], segmentsByID[currentSegmentID]) {
+						adjacency[neighborSegmentID] = append(adjacency[neighborSegmentID], currentSegmentID)
+					}
+				}
 			}
 		}
 	}
@@ -3,6 +3,7 @@ package gridloader
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"owenvi.com/fleetsim/internal/domainmodels"
 	"owenvi.com/fleetsim/internal/utils"
@@ -23,9 +24,24 @@ type GridLoader struct {
 	DeadEndBias  float64
 	currentGrid  *domainmodels.Grid
 
+	// BaseRoadConditions, when set, are assigned to segments by road class as
+	// the grid is generated.
+	BaseRoadConditions map[string]domainmodels.RoadCondition `json:"-"`
+
+	// OneWayStreets makes the streets off the main arteries one-way,
+	// alternating direction from one row or column to the next.
+	// ArterialLanes, when set, gives the main arteries that many lanes each
+	// way.
+	OneWayStreets bool  `json:"one_way_streets"`
+	ArterialLanes int64 `json:"arterial_lanes"`
+
 	SegmentIDCounter  int64
 	GenerationStatsSu *GenerationStats
 	endpointIndex     utils.EndpointIndex
+
+	// logOutput, when set, receives the loader's progress as it generates,
+	// loads and imports grids.
+	logOutput io.Writer
 }
 
 type GenerationStats struct {
@@ -121,6 +137,19 @@ func NewGridLoader() *GridLoader {
 	return &gl
 }
 
+// SetLogOutput makes the loader report its progress to w. It is silent by
+// default.
+func (gl *GridLoader) SetLogOutput(w io.Writer) {
+	gl.logOutput = w
+}
+
+func (gl *GridLoader) logf(format string, args ...any) {
+	if gl.logOutput == nil {
+		return
+	}
+	fmt.Fprintf(gl.logOutput, format+"\n", args...)
+}
+
 func PrintValGrid() {
 	Ct := NewGridLoader()
 	fmt.Println(Ct)
@@ -184,6 +213,15 @@ func (gl *GridLoader) validateImportedGrid(grid *domainmodels.Grid) error {
 				return fmt.Errorf("cell (%d,%d) contains segment %d with invalid coordinates",
 					cell.Xpos, cell.Ypos, segment.ID)
 			}
+
+			switch segment.Direction {
+			case domainmodels.BothWays, domainmodels.ForwardOnly, domainmodels.BackwardOnly:
+			default:
+				return fmt.Errorf("road segment %d has invalid direction: %s", segment.ID, segment.Direction)
+			}
+			if segment.LanesForward < 0 || segment.LanesBackward < 0 {
+				return fmt.Errorf("road segment %d has a negative lane count", segment.ID)
+			}
 		}
 	}
 
@@ -317,22 +355,22 @@ func (gl *GridLoader) logGenerationStats() {
 		return
 	}
 
-	fmt.Printf("\n=== Grid Generation Statistics ===\n")
-	fmt.Printf("Total cells: %d\n", stats.TotalCells)
-	fmt.Printf("Road cells: %d (%.1f%%)\n", stats.RoadCells,
+	gl.logf("\n=== Grid Generation Statistics ===")
+	gl.logf("Total cells: %d", stats.TotalCells)
+	gl.logf("Road cells: %d (%.1f%%)", stats.RoadCells,
 		float64(stats.RoadCells)/float64(stats.TotalCells)*100)
-	fmt.Printf("Special cells: %d (%.1f%%)\n", stats.SpecialCells,
+	gl.logf("Special cells: %d (%.1f%%)", stats.SpecialCells,
 		float64(stats.SpecialCells)/float64(stats.TotalCells)*100)
-	fmt.Printf("Total road segments: %d\n", stats.TotalSegments)
-	fmt.Printf("Generation time: %d ms\n", stats.GenerationTimeMs)
-	fmt.Printf("=====================================\n\n")
+	gl.logf("Total road segments: %d", stats.TotalSegments)
+	gl.logf("Generation time: %d ms", stats.GenerationTimeMs)
+	gl.logf("=====================================\n")
 }
 
 func (gl *GridLoader) useRealisticRoadConnections(grid *domainmodels.Grid) {
-	fmt.Println("Switching to realistic geometric road connections...")
+	gl.logf("Switching to realistic geometric road connections...")
 	geometricAdjacency := gl.buildGeometricAdjacency(grid)
 	grid.RoadGraph = &domainmodels.RoadGraph{Adjacency: geometricAdjacency}
-	fmt.Printf("Updated to geometric adjacency: %d connections\n",
+	gl.logf("Updated to geometric adjacency: %d connections",
 		countTotalConnections(geometricAdjacency))
 }
 func countTotalConnections(adjacency map[int64][]int64) int {
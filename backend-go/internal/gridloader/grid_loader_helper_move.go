@@ -6,7 +6,7 @@ import (
 	"math"
 )
 
-func (gl *GridLoader) calculateSegmentLength(fromX, fromY, toX, toY int64) float64 {
+func (gl *GridLoader) calculateSegmentLength(fromX, fromY, toX, toY int64, rng *rand.Rand) float64 {
 	dx := float64(toX - fromX)
 	dy := float64(toY - fromY)
 
@@ -14,6 +14,6 @@ func (gl *GridLoader) calculateSegmentLength(fromX, fromY, toX, toY int64) float
 	const kmPerGridUnit = 0.5
 	const variationRange = 0.2
 
-	variation := 1.0 + (rand.Float64()-0.5)*variationRange
+	variation := 1.0 + (rng.Float64()-0.5)*variationRange
 	return baseDistance * kmPerGridUnit * variation
 }
@@ -0,0 +1,38 @@
+package gridloader
+
+import "owenvi.com/fleetsim/internal/domainmodels"
+
+const (
+	urbanStreetCondition  = "urban_street"
+	arterialRoadCondition = "arterial_road"
+)
+
+// assignBaseConditions gives every segment the base condition of its road
+// class: main arteries are arterial roads, everything else an urban street.
+// Both cell copies of a segment get the same conditions.
+func (gl *GridLoader) assignBaseConditions(grid *domainmodels.Grid) {
+	if len(gl.BaseRoadConditions) == 0 {
+		return
+	}
+
+	for i := range grid.Cells {
+		for j := range grid.Cells[i].RoadSegments {
+			segment := &grid.Cells[i].RoadSegments[j].RoadSegment
+
+			conditionID := urbanStreetCondition
+			if segment.BaseSpeedKPH >= gl.getMainArterySpeed() {
+				conditionID = arterialRoadCondition
+			}
+			condition, ok := gl.BaseRoadConditions[conditionID]
+			if !ok {
+				continue
+			}
+
+			segment.BaseConditions = []domainmodels.RoadCondition{condition}
+			segment.VisualState = domainmodels.SegmentVisualState{
+				PrimaryColor: condition.VisualColor,
+				Opacity:      1.0,
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package gridloader
+
+import (
+	"fmt"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/reqpays"
+	"owenvi.com/fleetsim/internal/utils"
+)
+
+const congestedSpawnUtilization = 0.8
+
+// SpawnForRequest builds a vehicle for a validated spawn request. occupied holds
+// the positions of vehicles already on the grid so MinDistanceFromOthers can be
+// honoured. The vehicle only counts as spawned once the caller has put it on
+// the road and passed it to RecordSpawned.
+func (vs *VehicleSpawner) SpawnForRequest(grid *domainmodels.Grid, request *reqpays.VehicleSpawnRequest, occupied [][2]int64) (domainmodels.Vehicle, error) {
+	if _, known := vs.vehicleProfiles[string(request.VehicleType)]; !known {
+		return domainmodels.Vehicle{}, fmt.Errorf("unknown vehicle type %q", request.VehicleType)
+	}
+
+	spawnPoint, err := vs.selectRequestedSpawnLocation(grid, request.SpawnLocation, occupied)
+	if err != nil {
+		return domainmodels.Vehicle{}, err
+	}
+
+	destination, err := vs.selectRequestedDestination(grid, request.Destination, spawnPoint)
+	if err != nil {
+		return domainmodels.Vehicle{}, err
+	}
+
+	vehicle := vs.createVehicle(string(request.VehicleType), spawnPoint)
+	vehicle.DestinationCell = destination
+
+	if request.InitialFuelPercent != nil {
+		vehicle.FuelLevel = vehicle.Profile.TankLiters * *request.InitialFuelPercent
+		vehicle.InitialFuelPercent = request.InitialFuelPercent
+	}
+	if request.SpeedMultiplier != nil {
+		vehicle.SpeedMultiplier = *request.SpeedMultiplier
+	}
+	vehicle.CustomName = request.CustomName
+	vehicle.RequireFuelStop = request.Destination.RequireFuelStop
+	if request.RequestID != "" {
+		requestID := request.RequestID
+		vehicle.SpawnRequestID = &requestID
+	}
+
+	return vehicle, nil
+}
+
+// RecordSpawned counts a vehicle built by SpawnForRequest in the spawn
+// statistics.
+func (vs *VehicleSpawner) RecordSpawned(vehicle domainmodels.Vehicle) {
+	vs.spawnedVehicles = append(vs.spawnedVehicles, vehicle)
+}
+
+func (vs *VehicleSpawner) selectRequestedSpawnLocation(grid *domainmodels.Grid, location reqpays.SpawnLocationRequest, occupied [][2]int64) (*domainmodels.Cell, error) {
+	if location.CellX != nil && location.CellY != nil {
+		cell := grid.CoordIndex[[2]int64{*location.CellX, *location.CellY}]
+		if cell == nil {
+			return nil, fmt.Errorf("spawn cell (%d,%d) is outside the grid", *location.CellX, *location.CellY)
+		}
+		if !utils.IsSuitableSpawnLocation(grid, cell) {
+			return nil, fmt.Errorf("spawn cell (%d,%d) has no road access", cell.Xpos, cell.Ypos)
+		}
+		if !farFromOthers(cell, occupied, location.MinDistanceFromOthers) {
+			return nil, fmt.Errorf("spawn cell (%d,%d) is within %d cells of another vehicle",
+				cell.Xpos, cell.Ypos, location.MinDistanceFromOthers)
+		}
+		return cell, nil
+	}
+
+	candidates := vs.findValidSpawnLocations(grid)
+	switch location.LocationType {
+	case "depot":
+		candidates = filterCells(candidates, func(cell *domainmodels.Cell) bool {
+			return cell.CellType == domainmodels.CellTypeDepot
+		})
+	case "refuel":
+		candidates = filterCells(candidates, func(cell *domainmodels.Cell) bool {
+			return cell.CellType == domainmodels.CellTypeRefuel
+		})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no valid %s spawn locations found in grid", location.LocationType)
+	}
+
+	candidates = filterCells(candidates, func(cell *domainmodels.Cell) bool {
+		return farFromOthers(cell, occupied, location.MinDistanceFromOthers)
+	})
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no spawn location at least %d cells from other vehicles", location.MinDistanceFromOthers)
+	}
+
+	// Edge and congestion preferences narrow the candidates only when something
+	// is left afterwards; they are preferences rather than requirements.
+	if location.PreferEdgeSpawn || location.LocationType == "edge" {
+		candidates = preferCells(candidates, func(cell *domainmodels.Cell) bool {
+			return cell.Xpos == 0 || cell.Ypos == 0 || cell.Xpos == grid.DimX-1 || cell.Ypos == grid.DimY-1
+		})
+	}
+	if location.AvoidCongestion {
+		candidates = preferCells(candidates, func(cell *domainmodels.Cell) bool {
+			return !isCongestedCell(grid, cell)
+		})
+	}
+
+	return candidates[vs.rng.Intn(len(candidates))], nil
+}
+
+func (vs *VehicleSpawner) selectRequestedDestination(grid *domainmodels.Grid, destination reqpays.DestinationRequest, origin *domainmodels.Cell) (*domainmodels.Cell, error) {
+	if destination.CellX != nil && destination.CellY != nil {
+		cell := grid.CoordIndex[[2]int64{*destination.CellX, *destination.CellY}]
+		if cell == nil {
+			return nil, fmt.Errorf("destination cell (%d,%d) is outside the grid", *destination.CellX, *destination.CellY)
+		}
+		if cell.CellType == domainmodels.CellTypeBlocked || len(cell.RoadSegments) == 0 {
+			return nil, fmt.Errorf("destination cell (%d,%d) has no road access", cell.Xpos, cell.Ypos)
+		}
+		if cell.Xpos == origin.Xpos && cell.Ypos == origin.Ypos {
+			return nil, fmt.Errorf("destination cell (%d,%d) is the spawn cell", cell.Xpos, cell.Ypos)
+		}
+		return cell, nil
+	}
+
+	var wantType domainmodels.CellType
+	switch destination.DestinationType {
+	case "depot":
+		wantType = domainmodels.CellTypeDepot
+	case "refuel":
+		wantType = domainmodels.CellTypeRefuel
+	case "", "random":
+		if destination.MaxDistanceFromSpawn == nil {
+			if cell := vs.selectRandomDestination(grid, origin); cell != nil {
+				return cell, nil
+			}
+			return nil, fmt.Errorf("no reachable destination from (%d,%d)", origin.Xpos, origin.Ypos)
+		}
+	}
+
+	var candidates []*domainmodels.Cell
+	for i := range grid.Cells {
+		cell := &grid.Cells[i]
+		if len(cell.RoadSegments) == 0 || cell.CellType == domainmodels.CellTypeBlocked {
+			continue
+		}
+		if wantType != "" && cell.CellType != wantType {
+			continue
+		}
+		distance := utils.ManhattanDistance(origin.Xpos, origin.Ypos, cell.Xpos, cell.Ypos)
+		if distance == 0 {
+			continue
+		}
+		if destination.MaxDistanceFromSpawn != nil && distance > *destination.MaxDistanceFromSpawn {
+			continue
+		}
+		candidates = append(candidates, cell)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no %s destination available from (%d,%d)", destination.DestinationType, origin.Xpos, origin.Ypos)
+	}
+	return candidates[vs.rng.Intn(len(candidates))], nil
+}
+
+func farFromOthers(cell *domainmodels.Cell, occupied [][2]int64, minDistance int64) bool {
+	if minDistance <= 0 {
+		return true
+	}
+	for _, pos := range occupied {
+		if utils.ManhattanDistance(cell.Xpos, cell.Ypos, pos[0], pos[1]) < minDistance {
+			return false
+		}
+	}
+	return true
+}
+
+func isCongestedCell(grid *domainmodels.Grid, cell *domainmodels.Cell) bool {
+	for _, cellRoad := range cell.RoadSegments {
+		segment := grid.GetSegment(cellRoad.RoadSegmentID)
+		if segment != nil && segment.CurrentTrafficLoad.CapacityUtilization >= congestedSpawnUtilization {
+			return true
+		}
+	}
+	return false
+}
+
+func filterCells(cells []*domainmodels.Cell, keep func(*domainmodels.Cell) bool) []*domainmodels.Cell {
+	var filtered []*domainmodels.Cell
+	for _, cell := range cells {
+		if keep(cell) {
+			filtered = append(filtered, cell)
+		}
+	}
+	return filtered
+}
+
+func preferCells(cells []*domainmodels.Cell, prefer func(*domainmodels.Cell) bool) []*domainmodels.Cell {
+	if preferred := filterCells(cells, prefer); len(preferred) > 0 {
+		return preferred
+	}
+	return cells
+}
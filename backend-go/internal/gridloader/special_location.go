@@ -9,7 +9,7 @@ import (
 )
 
 func (gl *GridLoader) placeSpecialLocations(grid *domainmodels.Grid, rng *rand.Rand) error {
-	fmt.Printf("Placing special locations (%.1f%% fuel, %.1f%% depot)...\n",
+	gl.logf("Placing special locations (%.1f%% fuel, %.1f%% depot)...",
 		gl.RefuelCellsAllotment*100, gl.DepotCellsAllotment*100)
 
 	eligibleCells := gl.findEligibleCells(grid)
@@ -21,7 +21,7 @@ func (gl *GridLoader) placeSpecialLocations(grid *domainmodels.Grid, rng *rand.R
 	fuelStationsNeeded := int(float64(totalCells) * gl.RefuelCellsAllotment)
 	depotsNeeded := int(float64(totalCells) * gl.DepotCellsAllotment)
 
-	fmt.Printf("Creating %d fuel stations, %d depots from %d eligible cells\n",
+	gl.logf("Creating %d fuel stations, %d depots from %d eligible cells",
 		fuelStationsNeeded, depotsNeeded, len(eligibleCells))
 
 	if err := gl.placeFuelStations(grid, eligibleCells, fuelStationsNeeded, rng); err != nil {
@@ -105,7 +105,7 @@ func (gl *GridLoader) placeFuelStations(grid *domainmodels.Grid, eligibleCells [
 	}
 
 	if placed < count {
-		fmt.Printf("Warning: Only placed %d of %d requested fuel stations\n", placed, count)
+		gl.logf("Warning: Only placed %d of %d requested fuel stations", placed, count)
 	}
 
 	return nil
@@ -153,7 +153,7 @@ func (gl *GridLoader) placeDepots(grid *domainmodels.Grid, eligibleCells []*doma
 	}
 
 	if placed < count {
-		fmt.Printf("Warning: Only placed %d of %d requested depots\n", placed, count)
+		gl.logf("Warning: Only placed %d of %d requested depots", placed, count)
 	}
 
 	return nil
@@ -215,7 +215,7 @@ func (gl *GridLoader) placeBlockedAreas(grid *domainmodels.Grid, eligibleCells [
 	}
 
 	if placed < count {
-		fmt.Printf("Warning: Only placed %d of %d requested blocked areas (connectivity/spacing constraints)\n", placed, count)
+		gl.logf("Warning: Only placed %d of %d requested blocked areas (connectivity/spacing constraints)", placed, count)
 	}
 
 	return nil
@@ -2,12 +2,15 @@ package gridloader
 
 import (
 	"fmt"
+	"io"
 	"math/rand"
+	"sort"
 
 	"owenvi.com/fleetsim/internal/config"
 	"owenvi.com/fleetsim/internal/constants"
 	"owenvi.com/fleetsim/internal/domainmodels"
 	"owenvi.com/fleetsim/internal/utils"
+	"owenvi.com/roadgraph/simclock"
 
 	"time"
 )
@@ -21,6 +24,10 @@ type VehicleSpawner struct {
 	spawnedVehicles []domainmodels.Vehicle
 
 	rng *rand.Rand
+	// clock stamps SpawnedAt; without one vehicles are stamped with wall time.
+	clock simclock.Clock
+	// logOutput, when set, receives a line per vehicle spawned.
+	logOutput io.Writer
 }
 
 func NewVehicleSpawner(config *config.SimulationConfig, seed int64) *VehicleSpawner {
@@ -37,6 +44,31 @@ func NewVehicleSpawner(config *config.SimulationConfig, seed int64) *VehicleSpaw
 	return spawner
 }
 
+// SetClock stamps vehicles spawned from now on with clock's simulated time.
+func (vs *VehicleSpawner) SetClock(clock simclock.Clock) {
+	vs.clock = clock
+}
+
+// SetLogOutput makes the spawner report each vehicle it spawns to w. It is
+// silent by default.
+func (vs *VehicleSpawner) SetLogOutput(w io.Writer) {
+	vs.logOutput = w
+}
+
+func (vs *VehicleSpawner) logf(format string, args ...any) {
+	if vs.logOutput == nil {
+		return
+	}
+	fmt.Fprintf(vs.logOutput, format+"\n", args...)
+}
+
+func (vs *VehicleSpawner) now() time.Time {
+	if vs.clock == nil {
+		return time.Now()
+	}
+	return vs.clock.Now()
+}
+
 func (vs *VehicleSpawner) initializeVehicleProfiles() {
 
 	carProfile := &domainmodels.VehicleProfile{
@@ -71,19 +103,27 @@ func (vs *VehicleSpawner) initializeVehicleProfiles() {
 		CargoCapacityKG:   8000.0,
 	}
 	vs.vehicleProfiles["truck"] = truckProfile
+}
 
-	fmt.Printf("Initialized %d vehicle profiles: car, van, truck\n", len(vs.vehicleProfiles))
+// Profiles returns the spawner's vehicle profiles ordered by ID.
+func (vs *VehicleSpawner) Profiles() []domainmodels.VehicleProfile {
+	profiles := make([]domainmodels.VehicleProfile, 0, len(vs.vehicleProfiles))
+	for _, profile := range vs.vehicleProfiles {
+		profiles = append(profiles, *profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID < profiles[j].ID })
+	return profiles
 }
 
 func (vs *VehicleSpawner) SpawnRandomVehicles(grid *domainmodels.Grid, count int) ([]domainmodels.Vehicle, error) {
-	fmt.Printf("Spawning %d random vehicles...\n", count)
+	vs.logf("Spawning %d random vehicles...", count)
 
 	validSpawnPoints := vs.findValidSpawnLocations(grid)
 	if len(validSpawnPoints) == 0 {
 		return nil, fmt.Errorf("no valid spawn locations found in grid")
 	}
 
-	fmt.Printf("Found %d valid spawn locations\n", len(validSpawnPoints))
+	vs.logf("Found %d valid spawn locations", len(validSpawnPoints))
 
 	var spawnedVehicles []domainmodels.Vehicle
 	spawnAttempts := 0
@@ -105,7 +145,7 @@ func (vs *VehicleSpawner) SpawnRandomVehicles(grid *domainmodels.Grid, count int
 		spawnedVehicles = append(spawnedVehicles, vehicle)
 		vs.spawnedVehicles = append(vs.spawnedVehicles, vehicle)
 
-		fmt.Printf("Spawned %s '%s' at (%d,%d) -> (%d,%d)\n",
+		vs.logf("Spawned %s '%s' at (%d,%d) -> (%d,%d)",
 			vehicle.Profile.VehicleType,
 			vehicle.ID,
 			spawnPoint.Xpos, spawnPoint.Ypos,
@@ -113,7 +153,7 @@ func (vs *VehicleSpawner) SpawnRandomVehicles(grid *domainmodels.Grid, count int
 	}
 
 	if len(spawnedVehicles) < count {
-		fmt.Printf("Warning: Only spawned %d of %d requested vehicles after %d attempts\n",
+		vs.logf("Warning: Only spawned %d of %d requested vehicles after %d attempts",
 			len(spawnedVehicles), count, spawnAttempts)
 	}
 
@@ -175,7 +215,7 @@ func (vs *VehicleSpawner) createVehicle(vehicleType string, spawnLocation *domai
 		profile = vs.vehicleProfiles["car"]
 	}
 
-	vehicleID := fmt.Sprintf("v%d_%s_%d", vs.vehicleCounter, vehicleType, time.Now().Unix()%10000)
+	vehicleID := fmt.Sprintf("v%d_%s", vs.vehicleCounter, vehicleType)
 	vs.vehicleCounter++
 
 	fuelMin := vs.config.DefaultFuelRange[0]
@@ -196,9 +236,11 @@ func (vs *VehicleSpawner) createVehicle(vehicleType string, spawnLocation *domai
 
 		FuelLevel:       initialFuelAmount,
 		SpeedMultiplier: 1.0 + (vs.rng.Float64()-0.5)*vs.config.DefaultSpeedVariation,
-		ProximityLOD:    false,
+		// Spawned vehicles may drop to reduced live-state detail under load;
+		// ones a user asked for are switched back to full detail.
+		ProximityLOD: true,
 
-		SpawnedAt: &[]time.Time{time.Now()}[0],
+		SpawnedAt: &[]time.Time{vs.now()}[0],
 	}
 
 	return vehicle
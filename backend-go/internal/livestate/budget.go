@@ -0,0 +1,132 @@
+package livestate
+
+import (
+	"sync"
+	"time"
+)
+
+type DetailLevel int
+
+const (
+	// DetailFull publishes every change on every tick.
+	DetailFull DetailLevel = iota
+	// DetailReduced publishes vehicles flagged for proximity LOD only every
+	// few ticks and road loads only when their congestion level or
+	// conditions change.
+	DetailReduced
+	// DetailMinimal publishes status changes and little else.
+	DetailMinimal
+	// DetailPaused publishes nothing until the budget recovers.
+	DetailPaused
+)
+
+func (l DetailLevel) String() string {
+	switch l {
+	case DetailFull:
+		return "full"
+	case DetailReduced:
+		return "reduced"
+	case DetailMinimal:
+		return "minimal"
+	default:
+		return "paused"
+	}
+}
+
+// CommandBudget paces Redis commands to stay within an hourly allowance. It
+// combines a token bucket, refilled at the hourly rate and holding at most a
+// minute's worth, with a hard count over the trailing hour kept in per-minute
+// buckets.
+type CommandBudget struct {
+	mu sync.Mutex
+
+	perHour    int
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+
+	minuteCounts [60]int
+	minuteStamps [60]int64
+}
+
+func NewCommandBudget(perHour int, now time.Time) *CommandBudget {
+	burst := max(float64(perHour)/60.0, 1.0)
+	return &CommandBudget{
+		perHour:    perHour,
+		burst:      burst,
+		tokens:     burst,
+		lastRefill: now,
+	}
+}
+
+// Allow spends n commands if both the bucket and the trailing hour have room
+// for them, and reports whether it did.
+func (b *CommandBudget) Allow(n int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if float64(n) > b.tokens || b.usedLocked(now)+n > b.perHour {
+		return false
+	}
+	b.tokens -= float64(n)
+
+	minute := now.Unix() / 60
+	slot := minute % 60
+	if b.minuteStamps[slot] != minute {
+		b.minuteStamps[slot] = minute
+		b.minuteCounts[slot] = 0
+	}
+	b.minuteCounts[slot] += n
+	return true
+}
+
+// Level picks how much detail the publisher can afford right now: full while
+// the bucket is at least half full, then progressively less as it drains or as
+// the trailing hour nears the allowance.
+func (b *CommandBudget) Level(now time.Time) DetailLevel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	used := float64(b.usedLocked(now)) / float64(b.perHour)
+	fill := b.tokens / b.burst
+
+	switch {
+	case used >= 1.0 || b.tokens < 1.0:
+		return DetailPaused
+	case used >= 0.9 || fill < 0.2:
+		return DetailMinimal
+	case used >= 0.75 || fill < 0.5:
+		return DetailReduced
+	default:
+		return DetailFull
+	}
+}
+
+// Used is how many commands were spent over the trailing hour.
+func (b *CommandBudget) Used(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.usedLocked(now)
+}
+
+func (b *CommandBudget) usedLocked(now time.Time) int {
+	minute := now.Unix() / 60
+	used := 0
+	for slot, stamp := range b.minuteStamps {
+		if stamp > minute-60 && stamp <= minute {
+			used += b.minuteCounts[slot]
+		}
+	}
+	return used
+}
+
+func (b *CommandBudget) refill(now time.Time) {
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = min(b.burst, b.tokens+elapsed*float64(b.perHour)/3600.0)
+	b.lastRefill = now
+}
@@ -0,0 +1,380 @@
+package livestate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// lodInterval is how many publishes a proximity-LOD vehicle sits out between
+// updates once the budget drops below full detail.
+const lodInterval = 4
+
+// Snapshot is the simulation state offered to one Publish call. A nil Grid or
+// SpawnQueue leaves that part of the live state untouched.
+type Snapshot struct {
+	Vehicles   []*domainmodels.Vehicle
+	Grid       *domainmodels.Grid
+	SpawnQueue *domainmodels.RedisSpawnQueue
+}
+
+// PublishResult describes what one Publish call sent.
+type PublishResult struct {
+	Level             DetailLevel
+	Commands          int
+	VehiclesWritten   int
+	VehiclesRemoved   int
+	RoadsWritten      int
+	SpawnQueueWritten bool
+	// Deferred is set when there were changes but the budget could not cover
+	// them; they are retried on the next call.
+	Deferred bool
+}
+
+// vehicleKey holds the fields a vehicle update is compared on, rounded so
+// that noise in speed or progress does not count as a change.
+type vehicleKey struct {
+	cellX, cellY int64
+	segmentID    int64
+	speed        int64
+	fuel         int64
+	progress     int64
+	status       string
+}
+
+type roadKey struct {
+	fleetCount      int
+	backgroundCount int
+	congestion      string
+	conditions      string
+	open            bool
+}
+
+// Publisher mirrors vehicle positions, road loads and the spawn queue into
+// Redis hashes. Only entries that changed since they were last published are
+// written, all in one pipeline per call, and the amount of detail shrinks as
+// the hourly command budget runs low. It is not safe for concurrent use.
+type Publisher struct {
+	client redis.Cmdable
+	budget *CommandBudget
+
+	vehiclesKey   string
+	roadsKey      string
+	spawnQueueKey string
+
+	publishedVehicles map[string]vehicleKey
+	publishedRoads    map[int64]roadKey
+	publishedQueue    string
+
+	publishCount int64
+	level        DetailLevel
+	now          func() time.Time
+}
+
+func NewPublisher(client redis.Cmdable, cfg *config.SimulationConfig, keyPrefix string) *Publisher {
+	return &Publisher{
+		client:            client,
+		budget:            NewCommandBudget(cfg.RedisCommandBudgetPerHour, time.Now()),
+		vehiclesKey:       keyPrefix + ":vehicles",
+		roadsKey:          keyPrefix + ":roads",
+		spawnQueueKey:     keyPrefix + ":spawn_queue",
+		publishedVehicles: make(map[string]vehicleKey),
+		publishedRoads:    make(map[int64]roadKey),
+		now:               time.Now,
+	}
+}
+
+func (p *Publisher) Budget() *CommandBudget {
+	return p.budget
+}
+
+// Publish writes whatever changed in snapshot that the current budget level
+// allows.
+func (p *Publisher) Publish(ctx context.Context, snapshot Snapshot) (PublishResult, error) {
+	now := p.now()
+	level := p.budget.Level(now)
+	if level != p.level {
+		fmt.Printf("Live state detail %s -> %s (%d Redis commands in the last hour)\n", p.level, level, p.budget.Used(now))
+		p.level = level
+	}
+	result := PublishResult{Level: level}
+	if level == DetailPaused {
+		result.Deferred = true
+		return result, nil
+	}
+	p.publishCount++
+
+	vehicleFields, vehicleKeys, err := p.changedVehicles(snapshot.Vehicles, level, now)
+	if err != nil {
+		return result, err
+	}
+	removed := p.removedVehicles(snapshot.Vehicles)
+
+	var roadFields []any
+	var roadKeys map[int64]roadKey
+	if snapshot.Grid != nil && level != DetailMinimal {
+		roadFields, roadKeys, err = p.changedRoads(snapshot.Grid, level, now)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	var queuePayload string
+	if snapshot.SpawnQueue != nil {
+		payload, err := json.Marshal(snapshot.SpawnQueue)
+		if err != nil {
+			return result, fmt.Errorf("failed to encode spawn queue: %w", err)
+		}
+		if string(payload) != p.publishedQueue {
+			queuePayload = string(payload)
+		}
+	}
+
+	if len(vehicleFields) > 0 {
+		result.Commands++
+	}
+	if len(removed) > 0 {
+		result.Commands++
+	}
+	if len(roadFields) > 0 {
+		result.Commands++
+	}
+	if queuePayload != "" {
+		result.Commands++
+	}
+	if result.Commands == 0 {
+		return result, nil
+	}
+	if !p.budget.Allow(result.Commands, now) {
+		result.Commands = 0
+		result.Deferred = true
+		return result, nil
+	}
+
+	_, err = p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(vehicleFields) > 0 {
+			pipe.HSet(ctx, p.vehiclesKey, vehicleFields...)
+		}
+		if len(removed) > 0 {
+			pipe.HDel(ctx, p.vehiclesKey, removed...)
+		}
+		if len(roadFields) > 0 {
+			pipe.HSet(ctx, p.roadsKey, roadFields...)
+		}
+		if queuePayload != "" {
+			pipe.Set(ctx, p.spawnQueueKey, queuePayload, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to publish live state: %w", err)
+	}
+
+	// Only remember what was written once Redis has it, so a failed pipeline
+	// is retried in full on the next call.
+	for vehicleID, key := range vehicleKeys {
+		p.publishedVehicles[vehicleID] = key
+	}
+	for _, vehicleID := range removed {
+		delete(p.publishedVehicles, vehicleID)
+	}
+	for segmentID, key := range roadKeys {
+		p.publishedRoads[segmentID] = key
+	}
+	if queuePayload != "" {
+		p.publishedQueue = queuePayload
+	}
+
+	result.VehiclesWritten = len(vehicleKeys)
+	result.VehiclesRemoved = len(removed)
+	result.RoadsWritten = len(roadKeys)
+	result.SpawnQueueWritten = queuePayload != ""
+	return result, nil
+}
+
+func (p *Publisher) changedVehicles(vehicles []*domainmodels.Vehicle, level DetailLevel, now time.Time) ([]any, map[string]vehicleKey, error) {
+	sorted := append([]*domainmodels.Vehicle(nil), vehicles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	var fields []any
+	keys := make(map[string]vehicleKey)
+	for _, vehicle := range sorted {
+		if vehicle.CurrentCell == nil {
+			continue
+		}
+		key := vehicleKeyOf(vehicle)
+		previous, published := p.publishedVehicles[vehicle.ID]
+		if published && previous == key {
+			continue
+		}
+		if !p.vehicleDue(vehicle, level, published && previous.status == key.status) {
+			continue
+		}
+
+		payload, err := json.Marshal(RedisVehicleStateFor(vehicle, now))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode vehicle %s: %w", vehicle.ID, err)
+		}
+		fields = append(fields, vehicle.ID, string(payload))
+		keys[vehicle.ID] = key
+	}
+	return fields, keys, nil
+}
+
+// vehicleDue applies the budget level to a vehicle that has changed. Status
+// changes always go out; otherwise proximity-LOD vehicles are thinned first,
+// then everyone.
+func (p *Publisher) vehicleDue(vehicle *domainmodels.Vehicle, level DetailLevel, sameStatus bool) bool {
+	if !sameStatus {
+		return true
+	}
+	onLODTick := p.publishCount%lodInterval == 0
+	switch level {
+	case DetailFull:
+		return true
+	case DetailReduced:
+		return !vehicle.ProximityLOD || onLODTick
+	case DetailMinimal:
+		return !vehicle.ProximityLOD && onLODTick
+	default:
+		return false
+	}
+}
+
+func (p *Publisher) removedVehicles(vehicles []*domainmodels.Vehicle) []string {
+	present := make(map[string]bool, len(vehicles))
+	for _, vehicle := range vehicles {
+		present[vehicle.ID] = true
+	}
+
+	var removed []string
+	for vehicleID := range p.publishedVehicles {
+		if !present[vehicleID] {
+			removed = append(removed, vehicleID)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+func (p *Publisher) changedRoads(grid *domainmodels.Grid, level DetailLevel, now time.Time) ([]any, map[int64]roadKey, error) {
+	segmentIDs := make([]int64, 0, len(grid.SegmentIndex))
+	for segmentID := range grid.SegmentIndex {
+		segmentIDs = append(segmentIDs, segmentID)
+	}
+	sort.Slice(segmentIDs, func(i, j int) bool { return segmentIDs[i] < segmentIDs[j] })
+
+	var fields []any
+	keys := make(map[int64]roadKey)
+	for _, segmentID := range segmentIDs {
+		segment := grid.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+		load := RedisRoadLoadFor(segment, now)
+		key := roadKey{
+			fleetCount:      load.FleetCount,
+			backgroundCount: load.BackgroundCount,
+			congestion:      load.CongestionLevel,
+			conditions:      fmt.Sprint(load.ActiveConditions),
+			open:            segment.IsOpen,
+		}
+
+		previous, published := p.publishedRoads[segmentID]
+		if published && previous == key {
+			continue
+		}
+		// Below full detail, counts drifting within the same congestion level
+		// are not worth a write.
+		if published && level != DetailFull &&
+			previous.congestion == key.congestion && previous.conditions == key.conditions && previous.open == key.open {
+			continue
+		}
+
+		payload, err := json.Marshal(load)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode road load %d: %w", segmentID, err)
+		}
+		fields = append(fields, strconv.FormatInt(segmentID, 10), string(payload))
+		keys[segmentID] = key
+	}
+	return fields, keys, nil
+}
+
+func vehicleKeyOf(vehicle *domainmodels.Vehicle) vehicleKey {
+	key := vehicleKey{
+		cellX:    vehicle.CurrentCell.Xpos,
+		cellY:    vehicle.CurrentCell.Ypos,
+		speed:    int64(math.Round(vehicle.CurrentSpeedKPH)),
+		fuel:     int64(math.Round(vehicle.FuelLevel * 2)),
+		progress: int64(math.Round(vehicle.SegmentProgress * 20)),
+		status:   string(vehicle.Status),
+	}
+	if vehicle.CurrentSegment != nil {
+		key.segmentID = vehicle.CurrentSegment.ID
+	}
+	return key
+}
+
+// RedisVehicleStateFor converts a vehicle for the live-state hash. As with
+// telemetry, the cell's Y coordinate goes in Lat and X in Lng.
+func RedisVehicleStateFor(vehicle *domainmodels.Vehicle, now time.Time) domainmodels.RedisVehicleState {
+	state := domainmodels.RedisVehicleState{
+		VehicleID:       vehicle.ID,
+		SpeedKPH:        vehicle.CurrentSpeedKPH,
+		FuelLevel:       vehicle.FuelLevel,
+		EdgeProgress:    vehicle.SegmentProgress,
+		Status:          string(vehicle.Status),
+		UpdatedAt:       now,
+		UserSessionID:   vehicle.UserSessionID,
+		SpawnRequestID:  vehicle.SpawnRequestID,
+		CustomName:      vehicle.CustomName,
+		SpawnedAt:       vehicle.SpawnedAt,
+		SpeedMultiplier: vehicle.SpeedMultiplier,
+		FuelMultiplier:  1.0,
+	}
+	if vehicle.CurrentCell != nil {
+		state.Lat = float64(vehicle.CurrentCell.Ypos)
+		state.Lng = float64(vehicle.CurrentCell.Xpos)
+	}
+	if vehicle.CurrentSegment != nil {
+		segmentID := vehicle.CurrentSegment.ID
+		state.CurrentSegment = &segmentID
+		state.FuelMultiplier = vehicle.FuelMultiplier(vehicle.CurrentSegment)
+	}
+	return state
+}
+
+func RedisRoadLoadFor(segment *domainmodels.RoadSegment, now time.Time) domainmodels.RedisRoadLoad {
+	load := domainmodels.RedisRoadLoad{
+		SegmentID:           segment.ID,
+		FleetCount:          segment.CurrentTrafficLoad.VehicleCount,
+		BackgroundCount:     segment.CurrentTrafficLoad.BackgroundCount,
+		CongestionRatio:     segment.CurrentTrafficLoad.CapacityUtilization,
+		UpdatedAt:           now,
+		ActiveConditions:    make([]string, 0, len(segment.BaseConditions)+len(segment.TemporaryConditions)),
+		EffectiveSpeedLimit: segment.EffectiveSpeedLimit,
+		AverageSpeed:        segment.CurrentTrafficLoad.AverageSpeed,
+		VisualColor:         segment.VisualState.PrimaryColor,
+		CongestionLevel:     segment.CongestionLevel,
+		ShowWarning:         segment.VisualState.ShowWarning,
+	}
+	if capacity := segment.EffectiveCapacity(); capacity != nil {
+		load.Capacity = int(*capacity)
+	}
+	for _, condition := range segment.BaseConditions {
+		load.ActiveConditions = append(load.ActiveConditions, condition.ID)
+	}
+	for _, condition := range segment.TemporaryConditions {
+		load.ActiveConditions = append(load.ActiveConditions, condition.ID)
+	}
+	return load
+}
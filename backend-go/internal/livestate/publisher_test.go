@@ -0,0 +1,129 @@
+package livestate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+var testStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestPublisher(t *testing.T, perHour int) (*Publisher, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	cfg := config.Config()
+	cfg.RedisCommandBudgetPerHour = perHour
+	publisher := NewPublisher(client, cfg, "test")
+	publisher.budget = NewCommandBudget(perHour, testStart)
+	publisher.now = func() time.Time { return testStart }
+	return publisher, server
+}
+
+func testVehicle(id string, x int64) *domainmodels.Vehicle {
+	return &domainmodels.Vehicle{
+		ID:          id,
+		Status:      constants.VehicleStatusMoving,
+		CurrentCell: &domainmodels.Cell{Xpos: x, Ypos: 2},
+		FuelLevel:   40,
+	}
+}
+
+func TestPublishWritesOnlyWhatChanged(t *testing.T) {
+	publisher, server := newTestPublisher(t, 36000)
+	ctx := context.Background()
+	first, second := testVehicle("vehicle_1", 1), testVehicle("vehicle_2", 5)
+
+	result, err := publisher.Publish(ctx, Snapshot{Vehicles: []*domainmodels.Vehicle{first, second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.VehiclesWritten != 2 || result.Commands != 1 {
+		t.Fatalf("first publish wrote %d vehicles in %d commands, want 2 in 1", result.VehiclesWritten, result.Commands)
+	}
+	if keys, _ := server.HKeys("test:vehicles"); len(keys) != 2 {
+		t.Fatalf("hash holds %d vehicles, want 2", len(keys))
+	}
+
+	result, err = publisher.Publish(ctx, Snapshot{Vehicles: []*domainmodels.Vehicle{first, second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Commands != 0 {
+		t.Errorf("unchanged snapshot sent %d commands, want 0", result.Commands)
+	}
+
+	first.CurrentCell = &domainmodels.Cell{Xpos: 2, Ypos: 2}
+	result, err = publisher.Publish(ctx, Snapshot{Vehicles: []*domainmodels.Vehicle{first}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.VehiclesWritten != 1 || result.VehiclesRemoved != 1 {
+		t.Fatalf("wrote %d and removed %d vehicles, want 1 and 1", result.VehiclesWritten, result.VehiclesRemoved)
+	}
+	keys, _ := server.HKeys("test:vehicles")
+	if len(keys) != 1 || keys[0] != "vehicle_1" {
+		t.Fatalf("hash holds %v, want only vehicle_1", keys)
+	}
+	if x := publishedX(t, server.HGet("test:vehicles", "vehicle_1")); x != 2 {
+		t.Errorf("vehicle_1 published at x %v, want 2", x)
+	}
+}
+
+func TestPublishDefersPastTheBudget(t *testing.T) {
+	// A budget of 120 an hour holds two commands and refills two a minute.
+	publisher, server := newTestPublisher(t, 120)
+	ctx := context.Background()
+	vehicle := testVehicle("vehicle_1", 1)
+	snapshot := Snapshot{Vehicles: []*domainmodels.Vehicle{vehicle}, SpawnQueue: &domainmodels.RedisSpawnQueue{}}
+
+	result, err := publisher.Publish(ctx, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deferred || result.Commands != 2 {
+		t.Fatalf("first publish: deferred %v after %d commands, want 2 sent", result.Deferred, result.Commands)
+	}
+
+	vehicle.CurrentCell = &domainmodels.Cell{Xpos: 2, Ypos: 2}
+	result, err = publisher.Publish(ctx, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Deferred || result.Level != DetailPaused {
+		t.Fatalf("publish with the budget spent: %+v, want deferred while paused", result)
+	}
+	if state := server.HGet("test:vehicles", "vehicle_1"); publishedX(t, state) != 1 {
+		t.Fatal("deferred publish wrote to Redis")
+	}
+
+	publisher.now = func() time.Time { return testStart.Add(time.Minute) }
+	result, err = publisher.Publish(ctx, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deferred || result.VehiclesWritten != 1 {
+		t.Fatalf("publish after the budget refilled: %+v", result)
+	}
+	if x := publishedX(t, server.HGet("test:vehicles", "vehicle_1")); x != 2 {
+		t.Errorf("vehicle_1 published at x %v after the deferred move, want 2", x)
+	}
+}
+
+func publishedX(t *testing.T, payload string) float64 {
+	t.Helper()
+	var state domainmodels.RedisVehicleState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		t.Fatal(err)
+	}
+	return state.Lng
+}
@@ -0,0 +1,35 @@
+package movement
+
+import (
+	"math"
+	"testing"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/roadgraph/simclock"
+)
+
+func TestRefuellingLeavesStationAmountAlone(t *testing.T) {
+	perVisit := 10.0
+	station := &domainmodels.Cell{Xpos: 1, Ypos: 1, CellType: domainmodels.CellTypeRefuel, RefuelAmount: &perVisit}
+	manager := NewVehicleLifecycleManager(&domainmodels.Grid{}, nil, simclock.NewAsFastAsPossible(replayStart))
+
+	for visit := range 3 {
+		vehicle := &domainmodels.Vehicle{
+			ID:         "refueler",
+			Status:     constants.VehicleStatusRefueling,
+			Profile:    domainmodels.VehicleProfile{TankLiters: 60},
+			FuelLevel:  5,
+			RefuelStop: station,
+		}
+		for vehicle.RefuelStop != nil {
+			manager.refuelVehicle(vehicle, 1)
+		}
+		if math.Abs(vehicle.FuelLevel-(5+perVisit)) > 1e-9 {
+			t.Errorf("visit %d: left with %.1fL, want %.1fL", visit+1, vehicle.FuelLevel, 5+perVisit)
+		}
+	}
+	if *station.RefuelAmount != perVisit {
+		t.Errorf("station amount is %.1fL after three visits, want %.1fL", *station.RefuelAmount, perVisit)
+	}
+}
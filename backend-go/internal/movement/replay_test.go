@@ -0,0 +1,173 @@
+package movement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/conditions"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+	"owenvi.com/fleetsim/internal/traffic"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// replayStart pins simulated time so timestamps, and the incident expiries
+// derived from them, match between runs.
+var replayStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// world is what a seeded run is built from.
+type world struct {
+	width, height int64
+	vehicles      int
+	seed          int64
+	oneWay        bool
+	arterialLanes int64
+}
+
+// runDigest fingerprints one run: the grid as generated, every vehicle's
+// position, fuel and status after each movement step, and the grid at the end
+// with its traffic loads and road conditions.
+type runDigest struct {
+	grid         string
+	trajectories string
+	finalGrid    string
+}
+
+// generateGrid generates the grid of w the way the server does.
+func generateGrid(t testing.TB, cfg *config.SimulationConfig, w world) *domainmodels.Grid {
+	t.Helper()
+	gridLoader := gridloader.NewGridLoader()
+	gridLoader.ConfigureForTesting(w.width, w.height, w.seed, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	gridLoader.BaseRoadConditions = cfg.BaseRoadConditions
+	gridLoader.OneWayStreets = w.oneWay
+	gridLoader.ArterialLanes = w.arterialLanes
+	grid, err := gridLoader.GenerateProcedural()
+	if err != nil {
+		t.Fatalf("generating seed %d: %v", w.seed, err)
+	}
+	return grid
+}
+
+// copyGrid loads a fresh copy of a grid encoded as JSON, as if from a file.
+func copyGrid(t testing.TB, gridJSON []byte) *domainmodels.Grid {
+	t.Helper()
+	var grid domainmodels.Grid
+	if err := json.Unmarshal(gridJSON, &grid); err != nil {
+		t.Fatal(err)
+	}
+	if err := gridloader.NewGridLoader().PrepareImportedGrid(&grid, "test grid", replayStart); err != nil {
+		t.Fatal(err)
+	}
+	return &grid
+}
+
+// newWorldManager spawns the fleet of w on grid and returns its manager with
+// background traffic and road conditions, on a clock that runs as fast as
+// possible from replayStart.
+func newWorldManager(t testing.TB, cfg *config.SimulationConfig, grid *domainmodels.Grid, w world, workers int) (*VehicleLifecycleManager, *simclock.SimClock) {
+	t.Helper()
+	clock := simclock.NewAsFastAsPossible(replayStart)
+	vehicleSpawner := gridloader.NewVehicleSpawner(cfg, w.seed)
+	vehicleSpawner.SetClock(clock)
+	vehicles, err := vehicleSpawner.SpawnRandomVehicles(grid, w.vehicles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewVehicleLifecycleManager(grid, vehicles, clock)
+	manager.SetWorkers(workers)
+	if cfg.BackgroundPeakUtilization > 0 {
+		manager.SetBackgroundTraffic(traffic.NewBackgroundTraffic(cfg, w.seed))
+	}
+	manager.SetRoadConditions(conditions.NewEngine(grid, cfg, w.seed))
+	return manager, clock
+}
+
+// trajectories steps manager steps times, with a traffic and conditions tick
+// whenever a traffic interval of simulated time has passed, and hashes every
+// vehicle's state after each step.
+func trajectories(cfg *config.SimulationConfig, manager *VehicleLifecycleManager, clock simclock.Clock, steps int) string {
+	movementStep := clock.Step(time.Duration(cfg.MovementUpdateInterval) * time.Millisecond)
+	trafficStep := clock.Step(time.Duration(cfg.TrafficUpdateInterval) * time.Millisecond)
+	nextTraffic := trafficStep
+
+	h := sha256.New()
+	for step := range steps {
+		manager.UpdateAllVehicles(movementStep.Seconds())
+		writeTrajectoryStep(h, manager, step)
+
+		if clock.Elapsed() >= nextTraffic {
+			nextTraffic += trafficStep
+			manager.UpdateConditions()
+			manager.UpdateTraffic()
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay generates w and runs it for steps movement steps.
+func replay(t testing.TB, w world, steps int) runDigest {
+	t.Helper()
+	cfg := config.Config()
+	grid := generateGrid(t, cfg, w)
+	digest := runDigest{grid: hashGrid(t, grid)}
+
+	manager, clock := newWorldManager(t, cfg, grid, w, 1)
+	digest.trajectories = trajectories(cfg, manager, clock, steps)
+	digest.finalGrid = hashGrid(t, grid)
+	return digest
+}
+
+func hashGrid(t testing.TB, grid *domainmodels.Grid) string {
+	t.Helper()
+	gridJSON, err := json.Marshal(grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gridHash := sha256.Sum256(gridJSON)
+	return hex.EncodeToString(gridHash[:])
+}
+
+// writeTrajectoryStep hashes the simulated state of every vehicle.
+func writeTrajectoryStep(h hash.Hash, manager *VehicleLifecycleManager, step int) {
+	for _, vehicle := range manager.GetAllVehicles() {
+		cellX, cellY := int64(-1), int64(-1)
+		if vehicle.CurrentCell != nil {
+			cellX, cellY = vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos
+		}
+		segmentID := int64(-1)
+		if vehicle.CurrentSegment != nil {
+			segmentID = vehicle.CurrentSegment.ID
+		}
+		fmt.Fprintf(h, "%d %s %d,%d %d %x %x %x %s\n",
+			step, vehicle.ID, cellX, cellY, segmentID,
+			vehicle.SegmentProgress, vehicle.FuelLevel, vehicle.CurrentSpeedKPH, vehicle.Status)
+	}
+}
+
+func TestSameSeedReplaysIdentically(t *testing.T) {
+	worlds := map[string]world{
+		"two-way":            {width: 20, height: 20, vehicles: 25, seed: 99},
+		"one-way with lanes": {width: 20, height: 20, vehicles: 25, seed: 7, oneWay: true, arterialLanes: 2},
+	}
+	for name, w := range worlds {
+		t.Run(name, func(t *testing.T) {
+			first, second := replay(t, w, 1000), replay(t, w, 1000)
+			if first.grid != second.grid {
+				t.Error("grids generated from the same seed differ")
+			}
+			if first.trajectories != second.trajectories {
+				t.Error("trajectories from the same seed differ")
+			}
+			if first.finalGrid != second.finalGrid {
+				t.Error("traffic and road conditions from the same seed end up different")
+			}
+		})
+	}
+}
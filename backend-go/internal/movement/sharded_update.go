@@ -0,0 +1,107 @@
+package movement
+
+import (
+	"sync"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// minVehiclesPerShard keeps shards large enough that handing one to a worker
+// costs less than moving its vehicles.
+const minVehiclesPerShard = 64
+
+// speculativeMove is a position update computed on a copy of the vehicle
+// ahead of the commit phase, with the segment load it was computed against.
+type speculativeMove struct {
+	vehicle domainmodels.Vehicle
+	result  domainmodels.MovementResult
+
+	utilization  float64
+	averageSpeed float64
+	ready        bool
+}
+
+// SetWorkers spreads each movement step over up to workers goroutines. The
+// outcome is the same as with a single worker, which is the default.
+func (vlm *VehicleLifecycleManager) SetWorkers(workers int) {
+	vlm.workers = max(workers, 1)
+}
+
+func (vlm *VehicleLifecycleManager) shardCount(vehicles int) int {
+	return max(min(vlm.workers, vehicles/minVehiclesPerShard), 1)
+}
+
+// updateVehiclesSharded moves vehicles in two phases. Workers first compute
+// the position update of every moving vehicle on its own copy; those updates
+// only read the vehicle and its segment. The commit phase then walks the
+// vehicles in ID order exactly as the serial loop does, taking a precomputed
+// update when the vehicle's segment load is still the one it was computed
+// against and recomputing it otherwise, so segment entries, queueing and
+// refuelling resolve in the same order and the results match the serial loop.
+func (vlm *VehicleLifecycleManager) updateVehiclesSharded(vehicles []*domainmodels.Vehicle, timeStepSeconds float64) {
+	candidates := make([]int, 0, len(vehicles))
+	for i, vehicle := range vehicles {
+		if _, waiting := vlm.waitingSeconds[vehicle.ID]; waiting {
+			continue
+		}
+		if vehicle.Status == constants.VehicleStatusMoving && vehicle.CurrentSegment != nil {
+			candidates = append(candidates, i)
+		}
+	}
+
+	moves := make([]speculativeMove, len(vehicles))
+	forEachShard(len(candidates), vlm.shardCount(len(candidates)), func(lo, hi int) {
+		for _, i := range candidates[lo:hi] {
+			move := &moves[i]
+			load := vehicles[i].CurrentSegment.CurrentTrafficLoad
+			move.vehicle = *vehicles[i]
+			move.result = move.vehicle.UpdatePosition(timeStepSeconds, vlm.grid)
+			move.utilization, move.averageSpeed = load.CapacityUtilization, load.AverageSpeed
+			move.ready = true
+		}
+	})
+
+	for i, vehicle := range vehicles {
+		switch vehicle.Status {
+		case constants.VehicleStatusMoving:
+			if move := &moves[i]; move.ready && move.stillValid(vehicle) {
+				*vehicle = move.vehicle
+				vlm.applyMovement(vehicle, move.result)
+				continue
+			}
+			vlm.updateSingleVehicle(vehicle, timeStepSeconds)
+		case constants.VehicleStatusRefueling:
+			vlm.refuelVehicle(vehicle, timeStepSeconds)
+		}
+	}
+}
+
+// stillValid reports whether the vehicle's segment load is unchanged since the
+// move was computed. Vehicles committed earlier in the step may have entered
+// or left that segment, which changes the speed the vehicle gets.
+func (move *speculativeMove) stillValid(vehicle *domainmodels.Vehicle) bool {
+	load := vehicle.CurrentSegment.CurrentTrafficLoad
+	return load.CapacityUtilization == move.utilization && load.AverageSpeed == move.averageSpeed
+}
+
+// forEachShard splits n items into shards contiguous ranges and runs fn on
+// each in its own goroutine, returning once all of them are done.
+func forEachShard(n, shards int, fn func(lo, hi int)) {
+	if shards <= 1 {
+		fn(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	size := (n + shards - 1) / shards
+	for lo := 0; lo < n; lo += size {
+		hi := min(lo+size, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,66 @@
+package movement
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// The sharded update must leave every vehicle exactly where the serial loop
+// does. Run with -race to check the workers for data races as well.
+func TestShardedUpdateMatchesSerial(t *testing.T) {
+	cfg := config.Config()
+	// Enough vehicles for four shards.
+	w := world{width: 20, height: 20, vehicles: 4 * minVehiclesPerShard, seed: 99}
+	gridJSON, err := json.Marshal(generateGrid(t, cfg, w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(workers int) string {
+		manager, clock := newWorldManager(t, cfg, copyGrid(t, gridJSON), w, workers)
+		return trajectories(cfg, manager, clock, 200)
+	}
+
+	serial := run(1)
+	for _, workers := range []int{2, 4} {
+		if run(workers) != serial {
+			t.Errorf("trajectories with %d workers differ from the serial loop", workers)
+		}
+	}
+}
+
+const benchmarkStepsPerFleet = 200
+
+func BenchmarkUpdateAllVehicles(b *testing.B) {
+	cfg := config.Config()
+	w := world{width: 30, height: 30, vehicles: 1000, seed: 99}
+	// Generating the grid costs far more than the steps themselves, so it is
+	// generated once and every run starts from a fresh copy.
+	gridJSON, err := json.Marshal(generateGrid(b, cfg, w))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			var manager *VehicleLifecycleManager
+			var stepSeconds float64
+			for i := range b.N {
+				// Vehicles finish their trips, so the fleet is respawned
+				// every few hundred steps to keep the load steady.
+				if i%benchmarkStepsPerFleet == 0 {
+					b.StopTimer()
+					var clock simclock.Clock
+					manager, clock = newWorldManager(b, cfg, copyGrid(b, gridJSON), w, workers)
+					stepSeconds = clock.Step(time.Duration(cfg.MovementUpdateInterval) * time.Millisecond).Seconds()
+					b.StartTimer()
+				}
+				manager.UpdateAllVehicles(stepSeconds)
+			}
+		})
+	}
+}
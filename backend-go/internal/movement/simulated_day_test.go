@@ -0,0 +1,77 @@
+package movement
+
+import (
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/conditions"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+	"owenvi.com/fleetsim/internal/traffic"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// checkTrafficStamps fails unless every segment's traffic state was last
+// updated between from and to.
+func checkTrafficStamps(t *testing.T, grid *domainmodels.Grid, from, to time.Time) {
+	t.Helper()
+	for segmentID := range grid.SegmentIndex {
+		stamp := grid.GetSegment(segmentID).CurrentTrafficLoad.LastUpdated
+		if stamp.Before(from) || stamp.After(to) {
+			t.Fatalf("segment %d traffic stamped %v, want between %v and %v", segmentID, stamp, from, to)
+		}
+	}
+}
+
+func TestSimulatedDayRunsOnTheClock(t *testing.T) {
+	const day = 24 * time.Hour
+	cfg := config.Config()
+	w := world{width: 20, height: 20, vehicles: 25, seed: 3}
+	grid := generateGrid(t, cfg, w)
+
+	// Each movement tick covers a minute of simulated time per real second.
+	clock, err := simclock.New(simclock.AsFastAsPossible, 60, replayStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vehicleSpawner := gridloader.NewVehicleSpawner(cfg, w.seed)
+	vehicleSpawner.SetClock(clock)
+	vehicles, err := vehicleSpawner.SpawnRandomVehicles(grid, w.vehicles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewVehicleLifecycleManager(grid, vehicles, clock)
+	manager.SetBackgroundTraffic(traffic.NewBackgroundTraffic(cfg, w.seed))
+	manager.SetRoadConditions(conditions.NewEngine(grid, cfg, w.seed))
+
+	checkTrafficStamps(t, grid, replayStart, replayStart)
+	for _, vehicle := range manager.GetAllVehicles() {
+		if vehicle.SpawnedAt == nil || !vehicle.SpawnedAt.Equal(replayStart) {
+			t.Fatalf("vehicle %s spawned at %v, want %v", vehicle.ID, vehicle.SpawnedAt, replayStart)
+		}
+	}
+
+	movementStep := clock.Step(time.Duration(cfg.MovementUpdateInterval) * time.Millisecond)
+	trafficStep := clock.Step(time.Duration(cfg.TrafficUpdateInterval) * time.Millisecond)
+	nextTraffic := trafficStep
+	started := time.Now()
+	for clock.Elapsed() < day {
+		manager.UpdateAllVehicles(movementStep.Seconds())
+		if clock.Elapsed() >= nextTraffic {
+			nextTraffic += trafficStep
+			manager.UpdateConditions()
+			manager.UpdateTraffic()
+		}
+	}
+	t.Logf("simulated %v in %v", clock.Elapsed(), time.Since(started))
+
+	end := replayStart.Add(day)
+	if !clock.Now().Equal(end) {
+		t.Errorf("clock reads %v after a day of steps, want %v", clock.Now(), end)
+	}
+	if got := manager.SimulatedSeconds(); got != day.Seconds() {
+		t.Errorf("manager simulated %.0fs, want %.0fs", got, day.Seconds())
+	}
+	checkTrafficStamps(t, grid, end.Add(-trafficStep), end)
+}
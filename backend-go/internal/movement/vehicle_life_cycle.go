@@ -2,86 +2,432 @@ package movement
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"time"
 
+	"owenvi.com/fleetsim/internal/conditions"
 	"owenvi.com/fleetsim/internal/constants"
 	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/routing"
+	"owenvi.com/fleetsim/internal/traffic"
+	"owenvi.com/roadgraph/simclock"
 )
 
+// refuelLitersPerSecond is the pump rate at refuel cells.
+const refuelLitersPerSecond = 0.8
+
+// maxEntryWaitSeconds is how long a vehicle queues for a full segment before
+// it asks the router for a way around it.
+const maxEntryWaitSeconds = 60.0
+
 type VehicleLifecycleManager struct {
 	grid     *domainmodels.Grid
+	router   *routing.Router
+	traffic  *traffic.Engine
 	vehicles map[string]*domainmodels.Vehicle
+
+	background     *traffic.BackgroundTraffic
+	roadConditions *conditions.Engine
+	clock          simclock.Clock
+	workers        int
+	logOutput      io.Writer
+
+	// noStationInRange marks vehicles already found to have no refuel cell in
+	// range, so they are not re-planned on every segment.
+	noStationInRange map[string]bool
+	// waitingSeconds holds vehicles queued in front of a full segment and how
+	// long they have been waiting.
+	waitingSeconds map[string]float64
+	// refueledLiters holds what each refuelling vehicle has taken on at its
+	// current stop, to hold it to the station's per-visit amount.
+	refueledLiters map[string]float64
 }
 
-func NewVehicleLifecycleManager(grid *domainmodels.Grid, vehicles []domainmodels.Vehicle) *VehicleLifecycleManager {
-    vehicleMap := make(map[string]*domainmodels.Vehicle)
-    
-    for i := range vehicles {
-        vehicleCopy := vehicles[i]
-        vehicle := &vehicleCopy
-        
-        
-        fmt.Printf("Debug - Vehicle %s: CurrentCell=%v, DestinationCell=%v\n", 
-            vehicle.ID, 
-            vehicle.CurrentCell != nil,
-            vehicle.DestinationCell != nil)
-
-        
-        if vehicle.CurrentCell == nil {
-            fmt.Printf("Warning: Vehicle %s has no current cell\n", vehicle.ID)
-            continue
-        }
-        
-        if vehicle.DestinationCell == nil {
-            fmt.Printf("Warning: Vehicle %s has no destination cell\n", vehicle.ID)
-            continue
-        }
-        
-        if len(vehicle.CurrentCell.RoadSegments) > 0 {
-            vehicle.CurrentSegment = &vehicle.CurrentCell.RoadSegments[0].RoadSegment
-            vehicle.SegmentProgress = 0.0
-            vehicle.Status = constants.VehicleStatusMoving
-            fmt.Printf("Successfully initialized vehicle %s\n", vehicle.ID)
-        } else {
-            fmt.Printf("Warning: Vehicle %s has no road segments at (%d,%d)\n", 
-                vehicle.ID, vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos)
-        }
-        vehicleMap[vehicle.ID] = vehicle
-    }
-    
-    fmt.Printf("Initialized %d out of %d vehicles\n", len(vehicleMap), len(vehicles))
-    
-    return &VehicleLifecycleManager{
-        grid:     grid,
-        vehicles: vehicleMap,
-    }
+// NewVehicleLifecycleManager routes vehicles across grid on clock, the run's
+// simulated time. The manager advances the clock by every movement step, so
+// nothing else should.
+func NewVehicleLifecycleManager(grid *domainmodels.Grid, vehicles []domainmodels.Vehicle, clock simclock.Clock) *VehicleLifecycleManager {
+	vehicleMap := make(map[string]*domainmodels.Vehicle)
+	manager := &VehicleLifecycleManager{
+		grid:             grid,
+		router:           routing.NewRouter(grid),
+		traffic:          traffic.NewEngine(grid, clock.Now()),
+		vehicles:         vehicleMap,
+		clock:            clock,
+		workers:          1,
+		noStationInRange: make(map[string]bool),
+		waitingSeconds:   make(map[string]float64),
+		refueledLiters:   make(map[string]float64),
+	}
+
+	for i := range vehicles {
+		vehicleCopy := vehicles[i]
+		vehicle := &vehicleCopy
+		if vehicle.CurrentCell == nil || vehicle.DestinationCell == nil {
+			continue
+		}
+		// A vehicle that cannot be routed is kept, failed, so it still shows.
+		_ = manager.assignRoute(vehicle)
+		vehicleMap[vehicle.ID] = vehicle
+	}
+
+	return manager
 }
 
 func (vlm *VehicleLifecycleManager) UpdateAllVehicles(timeStepSeconds float64) {
-	for _, vehicle := range vlm.vehicles {
-		if vehicle.Status == constants.VehicleStatusMoving {
+	vlm.clock.Advance(time.Duration(timeStepSeconds * float64(time.Second)))
+	// Vehicles move in ID order: segment capacity is first come, first served,
+	// so map order would make runs with the same seed diverge.
+	vehicles := vlm.GetAllVehicles()
+	if vlm.shardCount(len(vehicles)) > 1 {
+		vlm.updateVehiclesSharded(vehicles, timeStepSeconds)
+		return
+	}
+	for _, vehicle := range vehicles {
+		switch vehicle.Status {
+		case constants.VehicleStatusMoving:
 			vlm.updateSingleVehicle(vehicle, timeStepSeconds)
+		case constants.VehicleStatusRefueling:
+			vlm.refuelVehicle(vehicle, timeStepSeconds)
 		}
 	}
 }
 
+// SetLogOutput makes the manager write a line to w whenever a vehicle changes
+// state: refuelling, queueing, replanning, finishing. It is silent by default.
+func (vlm *VehicleLifecycleManager) SetLogOutput(w io.Writer) {
+	vlm.logOutput = w
+}
+
+func (vlm *VehicleLifecycleManager) logf(format string, args ...any) {
+	if vlm.logOutput == nil {
+		return
+	}
+	fmt.Fprintf(vlm.logOutput, format+"\n", args...)
+}
+
+// SetBackgroundTraffic adds aggregate non-fleet load to every segment from the
+// next traffic update on.
+func (vlm *VehicleLifecycleManager) SetBackgroundTraffic(background *traffic.BackgroundTraffic) {
+	vlm.background = background
+}
+
+// Clock is the run's simulated time.
+func (vlm *VehicleLifecycleManager) Clock() simclock.Clock {
+	return vlm.clock
+}
+
+// SetRoadConditions lets the manager raise and expire temporary road
+// conditions on the condition tick.
+func (vlm *VehicleLifecycleManager) SetRoadConditions(engine *conditions.Engine) {
+	vlm.roadConditions = engine
+}
+
+// UpdateConditions applies incident changes and returns them for broadcast.
+func (vlm *VehicleLifecycleManager) UpdateConditions() []domainmodels.ConditionUpdateMessage {
+	if vlm.roadConditions == nil {
+		return nil
+	}
+	return vlm.roadConditions.Update(vlm.clock.Now())
+}
+
+// UpdateTraffic refreshes segment loads; it runs on the traffic tick.
+func (vlm *VehicleLifecycleManager) UpdateTraffic() {
+	if vlm.background != nil {
+		vlm.background.Update(vlm.grid, vlm.SimulatedSeconds())
+	}
+	vlm.traffic.Update(vlm.clock.Now())
+}
+
 func (vlm *VehicleLifecycleManager) updateSingleVehicle(vehicle *domainmodels.Vehicle, timeStepSeconds float64) {
-    if vehicle.CurrentSegment == nil {
-        fmt.Printf("Vehicle %s has no current segment\n", vehicle.ID)
-        vehicle.Status = constants.VehicleStatusCompleted
-        return
-    }
-    
-    result := vehicle.UpdatePosition(timeStepSeconds, vlm.grid)
-    
-    if result.ReachedDestination {
-        fmt.Printf("Vehicle %s reached destination!\n", vehicle.ID)
-        return
-    }
-    
-    if result.ReachedSegmentEnd {
-        vehicle.Status = constants.VehicleStatusCompleted
-        fmt.Printf("Vehicle %s reached end of segment\n", vehicle.ID)
-    }
+	if _, waiting := vlm.waitingSeconds[vehicle.ID]; waiting {
+		vlm.retryWaitingVehicle(vehicle, timeStepSeconds)
+		return
+	}
+
+	if vehicle.CurrentSegment == nil {
+		vlm.logf("Vehicle %s has no current segment", vehicle.ID)
+		vlm.completeVehicle(vehicle)
+		return
+	}
+
+	vlm.applyMovement(vehicle, vehicle.UpdatePosition(timeStepSeconds, vlm.grid))
+}
+
+// applyMovement acts on where a position update left the vehicle: out of
+// fuel, at its destination or at the end of its segment.
+func (vlm *VehicleLifecycleManager) applyMovement(vehicle *domainmodels.Vehicle, result domainmodels.MovementResult) {
+	if result.OutOfFuel {
+		x, y := int64(-1), int64(-1)
+		if vehicle.CurrentCell != nil {
+			x, y = vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos
+		}
+		vlm.logf("Vehicle %s ran out of fuel at (%d,%d)", vehicle.ID, x, y)
+		vlm.failVehicle(vehicle, fmt.Sprintf("ran out of fuel at (%d,%d)", x, y))
+		return
+	}
+
+	if result.ReachedDestination {
+		vlm.logf("Vehicle %s reached destination!", vehicle.ID)
+		vlm.completeVehicle(vehicle)
+		return
+	}
+
+	if result.ReachedSegmentEnd {
+		vlm.advanceToNextSegment(vehicle)
+	}
+}
+
+func (vlm *VehicleLifecycleManager) assignRoute(vehicle *domainmodels.Vehicle) error {
+	var path []int64
+	if vehicle.RefuelStop != nil {
+		stationPath, err := vlm.router.PlanRoute(vehicle.CurrentCell, vehicle.RefuelStop)
+		if err != nil {
+			vlm.failVehicle(vehicle, err.Error())
+			return err
+		}
+		path = stationPath
+	} else {
+		route, err := vlm.router.PlanFuelAwareRoute(vehicle.CurrentCell, vehicle.DestinationCell,
+			vehicle.RemainingRangeKM(), vehicle.RequireFuelStop)
+		if err != nil {
+			vlm.failVehicle(vehicle, err.Error())
+			return err
+		}
+		path = route.Path
+		vehicle.RefuelStop = route.RefuelStop
+		vlm.noStationInRange[vehicle.ID] = route.RefuelStop == nil &&
+			route.LengthKM > routing.UsableRangeKM(vehicle.RemainingRangeKM())
+		if route.RefuelStop != nil {
+			vlm.logf("Vehicle %s routing via refuel station at (%d,%d)",
+				vehicle.ID, route.RefuelStop.Xpos, route.RefuelStop.Ypos)
+		}
+	}
+
+	if len(path) == 0 {
+		if vehicle.RefuelStop != nil {
+			vlm.startRefueling(vehicle)
+			return nil
+		}
+		vlm.completeVehicle(vehicle)
+		return nil
+	}
+
+	if vlm.grid.GetSegment(path[0]) == nil {
+		err := fmt.Errorf("route references unknown segment %d", path[0])
+		vlm.failVehicle(vehicle, err.Error())
+		return err
+	}
+
+	vehicle.PlannedPath = path
+	vehicle.Status = constants.VehicleStatusMoving
+	vlm.enterNextSegment(vehicle, vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos, 0)
+	return nil
+}
+
+func (vlm *VehicleLifecycleManager) advanceToNextSegment(vehicle *domainmodels.Vehicle) {
+	exitX, exitY := vehicle.GetSegmentExitPoint()
+	if exitCell := vlm.grid.CoordIndex[[2]int64{exitX, exitY}]; exitCell != nil {
+		vehicle.CurrentCell = exitCell
+	}
+
+	if vehicle.HasReachedDestination() {
+		vlm.logf("Vehicle %s reached destination!", vehicle.ID)
+		vlm.completeVehicle(vehicle)
+		return
+	}
+
+	if vehicle.RefuelStop != nil && vehicle.CurrentCell != nil &&
+		vehicle.CurrentCell.Xpos == vehicle.RefuelStop.Xpos && vehicle.CurrentCell.Ypos == vehicle.RefuelStop.Ypos {
+		vlm.startRefueling(vehicle)
+		return
+	}
+
+	if vehicle.RefuelStop == nil && !vlm.noStationInRange[vehicle.ID] &&
+		vlm.router.PathLengthKM(vehicle.PlannedPath) > routing.UsableRangeKM(vehicle.RemainingRangeKM()) {
+		vlm.logf("Vehicle %s cannot finish its route on %.1fL, looking for fuel", vehicle.ID, vehicle.FuelLevel)
+		if err := vlm.assignRoute(vehicle); err != nil {
+			vlm.logf("Vehicle %s could not replan: %v", vehicle.ID, err)
+		}
+		return
+	}
+
+	if len(vehicle.PlannedPath) == 0 {
+		vlm.logf("Vehicle %s ran out of planned route at (%d,%d), replanning", vehicle.ID, exitX, exitY)
+		if err := vlm.assignRoute(vehicle); err != nil {
+			vlm.logf("Vehicle %s could not replan: %v", vehicle.ID, err)
+		}
+		return
+	}
+
+	next := vlm.grid.GetSegment(vehicle.PlannedPath[0])
+	if next == nil || !next.IsOpen || !next.CanLeave(exitX, exitY) {
+		vlm.logf("Vehicle %s planned segment is no longer reachable, replanning", vehicle.ID)
+		if err := vlm.assignRoute(vehicle); err != nil {
+			vlm.logf("Vehicle %s could not replan: %v", vehicle.ID, err)
+		}
+		return
+	}
+
+	overshootKM := 0.0
+	if vehicle.CurrentSegment != nil && vehicle.Progress > 1.0 {
+		overshootKM = (vehicle.Progress - 1.0) * vehicle.CurrentSegment.LengthKM
+	}
+
+	vlm.enterNextSegment(vehicle, exitX, exitY, overshootKM)
+}
+
+// enterNextSegment moves the vehicle from (fromX,fromY) onto the first segment
+// of its planned path. A full segment cannot be entered; the vehicle then
+// queues where it is and tries again on later ticks.
+func (vlm *VehicleLifecycleManager) enterNextSegment(vehicle *domainmodels.Vehicle, fromX, fromY int64, overshootKM float64) {
+	next := vlm.grid.GetSegment(vehicle.PlannedPath[0])
+	if !vehicle.CanEnterSegment(next, fromX, fromY) {
+		if _, waiting := vlm.waitingSeconds[vehicle.ID]; !waiting {
+			vlm.logf("Vehicle %s waiting at (%d,%d) for segment %d to clear", vehicle.ID, fromX, fromY, next.ID)
+			vlm.waitingSeconds[vehicle.ID] = 0
+		}
+		vehicle.CurrentSpeedKPH = 0
+		if vehicle.CurrentSegment != nil {
+			vehicle.Progress = min(vehicle.Progress, 1.0)
+			vehicle.SegmentProgress = vehicle.Progress
+		}
+		return
+	}
+	delete(vlm.waitingSeconds, vehicle.ID)
+
+	vehicle.EnterSegment(next, fromX, fromY)
+	vlm.traffic.Enter(vehicle, next)
+	vehicle.PlannedPath = vehicle.PlannedPath[1:]
+
+	if overshootKM > 0 && next.LengthKM > 0 {
+		vehicle.Progress = min(overshootKM/next.LengthKM, 0.99)
+		vehicle.SegmentProgress = vehicle.Progress
+	}
+}
+
+func (vlm *VehicleLifecycleManager) retryWaitingVehicle(vehicle *domainmodels.Vehicle, timeStepSeconds float64) {
+	waited := vlm.waitingSeconds[vehicle.ID] + timeStepSeconds
+	vlm.waitingSeconds[vehicle.ID] = waited
+
+	if waited >= maxEntryWaitSeconds || len(vehicle.PlannedPath) == 0 {
+		vlm.logf("Vehicle %s gave up waiting after %.0fs, replanning", vehicle.ID, waited)
+		delete(vlm.waitingSeconds, vehicle.ID)
+		if err := vlm.assignRoute(vehicle); err != nil {
+			vlm.logf("Vehicle %s could not replan: %v", vehicle.ID, err)
+		}
+		return
+	}
+
+	next := vlm.grid.GetSegment(vehicle.PlannedPath[0])
+	if next == nil || !next.IsOpen {
+		delete(vlm.waitingSeconds, vehicle.ID)
+		if err := vlm.assignRoute(vehicle); err != nil {
+			vlm.logf("Vehicle %s could not replan: %v", vehicle.ID, err)
+		}
+		return
+	}
+
+	vlm.enterNextSegment(vehicle, vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos, 0)
+}
+
+func (vlm *VehicleLifecycleManager) startRefueling(vehicle *domainmodels.Vehicle) {
+	vlm.traffic.Leave(vehicle)
+	vehicle.Status = constants.VehicleStatusRefueling
+	vehicle.CurrentSpeedKPH = 0
+	vehicle.PlannedPath = nil
+	vlm.logf("Vehicle %s refueling at (%d,%d) with %.1fL in the tank",
+		vehicle.ID, vehicle.RefuelStop.Xpos, vehicle.RefuelStop.Ypos, vehicle.FuelLevel)
+}
+
+func (vlm *VehicleLifecycleManager) refuelVehicle(vehicle *domainmodels.Vehicle, timeStepSeconds float64) {
+	station := vehicle.RefuelStop
+	if station == nil {
+		vlm.resumeAfterRefuel(vehicle)
+		return
+	}
+
+	// RefuelAmount is what the station hands out per visit, not a stock: the
+	// cell is shared by every vehicle that stops there.
+	liters := refuelLitersPerSecond * timeStepSeconds
+	visitDone := false
+	if station.RefuelAmount != nil {
+		if left := *station.RefuelAmount - vlm.refueledLiters[vehicle.ID]; liters >= left {
+			liters = max(left, 0)
+			visitDone = true
+		}
+	}
+	vlm.refueledLiters[vehicle.ID] += vehicle.Refuel(liters)
+
+	if vehicle.FuelLevel >= vehicle.Profile.TankLiters || visitDone {
+		vlm.resumeAfterRefuel(vehicle)
+	}
+}
+
+func (vlm *VehicleLifecycleManager) resumeAfterRefuel(vehicle *domainmodels.Vehicle) {
+	vlm.logf("Vehicle %s finished refueling with %.1fL", vehicle.ID, vehicle.FuelLevel)
+	vehicle.RefuelStop = nil
+	vehicle.RequireFuelStop = false
+	delete(vlm.noStationInRange, vehicle.ID)
+	delete(vlm.refueledLiters, vehicle.ID)
+	if err := vlm.assignRoute(vehicle); err != nil {
+		vlm.logf("Vehicle %s could not route after refueling: %v", vehicle.ID, err)
+	}
+}
+
+func (vlm *VehicleLifecycleManager) completeVehicle(vehicle *domainmodels.Vehicle) {
+	vlm.traffic.Leave(vehicle)
+	delete(vlm.waitingSeconds, vehicle.ID)
+	vehicle.Status = constants.VehicleStatusCompleted
+	vehicle.PlannedPath = nil
+}
+
+func (vlm *VehicleLifecycleManager) failVehicle(vehicle *domainmodels.Vehicle, reason string) {
+	vlm.traffic.Leave(vehicle)
+	delete(vlm.waitingSeconds, vehicle.ID)
+	vehicle.Status = constants.VehicleStatusFailed
+	vehicle.FailureReason = &reason
+}
+
+func (vlm *VehicleLifecycleManager) AddVehicle(vehicle domainmodels.Vehicle) (*domainmodels.Vehicle, error) {
+	if vehicle.CurrentCell == nil || vehicle.DestinationCell == nil {
+		return nil, fmt.Errorf("vehicle %s needs both a current and a destination cell", vehicle.ID)
+	}
+	if _, exists := vlm.vehicles[vehicle.ID]; exists {
+		return nil, fmt.Errorf("vehicle %s is already managed", vehicle.ID)
+	}
+
+	managed := &vehicle
+	if err := vlm.assignRoute(managed); err != nil {
+		return nil, err
+	}
+	vlm.vehicles[managed.ID] = managed
+	return managed, nil
+}
+
+func (vlm *VehicleLifecycleManager) GetVehicle(vehicleID string) *domainmodels.Vehicle {
+	return vlm.vehicles[vehicleID]
+}
+
+func (vlm *VehicleLifecycleManager) GetAllVehicles() []*domainmodels.Vehicle {
+	all := make([]*domainmodels.Vehicle, 0, len(vlm.vehicles))
+	for _, vehicle := range vlm.vehicles {
+		all = append(all, vehicle)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+	return all
+}
+
+func (vlm *VehicleLifecycleManager) GetGrid() *domainmodels.Grid {
+	return vlm.grid
+}
+
+// SimulatedSeconds is the simulated time elapsed since the run started.
+func (vlm *VehicleLifecycleManager) SimulatedSeconds() float64 {
+	return vlm.clock.Elapsed().Seconds()
 }
 
 func (vlm *VehicleLifecycleManager) GetActiveVehicles() []*domainmodels.Vehicle {
@@ -94,8 +440,27 @@ func (vlm *VehicleLifecycleManager) GetActiveVehicles() []*domainmodels.Vehicle
 	return active
 }
 
+// GetUnfinishedVehicles returns the vehicles that have not completed, failed
+// or been removed, whether they are moving, refuelling or waiting.
+func (vlm *VehicleLifecycleManager) GetUnfinishedVehicles() []*domainmodels.Vehicle {
+	var unfinished []*domainmodels.Vehicle
+	for _, vehicle := range vlm.vehicles {
+		switch vehicle.Status {
+		case constants.VehicleStatusCompleted, constants.VehicleStatusRemoved, constants.VehicleStatusFailed:
+		default:
+			unfinished = append(unfinished, vehicle)
+		}
+	}
+	return unfinished
+}
+
+// CountUnfinishedVehicles counts the vehicles GetUnfinishedVehicles returns.
+func (vlm *VehicleLifecycleManager) CountUnfinishedVehicles() int {
+	return len(vlm.GetUnfinishedVehicles())
+}
+
 func (vlm *VehicleLifecycleManager) PrintCurrentState() {
-	
+
 	fmt.Println("Current vehicle positions:")
 	for _, vehicle := range vlm.vehicles {
 		if vehicle.Status == constants.VehicleStatusMoving && vehicle.CurrentCell != nil {
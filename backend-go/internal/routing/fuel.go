@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/utils"
+)
+
+// fuelReserveFraction keeps part of the range unplanned to absorb condition and
+// traffic multipliers on fuel consumption.
+const fuelReserveFraction = 0.15
+
+// maxRefuelCandidates bounds how many stations, nearest detour first, get a
+// full route evaluation.
+const maxRefuelCandidates = 8
+
+// FuelRoute is the leg a vehicle should drive next. RefuelStop is set when the
+// leg ends at a refuel cell rather than the destination.
+type FuelRoute struct {
+	Path       []int64
+	RefuelStop *domainmodels.Cell
+	LengthKM   float64
+}
+
+func UsableRangeKM(rangeKM float64) float64 {
+	return rangeKM * (1 - fuelReserveFraction)
+}
+
+// PlanFuelAwareRoute routes directly when the usable range covers the trip and
+// requireStop is false. Otherwise it routes to the refuel cell that is in range
+// and keeps the whole trip shortest. When no refuel cell is in range the direct
+// route is returned with a nil RefuelStop; the vehicle may run dry on it.
+func (r *Router) PlanFuelAwareRoute(origin, destination *domainmodels.Cell, rangeKM float64, requireStop bool) (*FuelRoute, error) {
+	direct, err := r.PlanRoute(origin, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	usable := UsableRangeKM(rangeKM)
+	directLength := r.PathLengthKM(direct)
+	if !requireStop && directLength <= usable {
+		return &FuelRoute{Path: direct, LengthKM: directLength}, nil
+	}
+
+	var best *FuelRoute
+	bestTotal := math.Inf(1)
+	for _, station := range r.refuelCandidates(origin, destination) {
+		toStation, err := r.PlanRoute(origin, station)
+		if err != nil {
+			continue
+		}
+		stationLength := r.PathLengthKM(toStation)
+		if stationLength > usable {
+			continue
+		}
+
+		onward, err := r.PlanRoute(station, destination)
+		if err != nil {
+			continue
+		}
+		total := stationLength + r.PathLengthKM(onward)
+		if total < bestTotal {
+			bestTotal = total
+			best = &FuelRoute{Path: toStation, RefuelStop: station, LengthKM: stationLength}
+		}
+	}
+
+	if best != nil {
+		return best, nil
+	}
+	if requireStop {
+		return nil, &RouteError{
+			FromX: origin.Xpos, FromY: origin.Ypos,
+			ToX: destination.Xpos, ToY: destination.Ypos,
+			Details: fmt.Sprintf("no refuel station reachable within %.1f km", usable),
+		}
+	}
+	return &FuelRoute{Path: direct, LengthKM: directLength}, nil
+}
+
+func (r *Router) PathLengthKM(path []int64) float64 {
+	total := 0.0
+	for _, segmentID := range path {
+		if segment := r.grid.GetSegment(segmentID); segment != nil {
+			total += SegmentLengthKM(segment)
+		}
+	}
+	return total
+}
+
+func (r *Router) refuelCandidates(origin, destination *domainmodels.Cell) []*domainmodels.Cell {
+	var stations []*domainmodels.Cell
+	for i := range r.grid.Cells {
+		cell := &r.grid.Cells[i]
+		if cell.CellType != domainmodels.CellTypeRefuel || len(cell.RoadSegments) == 0 {
+			continue
+		}
+		if cell.RefuelAmount != nil && *cell.RefuelAmount <= 0 {
+			continue
+		}
+		if cell.Xpos == origin.Xpos && cell.Ypos == origin.Ypos {
+			continue
+		}
+		stations = append(stations, cell)
+	}
+
+	detour := func(cell *domainmodels.Cell) int64 {
+		return utils.ManhattanDistance(origin.Xpos, origin.Ypos, cell.Xpos, cell.Ypos) +
+			utils.ManhattanDistance(cell.Xpos, cell.Ypos, destination.Xpos, destination.Ypos)
+	}
+	sort.SliceStable(stations, func(i, j int) bool {
+		return detour(stations[i]) < detour(stations[j])
+	})
+	if len(stations) > maxRefuelCandidates {
+		stations = stations[:maxRefuelCandidates]
+	}
+	return stations
+}
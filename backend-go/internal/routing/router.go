@@ -0,0 +1,230 @@
+package routing
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const (
+	defaultRouteSpeedKPH = 50.0
+	maxRouteSpeedKPH     = 130.0
+	minKMPerGridUnit     = 0.4
+)
+
+type Router struct {
+	grid *domainmodels.Grid
+}
+
+func NewRouter(grid *domainmodels.Grid) *Router {
+	return &Router{grid: grid}
+}
+
+type RouteError struct {
+	FromX, FromY int64
+	ToX, ToY     int64
+	Details      string
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("[RouteError]: (%d,%d) -> (%d,%d): %s", e.FromX, e.FromY, e.ToX, e.ToY, e.Details)
+}
+
+// routeState is a segment together with the endpoint the vehicle leaves it by,
+// since the same segment can be driven in either direction.
+type routeState struct {
+	segmentID int64
+	exit      [2]int64
+}
+
+type routeItem struct {
+	state    routeState
+	priority float64
+	index    int
+}
+
+type routeQueue []*routeItem
+
+func (pq routeQueue) Len() int           { return len(pq) }
+func (pq routeQueue) Less(i, j int) bool { return pq[i].priority < pq[j].priority }
+func (pq routeQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+func (pq *routeQueue) Push(x any) {
+	item := x.(*routeItem)
+	item.index = len(*pq)
+	*pq = append(*pq, item)
+}
+func (pq *routeQueue) Pop() any {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*pq = old[0 : n-1]
+	return item
+}
+
+func (r *Router) PlanRoute(origin, destination *domainmodels.Cell) ([]int64, error) {
+	if origin == nil || destination == nil {
+		return nil, fmt.Errorf("route requires both an origin and a destination cell")
+	}
+
+	routeErr := func(details string) error {
+		return &RouteError{
+			FromX: origin.Xpos, FromY: origin.Ypos,
+			ToX: destination.Xpos, ToY: destination.Ypos,
+			Details: details,
+		}
+	}
+
+	if origin.Xpos == destination.Xpos && origin.Ypos == destination.Ypos {
+		return []int64{}, nil
+	}
+	if r.grid.RoadGraph == nil {
+		return nil, routeErr("grid has no road graph")
+	}
+
+	goal := [2]int64{destination.Xpos, destination.Ypos}
+
+	openSet := &routeQueue{}
+	heap.Init(openSet)
+
+	cost := make(map[routeState]float64)
+	cameFrom := make(map[routeState]routeState)
+
+	for _, cellRoad := range origin.RoadSegments {
+		segment := r.grid.GetSegment(cellRoad.RoadSegmentID)
+		if segment == nil || !segment.HasEndpoint(origin.Xpos, origin.Ypos) {
+			continue
+		}
+		if !r.isTraversable(segment, origin.Xpos, origin.Ypos) {
+			continue
+		}
+		exitX, exitY := segment.OtherEndpoint(origin.Xpos, origin.Ypos)
+		state := routeState{segmentID: segment.ID, exit: [2]int64{exitX, exitY}}
+		stateCost := SegmentCost(segment)
+		if existing, seen := cost[state]; seen && existing <= stateCost {
+			continue
+		}
+		cost[state] = stateCost
+		heap.Push(openSet, &routeItem{state: state, priority: stateCost + r.heuristic(state.exit, goal)})
+	}
+
+	if openSet.Len() == 0 {
+		return nil, routeErr("origin has no usable road segments")
+	}
+
+	closed := make(map[routeState]bool)
+
+	for openSet.Len() > 0 {
+		current := heap.Pop(openSet).(*routeItem).state
+		if closed[current] {
+			continue
+		}
+		closed[current] = true
+
+		if current.exit == goal {
+			return r.reconstructPath(cameFrom, current), nil
+		}
+
+		for _, neighborID := range r.grid.RoadGraph.Adjacency[current.segmentID] {
+			if neighborID == current.segmentID {
+				continue
+			}
+			neighbor := r.grid.GetSegment(neighborID)
+			if neighbor == nil || !neighbor.HasEndpoint(current.exit[0], current.exit[1]) {
+				continue
+			}
+			if !r.isTraversable(neighbor, current.exit[0], current.exit[1]) {
+				continue
+			}
+
+			exitX, exitY := neighbor.OtherEndpoint(current.exit[0], current.exit[1])
+			next := routeState{segmentID: neighbor.ID, exit: [2]int64{exitX, exitY}}
+			if closed[next] {
+				continue
+			}
+
+			tentative := cost[current] + SegmentCost(neighbor)
+			if existing, seen := cost[next]; seen && existing <= tentative {
+				continue
+			}
+			cost[next] = tentative
+			cameFrom[next] = current
+			heap.Push(openSet, &routeItem{state: next, priority: tentative + r.heuristic(next.exit, goal)})
+		}
+	}
+
+	return nil, routeErr("no connected route found")
+}
+
+func (r *Router) reconstructPath(cameFrom map[routeState]routeState, last routeState) []int64 {
+	path := []int64{last.segmentID}
+	current := last
+	for {
+		prev, ok := cameFrom[current]
+		if !ok {
+			break
+		}
+		path = append(path, prev.segmentID)
+		current = prev
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func (r *Router) isTraversable(segment *domainmodels.RoadSegment, fromX, fromY int64) bool {
+	if !segment.IsOpen || !segment.CanLeave(fromX, fromY) {
+		return false
+	}
+
+	toX, toY := segment.OtherEndpoint(fromX, fromY)
+	target := r.grid.CoordIndex[[2]int64{toX, toY}]
+	if target == nil || target.CellType == domainmodels.CellTypeBlocked {
+		return false
+	}
+
+	for _, cellRoad := range target.RoadSegments {
+		if cellRoad.RoadSegmentID == segment.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Router) heuristic(from, goal [2]int64) float64 {
+	dx := float64(goal[0] - from[0])
+	dy := float64(goal[1] - from[1])
+	distanceKM := math.Sqrt(dx*dx+dy*dy) * minKMPerGridUnit
+	return distanceKM / maxRouteSpeedKPH
+}
+
+// SegmentCost is the expected traversal time of a segment in hours.
+func SegmentCost(segment *domainmodels.RoadSegment) float64 {
+	length := SegmentLengthKM(segment)
+
+	speed := segment.EffectiveSpeedLimit
+	if speed <= 0 {
+		speed = segment.BaseSpeedKPH
+	}
+	if speed <= 0 {
+		speed = defaultRouteSpeedKPH
+	}
+	return length / speed
+}
+
+func SegmentLengthKM(segment *domainmodels.RoadSegment) float64 {
+	if segment.LengthKM > 0 {
+		return segment.LengthKM
+	}
+	dx := float64(segment.EndX - segment.StartX)
+	dy := float64(segment.EndY - segment.StartY)
+	return math.Sqrt(dx*dx+dy*dy) * 0.5
+}
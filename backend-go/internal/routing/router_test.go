@@ -0,0 +1,84 @@
+package routing
+
+import (
+	"math/rand"
+	"testing"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+)
+
+func routingGrid(t testing.TB, oneWay bool) *domainmodels.Grid {
+	t.Helper()
+	gridLoader := gridloader.NewGridLoader()
+	gridLoader.ConfigureForTesting(20, 20, 7, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	gridLoader.BaseRoadConditions = config.Config().BaseRoadConditions
+	gridLoader.OneWayStreets = oneWay
+	grid, err := gridLoader.GenerateProcedural()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return grid
+}
+
+func cellPairs(grid *domainmodels.Grid, count int, rng *rand.Rand) [][2]*domainmodels.Cell {
+	var onRoad []*domainmodels.Cell
+	for i := range grid.Cells {
+		if len(grid.Cells[i].RoadSegments) > 0 {
+			onRoad = append(onRoad, &grid.Cells[i])
+		}
+	}
+	pairs := make([][2]*domainmodels.Cell, count)
+	for i := range pairs {
+		pairs[i] = [2]*domainmodels.Cell{onRoad[rng.Intn(len(onRoad))], onRoad[rng.Intn(len(onRoad))]}
+	}
+	return pairs
+}
+
+// Every route must chain from its origin to its destination, driving each
+// segment a way it may be driven.
+func TestPlannedRoutesAreDrivable(t *testing.T) {
+	for name, oneWay := range map[string]bool{"two-way": false, "one-way": true} {
+		t.Run(name, func(t *testing.T) {
+			grid := routingGrid(t, oneWay)
+			router := NewRouter(grid)
+			routed := 0
+			for _, pair := range cellPairs(grid, 200, rand.New(rand.NewSource(1))) {
+				origin, destination := pair[0], pair[1]
+				path, err := router.PlanRoute(origin, destination)
+				if err != nil {
+					continue
+				}
+				routed++
+				x, y := origin.Xpos, origin.Ypos
+				for _, segmentID := range path {
+					segment := grid.GetSegment(segmentID)
+					if segment == nil || !segment.HasEndpoint(x, y) || !segment.CanLeave(x, y) {
+						t.Fatalf("route from (%d,%d) to (%d,%d) cannot take segment %d at (%d,%d)",
+							origin.Xpos, origin.Ypos, destination.Xpos, destination.Ypos, segmentID, x, y)
+					}
+					x, y = segment.OtherEndpoint(x, y)
+				}
+				if x != destination.Xpos || y != destination.Ypos {
+					t.Fatalf("route from (%d,%d) to (%d,%d) ends at (%d,%d)",
+						origin.Xpos, origin.Ypos, destination.Xpos, destination.Ypos, x, y)
+				}
+			}
+			if routed < 100 {
+				t.Errorf("only %d of 200 routes found", routed)
+			}
+		})
+	}
+}
+
+func BenchmarkPlanRoute(b *testing.B) {
+	grid := routingGrid(b, false)
+	router := NewRouter(grid)
+	pairs := cellPairs(grid, 500, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := range b.N {
+		pair := pairs[i%len(pairs)]
+		router.PlanRoute(pair[0], pair[1])
+	}
+}
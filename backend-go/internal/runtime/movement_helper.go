@@ -33,11 +33,16 @@ func (mv *MovementValidator) ValidateMove(request MoveRequest) bool {
 	return utils.SegmentIsConnected(request.FromCell, request.ToCell)
 }
 
+// GetConnectedCells lists the cells an open segment leads to from cell, in the
+// direction segments may be driven.
 func (mv *MovementValidator) GetConnectedCells(cell *domainmodels.Cell) []*domainmodels.Cell {
 	var connected []*domainmodels.Cell
 
 	for _, cellRoad := range cell.RoadSegments {
 		segment := cellRoad.RoadSegment
+		if !segment.IsOpen || !segment.CanLeave(cell.Xpos, cell.Ypos) {
+			continue
+		}
 		var targetX, targetY int64
 
 		if segment.StartX == cell.Xpos && segment.StartY == cell.Ypos {
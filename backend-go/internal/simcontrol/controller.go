@@ -0,0 +1,308 @@
+package simcontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// maxStepsPerCommand bounds a single step request so one command cannot hold
+// the loop for an unbounded time.
+const maxStepsPerCommand = 10000
+
+var (
+	// ErrInvalidTransition is returned when an action does not apply to the
+	// current state, such as resuming a simulation that is not paused.
+	ErrInvalidTransition = errors.New("invalid simulation transition")
+	// ErrStopped is returned once Run has returned.
+	ErrStopped = errors.New("simulation controller is stopped")
+)
+
+// Engine does the work of one tick. The controller only calls it from the
+// goroutine running Run.
+type Engine interface {
+	MovementTick(ctx context.Context, step time.Duration)
+	TrafficTick(ctx context.Context)
+}
+
+// Listener is told about every lifecycle transition, on the goroutine running
+// Run, with the status right after it.
+type Listener func(event constants.WSMessageType, status domainmodels.SimulationStatusMessage)
+
+// Controller owns the simulation tick loop. Run drives the engine at the pace
+// of the clock while the simulation is running; Start, Pause, Resume, Step and
+// SetSpeed are safe to call from any other goroutine and take effect between
+// ticks. Work that must not overlap a tick can be queued with Do.
+type Controller struct {
+	clock            simclock.Clock
+	engine           Engine
+	movementInterval time.Duration
+	trafficInterval  time.Duration
+	listener         Listener
+	logOutput        io.Writer
+
+	commands chan command
+	tasks    chan func()
+	done     chan struct{}
+	status   atomic.Pointer[domainmodels.SimulationStatusMessage]
+
+	// Owned by the goroutine running Run.
+	state        constants.SimulationState
+	ticks        int64
+	movementStep time.Duration
+	trafficStep  time.Duration
+	nextTraffic  time.Duration
+	ticker       *simclock.Ticker
+}
+
+type command struct {
+	request domainmodels.SimulationControlMessage
+	result  chan error
+}
+
+// NewController returns an idle controller. movementInterval and
+// trafficInterval are in real time; the clock turns them into simulated steps.
+func NewController(clock simclock.Clock, engine Engine, movementInterval, trafficInterval time.Duration) *Controller {
+	c := &Controller{
+		clock:            clock,
+		engine:           engine,
+		movementInterval: movementInterval,
+		trafficInterval:  trafficInterval,
+		commands:         make(chan command),
+		tasks:            make(chan func()),
+		done:             make(chan struct{}),
+		state:            constants.SimulationStateIdle,
+	}
+	c.updateSteps()
+	c.nextTraffic = clock.Elapsed() + c.trafficStep
+	c.publishStatus()
+	return c
+}
+
+// SetListener is told about transitions from the next one on. Set it before
+// calling Run.
+func (c *Controller) SetListener(listener Listener) {
+	c.listener = listener
+}
+
+// SetLogOutput makes the controller write a line to w on every transition. It
+// is silent by default. Set it before calling Run.
+func (c *Controller) SetLogOutput(w io.Writer) {
+	c.logOutput = w
+}
+
+func (c *Controller) logf(format string, args ...any) {
+	if c.logOutput == nil {
+		return
+	}
+	fmt.Fprintf(c.logOutput, format+"\n", args...)
+}
+
+// Run serves commands and, while running, ticks the engine until ctx is
+// cancelled. A movement tick covers one movement step of simulated time; a
+// traffic tick follows whenever a traffic interval of simulated time has
+// passed since the last one.
+func (c *Controller) Run(ctx context.Context) error {
+	defer close(c.done)
+	defer c.stopTicker()
+
+	for {
+		var tick <-chan time.Time
+		if c.state == constants.SimulationStateRunning {
+			tick = c.ticker.C
+		}
+
+		select {
+		case <-ctx.Done():
+			c.state = constants.SimulationStateStopped
+			c.emit(constants.WSMsgSimulationStop)
+			return nil
+		case <-tick:
+			c.tick(ctx)
+		case cmd := <-c.commands:
+			cmd.result <- c.apply(ctx, cmd.request)
+		case task := <-c.tasks:
+			task()
+		}
+	}
+}
+
+func (c *Controller) Start() error {
+	return c.Control(domainmodels.SimulationControlMessage{Action: constants.SimulationActionStart})
+}
+
+func (c *Controller) Pause() error {
+	return c.Control(domainmodels.SimulationControlMessage{Action: constants.SimulationActionPause})
+}
+
+func (c *Controller) Resume() error {
+	return c.Control(domainmodels.SimulationControlMessage{Action: constants.SimulationActionResume})
+}
+
+// Step runs n ticks back to back while the simulation is idle or paused and
+// leaves it where it was.
+func (c *Controller) Step(n int) error {
+	return c.Control(domainmodels.SimulationControlMessage{Action: constants.SimulationActionStep, Steps: n})
+}
+
+// SetSpeed changes how much simulated time each tick covers; the real-time
+// pace of the ticks stays the same.
+func (c *Controller) SetSpeed(multiplier float64) error {
+	return c.Control(domainmodels.SimulationControlMessage{Action: constants.SimulationActionSetSpeed, Speed: multiplier})
+}
+
+// Control applies request between ticks and waits for the outcome. It must
+// not be called from the goroutine running Run, including from a task.
+func (c *Controller) Control(request domainmodels.SimulationControlMessage) error {
+	cmd := command{request: request, result: make(chan error, 1)}
+	select {
+	case c.commands <- cmd:
+		return <-cmd.result
+	case <-c.done:
+		return ErrStopped
+	}
+}
+
+// Do runs task on the goroutine running Run, between ticks, and returns once
+// it has been handed over.
+func (c *Controller) Do(task func()) error {
+	select {
+	case c.tasks <- task:
+		return nil
+	case <-c.done:
+		return ErrStopped
+	}
+}
+
+// Status is the state as of the last tick or transition.
+func (c *Controller) Status() domainmodels.SimulationStatusMessage {
+	return *c.status.Load()
+}
+
+func (c *Controller) apply(ctx context.Context, request domainmodels.SimulationControlMessage) error {
+	switch request.Action {
+	case constants.SimulationActionStart:
+		if err := c.transition(request.Action, constants.SimulationStateIdle); err != nil {
+			return err
+		}
+		c.state = constants.SimulationStateRunning
+		c.startTicker()
+		c.emit(constants.WSMsgSimulationStart)
+	case constants.SimulationActionPause:
+		if err := c.transition(request.Action, constants.SimulationStateRunning); err != nil {
+			return err
+		}
+		c.state = constants.SimulationStatePaused
+		c.stopTicker()
+		c.emit(constants.WSMsgSimulationPause)
+	case constants.SimulationActionResume:
+		if err := c.transition(request.Action, constants.SimulationStatePaused); err != nil {
+			return err
+		}
+		c.state = constants.SimulationStateRunning
+		c.startTicker()
+		c.emit(constants.WSMsgSimulationResume)
+	case constants.SimulationActionStep:
+		if err := c.transition(request.Action, constants.SimulationStateIdle, constants.SimulationStatePaused); err != nil {
+			return err
+		}
+		if request.Steps < 1 || request.Steps > maxStepsPerCommand {
+			return fmt.Errorf("steps must be between 1 and %d, got %d", maxStepsPerCommand, request.Steps)
+		}
+		for i := 0; i < request.Steps; i++ {
+			c.tick(ctx)
+		}
+		c.emit(constants.WSMsgSimulationStep)
+	case constants.SimulationActionSetSpeed:
+		if err := c.clock.SetSpeed(request.Speed); err != nil {
+			return err
+		}
+		c.updateSteps()
+		if c.state == constants.SimulationStateRunning {
+			c.stopTicker()
+			c.startTicker()
+		}
+		c.emit(constants.WSMsgSimulationSpeed)
+	default:
+		return fmt.Errorf("unknown simulation action %q", request.Action)
+	}
+	return nil
+}
+
+// transition fails unless the current state is one of from.
+func (c *Controller) transition(action constants.SimulationAction, from ...constants.SimulationState) error {
+	for _, state := range from {
+		if c.state == state {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cannot %s while %s", ErrInvalidTransition, action, c.state)
+}
+
+func (c *Controller) tick(ctx context.Context) {
+	c.engine.MovementTick(ctx, c.movementStep)
+	if c.clock.Elapsed() >= c.nextTraffic {
+		c.nextTraffic += c.trafficStep
+		c.engine.TrafficTick(ctx)
+	}
+	c.ticks++
+	c.publishStatus()
+}
+
+func (c *Controller) updateSteps() {
+	c.movementStep = c.clock.Step(c.movementInterval)
+	c.trafficStep = c.clock.Step(c.trafficInterval)
+}
+
+func (c *Controller) startTicker() {
+	c.ticker = simclock.NewTicker(c.clock, c.movementStep)
+}
+
+func (c *Controller) stopTicker() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+		c.ticker = nil
+	}
+}
+
+func (c *Controller) emit(event constants.WSMessageType) {
+	c.publishStatus()
+	c.logf("Simulation %s: %s at %v simulated", event, c.state, c.clock.Elapsed())
+	if c.listener != nil {
+		c.listener(event, c.Status())
+	}
+}
+
+func (c *Controller) publishStatus() {
+	c.status.Store(&domainmodels.SimulationStatusMessage{
+		State:           c.state,
+		ClockMode:       c.clock.Mode(),
+		SpeedMultiplier: c.clock.Speed(),
+		SimulatedAt:     c.clock.Now(),
+		SimulatedTimeMs: c.clock.Elapsed().Milliseconds(),
+		Ticks:           c.ticks,
+	})
+}
+
+// ManagerEngine ticks a lifecycle manager with nothing around it, for runs
+// without a server.
+type ManagerEngine struct {
+	Manager *movement.VehicleLifecycleManager
+}
+
+func (e ManagerEngine) MovementTick(ctx context.Context, step time.Duration) {
+	e.Manager.UpdateAllVehicles(step.Seconds())
+}
+
+func (e ManagerEngine) TrafficTick(ctx context.Context) {
+	e.Manager.UpdateConditions()
+	e.Manager.UpdateTraffic()
+}
@@ -0,0 +1,268 @@
+package simcontrol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/roadgraph/simclock"
+)
+
+var testStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// countingEngine advances the clock by each movement step, as the lifecycle
+// manager does, and counts the ticks it is given.
+type countingEngine struct {
+	clock    simclock.Clock
+	movement atomic.Int64
+	traffic  atomic.Int64
+	lastStep atomic.Int64
+}
+
+func (e *countingEngine) MovementTick(ctx context.Context, step time.Duration) {
+	e.clock.Advance(step)
+	e.lastStep.Store(int64(step))
+	e.movement.Add(1)
+}
+
+func (e *countingEngine) TrafficTick(ctx context.Context) {
+	e.traffic.Add(1)
+}
+
+type lifecycleEvent struct {
+	event  constants.WSMessageType
+	status domainmodels.SimulationStatusMessage
+}
+
+// harness runs a controller until the test ends and collects the events it
+// broadcasts.
+type harness struct {
+	*Controller
+	engine *countingEngine
+	events chan lifecycleEvent
+	log    *syncBuffer
+	stop   func()
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// run starts a controller whose ticks cover a minute of simulated time per
+// real second and whose traffic ticks come every five seconds.
+func run(t *testing.T, clock simclock.Clock) *harness {
+	t.Helper()
+	h := &harness{
+		engine: &countingEngine{clock: clock},
+		events: make(chan lifecycleEvent, 100),
+		log:    &syncBuffer{},
+	}
+	h.Controller = NewController(clock, h.engine, time.Second, 5*time.Second)
+	h.SetListener(func(event constants.WSMessageType, status domainmodels.SimulationStatusMessage) {
+		h.events <- lifecycleEvent{event, status}
+	})
+	h.SetLogOutput(h.log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		h.Run(ctx)
+		close(returned)
+	}()
+	h.stop = func() {
+		cancel()
+		<-returned
+	}
+	t.Cleanup(h.stop)
+	return h
+}
+
+func accelerated(t *testing.T) simclock.Clock {
+	t.Helper()
+	clock, err := simclock.New(simclock.Accelerated, 60, testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clock
+}
+
+// expect fails unless the next event broadcast is event in state.
+func (h *harness) expect(t *testing.T, event constants.WSMessageType, state constants.SimulationState) domainmodels.SimulationStatusMessage {
+	t.Helper()
+	select {
+	case got := <-h.events:
+		if got.event != event || got.status.State != state {
+			t.Fatalf("got %s while %s, want %s while %s", got.event, got.status.State, event, state)
+		}
+		return got.status
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s event", event)
+	}
+	return domainmodels.SimulationStatusMessage{}
+}
+
+func (h *harness) expectNoEvent(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-h.events:
+		t.Fatalf("unexpected %s event", got.event)
+	default:
+	}
+}
+
+// waitForTicks waits until the engine has seen more than n movement ticks.
+func (h *harness) waitForTicks(t *testing.T, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for h.engine.movement.Load() <= n {
+		if time.Now().After(deadline) {
+			t.Fatalf("still %d ticks, want more than %d", h.engine.movement.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStartPauseResume(t *testing.T) {
+	h := run(t, simclock.NewAsFastAsPossible(testStart))
+
+	if err := h.Pause(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("pausing an idle simulation: %v", err)
+	}
+	if err := h.Resume(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("resuming an idle simulation: %v", err)
+	}
+	h.expectNoEvent(t)
+
+	if err := h.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	h.expect(t, constants.WSMsgSimulationStart, constants.SimulationStateRunning)
+	h.waitForTicks(t, 0)
+	if err := h.Start(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("starting a running simulation: %v", err)
+	}
+	if err := h.Step(1); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("stepping a running simulation: %v", err)
+	}
+
+	if err := h.Pause(); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	paused := h.expect(t, constants.WSMsgSimulationPause, constants.SimulationStatePaused)
+	ticks := h.engine.movement.Load()
+	if paused.Ticks != ticks {
+		t.Errorf("paused after %d ticks, status says %d", ticks, paused.Ticks)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if after := h.engine.movement.Load(); after != ticks {
+		t.Errorf("engine ticked %d times while paused", after-ticks)
+	}
+
+	if err := h.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	h.expect(t, constants.WSMsgSimulationResume, constants.SimulationStateRunning)
+	h.waitForTicks(t, ticks)
+
+	h.stop()
+	h.expect(t, constants.WSMsgSimulationStop, constants.SimulationStateStopped)
+	if err := h.Resume(); !errors.Is(err, ErrStopped) {
+		t.Errorf("resuming a stopped controller: %v", err)
+	}
+	if err := h.Do(func() {}); !errors.Is(err, ErrStopped) {
+		t.Errorf("queueing work on a stopped controller: %v", err)
+	}
+
+	if log := h.log.String(); strings.Count(log, "\n") != 4 || !strings.Contains(log, "Simulation "+string(constants.WSMsgSimulationPause)) {
+		t.Errorf("logged %q, want a line for each of the four transitions", log)
+	}
+}
+
+func TestStepRunsTicksAndKeepsTheState(t *testing.T) {
+	h := run(t, accelerated(t))
+
+	if err := h.Step(10); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	status := h.expect(t, constants.WSMsgSimulationStep, constants.SimulationStateIdle)
+	if status.Ticks != 10 || status.SimulatedTimeMs != (10*time.Minute).Milliseconds() {
+		t.Errorf("after ten steps: %d ticks, %dms simulated, want 10 and ten minutes", status.Ticks, status.SimulatedTimeMs)
+	}
+	if !status.SimulatedAt.Equal(testStart.Add(10 * time.Minute)) {
+		t.Errorf("status reads %v", status.SimulatedAt)
+	}
+	// A traffic tick follows every five minutes of simulated time.
+	if traffic := h.engine.traffic.Load(); traffic != 2 {
+		t.Errorf("%d traffic ticks in ten minutes, want 2", traffic)
+	}
+
+	for _, n := range []int{0, -1, maxStepsPerCommand + 1} {
+		if err := h.Step(n); err == nil {
+			t.Errorf("Step(%d) succeeded", n)
+		}
+	}
+
+	if err := h.Start(); err != nil {
+		t.Fatal(err)
+	}
+	h.expect(t, constants.WSMsgSimulationStart, constants.SimulationStateRunning)
+	if err := h.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	h.expect(t, constants.WSMsgSimulationPause, constants.SimulationStatePaused)
+	ticks := h.Status().Ticks
+	if err := h.Step(3); err != nil {
+		t.Fatalf("stepping while paused: %v", err)
+	}
+	if status := h.expect(t, constants.WSMsgSimulationStep, constants.SimulationStatePaused); status.Ticks != ticks+3 {
+		t.Errorf("%d ticks after three more steps, want %d", status.Ticks, ticks+3)
+	}
+}
+
+func TestSetSpeedScalesTheStep(t *testing.T) {
+	h := run(t, accelerated(t))
+
+	if err := h.SetSpeed(120); err != nil {
+		t.Fatalf("SetSpeed: %v", err)
+	}
+	if status := h.expect(t, constants.WSMsgSimulationSpeed, constants.SimulationStateIdle); status.SpeedMultiplier != 120 {
+		t.Errorf("status reports speed %g, want 120", status.SpeedMultiplier)
+	}
+	if err := h.Step(1); err != nil {
+		t.Fatal(err)
+	}
+	h.expect(t, constants.WSMsgSimulationStep, constants.SimulationStateIdle)
+	if step := time.Duration(h.engine.lastStep.Load()); step != 2*time.Minute {
+		t.Errorf("a tick covers %v at speed 120, want 2m", step)
+	}
+
+	if err := h.SetSpeed(0); err == nil {
+		t.Error("SetSpeed(0) succeeded")
+	}
+	h.expectNoEvent(t)
+
+	realTime := run(t, simclock.NewRealTime(testStart))
+	if err := realTime.SetSpeed(2); err == nil {
+		t.Error("a real-time simulation changed speed")
+	}
+}
@@ -0,0 +1,305 @@
+package spawning
+
+import (
+	"fmt"
+	"time"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/fleetsim/internal/reqpays"
+)
+
+const rateWindow = time.Minute
+
+// SpawnRequestProcessor moves VehicleSpawnRequests through
+// pending → validating → queued → processing → completed/failed, with
+// cancellation possible until processing starts. It is not safe for concurrent
+// use; callers drive it from the simulation loop.
+type SpawnRequestProcessor struct {
+	config  *config.SimulationConfig
+	manager *movement.VehicleLifecycleManager
+	spawner *gridloader.VehicleSpawner
+
+	queue    []*reqpays.VehicleSpawnRequest
+	requests map[string]*reqpays.VehicleSpawnRequest
+	sessions map[string]string
+	finished []*reqpays.VehicleSpawnRequest
+
+	recentProcessing []time.Time
+	requestCounter   int64
+	queueState       domainmodels.RedisSpawnQueue
+
+	// wallNow times the per-minute rate window; tests replace it.
+	wallNow func() time.Time
+}
+
+func NewSpawnRequestProcessor(cfg *config.SimulationConfig, manager *movement.VehicleLifecycleManager, spawner *gridloader.VehicleSpawner) *SpawnRequestProcessor {
+	return &SpawnRequestProcessor{
+		config:   cfg,
+		manager:  manager,
+		spawner:  spawner,
+		requests: make(map[string]*reqpays.VehicleSpawnRequest),
+		sessions: make(map[string]string),
+		wallNow:  time.Now,
+	}
+}
+
+// now is the run's simulated time, which request timestamps follow. The
+// per-minute rate window limits what operators can ask for, so it runs on
+// wall time instead.
+func (p *SpawnRequestProcessor) now() time.Time {
+	return p.manager.Clock().Now()
+}
+
+func (p *SpawnRequestProcessor) Submit(request reqpays.VehicleSpawnRequest, sessionID string) (*reqpays.VehicleSpawnRequest, error) {
+	if request.RequestID == "" {
+		p.requestCounter++
+		request.RequestID = fmt.Sprintf("spawn_%d", p.requestCounter)
+	}
+	if _, exists := p.requests[request.RequestID]; exists {
+		return nil, fmt.Errorf("spawn request %s was already submitted", request.RequestID)
+	}
+	if request.CreatedAt.IsZero() {
+		request.CreatedAt = p.now()
+	}
+
+	tracked := &request
+	tracked.Status = constants.SpawnRequestStatusPending
+	tracked.SpawnedVehicleID = nil
+	tracked.FailureReason = nil
+	tracked.ProcessedAt = nil
+	p.requests[tracked.RequestID] = tracked
+	if sessionID != "" {
+		p.sessions[tracked.RequestID] = sessionID
+	}
+
+	tracked.Status = constants.SpawnRequestStatusValidating
+	p.applyDefaults(tracked)
+	tracked.ValidationErrors = ValidateSpawnRequest(tracked, p.manager.GetGrid())
+	if len(tracked.ValidationErrors) > 0 {
+		p.finish(tracked, constants.SpawnRequestStatusFailed, "validation failed")
+		return tracked, nil
+	}
+
+	tracked.Status = constants.SpawnRequestStatusQueued
+	p.enqueue(tracked)
+	p.refreshQueueState()
+	return tracked, nil
+}
+
+// ProcessQueue spawns queued requests in priority order until the queue is
+// empty or the per-minute or active-vehicle limit is reached. It returns the
+// requests that reached a final status during this call.
+func (p *SpawnRequestProcessor) ProcessQueue() []*reqpays.VehicleSpawnRequest {
+	now := p.wallNow()
+	p.pruneRateWindow(now)
+
+	finishedBefore := len(p.finished)
+	for len(p.queue) > 0 {
+		if len(p.recentProcessing) >= p.config.MaxSpawnRequestsPerMin {
+			break
+		}
+		if p.manager.CountUnfinishedVehicles() >= p.config.MaxActiveVehicles {
+			break
+		}
+
+		request := p.queue[0]
+		p.queue = p.queue[1:]
+		p.recentProcessing = append(p.recentProcessing, now)
+		p.process(request)
+	}
+
+	p.refreshQueueState()
+	return append([]*reqpays.VehicleSpawnRequest(nil), p.finished[finishedBefore:]...)
+}
+
+func (p *SpawnRequestProcessor) process(request *reqpays.VehicleSpawnRequest) {
+	request.Status = constants.SpawnRequestStatusProcessing
+	p.queueState.ProcessingCount++
+	defer func() { p.queueState.ProcessingCount-- }()
+
+	vehicle, err := p.spawner.SpawnForRequest(p.manager.GetGrid(), request, p.occupiedPositions())
+	if err != nil {
+		p.finish(request, constants.SpawnRequestStatusFailed, err.Error())
+		return
+	}
+	if sessionID, ok := p.sessions[request.RequestID]; ok {
+		vehicle.UserSessionID = &sessionID
+		vehicle.ProximityLOD = false
+	}
+
+	managed, err := p.manager.AddVehicle(vehicle)
+	if err != nil {
+		p.finish(request, constants.SpawnRequestStatusFailed, err.Error())
+		return
+	}
+	p.spawner.RecordSpawned(*managed)
+
+	vehicleID := managed.ID
+	request.SpawnedVehicleID = &vehicleID
+	p.finish(request, constants.SpawnRequestStatusCompleted, "")
+}
+
+func (p *SpawnRequestProcessor) Cancel(requestID string) error {
+	request, ok := p.requests[requestID]
+	if !ok {
+		return fmt.Errorf("spawn request %s not found", requestID)
+	}
+	if request.Status != constants.SpawnRequestStatusPending && request.Status != constants.SpawnRequestStatusQueued {
+		return fmt.Errorf("spawn request %s cannot be cancelled while %s", requestID, request.Status)
+	}
+
+	for i, queued := range p.queue {
+		if queued.RequestID == requestID {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			break
+		}
+	}
+	p.finish(request, constants.SpawnRequestStatusCancelled, "cancelled by user")
+	p.refreshQueueState()
+	return nil
+}
+
+func (p *SpawnRequestProcessor) GetRequest(requestID string) *reqpays.VehicleSpawnRequest {
+	return p.requests[requestID]
+}
+
+func (p *SpawnRequestProcessor) SessionFor(requestID string) string {
+	return p.sessions[requestID]
+}
+
+func (p *SpawnRequestProcessor) QueueState() domainmodels.RedisSpawnQueue {
+	state := p.queueState
+	state.QueuedRequests = append([]string(nil), p.queueState.QueuedRequests...)
+	return state
+}
+
+// DrainFinished returns every request that reached a final status since the
+// previous call, so callers can notify whoever submitted them.
+func (p *SpawnRequestProcessor) DrainFinished() []*reqpays.VehicleSpawnRequest {
+	drained := p.finished
+	p.finished = nil
+	return drained
+}
+
+// HandleSpawnRequest lets the processor serve WebSocket spawn requests. The
+// request is submitted and the queue processed immediately; requests still
+// queued afterwards are reported again once DrainFinished picks them up.
+func (p *SpawnRequestProcessor) HandleSpawnRequest(message domainmodels.SpawnRequestMessage) domainmodels.SpawnResponseMessage {
+	request := message.SpawnRequest
+	request.RequestID = message.RequestID
+
+	tracked, err := p.Submit(request, message.UserSessionID)
+	if err != nil {
+		errorMessage := err.Error()
+		return domainmodels.SpawnResponseMessage{
+			RequestID:    message.RequestID,
+			Status:       constants.SpawnRequestStatusFailed,
+			ErrorMessage: &errorMessage,
+		}
+	}
+
+	p.ProcessQueue()
+	p.forgetFinished(tracked.RequestID)
+	return ResponseFor(tracked)
+}
+
+func ResponseFor(request *reqpays.VehicleSpawnRequest) domainmodels.SpawnResponseMessage {
+	return domainmodels.SpawnResponseMessage{
+		RequestID:        request.RequestID,
+		Success:          request.Status == constants.SpawnRequestStatusCompleted,
+		Status:           request.Status,
+		SpawnedVehicleID: request.SpawnedVehicleID,
+		ErrorMessage:     request.FailureReason,
+		ValidationErrors: request.ValidationErrors,
+	}
+}
+
+func (p *SpawnRequestProcessor) applyDefaults(request *reqpays.VehicleSpawnRequest) {
+	if request.SpawnLocation.LocationType == "" {
+		request.SpawnLocation.LocationType = p.config.DefaultSpawnLocation
+	}
+	if request.Destination.DestinationType == "" {
+		request.Destination.DestinationType = p.config.DefaultDestinationType
+	}
+	if p.config.RequireFuelStop {
+		request.Destination.RequireFuelStop = true
+	}
+	if request.SpawnLocation.CellX != nil && request.SpawnLocation.CellY != nil {
+		request.SpawnLocation.LocationType = "specific"
+	}
+	if request.Destination.CellX != nil && request.Destination.CellY != nil {
+		request.Destination.DestinationType = "specific"
+	}
+}
+
+// enqueue keeps the queue ordered by descending Priority; equal priorities
+// stay in submission order.
+func (p *SpawnRequestProcessor) enqueue(request *reqpays.VehicleSpawnRequest) {
+	position := len(p.queue)
+	for i, queued := range p.queue {
+		if request.Priority > queued.Priority {
+			position = i
+			break
+		}
+	}
+	p.queue = append(p.queue, nil)
+	copy(p.queue[position+1:], p.queue[position:])
+	p.queue[position] = request
+}
+
+func (p *SpawnRequestProcessor) finish(request *reqpays.VehicleSpawnRequest, status constants.SpawnRequestStatus, reason string) {
+	processedAt := p.now()
+	request.Status = status
+	request.ProcessedAt = &processedAt
+	if reason != "" {
+		request.FailureReason = &reason
+	}
+
+	p.finished = append(p.finished, request)
+	p.queueState.LastProcessed = processedAt
+	p.queueState.TotalProcessed++
+}
+
+func (p *SpawnRequestProcessor) forgetFinished(requestID string) {
+	for i, request := range p.finished {
+		if request.RequestID == requestID {
+			p.finished = append(p.finished[:i], p.finished[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *SpawnRequestProcessor) pruneRateWindow(now time.Time) {
+	cutoff := now.Add(-rateWindow)
+	kept := p.recentProcessing[:0]
+	for _, at := range p.recentProcessing {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	p.recentProcessing = kept
+}
+
+// occupiedPositions is where every unfinished vehicle stands, including ones
+// stopped to refuel or waiting to enter a segment.
+func (p *SpawnRequestProcessor) occupiedPositions() [][2]int64 {
+	var occupied [][2]int64
+	for _, vehicle := range p.manager.GetUnfinishedVehicles() {
+		if vehicle.CurrentCell != nil {
+			occupied = append(occupied, [2]int64{vehicle.CurrentCell.Xpos, vehicle.CurrentCell.Ypos})
+		}
+	}
+	return occupied
+}
+
+func (p *SpawnRequestProcessor) refreshQueueState() {
+	queued := make([]string, 0, len(p.queue))
+	for _, request := range p.queue {
+		queued = append(queued, request.RequestID)
+	}
+	p.queueState.QueuedRequests = queued
+}
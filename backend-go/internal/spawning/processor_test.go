@@ -0,0 +1,217 @@
+package spawning
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/fleetsim/internal/reqpays"
+	"owenvi.com/fleetsim/internal/utils"
+	"owenvi.com/roadgraph/simclock"
+)
+
+const testSeed = 7
+
+var testStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestProcessor builds a processor over an empty 12x12 run. The processor's
+// wall clock reads whatever *wall holds.
+func newTestProcessor(t *testing.T, cfg *config.SimulationConfig, wall *time.Time) (*SpawnRequestProcessor, simclock.Clock) {
+	t.Helper()
+	gridLoader := gridloader.NewGridLoader()
+	gridLoader.ConfigureForTesting(12, 12, testSeed, 0.05, 0.02, 0.05, 0.7, 0.3, 0.1)
+	grid, err := gridLoader.GenerateProcedural()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := simclock.NewAsFastAsPossible(testStart)
+	spawner := gridloader.NewVehicleSpawner(cfg, testSeed)
+	spawner.SetClock(clock)
+	manager := movement.NewVehicleLifecycleManager(grid, nil, clock)
+
+	processor := NewSpawnRequestProcessor(cfg, manager, spawner)
+	processor.wallNow = func() time.Time { return *wall }
+	return processor, clock
+}
+
+// spawnCells returns two cells a vehicle can be spawned on.
+func spawnCells(t *testing.T, grid *domainmodels.Grid) (*domainmodels.Cell, *domainmodels.Cell) {
+	t.Helper()
+	var found []*domainmodels.Cell
+	for i := range grid.Cells {
+		if utils.IsSuitableSpawnLocation(grid, &grid.Cells[i]) {
+			found = append(found, &grid.Cells[i])
+		}
+	}
+	if len(found) < 2 {
+		t.Fatalf("grid has %d spawn cells, want at least 2", len(found))
+	}
+	return found[0], found[len(found)-1]
+}
+
+func carAt(cell *domainmodels.Cell) reqpays.VehicleSpawnRequest {
+	x, y := cell.Xpos, cell.Ypos
+	return reqpays.VehicleSpawnRequest{
+		VehicleType:   constants.VehicleTypeCar,
+		SpawnLocation: reqpays.SpawnLocationRequest{CellX: &x, CellY: &y},
+	}
+}
+
+func submit(t *testing.T, processor *SpawnRequestProcessor, request reqpays.VehicleSpawnRequest) *reqpays.VehicleSpawnRequest {
+	t.Helper()
+	tracked, err := processor.Submit(request, "")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return tracked
+}
+
+func TestQueueSpawnsByPriority(t *testing.T) {
+	wall := testStart
+	processor, _ := newTestProcessor(t, config.Config(), &wall)
+
+	for _, priority := range []int{0, 2, 0, 1} {
+		submit(t, processor, reqpays.VehicleSpawnRequest{VehicleType: constants.VehicleTypeCar, Priority: priority})
+	}
+	if queued := processor.QueueState().QueuedRequests; strings.Join(queued, " ") != "spawn_2 spawn_4 spawn_1 spawn_3" {
+		t.Errorf("queued %v, want spawn_2 spawn_4 spawn_1 spawn_3", queued)
+	}
+
+	var order []string
+	for _, request := range processor.ProcessQueue() {
+		if request.Status != constants.SpawnRequestStatusCompleted {
+			t.Errorf("request %s %s: %v", request.RequestID, request.Status, *request.FailureReason)
+		}
+		order = append(order, request.RequestID)
+	}
+	if got := strings.Join(order, " "); got != "spawn_2 spawn_4 spawn_1 spawn_3" {
+		t.Errorf("processed %s, want spawn_2 spawn_4 spawn_1 spawn_3", got)
+	}
+}
+
+// Operators are limited per minute of wall time however fast the simulation
+// runs.
+func TestRateLimitRunsOnWallTime(t *testing.T) {
+	cfg := config.Config()
+	cfg.MaxSpawnRequestsPerMin = 2
+	wall := testStart
+	processor, clock := newTestProcessor(t, cfg, &wall)
+
+	for range 3 {
+		submit(t, processor, reqpays.VehicleSpawnRequest{VehicleType: constants.VehicleTypeCar})
+	}
+	if processed := processor.ProcessQueue(); len(processed) != 2 {
+		t.Fatalf("processed %d requests in the first minute, want 2", len(processed))
+	}
+
+	clock.Advance(time.Hour)
+	if processed := processor.ProcessQueue(); len(processed) != 0 {
+		t.Errorf("processed %d requests after a simulated hour but no wall time", len(processed))
+	}
+
+	wall = wall.Add(rateWindow + time.Second)
+	if processed := processor.ProcessQueue(); len(processed) != 1 {
+		t.Errorf("processed %d requests a wall minute later, want 1", len(processed))
+	}
+}
+
+func TestMinDistanceCountsVehiclesThatAreNotMoving(t *testing.T) {
+	for _, status := range []constants.VehicleStatus{constants.VehicleStatusRefueling, constants.VehicleStatusStopped} {
+		t.Run(string(status), func(t *testing.T) {
+			wall := testStart
+			processor, _ := newTestProcessor(t, config.Config(), &wall)
+			taken, free := spawnCells(t, processor.manager.GetGrid())
+
+			first := submit(t, processor, carAt(taken))
+			processor.ProcessQueue()
+			if first.Status != constants.SpawnRequestStatusCompleted {
+				t.Fatalf("first spawn %s: %v", first.Status, *first.FailureReason)
+			}
+			processor.manager.GetVehicle(*first.SpawnedVehicleID).Status = status
+
+			request := carAt(taken)
+			request.SpawnLocation.MinDistanceFromOthers = 1
+			crowded := submit(t, processor, request)
+			processor.ProcessQueue()
+			if crowded.Status != constants.SpawnRequestStatusFailed {
+				t.Errorf("spawned on top of a %s vehicle", status)
+			}
+
+			request = carAt(free)
+			request.SpawnLocation.MinDistanceFromOthers = 1
+			apart := submit(t, processor, request)
+			processor.ProcessQueue()
+			if apart.Status != constants.SpawnRequestStatusCompleted {
+				t.Errorf("spawn away from the %s vehicle %s: %v", status, apart.Status, *apart.FailureReason)
+			}
+		})
+	}
+}
+
+func TestInvalidRequestsFailBeforeQueueing(t *testing.T) {
+	wall := testStart
+	processor, _ := newTestProcessor(t, config.Config(), &wall)
+
+	outside := int64(99)
+	fuel := 1.5
+	tracked := submit(t, processor, reqpays.VehicleSpawnRequest{
+		VehicleType:        "bicycle",
+		InitialFuelPercent: &fuel,
+		SpawnLocation:      reqpays.SpawnLocationRequest{CellX: &outside, CellY: &outside},
+	})
+	if tracked.Status != constants.SpawnRequestStatusFailed {
+		t.Errorf("invalid request is %s, want failed", tracked.Status)
+	}
+	if len(tracked.ValidationErrors) != 3 {
+		t.Errorf("got validation errors %q, want one each for type, fuel and spawn cell", tracked.ValidationErrors)
+	}
+	if state := processor.QueueState(); len(state.QueuedRequests) != 0 {
+		t.Errorf("invalid request was queued: %v", state.QueuedRequests)
+	}
+
+	if _, err := processor.Submit(reqpays.VehicleSpawnRequest{RequestID: tracked.RequestID}, ""); err == nil {
+		t.Error("resubmitting a request ID succeeded")
+	}
+}
+
+// A vehicle the manager turns away is not counted as spawned.
+func TestRejectedVehicleIsNotRecorded(t *testing.T) {
+	wall := testStart
+	processor, _ := newTestProcessor(t, config.Config(), &wall)
+	taken, _ := spawnCells(t, processor.manager.GetGrid())
+
+	// A second spawner with the same seed hands out the same vehicle IDs.
+	rival := gridloader.NewVehicleSpawner(config.Config(), testSeed)
+	request := carAt(taken)
+	vehicle, err := rival.SpawnForRequest(processor.manager.GetGrid(), &request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processor.manager.AddVehicle(vehicle); err != nil {
+		t.Fatal(err)
+	}
+
+	duplicate := submit(t, processor, carAt(taken))
+	processor.ProcessQueue()
+	if duplicate.Status != constants.SpawnRequestStatusFailed {
+		t.Fatalf("spawn with a duplicate vehicle ID is %s, want failed", duplicate.Status)
+	}
+	if spawned := processor.spawner.GetSpawnStatistics().TotalVehiclesSpawned; spawned != 0 {
+		t.Errorf("spawner counts %d vehicles after a rejected spawn, want 0", spawned)
+	}
+
+	added := submit(t, processor, carAt(taken))
+	processor.ProcessQueue()
+	if added.Status != constants.SpawnRequestStatusCompleted {
+		t.Fatalf("second spawn %s: %v", added.Status, *added.FailureReason)
+	}
+	if spawned := processor.spawner.GetSpawnStatistics().TotalVehiclesSpawned; spawned != 1 {
+		t.Errorf("spawner counts %d vehicles, want 1", spawned)
+	}
+}
@@ -0,0 +1,72 @@
+package spawning
+
+import (
+	"fmt"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/reqpays"
+)
+
+const maxCustomNameLength = 64
+
+var (
+	validSpawnLocationTypes = map[string]bool{"random": true, "specific": true, "edge": true, "depot": true, "refuel": true}
+	validDestinationTypes   = map[string]bool{"random": true, "specific": true, "depot": true, "refuel": true}
+)
+
+func ValidateSpawnRequest(request *reqpays.VehicleSpawnRequest, grid *domainmodels.Grid) []string {
+	var validationErrors []string
+
+	switch request.VehicleType {
+	case constants.VehicleTypeCar, constants.VehicleTypeVan, constants.VehicleTypeTruck:
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("unknown vehicle type %q", request.VehicleType))
+	}
+
+	if request.InitialFuelPercent != nil && (*request.InitialFuelPercent <= 0 || *request.InitialFuelPercent > 1) {
+		validationErrors = append(validationErrors, "initial fuel percent must be in (0, 1]")
+	}
+	if request.SpeedMultiplier != nil && (*request.SpeedMultiplier <= 0 || *request.SpeedMultiplier > 2) {
+		validationErrors = append(validationErrors, "speed multiplier must be in (0, 2]")
+	}
+	if request.CustomName != nil && len(*request.CustomName) > maxCustomNameLength {
+		validationErrors = append(validationErrors, fmt.Sprintf("custom name must be at most %d characters", maxCustomNameLength))
+	}
+
+	location := request.SpawnLocation
+	if !validSpawnLocationTypes[location.LocationType] {
+		validationErrors = append(validationErrors, fmt.Sprintf("unknown spawn location type %q", location.LocationType))
+	}
+	validationErrors = append(validationErrors, validateCoordinates("spawn", location.LocationType, location.CellX, location.CellY, grid)...)
+	if location.MinDistanceFromOthers < 0 {
+		validationErrors = append(validationErrors, "min distance from others cannot be negative")
+	}
+
+	destination := request.Destination
+	if !validDestinationTypes[destination.DestinationType] {
+		validationErrors = append(validationErrors, fmt.Sprintf("unknown destination type %q", destination.DestinationType))
+	}
+	validationErrors = append(validationErrors, validateCoordinates("destination", destination.DestinationType, destination.CellX, destination.CellY, grid)...)
+	if destination.MaxDistanceFromSpawn != nil && *destination.MaxDistanceFromSpawn <= 0 {
+		validationErrors = append(validationErrors, "max distance from spawn must be positive")
+	}
+
+	return validationErrors
+}
+
+func validateCoordinates(label, locationType string, cellX, cellY *int64, grid *domainmodels.Grid) []string {
+	if (cellX == nil) != (cellY == nil) {
+		return []string{fmt.Sprintf("%s cell needs both cell_x and cell_y", label)}
+	}
+	if cellX == nil {
+		if locationType == "specific" {
+			return []string{fmt.Sprintf("specific %s requires cell_x and cell_y", label)}
+		}
+		return nil
+	}
+	if *cellX < 0 || *cellY < 0 || *cellX >= grid.DimX || *cellY >= grid.DimY {
+		return []string{fmt.Sprintf("%s cell (%d,%d) is outside the %dx%d grid", label, *cellX, *cellY, grid.DimX, grid.DimY)}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+func CellToDB(gridID int64, cell domainmodels.Cell) domainmodels.CellDB {
+	return domainmodels.CellDB{
+		GridID:       gridID,
+		Xpos:         cell.Xpos,
+		Ypos:         cell.Ypos,
+		CellType:     string(cell.CellType),
+		RefuelAmount: cell.RefuelAmount,
+	}
+}
+
+func CellFromDB(row domainmodels.CellDB) domainmodels.Cell {
+	return domainmodels.Cell{
+		Xpos:         row.Xpos,
+		Ypos:         row.Ypos,
+		CellType:     domainmodels.CellType(row.CellType),
+		RoadSegments: []domainmodels.CellRoad{},
+		RefuelAmount: row.RefuelAmount,
+	}
+}
+
+// SegmentToDB keeps a segment's static description. Traffic load, temporary
+// conditions and derived speeds belong to a run and are not stored.
+func SegmentToDB(gridID int64, segment domainmodels.RoadSegment) domainmodels.RoadSegmentDB {
+	baseConditions := segment.BaseConditions
+	if baseConditions == nil {
+		baseConditions = []domainmodels.RoadCondition{}
+	}
+	return domainmodels.RoadSegmentDB{
+		ID:             segment.ID,
+		GridID:         gridID,
+		StartX:         segment.StartX,
+		StartY:         segment.StartY,
+		EndX:           segment.EndX,
+		EndY:           segment.EndY,
+		LengthKM:       segment.LengthKM,
+		BaseSpeedKPH:   segment.BaseSpeedKPH,
+		BaseConditions: baseConditions,
+		SpeedLimit:     segment.SpeedLimit,
+		Capacity:       segment.Capacity,
+		IsOpen:         segment.IsOpen,
+		Direction:      segment.Direction,
+		LanesForward:   segment.LanesForward,
+		LanesBackward:  segment.LanesBackward,
+	}
+}
+
+func SegmentFromDB(row domainmodels.RoadSegmentDB) domainmodels.RoadSegment {
+	segment := domainmodels.RoadSegment{
+		ID:             row.ID,
+		StartX:         row.StartX,
+		StartY:         row.StartY,
+		EndX:           row.EndX,
+		EndY:           row.EndY,
+		LengthKM:       row.LengthKM,
+		BaseSpeedKPH:   row.BaseSpeedKPH,
+		SpeedLimit:     row.SpeedLimit,
+		Capacity:       row.Capacity,
+		IsOpen:         row.IsOpen,
+		Direction:      row.Direction,
+		LanesForward:   row.LanesForward,
+		LanesBackward:  row.LanesBackward,
+		BaseConditions: row.BaseConditions,
+		VisualState:    domainmodels.SegmentVisualState{Opacity: 1.0},
+	}
+	if len(segment.BaseConditions) > 0 {
+		segment.VisualState.PrimaryColor = segment.BaseConditions[0].VisualColor
+	}
+	return segment
+}
+
+func ProfileToDB(profile domainmodels.VehicleProfile) domainmodels.VehicleProfileDB {
+	return domainmodels.VehicleProfileDB{
+		ID:                profile.ID,
+		Name:              profile.Name,
+		VehicleType:       string(profile.VehicleType),
+		TankLiters:        profile.TankLiters,
+		ConsumptionL100KM: profile.ConsumptionL100KM,
+		MaxSpeedKPH:       profile.MaxSpeedKPH,
+		CargoCapacityKG:   profile.CargoCapacityKG,
+	}
+}
+
+func ProfileFromDB(row domainmodels.VehicleProfileDB) domainmodels.VehicleProfile {
+	return domainmodels.VehicleProfile{
+		ID:                row.ID,
+		Name:              row.Name,
+		VehicleType:       constants.VehicleType(row.VehicleType),
+		TankLiters:        row.TankLiters,
+		ConsumptionL100KM: row.ConsumptionL100KM,
+		MaxSpeedKPH:       row.MaxSpeedKPH,
+		CargoCapacityKG:   row.CargoCapacityKG,
+	}
+}
+
+// VehicleToDB stores cells by their database ID; cellIDs maps grid
+// coordinates to those IDs.
+func VehicleToDB(gridID int64, vehicle *domainmodels.Vehicle, cellIDs map[[2]int64]int64) domainmodels.VehicleDB {
+	row := domainmodels.VehicleDB{
+		ID:                vehicle.ID,
+		GridID:            gridID,
+		VehicleClass:      string(vehicle.Class),
+		VehicleProfileID:  vehicle.Profile.ID,
+		Status:            string(vehicle.Status),
+		CurrentCellID:     cellIDFor(vehicle.CurrentCell, cellIDs),
+		EdgeProgress:      vehicle.SegmentProgress,
+		OriginCellID:      cellIDFor(vehicle.OriginCell, cellIDs),
+		DestinationCellID: cellIDFor(vehicle.DestinationCell, cellIDs),
+		CurrentSpeedKPH:   vehicle.CurrentSpeedKPH,
+		FuelLevel:         vehicle.FuelLevel,
+		ProximityLOD:      vehicle.ProximityLOD,
+	}
+	if vehicle.CurrentSegment != nil {
+		segmentID := vehicle.CurrentSegment.ID
+		row.CurrentSegID = &segmentID
+	}
+	return row
+}
+
+// VehicleFromDB rebuilds a vehicle on grid, whose indexes must already be
+// built; cellCoords maps cell IDs back to grid coordinates.
+func VehicleFromDB(row domainmodels.VehicleDB, profile domainmodels.VehicleProfile, grid *domainmodels.Grid, cellCoords map[int64][2]int64) domainmodels.Vehicle {
+	vehicle := domainmodels.Vehicle{
+		ID:              row.ID,
+		Class:           constants.VehicleClass(row.VehicleClass),
+		Profile:         profile,
+		Status:          constants.VehicleStatus(row.Status),
+		CurrentCell:     cellFor(row.CurrentCellID, grid, cellCoords),
+		SegmentProgress: row.EdgeProgress,
+		OriginCell:      cellFor(row.OriginCellID, grid, cellCoords),
+		DestinationCell: cellFor(row.DestinationCellID, grid, cellCoords),
+		CurrentSpeedKPH: row.CurrentSpeedKPH,
+		FuelLevel:       row.FuelLevel,
+		SpeedMultiplier: 1.0,
+		ProximityLOD:    row.ProximityLOD,
+	}
+	if row.CurrentSegID != nil {
+		vehicle.CurrentSegment = grid.GetSegment(*row.CurrentSegID)
+	}
+	return vehicle
+}
+
+func cellIDFor(cell *domainmodels.Cell, cellIDs map[[2]int64]int64) *int64 {
+	if cell == nil {
+		return nil
+	}
+	cellID, ok := cellIDs[[2]int64{cell.Xpos, cell.Ypos}]
+	if !ok {
+		return nil
+	}
+	return &cellID
+}
+
+func cellFor(cellID *int64, grid *domainmodels.Grid, cellCoords map[int64][2]int64) *domainmodels.Cell {
+	if cellID == nil {
+		return nil
+	}
+	coords, ok := cellCoords[*cellID]
+	if !ok {
+		return nil
+	}
+	return grid.CoordIndex[coords]
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// throughJSON encodes and decodes v, as the jsonb columns store it.
+func throughJSON[T any](t *testing.T, v T) T {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded T
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestSegmentsRoundTrip(t *testing.T) {
+	limit, capacity := int64(50), int64(12)
+	rain := domainmodels.RoadCondition{
+		ID: "rain", Name: "Rain", SpeedMultiplier: 0.8, FuelMultiplier: 1.1,
+		Severity: "minor", VisualColor: "#3366cc", VisualPattern: "dashed",
+	}
+	for name, segment := range map[string]domainmodels.RoadSegment{
+		"two-way": {
+			ID: 1, StartX: 0, StartY: 0, EndX: 1, EndY: 0, LengthKM: 0.5, BaseSpeedKPH: 50,
+			IsOpen: true, Direction: domainmodels.BothWays, LanesForward: 2, LanesBackward: 1,
+			SpeedLimit: &limit, Capacity: &capacity, BaseConditions: []domainmodels.RoadCondition{rain},
+		},
+		"forward only": {
+			ID: 2, StartX: 1, StartY: 0, EndX: 1, EndY: 1, LengthKM: 0.5, BaseSpeedKPH: 30,
+			IsOpen: true, Direction: domainmodels.ForwardOnly, LanesForward: 3,
+			BaseConditions: []domainmodels.RoadCondition{},
+		},
+		"backward only and closed": {
+			ID: 3, StartX: 1, StartY: 1, EndX: 0, EndY: 1, LengthKM: 0.5, BaseSpeedKPH: 30,
+			Direction: domainmodels.BackwardOnly, LanesBackward: 2,
+			BaseConditions: []domainmodels.RoadCondition{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			row := throughJSON(t, SegmentToDB(7, segment))
+			if row.GridID != 7 {
+				t.Errorf("stored for grid %d, want 7", row.GridID)
+			}
+
+			got := SegmentFromDB(row)
+			if got.Direction != segment.Direction || got.LanesForward != segment.LanesForward || got.LanesBackward != segment.LanesBackward {
+				t.Errorf("came back %q with %d/%d lanes, want %q with %d/%d", got.Direction, got.LanesForward,
+					got.LanesBackward, segment.Direction, segment.LanesForward, segment.LanesBackward)
+			}
+			if !reflect.DeepEqual(got.BaseConditions, segment.BaseConditions) {
+				t.Errorf("base conditions came back %+v, want %+v", got.BaseConditions, segment.BaseConditions)
+			}
+
+			// Only the visual state is derived on the way back.
+			got.VisualState = domainmodels.SegmentVisualState{}
+			if !reflect.DeepEqual(got, segment) {
+				t.Errorf("came back as %+v, want %+v", got, segment)
+			}
+		})
+	}
+}
+
+func TestSegmentWithoutBaseConditionsStoresAnEmptyList(t *testing.T) {
+	row := SegmentToDB(1, domainmodels.RoadSegment{ID: 1})
+	if data, _ := json.Marshal(row.BaseConditions); string(data) != "[]" {
+		t.Errorf("stored base conditions as %s, want []", data)
+	}
+}
+
+func TestSegmentColourComesFromItsFirstBaseCondition(t *testing.T) {
+	segment := SegmentFromDB(domainmodels.RoadSegmentDB{
+		BaseConditions: []domainmodels.RoadCondition{{ID: "ice", VisualColor: "#ffffff"}, {ID: "fog", VisualColor: "#999999"}},
+	})
+	if segment.VisualState.PrimaryColor != "#ffffff" || segment.VisualState.Opacity != 1 {
+		t.Errorf("visual state %+v, want the ice colour at full opacity", segment.VisualState)
+	}
+}
+
+func TestCellsRoundTrip(t *testing.T) {
+	amount := 40.0
+	for _, cell := range []domainmodels.Cell{
+		{Xpos: 3, Ypos: 4, CellType: domainmodels.CellTypeRefuel, RefuelAmount: &amount, RoadSegments: []domainmodels.CellRoad{}},
+		{Xpos: 0, Ypos: 9, CellType: domainmodels.CellTypeNormal, RoadSegments: []domainmodels.CellRoad{}},
+	} {
+		row := CellToDB(5, cell)
+		if got := CellFromDB(row); !reflect.DeepEqual(got, cell) {
+			t.Errorf("cell came back as %+v, want %+v", got, cell)
+		}
+	}
+}
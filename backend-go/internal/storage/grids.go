@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/gridloader"
+)
+
+// ErrGridNotFound is returned when no grid has the requested ID.
+var ErrGridNotFound = errors.New("grid not found")
+
+// SaveGrid stores grid, its cells and road segments in one transaction and
+// returns the new grid's ID. Cells and their road links are written in slice
+// order so LoadGrid hands them back the same way.
+func (s *Store) SaveGrid(ctx context.Context, grid *domainmodels.Grid, name string) (int64, error) {
+	var gridID int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx,
+			`INSERT INTO grids (name, dim_x, dim_y) VALUES ($1, $2, $3) RETURNING id`,
+			name, grid.DimX, grid.DimY).Scan(&gridID); err != nil {
+			return fmt.Errorf("failed to insert grid: %w", err)
+		}
+
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"cells"},
+			[]string{"grid_id", "xpos", "ypos", "cell_type", "refuel_amount"},
+			pgx.CopyFromSlice(len(grid.Cells), func(i int) ([]any, error) {
+				row := CellToDB(gridID, grid.Cells[i])
+				return []any{row.GridID, row.Xpos, row.Ypos, row.CellType, row.RefuelAmount}, nil
+			})); err != nil {
+			return fmt.Errorf("failed to copy cells: %w", err)
+		}
+
+		cellIDs, err := cellIDsFor(ctx, tx, gridID)
+		if err != nil {
+			return err
+		}
+
+		segments := uniqueSegments(grid)
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"road_segments"},
+			[]string{"grid_id", "id", "start_x", "start_y", "end_x", "end_y",
+				"length_km", "base_speed_kph", "base_conditions",
+				"speed_limit", "capacity", "weather_conditions", "is_open",
+				"direction", "lanes_forward", "lanes_backward"},
+			pgx.CopyFromSlice(len(segments), func(i int) ([]any, error) {
+				row := SegmentToDB(gridID, segments[i])
+				return []any{row.GridID, row.ID, row.StartX, row.StartY, row.EndX, row.EndY,
+					row.LengthKM, row.BaseSpeedKPH, row.BaseConditions,
+					row.SpeedLimit, row.Capacity, row.Weather, row.IsOpen,
+					row.Direction, row.LanesForward, row.LanesBackward}, nil
+			})); err != nil {
+			return fmt.Errorf("failed to copy road segments: %w", err)
+		}
+
+		var links [][]any
+		for _, cell := range grid.Cells {
+			cellID := cellIDs[[2]int64{cell.Xpos, cell.Ypos}]
+			for _, road := range cell.RoadSegments {
+				links = append(links, []any{gridID, cellID, road.RoadSegmentID})
+			}
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"cell_roads"},
+			[]string{"grid_id", "cell_id", "road_segment_id"}, pgx.CopyFromRows(links)); err != nil {
+			return fmt.Errorf("failed to copy cell roads: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("Saved %dx%d grid as %d (%d cells, %d road segments)\n",
+		grid.DimX, grid.DimY, gridID, len(grid.Cells), len(grid.SegmentIndex))
+	return gridID, nil
+}
+
+// LoadGrid reads a stored grid back and rebuilds its indexes and road graph
+// the same way LoadFromJSON does.
+func (s *Store) LoadGrid(ctx context.Context, gridID int64) (*domainmodels.Grid, error) {
+	startTime := time.Now()
+
+	gridRow, err := s.GetGrid(ctx, gridID)
+	if err != nil {
+		return nil, err
+	}
+
+	cellRows, err := queryRows[domainmodels.CellDB](ctx, s.pool,
+		`SELECT * FROM cells WHERE grid_id = $1 ORDER BY id`, gridID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cells of grid %d: %w", gridID, err)
+	}
+	segmentRows, err := queryRows[domainmodels.RoadSegmentDB](ctx, s.pool,
+		`SELECT * FROM road_segments WHERE grid_id = $1`, gridID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load road segments of grid %d: %w", gridID, err)
+	}
+	linkRows, err := queryRows[domainmodels.CellRoadDB](ctx, s.pool,
+		`SELECT * FROM cell_roads WHERE grid_id = $1 ORDER BY id`, gridID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cell roads of grid %d: %w", gridID, err)
+	}
+
+	grid := &domainmodels.Grid{
+		DimX:  gridRow.DimX,
+		DimY:  gridRow.DimY,
+		Cells: make([]domainmodels.Cell, 0, len(cellRows)),
+	}
+	cellPositions := make(map[int64]int, len(cellRows))
+	for _, row := range cellRows {
+		cellPositions[row.ID] = len(grid.Cells)
+		grid.Cells = append(grid.Cells, CellFromDB(row))
+	}
+
+	segments := make(map[int64]domainmodels.RoadSegment, len(segmentRows))
+	for _, row := range segmentRows {
+		segments[row.ID] = SegmentFromDB(row)
+	}
+
+	for _, link := range linkRows {
+		position, ok := cellPositions[link.CellID]
+		if !ok {
+			return nil, fmt.Errorf("cell road %d refers to unknown cell %d", link.ID, link.CellID)
+		}
+		segment, ok := segments[link.RoadSegmentID]
+		if !ok {
+			return nil, fmt.Errorf("cell road %d refers to unknown segment %d", link.ID, link.RoadSegmentID)
+		}
+		cell := &grid.Cells[position]
+		cell.RoadSegments = append(cell.RoadSegments, domainmodels.CellRoad{
+			RoadSegmentID: segment.ID,
+			RoadSegment:   segment,
+		})
+	}
+
+	loader := gridloader.NewGridLoader()
+	if err := loader.PrepareImportedGrid(grid, fmt.Sprintf("database grid %d", gridID), startTime); err != nil {
+		return nil, err
+	}
+	return grid, nil
+}
+
+func (s *Store) GetGrid(ctx context.Context, gridID int64) (domainmodels.GridDB, error) {
+	rows, err := queryRows[domainmodels.GridDB](ctx, s.pool, `SELECT * FROM grids WHERE id = $1`, gridID)
+	if err != nil {
+		return domainmodels.GridDB{}, fmt.Errorf("failed to load grid %d: %w", gridID, err)
+	}
+	if len(rows) == 0 {
+		return domainmodels.GridDB{}, fmt.Errorf("%w: %d", ErrGridNotFound, gridID)
+	}
+	return rows[0], nil
+}
+
+// ListGrids returns every stored grid, newest first.
+func (s *Store) ListGrids(ctx context.Context) ([]domainmodels.GridDB, error) {
+	rows, err := queryRows[domainmodels.GridDB](ctx, s.pool, `SELECT * FROM grids ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grids: %w", err)
+	}
+	return rows, nil
+}
+
+// DeleteGrid removes a grid together with its cells, segments and vehicles.
+func (s *Store) DeleteGrid(ctx context.Context, gridID int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM grids WHERE id = $1`, gridID)
+	if err != nil {
+		return fmt.Errorf("failed to delete grid %d: %w", gridID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %d", ErrGridNotFound, gridID)
+	}
+	return nil
+}
+
+// uniqueSegments returns each segment once, preferring the canonical copy the
+// segment index points at, in order of first appearance.
+func uniqueSegments(grid *domainmodels.Grid) []domainmodels.RoadSegment {
+	seen := make(map[int64]bool)
+	var segments []domainmodels.RoadSegment
+	for _, cell := range grid.Cells {
+		for _, road := range cell.RoadSegments {
+			if seen[road.RoadSegmentID] {
+				continue
+			}
+			seen[road.RoadSegmentID] = true
+
+			segment := road.RoadSegment
+			if canonical := grid.GetSegment(road.RoadSegmentID); canonical != nil {
+				segment = *canonical
+			}
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func cellIDsFor(ctx context.Context, q querier, gridID int64) (map[[2]int64]int64, error) {
+	rows, err := q.Query(ctx, `SELECT id, xpos, ypos FROM cells WHERE grid_id = $1`, gridID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cell IDs of grid %d: %w", gridID, err)
+	}
+	defer rows.Close()
+
+	cellIDs := make(map[[2]int64]int64)
+	for rows.Next() {
+		var cellID, x, y int64
+		if err := rows.Scan(&cellID, &x, &y); err != nil {
+			return nil, fmt.Errorf("failed to read cell IDs of grid %d: %w", gridID, err)
+		}
+		cellIDs[[2]int64{x, y}] = cellID
+	}
+	return cellIDs, rows.Err()
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func queryRows[T any](ctx context.Context, q querier, sql string, args ...any) ([]T, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[T])
+}
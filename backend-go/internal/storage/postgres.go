@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"owenvi.com/fleetsim/internal/dbmigrate"
+)
+
+// migrations create the map and fleet schema. Segment IDs are only unique
+// within a grid, so road segments are keyed by (grid_id, id).
+var migrations = []dbmigrate.Migration{
+	{Version: 1, Statement: `CREATE TABLE IF NOT EXISTS grids (
+		id         BIGSERIAL PRIMARY KEY,
+		name       TEXT        NOT NULL DEFAULT '',
+		dim_x      BIGINT      NOT NULL,
+		dim_y      BIGINT      NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`},
+	{Version: 2, Statement: `CREATE TABLE IF NOT EXISTS cells (
+		id            BIGSERIAL PRIMARY KEY,
+		grid_id       BIGINT      NOT NULL REFERENCES grids (id) ON DELETE CASCADE,
+		xpos          BIGINT      NOT NULL,
+		ypos          BIGINT      NOT NULL,
+		cell_type     TEXT        NOT NULL,
+		refuel_amount DOUBLE PRECISION,
+		created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (grid_id, xpos, ypos)
+	)`},
+	{Version: 3, Statement: `CREATE TABLE IF NOT EXISTS road_segments (
+		grid_id            BIGINT           NOT NULL REFERENCES grids (id) ON DELETE CASCADE,
+		id                 BIGINT           NOT NULL,
+		start_x            BIGINT           NOT NULL,
+		start_y            BIGINT           NOT NULL,
+		end_x              BIGINT           NOT NULL,
+		end_y              BIGINT           NOT NULL,
+		length_km          DOUBLE PRECISION NOT NULL,
+		base_speed_kph     DOUBLE PRECISION NOT NULL,
+		base_conditions    JSONB            NOT NULL DEFAULT '[]',
+		speed_limit        BIGINT,
+		capacity           BIGINT,
+		weather_conditions TEXT[],
+		is_open            BOOLEAN          NOT NULL DEFAULT TRUE,
+		created_at         TIMESTAMPTZ      NOT NULL DEFAULT now(),
+		updated_at         TIMESTAMPTZ      NOT NULL DEFAULT now(),
+		PRIMARY KEY (grid_id, id)
+	)`},
+	{Version: 4, Statement: `CREATE TABLE IF NOT EXISTS cell_roads (
+		id              BIGSERIAL PRIMARY KEY,
+		grid_id         BIGINT      NOT NULL,
+		cell_id         BIGINT      NOT NULL REFERENCES cells (id) ON DELETE CASCADE,
+		road_segment_id BIGINT      NOT NULL,
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		FOREIGN KEY (grid_id, road_segment_id) REFERENCES road_segments (grid_id, id) ON DELETE CASCADE
+	)`},
+	{Version: 5, Statement: `CREATE INDEX IF NOT EXISTS cell_roads_cell_idx ON cell_roads (cell_id)`},
+	{Version: 6, Statement: `CREATE TABLE IF NOT EXISTS vehicle_profiles (
+		id                      BIGINT PRIMARY KEY,
+		name                    TEXT             NOT NULL,
+		vehicle_type            TEXT             NOT NULL,
+		tank_liters             DOUBLE PRECISION NOT NULL,
+		consumption_l_per_100km DOUBLE PRECISION NOT NULL,
+		max_speed_kph           INTEGER          NOT NULL,
+		cargo_capacity_kg       DOUBLE PRECISION NOT NULL,
+		created_at              TIMESTAMPTZ      NOT NULL DEFAULT now(),
+		updated_at              TIMESTAMPTZ      NOT NULL DEFAULT now()
+	)`},
+	{Version: 7, Statement: `CREATE TABLE IF NOT EXISTS vehicles (
+		id                  TEXT             NOT NULL,
+		grid_id             BIGINT           NOT NULL REFERENCES grids (id) ON DELETE CASCADE,
+		vehicle_class       TEXT             NOT NULL,
+		fleet_id            UUID,
+		vehicle_profile_id  BIGINT           NOT NULL REFERENCES vehicle_profiles (id),
+		status              TEXT             NOT NULL,
+		current_cell_id     BIGINT REFERENCES cells (id),
+		current_segment_id  BIGINT,
+		edge_progress       DOUBLE PRECISION NOT NULL DEFAULT 0,
+		origin_cell_id      BIGINT REFERENCES cells (id),
+		destination_cell_id BIGINT REFERENCES cells (id),
+		current_speed_kph   DOUBLE PRECISION NOT NULL DEFAULT 0,
+		fuel_level          DOUBLE PRECISION NOT NULL,
+		proximity_lod       BOOLEAN          NOT NULL DEFAULT FALSE,
+		created_at          TIMESTAMPTZ      NOT NULL DEFAULT now(),
+		updated_at          TIMESTAMPTZ      NOT NULL DEFAULT now(),
+		PRIMARY KEY (grid_id, id),
+		FOREIGN KEY (grid_id, current_segment_id) REFERENCES road_segments (grid_id, id)
+	)`},
+	{Version: 8, Statement: `ALTER TABLE road_segments
+		ADD COLUMN IF NOT EXISTS direction      TEXT   NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS lanes_forward  BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS lanes_backward BIGINT NOT NULL DEFAULT 0`},
+}
+
+// Store keeps generated cities and their fleets in Postgres so many
+// simulation runs can share one map.
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+func NewStore(ctx context.Context, dsn string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open grid database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to reach grid database: %w", err)
+	}
+	return &Store{pool: pool}, nil
+}
+
+func (s *Store) Migrate(ctx context.Context) error {
+	return dbmigrate.Apply(ctx, s.pool, "storage", migrations)
+}
+
+func (s *Store) Close() {
+	s.pool.Close()
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const upsertProfileSQL = `INSERT INTO vehicle_profiles
+	(id, name, vehicle_type, tank_liters, consumption_l_per_100km, max_speed_kph, cargo_capacity_kg)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (id) DO UPDATE SET
+		name = EXCLUDED.name,
+		vehicle_type = EXCLUDED.vehicle_type,
+		tank_liters = EXCLUDED.tank_liters,
+		consumption_l_per_100km = EXCLUDED.consumption_l_per_100km,
+		max_speed_kph = EXCLUDED.max_speed_kph,
+		cargo_capacity_kg = EXCLUDED.cargo_capacity_kg,
+		updated_at = now()`
+
+const upsertVehicleSQL = `INSERT INTO vehicles
+	(id, grid_id, vehicle_class, fleet_id, vehicle_profile_id, status,
+	 current_cell_id, current_segment_id, edge_progress, origin_cell_id, destination_cell_id,
+	 current_speed_kph, fuel_level, proximity_lod)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	ON CONFLICT (grid_id, id) DO UPDATE SET
+		vehicle_class = EXCLUDED.vehicle_class,
+		fleet_id = EXCLUDED.fleet_id,
+		vehicle_profile_id = EXCLUDED.vehicle_profile_id,
+		status = EXCLUDED.status,
+		current_cell_id = EXCLUDED.current_cell_id,
+		current_segment_id = EXCLUDED.current_segment_id,
+		edge_progress = EXCLUDED.edge_progress,
+		origin_cell_id = EXCLUDED.origin_cell_id,
+		destination_cell_id = EXCLUDED.destination_cell_id,
+		current_speed_kph = EXCLUDED.current_speed_kph,
+		fuel_level = EXCLUDED.fuel_level,
+		proximity_lod = EXCLUDED.proximity_lod,
+		updated_at = now()`
+
+func (s *Store) SaveVehicleProfiles(ctx context.Context, profiles []domainmodels.VehicleProfile) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		return saveProfiles(ctx, tx, profiles)
+	})
+}
+
+func (s *Store) LoadVehicleProfiles(ctx context.Context) ([]domainmodels.VehicleProfile, error) {
+	rows, err := queryRows[domainmodels.VehicleProfileDB](ctx, s.pool, `SELECT * FROM vehicle_profiles ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vehicle profiles: %w", err)
+	}
+	profiles := make([]domainmodels.VehicleProfile, 0, len(rows))
+	for _, row := range rows {
+		profiles = append(profiles, ProfileFromDB(row))
+	}
+	return profiles, nil
+}
+
+// SaveVehicles upserts vehicles on the stored grid gridID, together with the
+// profiles they use, in one transaction.
+func (s *Store) SaveVehicles(ctx context.Context, gridID int64, vehicles []*domainmodels.Vehicle) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		profiles := make(map[int64]domainmodels.VehicleProfile)
+		for _, vehicle := range vehicles {
+			profiles[vehicle.Profile.ID] = vehicle.Profile
+		}
+		unique := make([]domainmodels.VehicleProfile, 0, len(profiles))
+		for _, profile := range profiles {
+			unique = append(unique, profile)
+		}
+		if err := saveProfiles(ctx, tx, unique); err != nil {
+			return err
+		}
+
+		cellIDs, err := cellIDsFor(ctx, tx, gridID)
+		if err != nil {
+			return err
+		}
+
+		batch := &pgx.Batch{}
+		for _, vehicle := range vehicles {
+			row := VehicleToDB(gridID, vehicle, cellIDs)
+			batch.Queue(upsertVehicleSQL,
+				row.ID, row.GridID, row.VehicleClass, row.FleetID, row.VehicleProfileID, row.Status,
+				row.CurrentCellID, row.CurrentSegID, row.EdgeProgress, row.OriginCellID, row.DestinationCellID,
+				row.CurrentSpeedKPH, row.FuelLevel, row.ProximityLOD)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to save vehicles on grid %d: %w", gridID, err)
+		}
+		return nil
+	})
+}
+
+// LoadVehicles reads the vehicles stored on gridID and places them on grid,
+// which must be the same grid as returned by LoadGrid.
+func (s *Store) LoadVehicles(ctx context.Context, gridID int64, grid *domainmodels.Grid) ([]domainmodels.Vehicle, error) {
+	profiles, err := s.LoadVehicleProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profilesByID := make(map[int64]domainmodels.VehicleProfile, len(profiles))
+	for _, profile := range profiles {
+		profilesByID[profile.ID] = profile
+	}
+
+	cellIDs, err := cellIDsFor(ctx, s.pool, gridID)
+	if err != nil {
+		return nil, err
+	}
+	cellCoords := make(map[int64][2]int64, len(cellIDs))
+	for coords, cellID := range cellIDs {
+		cellCoords[cellID] = coords
+	}
+
+	rows, err := queryRows[domainmodels.VehicleDB](ctx, s.pool,
+		`SELECT * FROM vehicles WHERE grid_id = $1 ORDER BY id`, gridID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vehicles of grid %d: %w", gridID, err)
+	}
+
+	vehicles := make([]domainmodels.Vehicle, 0, len(rows))
+	for _, row := range rows {
+		profile, ok := profilesByID[row.VehicleProfileID]
+		if !ok {
+			return nil, fmt.Errorf("vehicle %s uses unknown profile %d", row.ID, row.VehicleProfileID)
+		}
+		vehicles = append(vehicles, VehicleFromDB(row, profile, grid, cellCoords))
+	}
+	return vehicles, nil
+}
+
+func saveProfiles(ctx context.Context, tx pgx.Tx, profiles []domainmodels.VehicleProfile) error {
+	batch := &pgx.Batch{}
+	for _, profile := range profiles {
+		row := ProfileToDB(profile)
+		batch.Queue(upsertProfileSQL, row.ID, row.Name, row.VehicleType,
+			row.TankLiters, row.ConsumptionL100KM, row.MaxSpeedKPH, row.CargoCapacityKG)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to save vehicle profiles: %w", err)
+	}
+	return nil
+}
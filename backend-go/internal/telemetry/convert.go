@@ -0,0 +1,94 @@
+package telemetry
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const (
+	EventTypePosition    = "position"
+	EventTypeFuel        = "fuel"
+	EventTypeTrafficLoad = "traffic_load"
+)
+
+// FromTelemetryEvent flattens an event into a hypertable row. Grid cells have
+// no geographic position, so cell coordinates go in the lat/lng columns: the
+// Y coordinate as lat and the X coordinate as lng.
+func FromTelemetryEvent(event domainmodels.TelemetryEvent) domainmodels.TelemetryEventDB {
+	row := domainmodels.TelemetryEventDB{
+		SimulationRunID: event.SimulationRunID,
+		Timestamp:       event.Timestamp,
+		EventType:       event.EventType,
+		VehicleID:       event.VehicleID,
+		SegmentID:       event.SegmentID,
+		SpeedKPH:        event.SpeedKPH,
+		FuelLevel:       event.FuelLevel,
+		EdgeProgress:    event.EdgeProgress,
+		FleetCount:      event.FleetCount,
+		BackgroundCount: event.BackgroundCount,
+		Capacity:        event.Capacity,
+		CreatedAt:       time.Now(),
+	}
+	if row.SegmentID == nil {
+		row.SegmentID = event.CurrentSegID
+	}
+	if event.CellX != nil {
+		lng := float64(*event.CellX)
+		row.Lng = &lng
+	}
+	if event.CellY != nil {
+		lat := float64(*event.CellY)
+		row.Lat = &lat
+	}
+	if event.Status != nil {
+		status := string(*event.Status)
+		row.Status = &status
+	}
+	return row
+}
+
+// FromVehicleState records a vehicle snapshot as a position event.
+func FromVehicleState(runID uuid.UUID, state domainmodels.VehicleState) domainmodels.TelemetryEventDB {
+	vehicleID := state.VehicleID
+	lat := float64(state.CellY)
+	lng := float64(state.CellX)
+	speed := state.SpeedKPH
+	fuel := state.FuelLevel
+	progress := state.EdgeProgress
+	status := string(state.Status)
+
+	return domainmodels.TelemetryEventDB{
+		SimulationRunID: runID,
+		Timestamp:       state.Timestamp,
+		EventType:       EventTypePosition,
+		VehicleID:       &vehicleID,
+		SegmentID:       state.CurrentSegID,
+		Lat:             &lat,
+		Lng:             &lng,
+		SpeedKPH:        &speed,
+		FuelLevel:       &fuel,
+		EdgeProgress:    &progress,
+		Status:          &status,
+		CreatedAt:       time.Now(),
+	}
+}
+
+func FromRoadLoadEvent(runID uuid.UUID, timestamp time.Time, load domainmodels.RoadLoadEvent) domainmodels.TelemetryEventDB {
+	segmentID := load.SegmentID
+	fleetCount := load.FleetCount
+	backgroundCount := load.BackgroundCount
+	capacity := load.Capacity
+
+	return domainmodels.TelemetryEventDB{
+		SimulationRunID: runID,
+		Timestamp:       timestamp,
+		EventType:       EventTypeTrafficLoad,
+		SegmentID:       &segmentID,
+		FleetCount:      &fleetCount,
+		BackgroundCount: &backgroundCount,
+		Capacity:        &capacity,
+		CreatedAt:       time.Now(),
+	}
+}
@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"owenvi.com/fleetsim/internal/dbmigrate"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const eventsTable = "telemetry_events"
+
+// copyColumns is the column order CopyEvents streams rows in; id is
+// generated by the database.
+var copyColumns = []string{
+	"simulation_run_id", "timestamp", "event_type",
+	"vehicle_id", "segment_id",
+	"lat", "lng", "speed_kph", "fuel_level", "edge_progress", "status",
+	"fleet_count", "background_count", "capacity",
+	"created_at",
+}
+
+// migrations create the telemetry schema. The hypertable step only runs where
+// the timescaledb extension is installed, so a plain Postgres container works
+// as a local stand-in.
+var migrations = []dbmigrate.Migration{
+	{Version: 1, Statement: `CREATE TABLE IF NOT EXISTS telemetry_events (
+		id                BIGINT GENERATED ALWAYS AS IDENTITY,
+		simulation_run_id UUID        NOT NULL,
+		timestamp         TIMESTAMPTZ NOT NULL,
+		event_type        TEXT        NOT NULL,
+		vehicle_id        TEXT,
+		segment_id        BIGINT,
+		lat               DOUBLE PRECISION,
+		lng               DOUBLE PRECISION,
+		speed_kph         DOUBLE PRECISION,
+		fuel_level        DOUBLE PRECISION,
+		edge_progress     DOUBLE PRECISION,
+		status            TEXT,
+		fleet_count       INTEGER,
+		background_count  INTEGER,
+		capacity          INTEGER,
+		created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (id, timestamp)
+	)`},
+	{Version: 2, Statement: `CREATE INDEX IF NOT EXISTS telemetry_events_run_time_idx
+		ON telemetry_events (simulation_run_id, timestamp DESC)`},
+	{Version: 3, Statement: `CREATE INDEX IF NOT EXISTS telemetry_events_vehicle_time_idx
+		ON telemetry_events (vehicle_id, timestamp DESC) WHERE vehicle_id IS NOT NULL`},
+	{Version: 4, Statement: `DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb') THEN
+			CREATE EXTENSION IF NOT EXISTS timescaledb;
+			PERFORM create_hypertable('telemetry_events', 'timestamp',
+				chunk_time_interval => INTERVAL '15 minutes',
+				if_not_exists => TRUE, migrate_data => TRUE);
+		END IF;
+	END
+	$$`},
+}
+
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(ctx context.Context, dsn string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open telemetry database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to reach telemetry database: %w", err)
+	}
+	return &PostgresStore{pool: pool}, nil
+}
+
+func (p *PostgresStore) Migrate(ctx context.Context) error {
+	return dbmigrate.Apply(ctx, p.pool, "telemetry", migrations)
+}
+
+func (p *PostgresStore) CopyEvents(ctx context.Context, rows []domainmodels.TelemetryEventDB) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	source := pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+		row := rows[i]
+		return []any{
+			row.SimulationRunID, row.Timestamp, row.EventType,
+			row.VehicleID, row.SegmentID,
+			row.Lat, row.Lng, row.SpeedKPH, row.FuelLevel, row.EdgeProgress, row.Status,
+			row.FleetCount, row.BackgroundCount, row.Capacity,
+			row.CreatedAt,
+		}, nil
+	})
+
+	copied, err := p.pool.CopyFrom(ctx, pgx.Identifier{eventsTable}, copyColumns, source)
+	if err != nil {
+		return copied, fmt.Errorf("failed to copy %d telemetry rows: %w", len(rows), err)
+	}
+	return copied, nil
+}
+
+// DeleteBefore enforces retention for one run. Every run stamps its rows with
+// its own simulated time, so chunks of the shared table cannot be dropped by
+// age; the run's expired rows are deleted instead.
+func (p *PostgresStore) DeleteBefore(ctx context.Context, runID uuid.UUID, cutoff time.Time) (int64, error) {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM telemetry_events WHERE simulation_run_id = $1 AND timestamp < $2`, runID, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired telemetry: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (p *PostgresStore) Close() {
+	p.pool.Close()
+}
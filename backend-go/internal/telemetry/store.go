@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// Store is where the writer sends flushed batches. PostgresStore talks to
+// TimescaleDB or plain Postgres; MemoryStore stands in for both in tests and
+// runs without a database.
+type Store interface {
+	Migrate(ctx context.Context) error
+	CopyEvents(ctx context.Context, rows []domainmodels.TelemetryEventDB) (int64, error)
+	// DeleteBefore removes runID's rows stamped before cutoff, leaving other
+	// runs' rows alone.
+	DeleteBefore(ctx context.Context, runID uuid.UUID, cutoff time.Time) (int64, error)
+	Close()
+}
+
+type MemoryStore struct {
+	mu     sync.Mutex
+	rows   []domainmodels.TelemetryEventDB
+	nextID int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+func (m *MemoryStore) Migrate(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) CopyEvents(ctx context.Context, rows []domainmodels.TelemetryEventDB) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, row := range rows {
+		row.ID = m.nextID
+		m.nextID++
+		m.rows = append(m.rows, row)
+	}
+	return int64(len(rows)), nil
+}
+
+func (m *MemoryStore) DeleteBefore(ctx context.Context, runID uuid.UUID, cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.rows[:0]
+	for _, row := range m.rows {
+		if row.SimulationRunID == runID && row.Timestamp.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, row)
+	}
+	deleted := int64(len(m.rows) - len(kept))
+	m.rows = kept
+	return deleted, nil
+}
+
+func (m *MemoryStore) Close() {}
+
+// Rows returns a copy of everything stored, oldest first.
+func (m *MemoryStore) Rows() []domainmodels.TelemetryEventDB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := make([]domainmodels.TelemetryEventDB, len(m.rows))
+	copy(rows, m.rows)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Timestamp.Before(rows[j].Timestamp)
+	})
+	return rows
+}
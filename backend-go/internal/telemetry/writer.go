@@ -0,0 +1,198 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// maxBufferedBatches bounds how much the writer holds on to while the store is
+// unreachable; past it the oldest rows are dropped.
+const maxBufferedBatches = 20
+
+// retentionCheckInterval is how often expired telemetry is pruned.
+const retentionCheckInterval = 5 * time.Minute
+
+// Writer buffers telemetry rows for one simulation run and flushes them to a
+// Store in batches, either when a batch fills up or on the flush interval.
+// Record methods are safe to call from the simulation loop while Run flushes
+// on its own goroutine.
+type Writer struct {
+	store Store
+	runID uuid.UUID
+
+	batchSize     int
+	flushInterval time.Duration
+	retention     time.Duration
+	// clock is the run's simulated time, which rows are stamped with and
+	// retention is measured in; without one it is wall time.
+	clock simclock.Clock
+
+	mu      sync.Mutex
+	buffer  []domainmodels.TelemetryEventDB
+	dropped int64
+
+	flushSignal chan struct{}
+}
+
+func NewWriter(store Store, cfg *config.SimulationConfig, runID uuid.UUID) *Writer {
+	return &Writer{
+		store:         store,
+		runID:         runID,
+		batchSize:     cfg.TelemetryBatchSize,
+		flushInterval: time.Duration(cfg.TelemetryFlushInterval) * time.Millisecond,
+		retention:     time.Duration(cfg.TimescaleRetentionHours) * time.Hour,
+		buffer:        make([]domainmodels.TelemetryEventDB, 0, cfg.TelemetryBatchSize),
+		flushSignal:   make(chan struct{}, 1),
+	}
+}
+
+// SetClock measures retention in clock's simulated time, the time the
+// simulation stamps rows with. Set it before Run.
+func (w *Writer) SetClock(clock simclock.Clock) {
+	w.clock = clock
+}
+
+func (w *Writer) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock.Now()
+}
+
+func (w *Writer) RunID() uuid.UUID {
+	return w.runID
+}
+
+func (w *Writer) RecordVehicleState(state domainmodels.VehicleState) {
+	w.enqueue(FromVehicleState(w.runID, state))
+}
+
+func (w *Writer) RecordRoadLoad(timestamp time.Time, load domainmodels.RoadLoadEvent) {
+	w.enqueue(FromRoadLoadEvent(w.runID, timestamp, load))
+}
+
+func (w *Writer) RecordEvent(event domainmodels.TelemetryEvent) {
+	if event.SimulationRunID == uuid.Nil {
+		event.SimulationRunID = w.runID
+	}
+	w.enqueue(FromTelemetryEvent(event))
+}
+
+func (w *Writer) enqueue(rows ...domainmodels.TelemetryEventDB) {
+	w.mu.Lock()
+	w.buffer = append(w.buffer, rows...)
+	w.trimLocked()
+	full := len(w.buffer) >= w.batchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *Writer) trimLocked() {
+	limit := w.batchSize * maxBufferedBatches
+	if over := len(w.buffer) - limit; over > 0 {
+		w.buffer = append(w.buffer[:0], w.buffer[over:]...)
+		w.dropped += int64(over)
+	}
+}
+
+// Flush writes everything buffered so far, one batch at a time. Rows from a
+// batch that fails to copy go back to the front of the buffer for the next
+// attempt.
+func (w *Writer) Flush(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		if len(w.buffer) == 0 {
+			w.mu.Unlock()
+			return nil
+		}
+		n := min(len(w.buffer), w.batchSize)
+		batch := make([]domainmodels.TelemetryEventDB, n)
+		copy(batch, w.buffer[:n])
+		w.buffer = append(w.buffer[:0], w.buffer[n:]...)
+		w.mu.Unlock()
+
+		if _, err := w.store.CopyEvents(ctx, batch); err != nil {
+			w.mu.Lock()
+			w.buffer = append(batch, w.buffer...)
+			w.trimLocked()
+			w.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// Stats reports how many rows are waiting to be flushed and how many were
+// dropped because the buffer overflowed.
+func (w *Writer) Stats() (buffered int, dropped int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.buffer), w.dropped
+}
+
+// EnforceRetention deletes this run's telemetry older than the configured
+// retention before now, a simulated time. Other runs' rows are stamped on their
+// own clocks and are left alone.
+func (w *Writer) EnforceRetention(ctx context.Context, now time.Time) error {
+	removed, err := w.store.DeleteBefore(ctx, w.runID, now.Add(-w.retention))
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		fmt.Printf("Telemetry retention pruned %d entries older than %v\n", removed, w.retention)
+	}
+	return nil
+}
+
+// Run flushes on the configured interval, whenever a batch fills up, and once
+// more when ctx is cancelled. Retention is enforced at startup and every few
+// minutes of wall time after, each time against the clock's current time.
+func (w *Writer) Run(ctx context.Context) {
+	flushTicker := time.NewTicker(w.flushInterval)
+	defer flushTicker.Stop()
+	retentionTicker := time.NewTicker(retentionCheckInterval)
+	defer retentionTicker.Stop()
+
+	if err := w.EnforceRetention(ctx, w.now()); err != nil {
+		fmt.Printf("Telemetry retention failed: %v\n", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := w.Flush(shutdownCtx); err != nil {
+				fmt.Printf("Final telemetry flush failed: %v\n", err)
+			}
+			cancel()
+			return
+		case <-flushTicker.C:
+			w.flushAndLog(ctx)
+		case <-w.flushSignal:
+			w.flushAndLog(ctx)
+		case <-retentionTicker.C:
+			if err := w.EnforceRetention(ctx, w.now()); err != nil {
+				fmt.Printf("Telemetry retention failed: %v\n", err)
+			}
+		}
+	}
+}
+
+func (w *Writer) flushAndLog(ctx context.Context) {
+	if err := w.Flush(ctx); err != nil && ctx.Err() == nil {
+		buffered, dropped := w.Stats()
+		fmt.Printf("Telemetry flush failed (%d buffered, %d dropped): %v\n", buffered, dropped, err)
+	}
+}
@@ -0,0 +1,152 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/roadgraph/simclock"
+)
+
+// flakyStore is a MemoryStore that records the size of every batch copied
+// to it and fails every copy while down is set.
+type flakyStore struct {
+	*MemoryStore
+	down    bool
+	batches []int
+}
+
+func (s *flakyStore) CopyEvents(ctx context.Context, rows []domainmodels.TelemetryEventDB) (int64, error) {
+	if s.down {
+		return 0, errors.New("store unreachable")
+	}
+	s.batches = append(s.batches, len(rows))
+	return s.MemoryStore.CopyEvents(ctx, rows)
+}
+
+func newTestWriter(store Store, batchSize int) *Writer {
+	cfg := config.Config()
+	cfg.TelemetryBatchSize = batchSize
+	return NewWriter(store, cfg, uuid.New())
+}
+
+var testStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func recordPositions(writer *Writer, count int) {
+	for i := range count {
+		writer.RecordEvent(domainmodels.TelemetryEvent{
+			Timestamp: testStart.Add(time.Duration(i) * time.Second),
+			EventType: EventTypePosition,
+		})
+	}
+}
+
+func TestFlushWritesInBatches(t *testing.T) {
+	store := &flakyStore{MemoryStore: NewMemoryStore()}
+	writer := newTestWriter(store, 3)
+	recordPositions(writer, 7)
+
+	if err := writer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.batches) != 3 || store.batches[0] != 3 || store.batches[1] != 3 || store.batches[2] != 1 {
+		t.Errorf("copied batches of %v, want [3 3 1]", store.batches)
+	}
+	rows := store.Rows()
+	if len(rows) != 7 {
+		t.Fatalf("stored %d rows, want 7", len(rows))
+	}
+	for _, row := range rows {
+		if row.SimulationRunID != writer.RunID() {
+			t.Fatalf("row stamped with run %v, want %v", row.SimulationRunID, writer.RunID())
+		}
+	}
+}
+
+func TestFailedFlushKeepsRowsUpToTheBufferLimit(t *testing.T) {
+	store := &flakyStore{MemoryStore: NewMemoryStore(), down: true}
+	writer := newTestWriter(store, 2)
+	limit := 2 * maxBufferedBatches
+	recordPositions(writer, limit+5)
+
+	if err := writer.Flush(context.Background()); err == nil {
+		t.Fatal("Flush to an unreachable store succeeded")
+	}
+	if buffered, dropped := writer.Stats(); buffered != limit || dropped != 5 {
+		t.Fatalf("%d buffered and %d dropped, want %d and 5", buffered, dropped, limit)
+	}
+
+	store.down = false
+	if err := writer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rows := store.Rows()
+	if len(rows) != limit {
+		t.Fatalf("stored %d rows once the store came back, want %d", len(rows), limit)
+	}
+	// The oldest rows are the ones dropped.
+	if oldest := rows[0].Timestamp.Sub(testStart); oldest != 5*time.Second {
+		t.Errorf("oldest kept row is %v in, want 5s", oldest)
+	}
+}
+
+func TestRunFlushesWhenStopped(t *testing.T) {
+	store := NewMemoryStore()
+	writer := newTestWriter(store, 100)
+	recordPositions(writer, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		writer.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+	if rows := store.Rows(); len(rows) != 4 {
+		t.Errorf("stored %d rows after stopping, want 4", len(rows))
+	}
+}
+
+func TestRetentionRunsOnSimulatedTime(t *testing.T) {
+	cfg := config.Config()
+	cfg.TimescaleRetentionHours = 24
+
+	clock, err := simclock.New(simclock.Accelerated, 3600, testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	writer := NewWriter(store, cfg, uuid.New())
+	writer.SetClock(clock)
+
+	ctx := context.Background()
+	otherRun := uuid.New()
+	rows := []domainmodels.TelemetryEventDB{
+		{SimulationRunID: writer.RunID(), Timestamp: testStart, EventType: EventTypePosition},
+		{SimulationRunID: writer.RunID(), Timestamp: testStart.Add(48 * time.Hour), EventType: EventTypePosition},
+		// Another run sharing the table keeps its own clock.
+		{SimulationRunID: otherRun, Timestamp: testStart, EventType: EventTypePosition},
+	}
+	if _, err := store.CopyEvents(ctx, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	// Far less than a day of wall time has passed, but the run is two days in.
+	clock.Advance(50 * time.Hour)
+	if err := writer.EnforceRetention(ctx, writer.now()); err != nil {
+		t.Fatal(err)
+	}
+	kept := store.Rows()
+	if len(kept) != 2 || kept[0].SimulationRunID != otherRun || !kept[1].Timestamp.Equal(rows[1].Timestamp) {
+		t.Fatalf("kept %+v, want the other run's row and this run's from simulated hour 48", kept)
+	}
+}
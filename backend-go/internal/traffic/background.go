@@ -0,0 +1,98 @@
+package traffic
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// arterialSpeedKPH is the base speed the grid loader gives main arteries;
+// segments are weighted for background demand relative to it.
+const arterialSpeedKPH = 70.0
+
+// maxBackgroundShare keeps some capacity free for fleet vehicles even on the
+// busiest artery at rush hour.
+const maxBackgroundShare = 0.9
+
+// backgroundNoise is the relative spread of background counts around the
+// demand curve from one update to the next.
+const backgroundNoise = 0.15
+
+// BackgroundTraffic models non-fleet vehicles in aggregate: each segment gets
+// a count of constants.VehicleClassBackground vehicles written to
+// CurrentTrafficLoad.BackgroundCount instead of individually simulated cars.
+type BackgroundTraffic struct {
+	rng *rand.Rand
+
+	peakUtilization float64
+	startHour       float64
+}
+
+func NewBackgroundTraffic(cfg *config.SimulationConfig, seed int64) *BackgroundTraffic {
+	return &BackgroundTraffic{
+		rng:             rand.New(rand.NewSource(seed)),
+		peakUtilization: cfg.BackgroundPeakUtilization,
+		startHour:       cfg.SimulationStartHour,
+	}
+}
+
+// HourOfDay converts simulated seconds since the run started into a clock
+// hour in [0,24).
+func (b *BackgroundTraffic) HourOfDay(elapsedSeconds float64) float64 {
+	return math.Mod(b.startHour+elapsedSeconds/3600.0, 24.0)
+}
+
+// Update sets the background count on every segment for the simulated time
+// elapsedSeconds into the run.
+func (b *BackgroundTraffic) Update(grid *domainmodels.Grid, elapsedSeconds float64) {
+	demand := Demand(b.HourOfDay(elapsedSeconds))
+
+	segmentIDs := make([]int64, 0, len(grid.SegmentIndex))
+	for segmentID := range grid.SegmentIndex {
+		segmentIDs = append(segmentIDs, segmentID)
+	}
+	sort.Slice(segmentIDs, func(i, j int) bool { return segmentIDs[i] < segmentIDs[j] })
+
+	for _, segmentID := range segmentIDs {
+		segment := grid.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+		segment.CurrentTrafficLoad.BackgroundCount = b.countFor(segment, demand)
+	}
+}
+
+func (b *BackgroundTraffic) countFor(segment *domainmodels.RoadSegment, demand float64) int {
+	effective := segment.EffectiveCapacity()
+	if !segment.IsOpen || effective == nil || *effective <= 0 {
+		return 0
+	}
+	capacity := float64(*effective)
+
+	expected := capacity * b.peakUtilization * demand * arterialWeight(segment)
+	expected *= 1.0 + backgroundNoise*b.rng.NormFloat64()
+
+	count := math.Round(math.Max(0, expected))
+	return int(math.Min(count, math.Floor(capacity*maxBackgroundShare)))
+}
+
+// arterialWeight favours fast roads: arteries carry full demand, local streets
+// a fraction of it.
+func arterialWeight(segment *domainmodels.RoadSegment) float64 {
+	ratio := math.Min(1.0, segment.BaseSpeedKPH/arterialSpeedKPH)
+	return ratio * ratio
+}
+
+// Demand is the share of peak background traffic on the roads at hour, with
+// a morning and a larger evening rush over a midday plateau.
+func Demand(hour float64) float64 {
+	peak := func(center, width float64) float64 {
+		d := (hour - center) / width
+		return math.Exp(-d * d)
+	}
+	demand := 0.1 + 0.8*peak(8.0, 1.5) + 0.9*peak(17.5, 2.0) + 0.35*peak(13.0, 3.0)
+	return math.Min(1.0, demand)
+}
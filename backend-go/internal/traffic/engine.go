@@ -0,0 +1,204 @@
+package traffic
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const (
+	CongestionFree     = "free"
+	CongestionLight    = "light"
+	CongestionModerate = "moderate"
+	CongestionHeavy    = "heavy"
+	CongestionJammed   = "jammed"
+)
+
+// averageSpeedSmoothing is the weight of the newest stream speed in the rolling
+// average kept on each segment.
+const averageSpeedSmoothing = 0.3
+
+// jammedSpeedMultiplier matches the slowdown vehicles apply to themselves on a
+// segment at or over capacity.
+const jammedSpeedMultiplier = 0.3
+
+// Engine keeps RoadSegment.CurrentTrafficLoad in step with the vehicles on the
+// grid. Occupancy changes as soon as a vehicle enters or leaves a segment; the
+// derived figures (average speed, congestion level, effective speed limit) are
+// refreshed by Update on the traffic tick.
+type Engine struct {
+	grid *domainmodels.Grid
+
+	occupancy map[string]carriageway
+	occupants map[int64]map[string]*domainmodels.Vehicle
+}
+
+// carriageway is one way along a segment: from its start to its end when
+// forward is set, and back otherwise.
+type carriageway struct {
+	segmentID int64
+	forward   bool
+}
+
+// NewEngine counts no vehicles yet and stamps the grid's traffic state with
+// now, the run's simulated time.
+func NewEngine(grid *domainmodels.Grid, now time.Time) *Engine {
+	engine := &Engine{
+		grid:      grid,
+		occupancy: make(map[string]carriageway),
+		occupants: make(map[int64]map[string]*domainmodels.Vehicle),
+	}
+	engine.Update(now)
+	return engine
+}
+
+// Enter moves vehicle onto segment, the way its TravelDirection drives it,
+// taking it off whatever segment it was counted on before.
+func (e *Engine) Enter(vehicle *domainmodels.Vehicle, segment *domainmodels.RoadSegment) {
+	way := carriageway{segmentID: segment.ID, forward: vehicle.TravelDirection >= 0}
+	if current, ok := e.occupancy[vehicle.ID]; ok {
+		if current == way {
+			return
+		}
+		e.Leave(vehicle)
+	}
+
+	segment.AddVehicle(way.forward)
+	e.occupancy[vehicle.ID] = way
+
+	occupants := e.occupants[segment.ID]
+	if occupants == nil {
+		occupants = make(map[string]*domainmodels.Vehicle)
+		e.occupants[segment.ID] = occupants
+	}
+	occupants[vehicle.ID] = vehicle
+}
+
+func (e *Engine) Leave(vehicle *domainmodels.Vehicle) {
+	way, ok := e.occupancy[vehicle.ID]
+	if !ok {
+		return
+	}
+	delete(e.occupancy, vehicle.ID)
+	delete(e.occupants[way.segmentID], vehicle.ID)
+
+	if segment := e.grid.GetSegment(way.segmentID); segment != nil {
+		segment.RemoveVehicle(way.forward)
+	}
+}
+
+// SegmentOf reports the segment vehicleID is counted on.
+func (e *Engine) SegmentOf(vehicleID string) (int64, bool) {
+	way, ok := e.occupancy[vehicleID]
+	return way.segmentID, ok
+}
+
+// Update recomputes the traffic state of every segment in the grid.
+func (e *Engine) Update(now time.Time) {
+	for _, segmentID := range e.segmentIDs() {
+		segment := e.grid.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+		e.updateSegment(segment, now)
+	}
+}
+
+func (e *Engine) updateSegment(segment *domainmodels.RoadSegment, now time.Time) {
+	occupants := e.occupants[segment.ID]
+	count := len(occupants)
+
+	load := &segment.CurrentTrafficLoad
+	load.VehicleCount = count
+	load.ForwardCount, load.BackwardCount = 0, 0
+	for vehicleID := range occupants {
+		if e.occupancy[vehicleID].forward {
+			load.ForwardCount++
+		} else {
+			load.BackwardCount++
+		}
+	}
+	load.CapacityUtilization = 0
+	if capacity := segment.EffectiveCapacity(); capacity != nil && *capacity > 0 {
+		load.CapacityUtilization = float64(count+load.BackgroundCount) / float64(*capacity)
+	}
+
+	freeFlow := FreeFlowSpeed(segment)
+	// The stream moves at the mean speed its vehicles would drive unhindered,
+	// so one slow truck holds back the cars sharing its segment. Background
+	// traffic drives at the segment's free-flow speed.
+	streamSpeed := freeFlow
+	if count > 0 {
+		total := freeFlow * float64(load.BackgroundCount)
+		// Summed in ID order so the float total is the same run to run.
+		vehicleIDs := make([]string, 0, count)
+		for vehicleID := range occupants {
+			vehicleIDs = append(vehicleIDs, vehicleID)
+		}
+		sort.Strings(vehicleIDs)
+		for _, vehicleID := range vehicleIDs {
+			total += occupants[vehicleID].FreeFlowSpeed(segment)
+		}
+		streamSpeed = total / float64(count+load.BackgroundCount)
+	}
+	streamSpeed *= congestionMultiplier(load.CapacityUtilization)
+
+	if load.AverageSpeed <= 0 {
+		load.AverageSpeed = streamSpeed
+	} else {
+		load.AverageSpeed += averageSpeedSmoothing * (streamSpeed - load.AverageSpeed)
+	}
+	load.LastUpdated = now
+
+	segment.EffectiveSpeedLimit = freeFlow * congestionMultiplier(load.CapacityUtilization)
+	segment.CongestionLevel = CongestionLevelFor(load.CapacityUtilization)
+}
+
+func (e *Engine) segmentIDs() []int64 {
+	ids := make([]int64, 0, len(e.grid.SegmentIndex))
+	for segmentID := range e.grid.SegmentIndex {
+		ids = append(ids, segmentID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// FreeFlowSpeed is the fastest any vehicle may drive segment given its limit
+// and current road conditions.
+func FreeFlowSpeed(segment *domainmodels.RoadSegment) float64 {
+	speed := segment.BaseSpeedKPH
+	if segment.SpeedLimit != nil {
+		speed = math.Min(speed, float64(*segment.SpeedLimit))
+	}
+	for _, condition := range segment.BaseConditions {
+		speed *= condition.SpeedMultiplier
+	}
+	for _, condition := range segment.TemporaryConditions {
+		speed *= condition.SpeedMultiplier
+	}
+	return speed
+}
+
+func congestionMultiplier(utilization float64) float64 {
+	if utilization >= 1.0 {
+		return jammedSpeedMultiplier
+	}
+	return 1.0 - 0.6*math.Pow(utilization, 2)
+}
+
+func CongestionLevelFor(utilization float64) string {
+	switch {
+	case utilization >= 1.0:
+		return CongestionJammed
+	case utilization >= 0.8:
+		return CongestionHeavy
+	case utilization >= 0.5:
+		return CongestionModerate
+	case utilization >= 0.2:
+		return CongestionLight
+	default:
+		return CongestionFree
+	}
+}
@@ -0,0 +1,113 @@
+package traffic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+// segmentGrid is one segment from (0,0) to (1,0), holding capacity as a road
+// of one lane each way.
+func segmentGrid(capacity int64, direction domainmodels.SegmentDirection, lanesForward int64) *domainmodels.Grid {
+	segment := domainmodels.RoadSegment{
+		ID: 1, StartX: 0, StartY: 0, EndX: 1, EndY: 0, LengthKM: 1, BaseSpeedKPH: 50,
+		Capacity: &capacity, IsOpen: true, Direction: direction, LanesForward: lanesForward,
+	}
+	cell := domainmodels.Cell{RoadSegments: []domainmodels.CellRoad{{RoadSegmentID: segment.ID, RoadSegment: segment}}}
+	grid := &domainmodels.Grid{Cells: []domainmodels.Cell{cell}}
+	grid.SegmentIndex = map[int64]*domainmodels.Cell{segment.ID: &grid.Cells[0]}
+	return grid
+}
+
+// fill enters vehicles onto the segment from (fromX,0) until it turns the
+// next one away, and returns how many got on.
+func fill(engine *Engine, segment *domainmodels.RoadSegment, fromX int64) int {
+	for entered := 0; entered < 100; entered++ {
+		vehicle := &domainmodels.Vehicle{ID: fmt.Sprintf("from-%d-%02d", fromX, entered)}
+		if !vehicle.CanEnterSegment(segment, fromX, 0) {
+			return entered
+		}
+		vehicle.EnterSegment(segment, fromX, 0)
+		engine.Enter(vehicle, segment)
+	}
+	return -1
+}
+
+func TestSegmentCapacityIsKeptPerDirection(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("two-way", func(t *testing.T) {
+		grid := segmentGrid(4, domainmodels.BothWays, 0)
+		segment := grid.GetSegment(1)
+		engine := NewEngine(grid, start)
+
+		if got := fill(engine, segment, 0); got != 2 {
+			t.Errorf("%d vehicles fit driving forward, want 2", got)
+		}
+		// Traffic one way leaves the other way's lanes free.
+		if got := fill(engine, segment, 1); got != 2 {
+			t.Errorf("%d vehicles fit driving back behind a full forward way, want 2", got)
+		}
+		load := segment.CurrentTrafficLoad
+		if load.ForwardCount != 2 || load.BackwardCount != 2 || load.VehicleCount != 4 {
+			t.Errorf("counted %d forward and %d back of %d, want 2, 2 and 4",
+				load.ForwardCount, load.BackwardCount, load.VehicleCount)
+		}
+
+		engine.Update(start)
+		if load := segment.CurrentTrafficLoad; load.ForwardCount != 2 || load.BackwardCount != 2 {
+			t.Errorf("after an update, counted %d forward and %d back, want 2 and 2", load.ForwardCount, load.BackwardCount)
+		}
+	})
+
+	t.Run("one-way with two lanes", func(t *testing.T) {
+		grid := segmentGrid(4, domainmodels.ForwardOnly, 2)
+		segment := grid.GetSegment(1)
+		engine := NewEngine(grid, start)
+
+		if got := fill(engine, segment, 1); got != 0 {
+			t.Errorf("%d vehicles entered the wrong way", got)
+		}
+		if got := fill(engine, segment, 0); got != 4 {
+			t.Errorf("%d vehicles fit on both lanes, want 4", got)
+		}
+	})
+
+	t.Run("background traffic", func(t *testing.T) {
+		grid := segmentGrid(4, domainmodels.BothWays, 0)
+		segment := grid.GetSegment(1)
+		engine := NewEngine(grid, start)
+		segment.CurrentTrafficLoad.BackgroundCount = 2
+
+		// Background traffic spreads over the lanes of both ways.
+		if got := fill(engine, segment, 0); got != 1 {
+			t.Errorf("%d vehicles fit forward beside background traffic, want 1", got)
+		}
+	})
+}
+
+func TestLeaveFreesTheWayTheVehicleDrove(t *testing.T) {
+	grid := segmentGrid(2, domainmodels.BothWays, 0)
+	segment := grid.GetSegment(1)
+	engine := NewEngine(grid, time.Time{})
+
+	forward := &domainmodels.Vehicle{ID: "forward"}
+	forward.EnterSegment(segment, 0, 0)
+	engine.Enter(forward, segment)
+	back := &domainmodels.Vehicle{ID: "back"}
+	back.EnterSegment(segment, 1, 0)
+	engine.Enter(back, segment)
+
+	engine.Leave(forward)
+	if load := segment.CurrentTrafficLoad; load.ForwardCount != 0 || load.BackwardCount != 1 {
+		t.Errorf("counted %d forward and %d back, want 0 and 1", load.ForwardCount, load.BackwardCount)
+	}
+	if !(&domainmodels.Vehicle{}).CanEnterSegment(segment, 0, 0) {
+		t.Error("the freed forward way turns vehicles away")
+	}
+	if (&domainmodels.Vehicle{}).CanEnterSegment(segment, 1, 0) {
+		t.Error("the full backward way lets vehicles on")
+	}
+}
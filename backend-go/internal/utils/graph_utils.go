@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"sort"
 
 	"owenvi.com/fleetsim/internal/domainmodels"
 )
@@ -182,6 +183,9 @@ func FindConnectedSegmentsFast(target domainmodels.RoadSegment, endpointIndex En
 	for segmentID := range connectionSet {
 		connections = append(connections, segmentID)
 	}
+	// Sorted so adjacency lists, and the routes searched over them, do not
+	// depend on map iteration order.
+	sort.Slice(connections, func(i, j int) bool { return connections[i] < connections[j] })
 
 	return connections
 }
@@ -2,9 +2,14 @@ package utils
 
 import "owenvi.com/fleetsim/internal/domainmodels"
 
+// SegmentIsConnected reports whether an open segment joins the two cells that
+// may be driven from from to to.
 func SegmentIsConnected(from, to *domainmodels.Cell) bool {
 	for _, cellRoad := range from.RoadSegments {
 		segment := cellRoad.RoadSegment
+		if !segment.IsOpen || !segment.CanLeave(from.Xpos, from.Ypos) {
+			continue
+		}
 
 		if (segment.StartX == from.Xpos && segment.StartY == from.Ypos &&
 			segment.EndX == to.Xpos && segment.EndY == to.Ypos) ||
@@ -0,0 +1,237 @@
+package wsserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 64 * 1024
+	clientBuffer   = 64
+)
+
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+
+	sessionID string
+
+	// mu guards send against being closed while another goroutine sends on
+	// it: the hub closes it, but replies are sent from the simulation loop.
+	mu     sync.Mutex
+	closed bool
+}
+
+type inboundMessage struct {
+	client  *Client
+	message wireMessage
+}
+
+// wireMessage mirrors domainmodels.WebSocketMessage but keeps Data raw so it
+// can be decoded once the message type is known.
+type wireMessage struct {
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+
+	UserSessionID *string `json:"user_session_id,omitempty"`
+	RequestID     *string `json:"request_id,omitempty"`
+}
+
+type Hub struct {
+	clients    map[*Client]bool
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan []byte
+	direct     chan sessionMessage
+	done       chan struct{}
+}
+
+// sessionMessage is a payload for the clients of one session only.
+type sessionMessage struct {
+	sessionID string
+	payload   []byte
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan []byte, clientBuffer),
+		direct:     make(chan sessionMessage, clientBuffer),
+		done:       make(chan struct{}),
+	}
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case <-h.done:
+			for client := range h.clients {
+				delete(h.clients, client)
+				client.close()
+			}
+			return
+		case client := <-h.register:
+			h.clients[client] = true
+		case client := <-h.unregister:
+			if h.clients[client] {
+				delete(h.clients, client)
+				client.close()
+			}
+		case payload := <-h.broadcast:
+			for client := range h.clients {
+				h.deliver(client, payload)
+			}
+		case message := <-h.direct:
+			for client := range h.clients {
+				if client.sessionID == message.sessionID {
+					h.deliver(client, message.payload)
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) deliver(client *Client, payload []byte) {
+	if err := client.deliver(payload); err != nil {
+		// Slow consumers are dropped rather than stalling the tick loop.
+		delete(h.clients, client)
+		client.close()
+	}
+}
+
+func (h *Hub) Broadcast(message domainmodels.WebSocketMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", message.Type, err)
+	}
+	select {
+	case h.broadcast <- payload:
+		return nil
+	case <-h.done:
+		return fmt.Errorf("hub is closed")
+	}
+}
+
+// SendToSession sends message to every client connected under sessionID and
+// to no one else. A session with no client connected misses it.
+func (h *Hub) SendToSession(sessionID string, message domainmodels.WebSocketMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", message.Type, err)
+	}
+	select {
+	case h.direct <- sessionMessage{sessionID: sessionID, payload: payload}:
+		return nil
+	case <-h.done:
+		return fmt.Errorf("hub is closed")
+	}
+}
+
+func (h *Hub) Close() {
+	close(h.done)
+}
+
+func (c *Client) Send(message domainmodels.WebSocketMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", message.Type, err)
+	}
+	return c.deliver(payload)
+}
+
+// deliver queues payload for the write pump without blocking. It fails once
+// the client is closed or when its buffer is full.
+func (c *Client) deliver(payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("client %s is disconnected", c.sessionID)
+	}
+	select {
+	case c.send <- payload:
+		return nil
+	default:
+		return fmt.Errorf("client %s send buffer is full", c.sessionID)
+	}
+}
+
+// close ends the write pump. It is safe to call more than once and while
+// other goroutines deliver to the client.
+func (c *Client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+func (c *Client) readPump(inbound chan<- inboundMessage) {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var message wireMessage
+		if err := c.conn.ReadJSON(&message); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				fmt.Printf("WebSocket client %s closed unexpectedly: %v\n", c.sessionID, err)
+			}
+			return
+		}
+		select {
+		case inbound <- inboundMessage{client: c, message: message}:
+		case <-c.hub.done:
+			return
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
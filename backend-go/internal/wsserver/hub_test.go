@@ -0,0 +1,85 @@
+package wsserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+)
+
+func newTestClient(hub *Hub, sessionID string) *Client {
+	return &Client{hub: hub, send: make(chan []byte, clientBuffer), sessionID: sessionID}
+}
+
+func TestSendAfterUnregisterFails(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Close()
+
+	client := newTestClient(hub, "session_1")
+	hub.register <- client
+	hub.unregister <- client
+	// The hub has handled the unregister once it takes the next message.
+	hub.register <- newTestClient(hub, "session_2")
+
+	message := domainmodels.WebSocketMessage{Type: constants.WSMsgSpawnResponse}
+	if err := client.Send(message); err == nil {
+		t.Fatal("Send to an unregistered client succeeded")
+	}
+}
+
+func TestSendRacesWithClose(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	clients := make([]*Client, 8)
+	for i := range clients {
+		clients[i] = newTestClient(hub, "session")
+		hub.register <- clients[i]
+	}
+
+	var wg sync.WaitGroup
+	message := domainmodels.WebSocketMessage{Type: constants.WSMsgSimulationStatus}
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 2 * clientBuffer {
+				_ = client.Send(message)
+			}
+		}()
+	}
+	for _, client := range clients[:len(clients)/2] {
+		hub.unregister <- client
+	}
+	hub.Close()
+	wg.Wait()
+}
+
+func TestSendToSessionReachesOnlyThatSession(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Close()
+
+	mine, theirs := newTestClient(hub, "session_1"), newTestClient(hub, "session_2")
+	hub.register <- mine
+	hub.register <- theirs
+
+	message := domainmodels.WebSocketMessage{Type: constants.WSMsgSpawnResponse}
+	if err := hub.SendToSession("session_1", message); err != nil {
+		t.Fatalf("SendToSession: %v", err)
+	}
+	select {
+	case <-mine.send:
+	case <-time.After(time.Second):
+		t.Fatal("submitting session got no message")
+	}
+	// The hub has finished with the send once it takes the next message.
+	hub.register <- newTestClient(hub, "session_3")
+
+	if len(theirs.send) != 0 {
+		t.Errorf("other session got %d messages, want 0", len(theirs.send))
+	}
+}
@@ -0,0 +1,552 @@
+package wsserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"owenvi.com/fleetsim/internal/config"
+	"owenvi.com/fleetsim/internal/constants"
+	"owenvi.com/fleetsim/internal/domainmodels"
+	"owenvi.com/fleetsim/internal/livestate"
+	"owenvi.com/fleetsim/internal/movement"
+	"owenvi.com/fleetsim/internal/reqpays"
+	"owenvi.com/fleetsim/internal/simcontrol"
+	"owenvi.com/fleetsim/internal/spawning"
+)
+
+type SpawnHandler interface {
+	HandleSpawnRequest(request domainmodels.SpawnRequestMessage) domainmodels.SpawnResponseMessage
+}
+
+// SpawnQueue is implemented by spawn handlers that may finish requests after
+// replying; the server processes it every movement tick and sends the outcome
+// to the submitting session only.
+type SpawnQueue interface {
+	ProcessQueue() []*reqpays.VehicleSpawnRequest
+	DrainFinished() []*reqpays.VehicleSpawnRequest
+	SessionFor(requestID string) string
+	QueueState() domainmodels.RedisSpawnQueue
+}
+
+// liveStateTimeout bounds how long a live-state publish may hold up the
+// simulation loop.
+const liveStateTimeout = 500 * time.Millisecond
+
+// TelemetryRecorder receives a snapshot of every vehicle after each movement
+// tick and of every segment's load after each traffic tick.
+type TelemetryRecorder interface {
+	RecordVehicleState(state domainmodels.VehicleState)
+	RecordRoadLoad(timestamp time.Time, load domainmodels.RoadLoadEvent)
+}
+
+// LiveStatePublisher mirrors simulation state into an external store such as
+// Redis for readers outside the WebSocket stream.
+type LiveStatePublisher interface {
+	Publish(ctx context.Context, snapshot livestate.Snapshot) (livestate.PublishResult, error)
+}
+
+// Server owns the lifecycle manager: every read or write of simulation state
+// happens on the controller's loop goroutine, so no locking is needed around
+// it.
+type Server struct {
+	config     *config.SimulationConfig
+	manager    *movement.VehicleLifecycleManager
+	spawner    SpawnHandler
+	controller *simcontrol.Controller
+
+	telemetry TelemetryRecorder
+	liveState LiveStatePublisher
+
+	hub      *Hub
+	inbound  chan inboundMessage
+	upgrader websocket.Upgrader
+
+	sessionCounter atomic.Int64
+}
+
+// NewServer ticks manager at the pace of its clock, so set the clock before
+// calling it.
+func NewServer(cfg *config.SimulationConfig, manager *movement.VehicleLifecycleManager, spawner SpawnHandler) *Server {
+	s := &Server{
+		config:  cfg,
+		manager: manager,
+		spawner: spawner,
+		hub:     NewHub(),
+		inbound: make(chan inboundMessage, clientBuffer),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+	}
+	s.controller = simcontrol.NewController(manager.Clock(), tickEngine{s},
+		time.Duration(cfg.MovementUpdateInterval)*time.Millisecond,
+		time.Duration(cfg.TrafficUpdateInterval)*time.Millisecond)
+	s.controller.SetListener(s.broadcastLifecycle)
+	s.controller.SetLogOutput(os.Stdout)
+	return s
+}
+
+// tickEngine lets the controller drive the server's ticks without exporting
+// them.
+type tickEngine struct {
+	server *Server
+}
+
+func (e tickEngine) MovementTick(ctx context.Context, step time.Duration) {
+	e.server.movementTick(ctx, step)
+}
+
+func (e tickEngine) TrafficTick(ctx context.Context) {
+	e.server.trafficTick(ctx)
+}
+
+// SetTelemetry records simulation state to recorder from the next tick on.
+func (s *Server) SetTelemetry(recorder TelemetryRecorder) {
+	s.telemetry = recorder
+}
+
+// SetLiveState publishes vehicles and the spawn queue after each movement tick
+// and road loads after each traffic tick.
+func (s *Server) SetLiveState(publisher LiveStatePublisher) {
+	s.liveState = publisher
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.serveWebSocket)
+	mux.HandleFunc("/simulation", s.serveSimulationControl)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Printf("WebSocket upgrade failed: %v\n", err)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("session_%d", s.sessionCounter.Add(1))
+	}
+
+	client := &Client{
+		hub:       s.hub,
+		conn:      conn,
+		send:      make(chan []byte, clientBuffer),
+		sessionID: sessionID,
+	}
+
+	select {
+	case s.hub.register <- client:
+	case <-s.hub.done:
+		conn.Close()
+		return
+	}
+
+	go client.writePump()
+	go client.readPump(s.inbound)
+}
+
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(ctx)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Fleet server listening on %s\n", addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down http server: %w", err)
+	}
+	return <-runErr
+}
+
+// Run starts the simulation controller and serves inbound client messages
+// until ctx is cancelled. Control messages go straight to the controller;
+// everything else is handled on its loop, between ticks.
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.config.ValidateConfig(); err != nil {
+		return err
+	}
+
+	go s.hub.Run()
+	defer s.hub.Close()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.controller.Run(ctx)
+	}()
+	if err := s.controller.Start(); err != nil {
+		return fmt.Errorf("failed to start simulation: %w", err)
+	}
+
+	for {
+		select {
+		case err := <-runErr:
+			return err
+		case inbound := <-s.inbound:
+			if constants.WSMessageType(inbound.message.Type) == constants.WSMsgSimulationControl {
+				s.handleControl(inbound)
+				continue
+			}
+			if err := s.controller.Do(func() { s.handleInbound(inbound) }); err != nil {
+				return <-runErr
+			}
+		}
+	}
+}
+
+// broadcastLifecycle tells every client about a controller transition. The
+// start message also describes the world being simulated.
+func (s *Server) broadcastLifecycle(event constants.WSMessageType, status domainmodels.SimulationStatusMessage) {
+	switch event {
+	case constants.WSMsgSimulationStart:
+		s.broadcast(event, map[string]any{
+			"grid_width":           s.manager.GetGrid().DimX,
+			"grid_height":          s.manager.GetGrid().DimY,
+			"vehicle_count":        len(s.manager.GetAllVehicles()),
+			"movement_interval_ms": s.config.MovementUpdateInterval,
+			"traffic_interval_ms":  s.config.TrafficUpdateInterval,
+			"speed_multiplier":     status.SpeedMultiplier,
+			"clock_mode":           status.ClockMode,
+			"status":               status,
+		})
+	case constants.WSMsgSimulationStop:
+		s.broadcast(event, map[string]any{"reason": "server shutting down", "status": status})
+	default:
+		s.broadcast(event, status)
+	}
+}
+
+// handleControl applies a control message and replies with the resulting
+// status; every client hears about the transition itself.
+func (s *Server) handleControl(inbound inboundMessage) {
+	client := inbound.client
+	message := inbound.message
+
+	var request domainmodels.SimulationControlMessage
+	if err := json.Unmarshal(message.Data, &request); err != nil {
+		s.reply(client, constants.WSMsgSimulationError, message.RequestID,
+			map[string]string{"error": fmt.Sprintf("invalid simulation control: %v", err)})
+		return
+	}
+	if err := s.controller.Control(request); err != nil {
+		s.reply(client, constants.WSMsgSimulationError, message.RequestID,
+			map[string]string{"error": err.Error()})
+		return
+	}
+	s.reply(client, constants.WSMsgSimulationStatus, message.RequestID, s.controller.Status())
+}
+
+// serveSimulationControl reports the controller status on GET and applies a
+// SimulationControlMessage body on POST.
+func (s *Server) serveSimulationControl(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var request domainmodels.SimulationControlMessage
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid simulation control: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.controller.Control(request); err != nil {
+			switch {
+			case errors.Is(err, simcontrol.ErrInvalidTransition):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, simcontrol.ErrStopped):
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			default:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.controller.Status()); err != nil {
+		fmt.Printf("Failed to write simulation status: %v\n", err)
+	}
+}
+
+func (s *Server) movementTick(ctx context.Context, step time.Duration) {
+	s.processSpawnQueue()
+	s.manager.UpdateAllVehicles(step.Seconds())
+	s.broadcast(constants.WSMsgVehiclePosition, s.buildPositionUpdates())
+	s.recordVehicleStates()
+	s.publishLiveState(ctx, livestate.Snapshot{
+		Vehicles:   s.manager.GetAllVehicles(),
+		SpawnQueue: s.spawnQueueState(),
+	})
+}
+
+func (s *Server) trafficTick(ctx context.Context) {
+	if updates := s.manager.UpdateConditions(); len(updates) > 0 {
+		s.broadcast(constants.WSMsgConditionUpdate, updates)
+	}
+	s.manager.UpdateTraffic()
+	trafficUpdates := s.buildTrafficUpdates()
+	s.broadcast(constants.WSMsgTrafficUpdate, trafficUpdates)
+	s.recordRoadLoads(trafficUpdates)
+	s.publishLiveState(ctx, livestate.Snapshot{
+		Vehicles: s.manager.GetAllVehicles(),
+		Grid:     s.manager.GetGrid(),
+	})
+}
+
+func (s *Server) handleInbound(inbound inboundMessage) {
+	client := inbound.client
+	message := inbound.message
+
+	switch constants.WSMessageType(message.Type) {
+	case constants.WSMsgSpawnRequest:
+		var request domainmodels.SpawnRequestMessage
+		if err := json.Unmarshal(message.Data, &request); err != nil {
+			s.reply(client, constants.WSMsgSimulationError, message.RequestID,
+				map[string]string{"error": fmt.Sprintf("invalid spawn request: %v", err)})
+			return
+		}
+		if request.RequestID == "" && message.RequestID != nil {
+			request.RequestID = *message.RequestID
+		}
+		if request.UserSessionID == "" {
+			request.UserSessionID = client.sessionID
+		}
+
+		response := s.spawner.HandleSpawnRequest(request)
+		s.reply(client, constants.WSMsgSpawnResponse, &request.RequestID, response)
+	default:
+		s.reply(client, constants.WSMsgSimulationError, message.RequestID,
+			map[string]string{"error": fmt.Sprintf("unsupported message type %q", message.Type)})
+	}
+}
+
+func (s *Server) processSpawnQueue() {
+	queue, ok := s.spawner.(SpawnQueue)
+	if !ok {
+		return
+	}
+
+	queue.ProcessQueue()
+	for _, request := range queue.DrainFinished() {
+		requestID := request.RequestID
+		message := domainmodels.WebSocketMessage{
+			Type:      constants.WSMsgSpawnResponse,
+			Timestamp: time.Now(),
+			Data:      spawning.ResponseFor(request),
+			RequestID: &requestID,
+		}
+		sessionID := queue.SessionFor(requestID)
+		if sessionID == "" {
+			// Requests submitted outside a session have nobody to tell.
+			continue
+		}
+		message.UserSessionID = &sessionID
+		if err := s.hub.SendToSession(sessionID, message); err != nil {
+			fmt.Printf("Failed to send spawn response %s: %v\n", requestID, err)
+		}
+	}
+}
+
+func (s *Server) broadcast(messageType constants.WSMessageType, data any) {
+	message := domainmodels.WebSocketMessage{
+		Type:      messageType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	if err := s.hub.Broadcast(message); err != nil {
+		fmt.Printf("Failed to broadcast %s: %v\n", messageType, err)
+	}
+}
+
+func (s *Server) reply(client *Client, messageType constants.WSMessageType, requestID *string, data any) {
+	sessionID := client.sessionID
+	message := domainmodels.WebSocketMessage{
+		Type:          messageType,
+		Timestamp:     time.Now(),
+		Data:          data,
+		UserSessionID: &sessionID,
+		RequestID:     requestID,
+	}
+	if err := client.Send(message); err != nil {
+		fmt.Printf("Failed to reply to %s: %v\n", sessionID, err)
+	}
+}
+
+func (s *Server) buildPositionUpdates() []domainmodels.VehiclePositionUpdate {
+	vehicles := s.manager.GetAllVehicles()
+	updates := make([]domainmodels.VehiclePositionUpdate, 0, len(vehicles))
+
+	for _, vehicle := range vehicles {
+		if vehicle.CurrentCell == nil {
+			continue
+		}
+		update := domainmodels.VehiclePositionUpdate{
+			VehicleID:      vehicle.ID,
+			Xpos:           vehicle.CurrentCell.Xpos,
+			Ypos:           vehicle.CurrentCell.Ypos,
+			SpeedKPH:       vehicle.CurrentSpeedKPH,
+			Status:         vehicle.Status,
+			EdgeProgress:   vehicle.SegmentProgress,
+			FuelLevel:      vehicle.FuelLevel,
+			UserSessionID:  vehicle.UserSessionID,
+			CustomName:     vehicle.CustomName,
+			SpawnRequestID: vehicle.SpawnRequestID,
+		}
+		if vehicle.CurrentSegment != nil {
+			segmentID := vehicle.CurrentSegment.ID
+			update.RoadSegmentID = &segmentID
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+func (s *Server) publishLiveState(ctx context.Context, snapshot livestate.Snapshot) {
+	if s.liveState == nil {
+		return
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, liveStateTimeout)
+	defer cancel()
+	if _, err := s.liveState.Publish(publishCtx, snapshot); err != nil {
+		fmt.Printf("Failed to publish live state: %v\n", err)
+	}
+}
+
+func (s *Server) spawnQueueState() *domainmodels.RedisSpawnQueue {
+	queue, ok := s.spawner.(SpawnQueue)
+	if !ok {
+		return nil
+	}
+	state := queue.QueueState()
+	return &state
+}
+
+func (s *Server) recordVehicleStates() {
+	if s.telemetry == nil {
+		return
+	}
+
+	now := s.manager.Clock().Now()
+	simulatedMillis := s.manager.Clock().Elapsed().Milliseconds()
+	for _, vehicle := range s.manager.GetAllVehicles() {
+		if vehicle.CurrentCell == nil {
+			continue
+		}
+		state := domainmodels.VehicleState{
+			VehicleID:     vehicle.ID,
+			Timestamp:     now,
+			CellX:         vehicle.CurrentCell.Xpos,
+			CellY:         vehicle.CurrentCell.Ypos,
+			SpeedKPH:      vehicle.CurrentSpeedKPH,
+			FuelLevel:     vehicle.FuelLevel,
+			EdgeProgress:  vehicle.SegmentProgress,
+			Status:        vehicle.Status,
+			SimulatedTime: simulatedMillis,
+		}
+		if vehicle.CurrentSegment != nil {
+			segmentID := vehicle.CurrentSegment.ID
+			state.CurrentSegID = &segmentID
+		}
+		s.telemetry.RecordVehicleState(state)
+	}
+}
+
+func (s *Server) recordRoadLoads(updates []domainmodels.TrafficUpdate) {
+	if s.telemetry == nil {
+		return
+	}
+
+	now := s.manager.Clock().Now()
+	for _, update := range updates {
+		s.telemetry.RecordRoadLoad(now, domainmodels.RoadLoadEvent{
+			SegmentID:       update.RoadSegmentID,
+			FleetCount:      update.FleetCount,
+			BackgroundCount: update.BackgroundCount,
+			Capacity:        update.Capacity,
+		})
+	}
+}
+
+func (s *Server) buildTrafficUpdates() []domainmodels.TrafficUpdate {
+	grid := s.manager.GetGrid()
+
+	segmentIDs := make([]int64, 0, len(grid.SegmentIndex))
+	for segmentID := range grid.SegmentIndex {
+		segmentIDs = append(segmentIDs, segmentID)
+	}
+	sort.Slice(segmentIDs, func(i, j int) bool { return segmentIDs[i] < segmentIDs[j] })
+
+	updates := make([]domainmodels.TrafficUpdate, 0, len(segmentIDs))
+	for _, segmentID := range segmentIDs {
+		segment := grid.GetSegment(segmentID)
+		if segment == nil {
+			continue
+		}
+
+		capacity := 0
+		if effective := segment.EffectiveCapacity(); effective != nil {
+			capacity = int(*effective)
+		}
+
+		activeConditions := make([]string, 0, len(segment.BaseConditions)+len(segment.TemporaryConditions))
+		for _, condition := range segment.BaseConditions {
+			activeConditions = append(activeConditions, condition.ID)
+		}
+		for _, condition := range segment.TemporaryConditions {
+			activeConditions = append(activeConditions, condition.ID)
+		}
+
+		updates = append(updates, domainmodels.TrafficUpdate{
+			RoadSegmentID:       segment.ID,
+			FleetCount:          segment.CurrentTrafficLoad.VehicleCount,
+			BackgroundCount:     segment.CurrentTrafficLoad.BackgroundCount,
+			Capacity:            capacity,
+			CongestionRatio:     segment.CurrentTrafficLoad.CapacityUtilization,
+			ActiveConditions:    activeConditions,
+			EffectiveSpeedLimit: segment.EffectiveSpeedLimit,
+			AverageSpeed:        segment.CurrentTrafficLoad.AverageSpeed,
+			CongestionLevel:     segment.CongestionLevel,
+			VisualColor:         segment.VisualState.PrimaryColor,
+		})
+	}
+	return updates
+}
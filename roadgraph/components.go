@@ -0,0 +1,168 @@
+package roadgraph
+
+import "slices"
+
+// Components groups the node IDs of g by the roads that connect them, largest
+// group first. Nodes without roads form groups of their own.
+func (g *Graph) Components() [][]int64 {
+	index := make(map[int64]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+	}
+
+	parent := make([]int, len(g.Nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, segment := range g.Segments {
+		from, fromOK := index[segment.From]
+		to, toOK := index[segment.To]
+		if !fromOK || !toOK {
+			continue
+		}
+		if a, b := find(from), find(to); a != b {
+			parent[max(a, b)] = min(a, b)
+		}
+	}
+
+	groups := make(map[int][]int64)
+	var roots []int
+	for i, node := range g.Nodes {
+		root := find(i)
+		if _, seen := groups[root]; !seen {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], node.ID)
+	}
+
+	components := make([][]int64, 0, len(roots))
+	for _, root := range roots {
+		components = append(components, groups[root])
+	}
+	slices.SortStableFunc(components, func(a, b []int64) int {
+		return len(b) - len(a)
+	})
+	return components
+}
+
+// LargestComponent is the part of g reachable from its largest group of
+// connected nodes. g itself is left as it is.
+func (g *Graph) LargestComponent() *Graph {
+	components := g.Components()
+	if len(components) <= 1 {
+		return g.Clone()
+	}
+
+	keep := make(map[int64]bool, len(components[0]))
+	for _, nodeID := range components[0] {
+		keep[nodeID] = true
+	}
+
+	pruned := g.Clone()
+	pruned.Nodes = slices.DeleteFunc(pruned.Nodes, func(node Node) bool {
+		return !keep[node.ID]
+	})
+	pruned.Segments = slices.DeleteFunc(pruned.Segments, func(segment Segment) bool {
+		return !keep[segment.From]
+	})
+	return pruned
+}
+
+// StrandingOneWays lists, in ID order, the one-way segments of g that lead
+// out of a group of nodes which no road leads back into, so that a vehicle
+// taking one cannot return. Opening those segments both ways leaves every
+// node reachable from every other node it is connected to.
+func (g *Graph) StrandingOneWays() []int64 {
+	index := make(map[int64]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+	}
+	out := make([][]int, len(g.Nodes))
+	in := make([][]int, len(g.Nodes))
+	link := func(from, to int) {
+		out[from] = append(out[from], to)
+		in[to] = append(in[to], from)
+	}
+	for _, segment := range g.Segments {
+		from, fromOK := index[segment.From]
+		to, toOK := index[segment.To]
+		if !fromOK || !toOK {
+			continue
+		}
+		link(from, to)
+		if !segment.OneWay {
+			link(to, from)
+		}
+	}
+
+	// Kosaraju: nodes in the order a search along the roads finishes them,
+	// then searches against the roads, latest finished first, each collect
+	// one strongly connected group.
+	finished := make([]int, 0, len(g.Nodes))
+	seen := make([]bool, len(g.Nodes))
+	type frame struct{ node, next int }
+	var stack []frame
+	for start := range g.Nodes {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack, frame{node: start})
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(out[top.node]) {
+				next := out[top.node][top.next]
+				top.next++
+				if !seen[next] {
+					seen[next] = true
+					stack = append(stack, frame{node: next})
+				}
+				continue
+			}
+			finished = append(finished, top.node)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	group := make([]int, len(g.Nodes))
+	for i := range group {
+		group[i] = -1
+	}
+	var pending []int
+	for i := len(finished) - 1; i >= 0; i-- {
+		root := finished[i]
+		if group[root] >= 0 {
+			continue
+		}
+		group[root] = root
+		pending = append(pending[:0], root)
+		for len(pending) > 0 {
+			node := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			for _, prev := range in[node] {
+				if group[prev] < 0 {
+					group[prev] = root
+					pending = append(pending, prev)
+				}
+			}
+		}
+	}
+
+	var stranding []int64
+	for _, segment := range g.Segments {
+		from, fromOK := index[segment.From]
+		to, toOK := index[segment.To]
+		if segment.OneWay && fromOK && toOK && group[from] != group[to] {
+			stranding = append(stranding, segment.ID)
+		}
+	}
+	slices.Sort(stranding)
+	return stranding
+}
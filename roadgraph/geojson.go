@@ -0,0 +1,137 @@
+package roadgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FeatureCollection is a GeoJSON document (RFC 7946) as both simulators write
+// their maps for GIS tools. Properties is a foreign member holding what the
+// writer needs to read the map back; tools that re-save the file may drop it,
+// so readers fall back to what the features carry.
+type FeatureCollection struct {
+	Type       string          `json:"type"`
+	Properties json.RawMessage `json:"properties,omitempty"`
+	Features   []Feature       `json:"features"`
+}
+
+// Feature is one GeoJSON feature. Properties stays raw for the reader to
+// decode into whatever the feature's kind calls for.
+type Feature struct {
+	Type       string          `json:"type"`
+	Geometry   Geometry        `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry of positions in [lon, lat] degrees.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// NewFeatureCollection starts a collection, encoding properties as its
+// foreign member.
+func NewFeatureCollection(properties any) (*FeatureCollection, error) {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	return &FeatureCollection{Type: "FeatureCollection", Properties: data, Features: []Feature{}}, nil
+}
+
+// Add appends a feature of the given geometry and properties.
+func (fc *FeatureCollection) Add(geometry Geometry, properties any) error {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+	fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: geometry, Properties: data})
+	return nil
+}
+
+// Point is a Point geometry.
+func Point(position [2]float64) Geometry {
+	return newGeometry("Point", position)
+}
+
+// LineString is a LineString geometry through positions in order.
+func LineString(positions ...[2]float64) Geometry {
+	return newGeometry("LineString", positions)
+}
+
+// Polygon is a Polygon geometry with a single outer ring, closed by repeating
+// its first position.
+func Polygon(ring ...[2]float64) Geometry {
+	closed := append(ring[:len(ring):len(ring)], ring[0])
+	return newGeometry("Polygon", [][][2]float64{closed})
+}
+
+func newGeometry(kind string, coordinates any) Geometry {
+	data, _ := json.Marshal(coordinates)
+	return Geometry{Type: kind, Coordinates: data}
+}
+
+// Point reads the position of a Point geometry.
+func (g Geometry) Point() ([2]float64, error) {
+	var position [2]float64
+	return position, g.decode("Point", &position)
+}
+
+// LineString reads the positions of a LineString geometry.
+func (g Geometry) LineString() ([][2]float64, error) {
+	var positions [][2]float64
+	if err := g.decode("LineString", &positions); err != nil {
+		return nil, err
+	}
+	if len(positions) < 2 {
+		return nil, fmt.Errorf("LineString has %d positions, needs at least 2", len(positions))
+	}
+	return positions, nil
+}
+
+func (g Geometry) decode(kind string, v any) error {
+	if g.Type != kind {
+		return fmt.Errorf("geometry is a %q, expected a %s", g.Type, kind)
+	}
+	if err := json.Unmarshal(g.Coordinates, v); err != nil {
+		return fmt.Errorf("invalid %s coordinates: %w", kind, err)
+	}
+	return nil
+}
+
+// Kind is the "kind" property the simulators tag their features with, or ""
+// for features without one.
+func (f Feature) Kind() string {
+	var tagged struct {
+		Kind string `json:"kind"`
+	}
+	_ = json.Unmarshal(f.Properties, &tagged)
+	return tagged.Kind
+}
+
+// LoadGeoJSON reads a feature collection from a file.
+func LoadGeoJSON(path string) (*FeatureCollection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoJSON %s: %w", path, err)
+	}
+
+	var fc FeatureCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON %s: %w", path, err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("%s is a GeoJSON %q, expected a FeatureCollection", path, fc.Type)
+	}
+	return &fc, nil
+}
+
+// Save writes the collection to a file.
+func (fc *FeatureCollection) Save(path string) error {
+	data, err := json.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,3 @@
+module owenvi.com/roadgraph
+
+go 1.24.0
@@ -0,0 +1,157 @@
+// Package roadgraph is the road network model shared by fleetsim and simsim:
+// intersections as nodes and roads as segments between them. Each service
+// converts its own map to and from a Graph, which lets a map built by one run
+// on the other.
+package roadgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+type NodeKind string
+
+const (
+	KindJunction NodeKind = ""
+	KindRefuel   NodeKind = "refuel"
+	KindDepot    NodeKind = "depot"
+	KindBlocked  NodeKind = "blocked"
+)
+
+// Graph is a road network. Positions and extents are in kilometres from the
+// map's origin.
+type Graph struct {
+	ID string `json:"id,omitempty"`
+
+	WidthKM  float64 `json:"width_km"`
+	HeightKM float64 `json:"height_km"`
+	// CellKM is set on graphs laid out on a lattice of square cells this many
+	// kilometres wide: every node then sits on its own lattice point. It is
+	// zero for graphs with free positions.
+	CellKM float64 `json:"cell_km,omitempty"`
+
+	Nodes    []Node    `json:"nodes"`
+	Segments []Segment `json:"segments"`
+}
+
+type Node struct {
+	ID   int64    `json:"id"`
+	X    float64  `json:"x"`
+	Y    float64  `json:"y"`
+	Kind NodeKind `json:"kind,omitempty"`
+	// RefuelAmount is the fuel a refuel node hands out per stop.
+	RefuelAmount *float64 `json:"refuel_amount,omitempty"`
+}
+
+// Segment is a road between two nodes, two-way unless OneWay. Attributes a
+// map does not model are left zero: no speed, capacity or limit means the
+// importing side picks its own default, no lane count means one lane each way
+// the road is driven, and a zero congestion factor reads as free flow.
+type Segment struct {
+	ID       int64   `json:"id"`
+	From     int64   `json:"from"`
+	To       int64   `json:"to"`
+	LengthKM float64 `json:"length_km"`
+
+	SpeedKPH   float64 `json:"speed_kph,omitempty"`
+	SpeedLimit *int64  `json:"speed_limit,omitempty"`
+	Capacity   *int64  `json:"capacity,omitempty"`
+	Closed     bool    `json:"closed,omitempty"`
+	// OneWay roads are only driven from From to To.
+	OneWay bool `json:"one_way,omitempty"`
+	// LanesForward and LanesBackward are the lanes driven from From to To
+	// and from To to From.
+	LanesForward  int `json:"lanes_forward,omitempty"`
+	LanesBackward int `json:"lanes_backward,omitempty"`
+	// Class is the kind of road, such as OpenStreetMap's highway tag.
+	Class string `json:"class,omitempty"`
+
+	CongestionFactor float64 `json:"congestion_factor,omitempty"`
+}
+
+// Validate checks that node and segment IDs are unique and that every segment
+// joins two different nodes of the graph, with no lanes against its direction.
+func (g *Graph) Validate() error {
+	nodes := make(map[int64]bool, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if nodes[node.ID] {
+			return fmt.Errorf("duplicate node %d", node.ID)
+		}
+		nodes[node.ID] = true
+	}
+
+	segments := make(map[int64]bool, len(g.Segments))
+	for _, segment := range g.Segments {
+		if segments[segment.ID] {
+			return fmt.Errorf("duplicate segment %d", segment.ID)
+		}
+		segments[segment.ID] = true
+
+		if !nodes[segment.From] || !nodes[segment.To] {
+			return fmt.Errorf("segment %d joins unknown nodes %d and %d", segment.ID, segment.From, segment.To)
+		}
+		if segment.From == segment.To {
+			return fmt.Errorf("segment %d starts and ends at node %d", segment.ID, segment.From)
+		}
+		if segment.LengthKM < 0 {
+			return fmt.Errorf("segment %d has negative length %g", segment.ID, segment.LengthKM)
+		}
+		if segment.LanesForward < 0 || segment.LanesBackward < 0 {
+			return fmt.Errorf("segment %d has a negative lane count", segment.ID)
+		}
+		if segment.OneWay && segment.LanesBackward > 0 {
+			return fmt.Errorf("segment %d is one-way but has %d lanes back", segment.ID, segment.LanesBackward)
+		}
+	}
+	return nil
+}
+
+// Clone copies the graph, including the values behind its pointers.
+func (g *Graph) Clone() *Graph {
+	clone := *g
+	clone.Nodes = slices.Clone(g.Nodes)
+	for i := range clone.Nodes {
+		clone.Nodes[i].RefuelAmount = clonePtr(clone.Nodes[i].RefuelAmount)
+	}
+	clone.Segments = slices.Clone(g.Segments)
+	for i := range clone.Segments {
+		clone.Segments[i].SpeedLimit = clonePtr(clone.Segments[i].SpeedLimit)
+		clone.Segments[i].Capacity = clonePtr(clone.Segments[i].Capacity)
+	}
+	return &clone
+}
+
+// Load reads and validates a graph written by Save.
+func Load(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read road graph %s: %w", path, err)
+	}
+
+	var g Graph
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("failed to parse road graph %s: %w", path, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("road graph %s is invalid: %w", path, err)
+	}
+	return &g, nil
+}
+
+func (g *Graph) Save(path string) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
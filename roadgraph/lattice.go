@@ -0,0 +1,252 @@
+package roadgraph
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultCellKM is the cell size of fleetsim's procedural grids.
+const DefaultCellKM = 0.5
+
+// maxLatticeCells bounds the lattice Rasterize picks a cell size for. Cells
+// stop shrinking once halving them again would pass it, and the nodes still
+// sharing a cell are merged.
+const maxLatticeCells = 1 << 18
+
+type point [2]int64
+
+// Rasterize lays g out on a lattice of cellKM cells, the shape of a cell grid.
+// Every node moves to its nearest lattice point; nodes landing on the same
+// point merge into the one with the lowest ID. Every segment becomes a
+// staircase of steps between neighbouring points that stays as close as it
+// can to the original line. The first step keeps the segment's ID, and the
+// steps share its length and copy its attributes. Staircases running along the
+// same cells share their steps, which carry the traffic of both, and where
+// they cross they meet at a new intersection.
+//
+// A zero cellKM keeps a graph already on a lattice on its own, and otherwise
+// picks the largest cell, at most DefaultCellKM, that keeps all nodes apart. A
+// graph already on a lattice of the chosen size is returned as a copy.
+func Rasterize(g *Graph, cellKM float64) (*Graph, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("road graph has no nodes")
+	}
+	if cellKM < 0 || math.IsNaN(cellKM) {
+		return nil, fmt.Errorf("cell size must be positive, got %g", cellKM)
+	}
+	if cellKM == 0 {
+		cellKM = g.CellKM
+	}
+	if cellKM == 0 {
+		cellKM = fitCellKM(g)
+	}
+	if g.CellKM == cellKM {
+		return g.Clone(), nil
+	}
+
+	originX, originY := origin(g)
+	nodes := slices.Clone(g.Nodes)
+	slices.SortFunc(nodes, func(a, b Node) int { return cmp.Compare(a.ID, b.ID) })
+	segments := slices.Clone(g.Segments)
+	slices.SortFunc(segments, func(a, b Segment) int { return cmp.Compare(a.ID, b.ID) })
+
+	lattice := &Graph{ID: g.ID, CellKM: cellKM}
+	nodeAt := make(map[point]int)
+	placed := make(map[int64]point, len(nodes))
+	var extent point
+	for _, node := range nodes {
+		p := snap(node.X, node.Y, originX, originY, cellKM)
+		placed[node.ID] = p
+		extent = point{max(extent[0], p[0]), max(extent[1], p[1])}
+
+		if i, taken := nodeAt[p]; taken {
+			if lattice.Nodes[i].Kind == KindJunction && node.Kind != KindJunction {
+				lattice.Nodes[i].Kind = node.Kind
+				lattice.Nodes[i].RefuelAmount = clonePtr(node.RefuelAmount)
+			}
+			continue
+		}
+		node.X, node.Y = float64(p[0])*cellKM, float64(p[1])*cellKM
+		node.RefuelAmount = clonePtr(node.RefuelAmount)
+		nodeAt[p] = len(lattice.Nodes)
+		lattice.Nodes = append(lattice.Nodes, node)
+	}
+
+	nextNodeID := nodes[len(nodes)-1].ID + 1
+	nodeID := func(p point) int64 {
+		if i, exists := nodeAt[p]; exists {
+			return lattice.Nodes[i].ID
+		}
+		nodeAt[p] = len(lattice.Nodes)
+		lattice.Nodes = append(lattice.Nodes, Node{ID: nextNodeID, X: float64(p[0]) * cellKM, Y: float64(p[1]) * cellKM})
+		nextNodeID++
+		return nextNodeID - 1
+	}
+
+	var nextSegmentID int64
+	if len(segments) > 0 {
+		nextSegmentID = segments[len(segments)-1].ID + 1
+	}
+	laid := make(map[[2]point]int)
+	for _, segment := range segments {
+		path := staircase(placed[segment.From], placed[segment.To])
+		if len(path) < 2 {
+			continue
+		}
+
+		first := true
+		for i := 1; i < len(path); i++ {
+			from, to := path[i-1], path[i]
+			key := [2]point{from, to}
+			if less(to, from) {
+				key = [2]point{to, from}
+			}
+			step := segment
+			step.From, step.To = nodeID(from), nodeID(to)
+			if shared, taken := laid[key]; taken {
+				widen(&lattice.Segments[shared], step)
+				continue
+			}
+			laid[key] = len(lattice.Segments)
+
+			step.LengthKM = segment.LengthKM / float64(len(path)-1)
+			step.SpeedLimit = clonePtr(segment.SpeedLimit)
+			step.Capacity = clonePtr(segment.Capacity)
+			if first {
+				first = false
+			} else {
+				step.ID = nextSegmentID
+				nextSegmentID++
+			}
+			lattice.Segments = append(lattice.Segments, step)
+		}
+	}
+
+	cellsX := max(extent[0]+1, int64(math.Ceil((g.WidthKM-originX)/cellKM)))
+	cellsY := max(extent[1]+1, int64(math.Ceil((g.HeightKM-originY)/cellKM)))
+	lattice.WidthKM = float64(cellsX) * cellKM
+	lattice.HeightKM = float64(cellsY) * cellKM
+	return lattice, nil
+}
+
+// widen opens a step shared by two staircases to the traffic of step as well,
+// in either direction and on as many lanes as either has.
+func widen(shared *Segment, step Segment) {
+	forward, backward := step.LanesForward, step.LanesBackward
+	along, against := true, !step.OneWay
+	if step.From != shared.From {
+		forward, backward = backward, forward
+		along, against = against, along
+	}
+	if along {
+		shared.LanesForward = max(shared.LanesForward, forward)
+	}
+	if against {
+		if shared.OneWay {
+			shared.OneWay = false
+			shared.LanesBackward = backward
+		} else {
+			shared.LanesBackward = max(shared.LanesBackward, backward)
+		}
+	}
+}
+
+// fitCellKM halves DefaultCellKM until no two nodes of g share a cell, or
+// until the lattice would grow past maxLatticeCells.
+func fitCellKM(g *Graph) float64 {
+	cellKM := DefaultCellKM
+	for sharesCell(g, cellKM) {
+		finer := cellKM / 2
+		if latticeCells(g, finer) > maxLatticeCells {
+			break
+		}
+		cellKM = finer
+	}
+	return cellKM
+}
+
+func sharesCell(g *Graph, cellKM float64) bool {
+	originX, originY := origin(g)
+	taken := make(map[point]bool, len(g.Nodes))
+	for _, node := range g.Nodes {
+		p := snap(node.X, node.Y, originX, originY, cellKM)
+		if taken[p] {
+			return true
+		}
+		taken[p] = true
+	}
+	return false
+}
+
+func latticeCells(g *Graph, cellKM float64) float64 {
+	originX, originY := origin(g)
+	spanX, spanY := g.WidthKM, g.HeightKM
+	for _, node := range g.Nodes {
+		spanX, spanY = max(spanX, node.X), max(spanY, node.Y)
+	}
+	return (math.Floor((spanX-originX)/cellKM) + 1) * (math.Floor((spanY-originY)/cellKM) + 1)
+}
+
+// origin is the lattice's corner: the map's origin, moved down and left to
+// take in any nodes with negative positions.
+func origin(g *Graph) (float64, float64) {
+	var x, y float64
+	for _, node := range g.Nodes {
+		x, y = min(x, node.X), min(y, node.Y)
+	}
+	return x, y
+}
+
+func snap(x, y, originX, originY, cellKM float64) point {
+	return point{int64(math.Round((x - originX) / cellKM)), int64(math.Round((y - originY) / cellKM))}
+}
+
+// staircase walks from one lattice point to another one neighbouring step at
+// a time, each step taken along whichever axis keeps it nearer the straight
+// line between them. Both ends are included.
+func staircase(from, to point) []point {
+	dx, dy := to[0]-from[0], to[1]-from[1]
+	stepX, stepY := sign(dx), sign(dy)
+	offLine := func(x, y int64) int64 {
+		return abs((x-from[0])*dy - (y-from[1])*dx)
+	}
+
+	path := make([]point, 0, abs(dx)+abs(dy)+1)
+	path = append(path, from)
+	x, y := from[0], from[1]
+	for x != to[0] || y != to[1] {
+		if y == to[1] || (x != to[0] && offLine(x+stepX, y) <= offLine(x, y+stepY)) {
+			x += stepX
+		} else {
+			y += stepY
+		}
+		path = append(path, point{x, y})
+	}
+	return path
+}
+
+func less(a, b point) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
+
+func sign(v int64) int64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,320 @@
+package roadgraph
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// drivableClasses are the OpenStreetMap highway classes LoadOSM keeps as
+// roads; footways, cycleways, tracks and roads not yet built are left out.
+var drivableClasses = map[string]bool{
+	"motorway": true, "motorway_link": true,
+	"trunk": true, "trunk_link": true,
+	"primary": true, "primary_link": true,
+	"secondary": true, "secondary_link": true,
+	"tertiary": true, "tertiary_link": true,
+	"unclassified": true, "residential": true, "living_street": true,
+	"service": true, "road": true,
+}
+
+// osmData is what LoadOSM needs of an OpenStreetMap extract: where nodes are
+// and which nodes each way runs through.
+type osmData struct {
+	nodes map[int64][2]float64 // [lon, lat]
+	ways  []osmWay
+}
+
+type osmWay struct {
+	id   int64
+	refs []int64
+	tags map[string]string
+}
+
+// LoadOSM reads the drivable roads of an OpenStreetMap extract, in .osm XML
+// or .osm.pbf form, as a road graph. Ways are split into segments wherever
+// they meet another way, so nodes are the intersections and dead ends, and a
+// segment is as long as the way between them. Positions are projected onto a
+// plane whose origin is the south-west corner of the roads.
+//
+// Segments keep the way's highway tag as their class, its maxspeed as their
+// speed limit, its lanes and its oneway tag, with motorways and roundabouts
+// one-way unless tagged otherwise. Node IDs are OpenStreetMap's; segments are
+// numbered from 0 in way order. Ways clipped by the extract's edge keep the
+// parts inside it.
+func LoadOSM(path string) (*Graph, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenStreetMap extract %s: %w", path, err)
+	}
+
+	var data *osmData
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("<")) {
+		data, err = readOSMXML(bytes.NewReader(contents))
+	} else {
+		data, err = readOSMPBF(contents)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenStreetMap extract %s: %w", path, err)
+	}
+
+	g, err := data.graph()
+	if err != nil {
+		return nil, fmt.Errorf("OpenStreetMap extract %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// readOSMXML reads the nodes and ways of an .osm file, skipping relations.
+func readOSMXML(r io.Reader) (*osmData, error) {
+	data := &osmData{nodes: make(map[int64][2]float64)}
+	decoder := xml.NewDecoder(r)
+	var way *osmWay
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string, len(element.Attr))
+			for _, attr := range element.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			switch element.Name.Local {
+			case "node":
+				id, err := strconv.ParseInt(attrs["id"], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("node with invalid id %q", attrs["id"])
+				}
+				lat, errLat := strconv.ParseFloat(attrs["lat"], 64)
+				lon, errLon := strconv.ParseFloat(attrs["lon"], 64)
+				if errLat != nil || errLon != nil {
+					return nil, fmt.Errorf("node %d has no valid position", id)
+				}
+				data.nodes[id] = [2]float64{lon, lat}
+			case "way":
+				id, err := strconv.ParseInt(attrs["id"], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("way with invalid id %q", attrs["id"])
+				}
+				way = &osmWay{id: id, tags: make(map[string]string)}
+			case "nd":
+				if way != nil {
+					ref, err := strconv.ParseInt(attrs["ref"], 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("way %d refers to invalid node %q", way.id, attrs["ref"])
+					}
+					way.refs = append(way.refs, ref)
+				}
+			case "tag":
+				if way != nil {
+					way.tags[attrs["k"]] = attrs["v"]
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "way" && way != nil {
+				data.ways = append(data.ways, *way)
+				way = nil
+			}
+		}
+	}
+	return data, nil
+}
+
+// graph splits the drivable ways into segments between the nodes where ways
+// meet or end.
+func (data *osmData) graph() (*Graph, error) {
+	type piece struct {
+		way  *osmWay
+		refs []int64
+	}
+	var pieces []piece
+	ways := slices.Clone(data.ways)
+	slices.SortFunc(ways, func(a, b osmWay) int { return cmp.Compare(a.id, b.id) })
+	for i := range ways {
+		way := &ways[i]
+		if !drivableClasses[way.tags["highway"]] || way.tags["area"] == "yes" ||
+			way.tags["access"] == "no" || way.tags["access"] == "private" {
+			continue
+		}
+		// Nodes the extract left out cut the way into the parts on either side.
+		var refs []int64
+		for _, ref := range way.refs {
+			if _, exists := data.nodes[ref]; !exists {
+				if len(refs) > 1 {
+					pieces = append(pieces, piece{way, refs})
+				}
+				refs = nil
+				continue
+			}
+			if len(refs) == 0 || refs[len(refs)-1] != ref {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) > 1 {
+			pieces = append(pieces, piece{way, refs})
+		}
+	}
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("no drivable roads")
+	}
+
+	uses := make(map[int64]int)
+	origin := Projection{OriginLon: math.Inf(1), OriginLat: math.Inf(1)}
+	for _, p := range pieces {
+		for i, ref := range p.refs {
+			uses[ref]++
+			if i == 0 || i == len(p.refs)-1 {
+				uses[ref]++
+			}
+			position := data.nodes[ref]
+			origin.OriginLon = min(origin.OriginLon, position[0])
+			origin.OriginLat = min(origin.OriginLat, position[1])
+		}
+	}
+	at := func(ref int64) (float64, float64) {
+		return origin.FromLonLat(data.nodes[ref])
+	}
+	distance := func(refs []int64) float64 {
+		total := 0.0
+		for i := 1; i < len(refs); i++ {
+			x1, y1 := at(refs[i-1])
+			x2, y2 := at(refs[i])
+			total += math.Hypot(x2-x1, y2-y1)
+		}
+		return total
+	}
+
+	g := &Graph{}
+	placed := make(map[int64]bool)
+	addNode := func(ref int64) {
+		if placed[ref] {
+			return
+		}
+		placed[ref] = true
+		x, y := at(ref)
+		g.Nodes = append(g.Nodes, Node{ID: ref, X: x, Y: y})
+		g.WidthKM, g.HeightKM = max(g.WidthKM, x), max(g.HeightKM, y)
+	}
+	addSegment := func(way *osmWay, refs []int64) {
+		addNode(refs[0])
+		addNode(refs[len(refs)-1])
+		segment := Segment{
+			ID:         int64(len(g.Segments)),
+			From:       refs[0],
+			To:         refs[len(refs)-1],
+			LengthKM:   distance(refs),
+			SpeedLimit: parseMaxSpeed(way.tags["maxspeed"]),
+			Class:      way.tags["highway"],
+		}
+		direction := oneWay(way.tags)
+		segment.LanesForward, segment.LanesBackward = lanes(way.tags, direction)
+		switch direction {
+		case 1:
+			segment.OneWay = true
+		case -1:
+			segment.OneWay = true
+			segment.From, segment.To = segment.To, segment.From
+		}
+		g.Segments = append(g.Segments, segment)
+	}
+
+	for _, p := range pieces {
+		start := 0
+		for i := 1; i < len(p.refs); i++ {
+			if uses[p.refs[i]] < 2 {
+				continue
+			}
+			stretch := p.refs[start : i+1]
+			if stretch[0] == stretch[len(stretch)-1] {
+				// A loop back to where it started is split halfway round,
+				// as a segment cannot end where it starts.
+				if len(stretch) > 2 {
+					middle := len(stretch) / 2
+					addSegment(p.way, stretch[:middle+1])
+					addSegment(p.way, stretch[middle:])
+				}
+			} else {
+				addSegment(p.way, stretch)
+			}
+			start = i
+		}
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b Node) int { return cmp.Compare(a.ID, b.ID) })
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// oneWay reads a way's direction from its tags: 1 if it is driven along the
+// way, -1 if against it and 0 if both ways.
+func oneWay(tags map[string]string) int {
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		return 1
+	case "-1", "reverse":
+		return -1
+	case "no", "false", "0":
+		return 0
+	}
+	if tags["highway"] == "motorway" || tags["junction"] == "roundabout" || tags["junction"] == "circular" {
+		return 1
+	}
+	return 0
+}
+
+// lanes reads a way's lane counts along and against the segment it becomes,
+// given the way's direction from oneWay. A one-way road has all its lanes
+// along it; a two-way road without lanes:forward and lanes:backward splits
+// its lanes, the odd one going forward. Counts the tags leave out are zero.
+func lanes(tags map[string]string, direction int) (forward, backward int) {
+	count := func(key string) int {
+		n, err := strconv.Atoi(strings.TrimSpace(tags[key]))
+		if err != nil || n < 0 {
+			return 0
+		}
+		return n
+	}
+	total := count("lanes")
+	if direction != 0 {
+		return total, 0
+	}
+	forward, backward = count("lanes:forward"), count("lanes:backward")
+	if forward == 0 && backward == 0 && total > 0 {
+		forward, backward = total-total/2, total/2
+	}
+	return forward, backward
+}
+
+// parseMaxSpeed reads a maxspeed tag in km/h, such as "50", "50 km/h" or
+// "30 mph". Values that are not a number, such as "none" or "signals", give
+// no limit.
+func parseMaxSpeed(tag string) *int64 {
+	value := strings.TrimSpace(tag)
+	factor := 1.0
+	if number, found := strings.CutSuffix(value, "mph"); found {
+		value, factor = number, 1.609344
+	} else {
+		value = strings.TrimSuffix(strings.TrimSuffix(value, "km/h"), "kmh")
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || speed <= 0 {
+		return nil
+	}
+	limit := int64(math.Round(speed * factor))
+	return &limit
+}
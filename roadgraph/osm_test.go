@@ -0,0 +1,146 @@
+package roadgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The town fixtures hold the same map as XML and as PBF: a main street and a
+// parallel side street joined by link roads, a roundabout, and ways the
+// import has to leave out or cut short.
+const (
+	townXML = "testdata/town.osm"
+	townPBF = "testdata/town.osm.pbf"
+)
+
+// wantSegment is what the import should make of one road of the town, found
+// by the nodes it joins. A limit of zero means the road has none.
+type wantSegment struct {
+	from, to      int64
+	class         string
+	oneWay        bool
+	limit         int64
+	forward, back int
+}
+
+var townSegments = []wantSegment{
+	// The main street is split where the link roads join it, but not at the
+	// node it only passes through.
+	{from: 101, to: 102, class: "primary", limit: 50, forward: 2, back: 2},
+	{from: 102, to: 103, class: "primary", limit: 50, forward: 2, back: 2},
+	{from: 103, to: 104, class: "primary", limit: 50, forward: 2, back: 2},
+	{from: 104, to: 105, class: "primary", limit: 50, forward: 2, back: 2},
+	{from: 201, to: 202, class: "residential", limit: 30},
+	{from: 202, to: 203, class: "residential", limit: 30},
+	{from: 203, to: 204, class: "residential", limit: 30},
+	{from: 204, to: 205, class: "residential", limit: 30},
+	// 20 mph rounds to 32 km/h; the lanes each way are tagged apart.
+	{from: 102, to: 202, class: "secondary", limit: 32, forward: 2, back: 1},
+	{from: 104, to: 204, class: "residential", oneWay: true},
+	// oneway=-1 is driven against the way's node order, and a limit of
+	// "signals" is no number at all.
+	{from: 103, to: 203, class: "tertiary", oneWay: true, forward: 2},
+	// The roundabout is one-way without being tagged so, and its loop is
+	// cut where the other roads join it and halfway round.
+	{from: 301, to: 303, class: "primary", oneWay: true, limit: 30},
+	{from: 303, to: 304, class: "primary", oneWay: true, limit: 30},
+	{from: 304, to: 301, class: "primary", oneWay: true, limit: 30},
+	{from: 105, to: 304, class: "primary_link"},
+	{from: 205, to: 303, class: "residential"},
+	{from: 103, to: 401, class: "service"},
+	{from: 701, to: 702, class: "residential"},
+	// The way ends at a node missing from the extract.
+	{from: 201, to: 801, class: "unclassified"},
+}
+
+func TestLoadOSMReadsTheTown(t *testing.T) {
+	for _, path := range []string{townXML, townPBF} {
+		t.Run(path, func(t *testing.T) {
+			graph, err := LoadOSM(path)
+			if err != nil {
+				t.Fatalf("LoadOSM: %v", err)
+			}
+			if err := graph.Validate(); err != nil {
+				t.Fatalf("imported graph does not validate: %v", err)
+			}
+			// Footways, roads under construction, private roads and
+			// areas are left out, and so are the nodes only they use.
+			if len(graph.Nodes) != 17 {
+				t.Errorf("got %d nodes, want 17", len(graph.Nodes))
+			}
+			if len(graph.Segments) != len(townSegments) {
+				t.Fatalf("got %d segments, want %d", len(graph.Segments), len(townSegments))
+			}
+			for i, want := range townSegments {
+				checkTownSegment(t, graph.Segments[i], want)
+			}
+
+			// The lone residential street is cut off from the rest.
+			if largest := graph.LargestComponent(); len(largest.Nodes) != 15 || len(largest.Segments) != 18 {
+				t.Errorf("largest component has %d nodes and %d segments, want 15 and 18",
+					len(largest.Nodes), len(largest.Segments))
+			}
+		})
+	}
+}
+
+func checkTownSegment(t *testing.T, got Segment, want wantSegment) {
+	t.Helper()
+	if got.From != want.from || got.To != want.to {
+		t.Errorf("segment %d joins %d to %d, want %d to %d", got.ID, got.From, got.To, want.from, want.to)
+		return
+	}
+	if got.Class != want.class {
+		t.Errorf("segment %d-%d is %q, want %q", want.from, want.to, got.Class, want.class)
+	}
+	if got.OneWay != want.oneWay {
+		t.Errorf("segment %d-%d one-way %v, want %v", want.from, want.to, got.OneWay, want.oneWay)
+	}
+	var limit int64
+	if got.SpeedLimit != nil {
+		limit = *got.SpeedLimit
+	}
+	if limit != want.limit {
+		t.Errorf("segment %d-%d limit %d, want %d", want.from, want.to, limit, want.limit)
+	}
+	if got.LanesForward != want.forward || got.LanesBackward != want.back {
+		t.Errorf("segment %d-%d has %d/%d lanes, want %d/%d",
+			want.from, want.to, got.LanesForward, got.LanesBackward, want.forward, want.back)
+	}
+	if got.LengthKM <= 0 {
+		t.Errorf("segment %d-%d is %.3fkm long", want.from, want.to, got.LengthKM)
+	}
+}
+
+func TestLoadOSMReadsPBFLikeXML(t *testing.T) {
+	fromXML, err := LoadOSM(townXML)
+	if err != nil {
+		t.Fatalf("LoadOSM XML: %v", err)
+	}
+	fromPBF, err := LoadOSM(townPBF)
+	if err != nil {
+		t.Fatalf("LoadOSM PBF: %v", err)
+	}
+	if !reflect.DeepEqual(fromXML, fromPBF) {
+		t.Error("the PBF extract imports differently from the XML one")
+	}
+}
+
+func TestParseMaxSpeed(t *testing.T) {
+	for tag, want := range map[string]int64{
+		"50":      50,
+		"30 km/h": 30,
+		"20 mph":  32,
+		"signals": 0,
+		"none":    0,
+		"":        0,
+	} {
+		var got int64
+		if limit := parseMaxSpeed(tag); limit != nil {
+			got = *limit
+		}
+		if got != want {
+			t.Errorf("parseMaxSpeed(%q) = %d, want %d", tag, got, want)
+		}
+	}
+}
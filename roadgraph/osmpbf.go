@@ -0,0 +1,395 @@
+package roadgraph
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// pbfFeatures are the required features of an .osm.pbf file readOSMPBF can
+// read; files needing others, such as history, are refused.
+var pbfFeatures = []string{"OsmSchema-V0.6", "DenseNodes"}
+
+// maxPBFBlob is the largest blob the format allows, uncompressed.
+const maxPBFBlob = 32 << 20
+
+// readOSMPBF reads the nodes and ways of an .osm.pbf file, skipping
+// relations. Blobs must be stored raw or zlib-compressed.
+func readOSMPBF(contents []byte) (*osmData, error) {
+	data := &osmData{nodes: make(map[int64][2]float64)}
+	for len(contents) > 0 {
+		if len(contents) < 4 {
+			return nil, fmt.Errorf("truncated blob header length")
+		}
+		headerSize := binary.BigEndian.Uint32(contents)
+		contents = contents[4:]
+		if uint64(headerSize) > uint64(len(contents)) {
+			return nil, fmt.Errorf("truncated blob header")
+		}
+
+		var blobType string
+		var blobSize uint64
+		header := pbMessage(contents[:headerSize])
+		contents = contents[headerSize:]
+		for header.next() {
+			switch header.field {
+			case 1:
+				blobType = string(header.bytes)
+			case 3:
+				blobSize = header.varint
+			}
+		}
+		if header.err != nil {
+			return nil, fmt.Errorf("blob header: %w", header.err)
+		}
+		if blobSize > uint64(len(contents)) {
+			return nil, fmt.Errorf("truncated %s blob", blobType)
+		}
+
+		block, err := pbfBlob(contents[:blobSize])
+		contents = contents[blobSize:]
+		if err != nil {
+			return nil, fmt.Errorf("%s blob: %w", blobType, err)
+		}
+		switch blobType {
+		case "OSMHeader":
+			err = checkPBFHeader(block)
+		case "OSMData":
+			err = data.readPrimitiveBlock(block)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pbfBlob unpacks a blob's contents.
+func pbfBlob(blob []byte) ([]byte, error) {
+	var raw, compressed []byte
+	var rawSize uint64
+	m := pbMessage(blob)
+	for m.next() {
+		switch m.field {
+		case 1:
+			raw = m.bytes
+		case 2:
+			rawSize = m.varint
+		case 3:
+			compressed = m.bytes
+		case 4, 5, 6, 7:
+			return nil, fmt.Errorf("unsupported compression (field %d); only zlib is read", m.field)
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	if compressed == nil {
+		return raw, nil
+	}
+	if rawSize > maxPBFBlob {
+		return nil, fmt.Errorf("blob of %d bytes is larger than the format allows", rawSize)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	unpacked := make([]byte, 0, rawSize)
+	buffer := bytes.NewBuffer(unpacked)
+	if _, err := io.Copy(buffer, io.LimitReader(reader, maxPBFBlob+1)); err != nil {
+		return nil, err
+	}
+	if buffer.Len() > maxPBFBlob {
+		return nil, fmt.Errorf("blob unpacks past the %d bytes the format allows", maxPBFBlob)
+	}
+	return buffer.Bytes(), nil
+}
+
+func checkPBFHeader(block []byte) error {
+	m := pbMessage(block)
+	for m.next() {
+		if m.field == 4 && !slices.Contains(pbfFeatures, string(m.bytes)) {
+			return fmt.Errorf("extract needs unsupported feature %q", m.bytes)
+		}
+	}
+	return m.err
+}
+
+// readPrimitiveBlock reads the nodes and ways of one data block.
+func (data *osmData) readPrimitiveBlock(block []byte) error {
+	var stringTable [][]byte
+	var groups [][]byte
+	granularity, latOffset, lonOffset := int64(100), int64(0), int64(0)
+	m := pbMessage(block)
+	for m.next() {
+		switch m.field {
+		case 1:
+			table := pbMessage(m.bytes)
+			for table.next() {
+				if table.field == 1 {
+					stringTable = append(stringTable, table.bytes)
+				}
+			}
+			if table.err != nil {
+				return fmt.Errorf("string table: %w", table.err)
+			}
+		case 2:
+			groups = append(groups, m.bytes)
+		case 17:
+			granularity = int64(m.varint)
+		case 19:
+			latOffset = int64(m.varint)
+		case 20:
+			lonOffset = int64(m.varint)
+		}
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	// Positions are in nanodegrees; dividing rounds them to the same degrees
+	// as reading the decimal an .osm file would give.
+	position := func(lat, lon int64) [2]float64 {
+		return [2]float64{
+			float64(lonOffset+granularity*lon) / 1e9,
+			float64(latOffset+granularity*lat) / 1e9,
+		}
+	}
+	str := func(index uint64) (string, error) {
+		if index >= uint64(len(stringTable)) {
+			return "", fmt.Errorf("string %d is not in the block's table", index)
+		}
+		return string(stringTable[index]), nil
+	}
+
+	for _, group := range groups {
+		g := pbMessage(group)
+		for g.next() {
+			var err error
+			switch g.field {
+			case 1:
+				err = data.readNode(g.bytes, position)
+			case 2:
+				err = data.readDenseNodes(g.bytes, position)
+			case 3:
+				err = data.readWay(g.bytes, str)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if g.err != nil {
+			return g.err
+		}
+	}
+	return nil
+}
+
+func (data *osmData) readNode(node []byte, position func(lat, lon int64) [2]float64) error {
+	var id, lat, lon int64
+	m := pbMessage(node)
+	for m.next() {
+		switch m.field {
+		case 1:
+			id = zigzag(m.varint)
+		case 8:
+			lat = zigzag(m.varint)
+		case 9:
+			lon = zigzag(m.varint)
+		}
+	}
+	if m.err != nil {
+		return fmt.Errorf("node: %w", m.err)
+	}
+	data.nodes[id] = position(lat, lon)
+	return nil
+}
+
+// readDenseNodes reads a run of nodes stored column by column, each value a
+// difference from the one before.
+func (data *osmData) readDenseNodes(dense []byte, position func(lat, lon int64) [2]float64) error {
+	var ids, lats, lons []int64
+	m := pbMessage(dense)
+	for m.next() {
+		var err error
+		switch m.field {
+		case 1:
+			ids, err = m.sint64s(ids)
+		case 8:
+			lats, err = m.sint64s(lats)
+		case 9:
+			lons, err = m.sint64s(lons)
+		}
+		if err != nil {
+			return fmt.Errorf("dense nodes: %w", err)
+		}
+	}
+	if m.err != nil {
+		return fmt.Errorf("dense nodes: %w", m.err)
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return fmt.Errorf("dense nodes have %d ids but %d latitudes and %d longitudes", len(ids), len(lats), len(lons))
+	}
+
+	var id, lat, lon int64
+	for i := range ids {
+		id, lat, lon = id+ids[i], lat+lats[i], lon+lons[i]
+		data.nodes[id] = position(lat, lon)
+	}
+	return nil
+}
+
+func (data *osmData) readWay(way []byte, str func(uint64) (string, error)) error {
+	var keys, values []uint64
+	var deltas []int64
+	w := osmWay{tags: make(map[string]string)}
+	m := pbMessage(way)
+	for m.next() {
+		var err error
+		switch m.field {
+		case 1:
+			w.id = int64(m.varint)
+		case 2:
+			keys, err = m.uvarints(keys)
+		case 3:
+			values, err = m.uvarints(values)
+		case 8:
+			deltas, err = m.sint64s(deltas)
+		}
+		if err != nil {
+			return fmt.Errorf("way %d: %w", w.id, err)
+		}
+	}
+	if m.err != nil {
+		return fmt.Errorf("way %d: %w", w.id, m.err)
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("way %d has %d tag keys but %d values", w.id, len(keys), len(values))
+	}
+
+	for i := range keys {
+		key, err := str(keys[i])
+		if err != nil {
+			return fmt.Errorf("way %d: %w", w.id, err)
+		}
+		value, err := str(values[i])
+		if err != nil {
+			return fmt.Errorf("way %d: %w", w.id, err)
+		}
+		w.tags[key] = value
+	}
+	var ref int64
+	for _, delta := range deltas {
+		ref += delta
+		w.refs = append(w.refs, ref)
+	}
+	data.ways = append(data.ways, w)
+	return nil
+}
+
+// pbReader walks the fields of a protocol buffer message. After each
+// successful next, field is the field number and varint or bytes holds its
+// value, by wire type; fixed-width values are skipped.
+type pbReader struct {
+	rest   []byte
+	field  uint64
+	wire   uint64
+	varint uint64
+	bytes  []byte
+	err    error
+}
+
+func pbMessage(message []byte) *pbReader {
+	return &pbReader{rest: message}
+}
+
+func (r *pbReader) next() bool {
+	for len(r.rest) > 0 && r.err == nil {
+		key, ok := r.readVarint()
+		if !ok {
+			return false
+		}
+		r.field, r.wire = key>>3, key&7
+		r.varint, r.bytes = 0, nil
+		switch r.wire {
+		case 0:
+			if r.varint, ok = r.readVarint(); !ok {
+				return false
+			}
+			return true
+		case 2:
+			size, ok := r.readVarint()
+			if !ok {
+				return false
+			}
+			if size > uint64(len(r.rest)) {
+				r.err = fmt.Errorf("field %d runs past the end of its message", r.field)
+				return false
+			}
+			r.bytes, r.rest = r.rest[:size], r.rest[size:]
+			return true
+		case 1, 5:
+			width := 8
+			if r.wire == 5 {
+				width = 4
+			}
+			if width > len(r.rest) {
+				r.err = fmt.Errorf("field %d runs past the end of its message", r.field)
+				return false
+			}
+			r.rest = r.rest[width:]
+		default:
+			r.err = fmt.Errorf("field %d has unsupported wire type %d", r.field, r.wire)
+			return false
+		}
+	}
+	return false
+}
+
+func (r *pbReader) readVarint() (uint64, bool) {
+	value, n := binary.Uvarint(r.rest)
+	if n <= 0 {
+		r.err = fmt.Errorf("invalid varint")
+		return 0, false
+	}
+	r.rest = r.rest[n:]
+	return value, true
+}
+
+// uvarints appends the field's values, packed or not, to values.
+func (r *pbReader) uvarints(values []uint64) ([]uint64, error) {
+	if r.wire == 0 {
+		return append(values, r.varint), nil
+	}
+	packed := r.bytes
+	for len(packed) > 0 {
+		value, n := binary.Uvarint(packed)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid packed varint in field %d", r.field)
+		}
+		values = append(values, value)
+		packed = packed[n:]
+	}
+	return values, nil
+}
+
+// sint64s appends the field's zigzag-encoded values, packed or not, to values.
+func (r *pbReader) sint64s(values []int64) ([]int64, error) {
+	raw, err := r.uvarints(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range raw {
+		values = append(values, zigzag(value))
+	}
+	return values, nil
+}
+
+func zigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
@@ -0,0 +1,52 @@
+package roadgraph
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKM = 6371.0088
+
+// Projection places a map's plane on the globe around an origin, where the
+// plane's (0, 0) lies: x runs east and y north, both in kilometres. It uses
+// the equirectangular approximation, which stays well within a metre over the
+// few kilometres a map spans.
+type Projection struct {
+	OriginLon float64 `json:"lon"`
+	OriginLat float64 `json:"lat"`
+}
+
+// ParseProjection reads an origin written as "lon,lat" in degrees.
+func ParseProjection(origin string) (Projection, error) {
+	lon, lat, found := strings.Cut(origin, ",")
+	if !found {
+		return Projection{}, fmt.Errorf("origin %q is not lon,lat", origin)
+	}
+	var p Projection
+	var err error
+	if p.OriginLon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil || math.Abs(p.OriginLon) > 180 {
+		return Projection{}, fmt.Errorf("origin longitude %q is not between -180 and 180", lon)
+	}
+	if p.OriginLat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil || math.Abs(p.OriginLat) >= 90 {
+		return Projection{}, fmt.Errorf("origin latitude %q is not between -90 and 90", lat)
+	}
+	return p, nil
+}
+
+// ToLonLat is the position of a point of the plane in degrees.
+func (p Projection) ToLonLat(xKM, yKM float64) [2]float64 {
+	degrees := 180 / math.Pi
+	return [2]float64{
+		p.OriginLon + xKM/(earthRadiusKM*math.Cos(p.OriginLat/degrees))*degrees,
+		p.OriginLat + yKM/earthRadiusKM*degrees,
+	}
+}
+
+// FromLonLat is the point of the plane at a position in degrees.
+func (p Projection) FromLonLat(lonLat [2]float64) (xKM, yKM float64) {
+	degrees := 180 / math.Pi
+	return (lonLat[0] - p.OriginLon) / degrees * earthRadiusKM * math.Cos(p.OriginLat/degrees),
+		(lonLat[1] - p.OriginLat) / degrees * earthRadiusKM
+}
@@ -0,0 +1,159 @@
+// Package simclock is the simulated time shared by fleetsim and simsim. A
+// clock only moves when the simulation advances it by a step; its mode decides
+// how long the simulation waits in real time before taking the next one.
+package simclock
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+type Mode string
+
+const (
+	RealTime         Mode = "realtime"
+	Accelerated      Mode = "accelerated"
+	AsFastAsPossible Mode = "afap"
+)
+
+func ParseMode(raw string) (Mode, error) {
+	switch mode := Mode(raw); mode {
+	case RealTime, Accelerated, AsFastAsPossible:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown clock mode %q (want realtime, accelerated or afap)", raw)
+}
+
+// Clock tells simulated time.
+type Clock interface {
+	// Now is the current simulated time.
+	Now() time.Time
+	// Elapsed is the simulated time since the clock started.
+	Elapsed() time.Duration
+	// Advance moves simulated time forward by d.
+	Advance(d time.Duration)
+	// Step is the simulated time one tick of wall length covers.
+	Step(wall time.Duration) time.Duration
+	// Wait is how long to wait in real time for simulated to pass; zero when
+	// the clock runs as fast as possible.
+	Wait(simulated time.Duration) time.Duration
+	// Speed is simulated seconds per real second.
+	Speed() float64
+	// SetSpeed changes the speed from the next step on. A real-time clock
+	// always runs at speed 1 and rejects any change.
+	SetSpeed(speed float64) error
+	Mode() Mode
+}
+
+// SimClock is the Clock used by every mode. Elapsed time and speed are kept
+// atomically so they can be read while the simulation runs on another
+// goroutine.
+type SimClock struct {
+	mode  Mode
+	start time.Time
+
+	elapsed atomic.Int64
+	speed   atomic.Uint64
+}
+
+// New returns a clock starting at start. speed is simulated seconds per real
+// second; it is ignored in real-time mode and, when running as fast as
+// possible, only sets how much simulated time each tick covers.
+func New(mode Mode, speed float64, start time.Time) (*SimClock, error) {
+	switch mode {
+	case RealTime:
+		speed = 1.0
+	case Accelerated, AsFastAsPossible:
+		if speed <= 0 {
+			return nil, fmt.Errorf("clock speed must be positive, got %.2f", speed)
+		}
+	default:
+		return nil, fmt.Errorf("unknown clock mode %q", mode)
+	}
+	return newClock(mode, speed, start), nil
+}
+
+func NewRealTime(start time.Time) *SimClock {
+	return newClock(RealTime, 1.0, start)
+}
+
+func NewAsFastAsPossible(start time.Time) *SimClock {
+	return newClock(AsFastAsPossible, 1.0, start)
+}
+
+func newClock(mode Mode, speed float64, start time.Time) *SimClock {
+	clock := &SimClock{mode: mode, start: start}
+	clock.speed.Store(math.Float64bits(speed))
+	return clock
+}
+
+func (c *SimClock) Now() time.Time {
+	return c.start.Add(c.Elapsed())
+}
+
+func (c *SimClock) Elapsed() time.Duration {
+	return time.Duration(c.elapsed.Load())
+}
+
+func (c *SimClock) Advance(d time.Duration) {
+	if d > 0 {
+		c.elapsed.Add(int64(d))
+	}
+}
+
+func (c *SimClock) Step(wall time.Duration) time.Duration {
+	return time.Duration(float64(wall) * c.Speed())
+}
+
+func (c *SimClock) Wait(simulated time.Duration) time.Duration {
+	if c.mode == AsFastAsPossible {
+		return 0
+	}
+	return time.Duration(float64(simulated) / c.Speed())
+}
+
+func (c *SimClock) Speed() float64 {
+	return math.Float64frombits(c.speed.Load())
+}
+
+func (c *SimClock) SetSpeed(speed float64) error {
+	if c.mode == RealTime {
+		return fmt.Errorf("a real-time clock cannot change speed")
+	}
+	if speed <= 0 {
+		return fmt.Errorf("clock speed must be positive, got %.2f", speed)
+	}
+	c.speed.Store(math.Float64bits(speed))
+	return nil
+}
+
+func (c *SimClock) Mode() Mode {
+	return c.mode
+}
+
+// Ticker fires once per simulated step at the pace of its clock. When the
+// clock runs as fast as possible its channel is always ready.
+type Ticker struct {
+	C <-chan time.Time
+
+	ticker *time.Ticker
+}
+
+func NewTicker(clock Clock, simulated time.Duration) *Ticker {
+	wait := clock.Wait(simulated)
+	if wait <= 0 {
+		ready := make(chan time.Time)
+		close(ready)
+		return &Ticker{C: ready}
+	}
+	ticker := time.NewTicker(wait)
+	return &Ticker{C: ticker.C, ticker: ticker}
+}
+
+func (t *Ticker) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+}
@@ -0,0 +1,88 @@
+package simclock
+
+import (
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestAsFastAsPossibleRunsADayInSeconds(t *testing.T) {
+	const day = 24 * time.Hour
+	clock := NewAsFastAsPossible(testStart)
+	ticker := NewTicker(clock, time.Second)
+	defer ticker.Stop()
+
+	started := time.Now()
+	for clock.Elapsed() < day {
+		<-ticker.C
+		clock.Advance(clock.Step(time.Second))
+	}
+	if took := time.Since(started); took > 10*time.Second {
+		t.Errorf("a simulated day of one-second steps took %v", took)
+	}
+	if want := testStart.Add(day); !clock.Now().Equal(want) {
+		t.Errorf("clock reads %v, want %v", clock.Now(), want)
+	}
+	if wait := clock.Wait(time.Hour); wait != 0 {
+		t.Errorf("clock waits %v for an hour, want no wait", wait)
+	}
+}
+
+func TestAcceleratedClockScalesSteps(t *testing.T) {
+	clock, err := New(Accelerated, 60, testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := clock.Step(time.Second); step != time.Minute {
+		t.Errorf("a second's tick covers %v, want 1m", step)
+	}
+	if wait := clock.Wait(time.Minute); wait != time.Second {
+		t.Errorf("a simulated minute waits %v, want 1s", wait)
+	}
+
+	if err := clock.SetSpeed(120); err != nil {
+		t.Fatalf("SetSpeed: %v", err)
+	}
+	if step := clock.Step(time.Second); step != 2*time.Minute {
+		t.Errorf("after speeding up, a second's tick covers %v, want 2m", step)
+	}
+	if err := clock.SetSpeed(0); err == nil {
+		t.Error("SetSpeed(0) succeeded")
+	}
+	if _, err := New(Accelerated, -1, testStart); err == nil {
+		t.Error("New with a negative speed succeeded")
+	}
+}
+
+func TestRealTimeClockKeepsSpeedOne(t *testing.T) {
+	clock, err := New(RealTime, 5, testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if speed := clock.Speed(); speed != 1 {
+		t.Errorf("real-time clock runs at %g, want 1", speed)
+	}
+	if err := clock.SetSpeed(2); err == nil {
+		t.Error("real-time clock changed speed")
+	}
+	if wait := clock.Wait(time.Second); wait != time.Second {
+		t.Errorf("a simulated second waits %v, want 1s", wait)
+	}
+
+	clock.Advance(-time.Minute)
+	if !clock.Now().Equal(testStart) {
+		t.Errorf("clock moved back to %v", clock.Now())
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []Mode{RealTime, Accelerated, AsFastAsPossible} {
+		if parsed, err := ParseMode(string(mode)); err != nil || parsed != mode {
+			t.Errorf("ParseMode(%q) = %q, %v", mode, parsed, err)
+		}
+	}
+	if _, err := ParseMode("warp"); err == nil {
+		t.Error(`ParseMode("warp") succeeded`)
+	}
+}
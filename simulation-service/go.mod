@@ -5,6 +5,7 @@ go 1.24.6
 require (
 	github.com/segmentio/ksuid v1.0.4
 	gonum.org/v1/plot v0.16.0
+	owenvi.com/roadgraph v0.0.0
 )
 
 require (
@@ -19,3 +20,5 @@ require (
 	golang.org/x/image v0.30.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
 )
+
+replace owenvi.com/roadgraph => ../roadgraph
@@ -0,0 +1,194 @@
+package coremodels
+
+import (
+	"math"
+	"sort"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Defaults of the car-following model for vehicles that leave their own
+// parameters at zero.
+const (
+	DefaultAccelerationMPS2            = 1.5
+	DefaultComfortableDecelerationMPS2 = 2.0
+	DefaultMinimumGapM                 = 2.0
+	DefaultTimeHeadwaySeconds          = 1.5
+)
+
+const (
+	// VehicleLengthM is how much of the road a vehicle takes up, bumper to
+	// bumper.
+	VehicleLengthM = 4.5
+	// maxDecelerationMPS2 caps emergency braking.
+	maxDecelerationMPS2 = 9.0
+	// accelerationExponent is how sharply a vehicle eases off as it nears
+	// the speed it wants.
+	accelerationExponent = 4
+)
+
+// stopLineSlackM is how close to a stop line a vehicle braking for it has to
+// get to count as having reached it.
+const stopLineSlackM = 1.0
+
+// Leader is what a follower drives behind, as it was at the start of the
+// step: the gap to it along the segment and how fast it went. At the front
+// of a queue it is the stop line, which the follower drives right up to.
+type Leader struct {
+	GapM     float64
+	SpeedKPH float64
+	StopLine bool
+}
+
+// FindLeaders orders the moving vehicles on each way of each segment by how
+// far along it they are and pairs each with the vehicle it follows. On a way
+// of several lanes a vehicle follows the one as many places ahead, as if
+// they kept to alternate lanes. The vehicles at the front follow the stop
+// line when control would hold them there; the others with nobody ahead of
+// them are left out.
+func FindLeaders(vehicles []*Vehicle, grid *Grid, control *IntersectionControl) map[*Vehicle]Leader {
+	ways := make(map[carriageway][]*Vehicle)
+	for _, vehicle := range vehicles {
+		if vehicle.Status != StatusMoving {
+			continue
+		}
+		if _, exists := grid.Segments[vehicle.CurrentSegmentID]; !exists {
+			continue
+		}
+		way := carriageway{segmentID: vehicle.CurrentSegmentID, forward: vehicle.TravelDirection >= 0}
+		ways[way] = append(ways[way], vehicle)
+	}
+
+	leaders := make(map[*Vehicle]Leader)
+	for way, onWay := range ways {
+		sort.Slice(onWay, func(i, j int) bool {
+			pi, pj := onWay[i].distanceAlong(), onWay[j].distanceAlong()
+			if pi != pj {
+				return pi > pj
+			}
+			return ksuid.Compare(onWay[i].ID, onWay[j].ID) < 0
+		})
+
+		segment := grid.Segments[way.segmentID]
+		from := segment.StartNode
+		if !way.forward {
+			from = segment.EndNode
+		}
+		lanes := max(segment.LanesFrom(from), 1)
+		if to := segment.otherEnd(from); control.StopsAt(to, segment.ID) {
+			for _, front := range onWay[:min(lanes, len(onWay))] {
+				if front.TargetNodeID == to {
+					continue
+				}
+				leaders[front] = Leader{
+					GapM:     (1-front.distanceAlong())*segment.LengthKM*1000 + front.minimumGap(),
+					StopLine: true,
+				}
+			}
+		}
+		for i := lanes; i < len(onWay); i++ {
+			ahead, follower := onWay[i-lanes], onWay[i]
+			leaders[follower] = Leader{
+				GapM:     (ahead.distanceAlong()-follower.distanceAlong())*segment.LengthKM*1000 - VehicleLengthM,
+				SpeedKPH: ahead.CurrentSpeedKPH,
+			}
+		}
+	}
+	return leaders
+}
+
+// distanceAlong is the share of its segment the vehicle has driven, whichever
+// way it drives it.
+func (v *Vehicle) distanceAlong() float64 {
+	if v.TravelDirection >= 0 {
+		return v.SegmentProgress
+	}
+	return 1 - v.SegmentProgress
+}
+
+// FollowOnSegment moves the vehicle along its current segment like
+// AdvanceOnSegment, but under the Intelligent Driver Model: rather than
+// taking its effective speed at once, it accelerates towards it and brakes
+// to keep a safe headway behind the leader, if it has one. It never closes
+// on the leader to within the minimum gap, so queues form behind slow and
+// stopped vehicles.
+func (v *Vehicle) FollowOnSegment(deltaTimeSeconds float64, grid *Grid, leader *Leader) (bool, error) {
+	segment, moving, err := v.segmentToAdvance(grid)
+	if err != nil || !moving {
+		return false, err
+	}
+	if v.isHeldAtSegmentEnd() {
+		v.CurrentSpeedKPH = 0
+		return true, nil
+	}
+
+	speed := v.CurrentSpeedKPH / 3.6
+	acceleration := v.idmAcceleration(speed, v.effectiveSpeed(grid)/3.6, leader)
+	next := speed + acceleration*deltaTimeSeconds
+	distanceM := (speed + next) / 2 * deltaTimeSeconds
+	if next < 0 {
+		// The vehicle stops within the step.
+		next = 0
+		distanceM = speed * speed / (-2 * acceleration)
+	}
+	if leader != nil && distanceM > leader.GapM-v.minimumGap() {
+		distanceM = math.Max(leader.GapM-v.minimumGap(), 0)
+		next = math.Min(next, leader.SpeedKPH/3.6)
+	}
+	// Braking for the stop line only ever creeps up to it, so a vehicle
+	// that gets close enough pulls up there.
+	remainingM := (1 - v.distanceAlong()) * segment.LengthKM * 1000
+	pullUp := (leader == nil || leader.StopLine) && remainingM-distanceM < stopLineSlackM
+	if pullUp {
+		distanceM = math.Max(distanceM, remainingM)
+	}
+	v.CurrentSpeedKPH = next * 3.6
+
+	atEnd := v.moveAlong(segment, distanceM/1000)
+	if pullUp && !atEnd {
+		// Rounding left it a hair short of the line.
+		v.SegmentProgress = 1
+		if v.TravelDirection < 0 {
+			v.SegmentProgress = 0
+		}
+		atEnd = true
+	}
+	return atEnd, nil
+}
+
+// idmAcceleration is the Intelligent Driver Model's acceleration, in m/s²,
+// at speed towards desired, both in m/s, behind leader.
+func (v *Vehicle) idmAcceleration(speed, desired float64, leader *Leader) float64 {
+	accel := v.AccelerationMPS2
+	if accel <= 0 {
+		accel = DefaultAccelerationMPS2
+	}
+	decel := v.ComfortableDecelerationMPS2
+	if decel <= 0 {
+		decel = DefaultComfortableDecelerationMPS2
+	}
+	headway := v.TimeHeadwaySeconds
+	if headway <= 0 {
+		headway = DefaultTimeHeadwaySeconds
+	}
+
+	freeRoad := 1.0
+	if desired > 0 {
+		freeRoad -= math.Pow(speed/desired, accelerationExponent)
+	}
+	interaction := 0.0
+	if leader != nil {
+		closing := speed - leader.SpeedKPH/3.6
+		wanted := v.minimumGap() + math.Max(0, speed*headway+speed*closing/(2*math.Sqrt(accel*decel)))
+		gap := math.Max(leader.GapM, 0.1)
+		interaction = (wanted / gap) * (wanted / gap)
+	}
+	return math.Max(accel*(freeRoad-interaction), -maxDecelerationMPS2)
+}
+
+func (v *Vehicle) minimumGap() float64 {
+	if v.MinimumGapM <= 0 {
+		return DefaultMinimumGapM
+	}
+	return v.MinimumGapM
+}
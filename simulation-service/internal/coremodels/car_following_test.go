@@ -0,0 +1,181 @@
+package coremodels_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/segmentio/ksuid"
+
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+const stepSeconds = 0.5
+
+// straightRoad is node 1 to node 2 to node 3 along two kilometre-long
+// segments, 1 and 2, driven both ways.
+func straightRoad() *coremodels.Grid {
+	segments := map[int64]*coremodels.RoadSegment{
+		1: {ID: 1, StartNode: 1, EndNode: 2, LengthKM: 1, CongestionFactor: 1},
+		2: {ID: 2, StartNode: 2, EndNode: 3, LengthKM: 1, CongestionFactor: 1},
+	}
+	return &coremodels.Grid{
+		Segments:  segments,
+		Adjacency: map[int64][]int64{1: {1}, 2: {1, 2}, 3: {2}},
+		Nodes: map[int64]*coremodels.Node{
+			1: {ID: 1, Pos_X: 0},
+			2: {ID: 2, Pos_X: 1},
+			3: {ID: 3, Pos_X: 2},
+		},
+	}
+}
+
+// onFirstSegment is a vehicle heading for node 3 that has driven progress of
+// segment 1 at speedKPH.
+func onFirstSegment(progress, speedKPH float64) *coremodels.Vehicle {
+	return &coremodels.Vehicle{
+		ID:               ksuid.New(),
+		CurrentSegmentID: 1,
+		SegmentProgress:  progress,
+		TargetNodeID:     3,
+		BaseSpeedKPH:     50,
+		CurrentSpeedKPH:  speedKPH,
+		Status:           coremodels.StatusMoving,
+		TravelDirection:  1,
+		PreviousNodeID:   1,
+	}
+}
+
+// follow moves every vehicle in drivers one step behind the leaders all the
+// vehicles had at its start, the way the simulation does. It reports which
+// drivers reached the end of the segment.
+func follow(t *testing.T, grid *coremodels.Grid, control *coremodels.IntersectionControl, vehicles, drivers []*coremodels.Vehicle) map[*coremodels.Vehicle]bool {
+	t.Helper()
+	leaders := coremodels.FindLeaders(vehicles, grid, control)
+	atEnd := make(map[*coremodels.Vehicle]bool)
+	for _, vehicle := range drivers {
+		var leader *coremodels.Leader
+		if found, ok := leaders[vehicle]; ok {
+			leader = &found
+		}
+		end, err := vehicle.FollowOnSegment(stepSeconds, grid, leader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		atEnd[vehicle] = end
+	}
+	return atEnd
+}
+
+// gapM is the room between the back of ahead and the front of behind on
+// segment 1.
+func gapM(ahead, behind *coremodels.Vehicle) float64 {
+	return (ahead.SegmentProgress-behind.SegmentProgress)*1000 - coremodels.VehicleLengthM
+}
+
+func TestFollowerKeepsItsMinimumGapToAStoppedLeader(t *testing.T) {
+	grid := straightRoad()
+	// The stopped vehicle is left out of the drivers, so it stays put.
+	stopped := onFirstSegment(0.5, 0)
+	follower := onFirstSegment(0.3, 50)
+	follower.MinimumGapM = 3
+
+	for step := 0; step < 240; step++ {
+		follow(t, grid, nil, []*coremodels.Vehicle{stopped, follower}, []*coremodels.Vehicle{follower})
+		if gap := gapM(stopped, follower); gap < follower.MinimumGapM-1e-9 {
+			t.Fatalf("step %d: follower closed to %.2fm of the stopped vehicle, minimum %.1fm", step, gap, follower.MinimumGapM)
+		}
+	}
+	if follower.CurrentSpeedKPH > 0.5 {
+		t.Errorf("follower still drives at %.1fkm/h behind a stopped vehicle", follower.CurrentSpeedKPH)
+	}
+	if gap := gapM(stopped, follower); gap > follower.MinimumGapM+1 {
+		t.Errorf("follower stopped %.2fm short of the stopped vehicle, want about %.1fm", gap, follower.MinimumGapM)
+	}
+}
+
+func TestQueueFormsBehindAStoppedVehicle(t *testing.T) {
+	grid := straightRoad()
+	stopped := onFirstSegment(0.9, 0)
+	vehicles := []*coremodels.Vehicle{stopped}
+	var queue []*coremodels.Vehicle
+	for i := range 5 {
+		vehicle := onFirstSegment(0.2-0.04*float64(i), 40)
+		vehicles = append(vehicles, vehicle)
+		queue = append(queue, vehicle)
+	}
+
+	for range 600 {
+		follow(t, grid, nil, vehicles, queue)
+	}
+	for i, vehicle := range vehicles[1:] {
+		ahead := vehicles[i]
+		gap := gapM(ahead, vehicle)
+		if vehicle.CurrentSpeedKPH > 0.5 {
+			t.Errorf("vehicle %d of the queue still drives at %.1fkm/h", i+1, vehicle.CurrentSpeedKPH)
+		}
+		if gap < coremodels.DefaultMinimumGapM-1e-9 || gap > coremodels.DefaultMinimumGapM+1 {
+			t.Errorf("vehicle %d of the queue stopped %.2fm behind the one ahead, want about %.1fm",
+				i+1, gap, coremodels.DefaultMinimumGapM)
+		}
+	}
+}
+
+func TestSpeedRisesByAtMostTheAcceleration(t *testing.T) {
+	grid := straightRoad()
+	vehicle := onFirstSegment(0, 0)
+	vehicle.AccelerationMPS2 = 2
+
+	limit := vehicle.AccelerationMPS2 * stepSeconds * 3.6
+	previous := vehicle.CurrentSpeedKPH
+	for step := 0; step < 120; step++ {
+		follow(t, grid, nil, []*coremodels.Vehicle{vehicle}, []*coremodels.Vehicle{vehicle})
+		if rise := vehicle.CurrentSpeedKPH - previous; rise > limit+1e-9 {
+			t.Fatalf("step %d: speed rose %.2fkm/h, at most %.2fkm/h", step, rise, limit)
+		}
+		if vehicle.CurrentSpeedKPH > vehicle.BaseSpeedKPH {
+			t.Fatalf("step %d: speed %.1fkm/h passed the %.0fkm/h wanted", step, vehicle.CurrentSpeedKPH, vehicle.BaseSpeedKPH)
+		}
+		previous = vehicle.CurrentSpeedKPH
+	}
+	if vehicle.CurrentSpeedKPH < 0.9*vehicle.BaseSpeedKPH {
+		t.Errorf("after a minute on a free road, driving at %.1fkm/h of %.0fkm/h", vehicle.CurrentSpeedKPH, vehicle.BaseSpeedKPH)
+	}
+}
+
+func TestVehicleBrakesToAStopAtARedSignal(t *testing.T) {
+	grid := straightRoad()
+	control := coremodels.NewIntersectionControl(grid, coremodels.Uncontrolled)
+	// Segment 2's phase comes first and stays green for the whole test.
+	err := control.SetPlan(2, &coremodels.SignalPlan{
+		Control:      coremodels.FixedTimeSignal,
+		Phases:       []coremodels.SignalPhase{{Approaches: []int64{2}}, {Approaches: []int64{1}}},
+		GreenSeconds: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !control.StopsAt(2, 1) {
+		t.Fatal("the signal shows green to segment 1")
+	}
+
+	vehicle := onFirstSegment(0.7, 50)
+	for step := 0; step < 240; step++ {
+		previous := vehicle.SegmentProgress
+		atEnd := follow(t, grid, control, []*coremodels.Vehicle{vehicle}, []*coremodels.Vehicle{vehicle})[vehicle]
+		if vehicle.SegmentProgress > 1 {
+			t.Fatalf("step %d: ran %.1fm past the stop line", step, (vehicle.SegmentProgress-1)*1000)
+		}
+		if atEnd && previous < 1 {
+			if vehicle.SegmentProgress != 1 {
+				t.Errorf("reported the line %.1fm short of it", (1-vehicle.SegmentProgress)*1000)
+			}
+			if vehicle.CurrentSpeedKPH > 5 {
+				t.Errorf("reached the red signal at %.1fkm/h", vehicle.CurrentSpeedKPH)
+			}
+		}
+	}
+	if vehicle.SegmentProgress != 1 || math.Abs(vehicle.CurrentSpeedKPH) > 1e-9 {
+		t.Errorf("waits at %.1fm from the line at %.1fkm/h, want at the line and stopped",
+			(1-vehicle.SegmentProgress)*1000, vehicle.CurrentSpeedKPH)
+	}
+}
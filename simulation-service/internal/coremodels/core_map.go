@@ -1,64 +1,153 @@
 package coremodels
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/segmentio/ksuid"
 )
 
 type Grid struct {
 	ID ksuid.KSUID
 	//bounds for n by m grid
-	DimX  int64  
-	DimY  int64  
-	Segments map[int64]*RoadSegment 
+	DimX     int64
+	DimY     int64
+	Segments map[int64]*RoadSegment
+	// Adjacency lists the segments at each node, whichever way they may be
+	// driven; RoadSegment.CanLeave tells which lead away from it.
 	Adjacency map[int64][]int64 //adjacency map for O(1) lookup, nodeID -> list of connected segment ID
-	Nodes map[int64]*Node 
+	Nodes     map[int64]*Node
 
-	
+	// costRevision counts the segment cost changes made through
+	// SetCongestionFactor, so a route index can tell it has fallen behind.
+	costRevision uint64
 }
 type GenerationAlgorithmType int
 
-
 const (
-    Varonoi GenerationAlgorithmType = iota
-    LForm
-    Space
-    Lorenz
-    LSystem
+	Varonoi GenerationAlgorithmType = iota
+	LForm
+	Space
+	Lorenz
+	LSystem
 	Hierarchical
 	Suburban
 	CityLike
+	// Imported grids were read from a road graph rather than generated.
+	Imported
 )
+
 type GridConfig struct {
-    DimX int64
-    DimY int64
-    Algo GenerationAlgorithmType
-    Seed ksuid.KSUID
+	DimX int64
+	DimY int64
+	Algo GenerationAlgorithmType
+	Seed ksuid.KSUID
+	// OneWayStreets and ArterialLanes shape the streets of lattice grids;
+	// see gridengine.LatticeParams.
+	OneWayStreets bool
+	ArterialLanes int
 }
 
-
-//like intersection 
+// like intersection
 type Node struct {
-	ID int64
+	ID           int64
 	Pos_X, Pos_Y float64 //geo coord
 
 }
 
 type RoadSegment struct {
-	ID int64
+	ID                 int64
 	StartNode, EndNode int64
-	LengthKM float64
-	CongestionFactor float64 //ratio 
+	LengthKM           float64
+	// CongestionFactor is a ratio; change it with Grid.SetCongestionFactor.
+	CongestionFactor float64
+	// SpeedLimitKPH is the posted limit, zero when the map gives none.
+	SpeedLimitKPH float64
+	// Direction is which way the road may be driven.
+	Direction SegmentDirection
+	// LanesForward and LanesBackward are the lanes driven from StartNode to
+	// EndNode and back. Zero means one lane, wherever Direction allows
+	// driving that way.
+	LanesForward, LanesBackward int
+	// Class is the kind of road an imported map gives, such as an
+	// OpenStreetMap highway class.
+	Class string
+}
+
+// SegmentDirection is which way a road segment may be driven.
+type SegmentDirection int
+
+const (
+	BothWays SegmentDirection = iota
+	// ForwardOnly roads are driven from StartNode to EndNode.
+	ForwardOnly
+	// BackwardOnly roads are driven from EndNode to StartNode.
+	BackwardOnly
+)
+
+func (d SegmentDirection) String() string {
+	switch d {
+	case BothWays:
+		return "both"
+	case ForwardOnly:
+		return "forward"
+	case BackwardOnly:
+		return "backward"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseSegmentDirection(name string) (SegmentDirection, error) {
+	switch strings.ToLower(name) {
+	case "", "both":
+		return BothWays, nil
+	case "forward":
+		return ForwardOnly, nil
+	case "backward":
+		return BackwardOnly, nil
+	}
+	return 0, fmt.Errorf("unknown segment direction %q", name)
+}
 
+// OneWay reports whether the road may be driven only one way.
+func (s *RoadSegment) OneWay() bool {
+	return s.Direction != BothWays
+}
+
+// CanLeave reports whether the road may be driven away from nodeID, one of
+// its ends.
+func (s *RoadSegment) CanLeave(nodeID int64) bool {
+	switch {
+	case nodeID == s.StartNode:
+		return s.Direction != BackwardOnly
+	case nodeID == s.EndNode:
+		return s.Direction != ForwardOnly
+	}
+	return false
+}
+
+// LanesFrom is the number of lanes driven away from nodeID, one of the
+// road's ends, and zero if it may not be driven that way.
+func (s *RoadSegment) LanesFrom(nodeID int64) int {
+	if !s.CanLeave(nodeID) {
+		return 0
+	}
+	lanes := s.LanesForward
+	if nodeID != s.StartNode {
+		lanes = s.LanesBackward
+	}
+	return max(lanes, 1)
 }
 
 func (g *Grid) getGridAdjacencyMatrix() map[int64][]int64 {
 	if g.Adjacency == nil {
-		
+
 		return make(map[int64][]int64)
 	}
 
 	result := make(map[int64][]int64)
-	for nodeID, neighbors := range g.Adjacency{
+	for nodeID, neighbors := range g.Adjacency {
 		neighborsCopy := make([]int64, len(neighbors))
 		copy(neighborsCopy, neighbors)
 		result[nodeID] = neighborsCopy
@@ -68,10 +157,66 @@ func (g *Grid) getGridAdjacencyMatrix() map[int64][]int64 {
 	return result
 }
 
+// SetCongestionFactor changes a segment's congestion factor. Route indexes on
+// the grid pick the change up before their next query; setting the field
+// directly leaves them routing on the old cost.
+func (g *Grid) SetCongestionFactor(segmentID int64, factor float64) {
+	segment, exists := g.Segments[segmentID]
+	if !exists || segment.CongestionFactor == factor {
+		return
+	}
+	segment.CongestionFactor = factor
+	g.costRevision++
+}
 
 func (g *Grid) getRoadSegments() map[int64]*RoadSegment {
-    //DO NOT MODIFY SEGMENTS 
-    return g.Segments
+	//DO NOT MODIFY SEGMENTS
+	return g.Segments
 }
 
+func (a GenerationAlgorithmType) String() string {
+	switch a {
+	case Varonoi:
+		return "voronoi"
+	case LForm:
+		return "lform"
+	case Space:
+		return "space"
+	case Lorenz:
+		return "lorenz"
+	case LSystem:
+		return "lsystem"
+	case Hierarchical:
+		return "hierarchical"
+	case Suburban:
+		return "suburban"
+	case CityLike:
+		return "citylike"
+	case Imported:
+		return "imported"
+	default:
+		return "random"
+	}
+}
 
+func ParseGenerationAlgorithm(name string) (GenerationAlgorithmType, error) {
+	switch strings.ToLower(name) {
+	case "voronoi", "varonoi", "knn":
+		return Varonoi, nil
+	case "lform", "lattice":
+		return LForm, nil
+	case "space", "spacecolonization":
+		return Space, nil
+	case "lorenz":
+		return Lorenz, nil
+	case "lsystem":
+		return LSystem, nil
+	case "hierarchical":
+		return Hierarchical, nil
+	case "suburban":
+		return Suburban, nil
+	case "citylike", "radial":
+		return CityLike, nil
+	}
+	return 0, fmt.Errorf("unknown generation algorithm %q", name)
+}
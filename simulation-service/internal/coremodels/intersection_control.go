@@ -0,0 +1,656 @@
+package coremodels
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/ksuid"
+)
+
+// ControlKind is how a junction decides which vehicles may cross it.
+type ControlKind int
+
+const (
+	// Uncontrolled junctions let every vehicle straight through.
+	Uncontrolled ControlKind = iota
+	// FixedTimeSignal junctions give each phase green in turn for a set time.
+	FixedTimeSignal
+	// ActuatedSignal junctions hold green on a phase while vehicles queue on
+	// it, between a minimum and a maximum, and skip phases nobody waits on.
+	ActuatedSignal
+	// AllWayStop junctions make every vehicle stop, then let them across one
+	// at a time in the order they arrived.
+	AllWayStop
+	// PriorityControl junctions give way to the major road: the first phase
+	// crosses freely, the others wait for a gap in its traffic.
+	PriorityControl
+)
+
+func (k ControlKind) String() string {
+	switch k {
+	case Uncontrolled:
+		return "none"
+	case FixedTimeSignal:
+		return "fixed"
+	case ActuatedSignal:
+		return "actuated"
+	case AllWayStop:
+		return "stop"
+	case PriorityControl:
+		return "priority"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseControlKind(name string) (ControlKind, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return Uncontrolled, nil
+	case "fixed", "signal":
+		return FixedTimeSignal, nil
+	case "actuated":
+		return ActuatedSignal, nil
+	case "stop", "allway":
+		return AllWayStop, nil
+	case "priority", "yield":
+		return PriorityControl, nil
+	}
+	return 0, fmt.Errorf("unknown intersection control %q", name)
+}
+
+func (k ControlKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ControlKind) UnmarshalText(text []byte) error {
+	kind, err := ParseControlKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
+
+// SignalPhase is a set of approaches, named by the segment vehicles arrive
+// on, that get green together.
+type SignalPhase struct {
+	Approaches []int64 `json:"approaches"`
+	// GreenSeconds overrides the plan's green time for this phase of a
+	// fixed-time signal.
+	GreenSeconds float64 `json:"green_seconds,omitempty"`
+}
+
+// SignalPlan is how one junction is controlled. Phases must between them
+// list every approach of the junction; left empty they are worked out from
+// its layout, grouping approaches that come from opposite sides. Priority
+// control takes the first phase as the major road, and all-way stops do not
+// use phases at all. Times left at zero take the defaults.
+type SignalPlan struct {
+	Control ControlKind   `json:"control"`
+	Phases  []SignalPhase `json:"phases,omitempty"`
+
+	// GreenSeconds is how long each phase of a fixed-time signal stays
+	// green; MinGreenSeconds and MaxGreenSeconds bound it for an actuated
+	// one.
+	GreenSeconds    float64 `json:"green_seconds,omitempty"`
+	MinGreenSeconds float64 `json:"min_green_seconds,omitempty"`
+	MaxGreenSeconds float64 `json:"max_green_seconds,omitempty"`
+	// ClearanceSeconds is the amber and all-red time between two phases,
+	// when nobody may cross.
+	ClearanceSeconds float64 `json:"clearance_seconds,omitempty"`
+	// OffsetSeconds shifts a fixed-time signal's cycle, so neighbouring
+	// signals can be coordinated.
+	OffsetSeconds float64 `json:"offset_seconds,omitempty"`
+}
+
+// Default signal timings.
+const (
+	DefaultGreenSeconds     = 20.0
+	DefaultMinGreenSeconds  = 6.0
+	DefaultMaxGreenSeconds  = 40.0
+	DefaultClearanceSeconds = 3.0
+)
+
+func (p *SignalPlan) withDefaults() {
+	if p.GreenSeconds <= 0 {
+		p.GreenSeconds = DefaultGreenSeconds
+	}
+	if p.MinGreenSeconds <= 0 {
+		p.MinGreenSeconds = DefaultMinGreenSeconds
+	}
+	if p.MaxGreenSeconds < p.MinGreenSeconds {
+		p.MaxGreenSeconds = max(DefaultMaxGreenSeconds, p.MinGreenSeconds)
+	}
+	if p.ClearanceSeconds <= 0 {
+		p.ClearanceSeconds = DefaultClearanceSeconds
+	}
+}
+
+func (p *SignalPlan) phaseGreen(phase int) float64 {
+	if green := p.Phases[phase].GreenSeconds; green > 0 {
+		return green
+	}
+	return p.GreenSeconds
+}
+
+// IntersectionWait is how long vehicles have waited at one junction.
+type IntersectionWait struct {
+	NodeID    int64       `json:"node_id"`
+	Control   ControlKind `json:"control"`
+	Crossings int         `json:"crossings"`
+	// WaitSeconds counts both the vehicles that crossed and those still
+	// queued at the stop line.
+	WaitSeconds float64 `json:"wait_seconds"`
+	Queued      int     `json:"queued"`
+}
+
+// IntersectionControl holds vehicles at the stop line of controlled
+// junctions until their signal turns green or the right of way lets them
+// cross, and records how long they wait. Vehicles queue on each approach in
+// the order they reach it and cross one at a time, a saturation headway
+// apart on each lane.
+//
+// Like the movement arbiter it must be asked one vehicle at a time; the
+// outcome depends on the order.
+type IntersectionControl struct {
+	// SaturationHeadwaySeconds is the least time between two vehicles
+	// crossing from one lane of an approach, or through an all-way stop.
+	SaturationHeadwaySeconds float64
+	// StopSeconds is how long a vehicle stands at a stop sign before it
+	// may go.
+	StopSeconds float64
+	// CriticalGapSeconds is the gap in major road traffic a vehicle
+	// waiting on a minor road needs to cross.
+	CriticalGapSeconds float64
+
+	grid    *Grid
+	plans   map[int64]*SignalPlan
+	signals map[int64]*actuatedState
+	elapsed float64
+
+	queues       map[int64][]queuedVehicle
+	queuedAt     map[ksuid.KSUID]int64
+	lastApproach map[approach]float64
+	lastNode     map[int64]float64
+	lastMajor    map[int64]float64
+
+	crossings map[int64]int
+	waited    map[int64]float64
+}
+
+// approach is one segment arriving at a junction.
+type approach struct {
+	nodeID    int64
+	segmentID int64
+}
+
+type queuedVehicle struct {
+	vehicleID ksuid.KSUID
+	segmentID int64
+	arrived   float64
+}
+
+// actuatedState is where an actuated signal is in its cycle: phase is green,
+// or about to be once clearing ends.
+type actuatedState struct {
+	phase    int
+	clearing bool
+	since    float64
+}
+
+// NewIntersectionControl controls every junction of grid where three or more
+// segments meet with a default plan of the given kind. Uncontrolled leaves
+// them all uncontrolled, for plans to be set one by one.
+func NewIntersectionControl(grid *Grid, kind ControlKind) *IntersectionControl {
+	c := &IntersectionControl{
+		SaturationHeadwaySeconds: 2,
+		StopSeconds:              2,
+		CriticalGapSeconds:       4,
+		grid:                     grid,
+		plans:                    make(map[int64]*SignalPlan),
+		signals:                  make(map[int64]*actuatedState),
+		queues:                   make(map[int64][]queuedVehicle),
+		queuedAt:                 make(map[ksuid.KSUID]int64),
+		lastApproach:             make(map[approach]float64),
+		lastNode:                 make(map[int64]float64),
+		lastMajor:                make(map[int64]float64),
+		crossings:                make(map[int64]int),
+		waited:                   make(map[int64]float64),
+	}
+	if kind == Uncontrolled {
+		return c
+	}
+	for nodeID, segmentIDs := range grid.Adjacency {
+		if len(segmentIDs) < 3 {
+			continue
+		}
+		// Default phases always cover the junction, so this cannot fail.
+		_ = c.SetPlan(nodeID, &SignalPlan{Control: kind})
+	}
+	return c
+}
+
+// SetPlan controls the junction at nodeID by plan, filling in its default
+// phases and timings. An uncontrolled plan lifts any control there; a nil one
+// is an error.
+func (c *IntersectionControl) SetPlan(nodeID int64, plan *SignalPlan) error {
+	if _, exists := c.grid.Nodes[nodeID]; !exists {
+		return fmt.Errorf("node %d not found", nodeID)
+	}
+	if plan == nil {
+		return fmt.Errorf("node %d: no plan given", nodeID)
+	}
+	if plan.Control == Uncontrolled {
+		delete(c.plans, nodeID)
+		delete(c.signals, nodeID)
+		return nil
+	}
+
+	plan = &SignalPlan{
+		Control:          plan.Control,
+		Phases:           slices.Clone(plan.Phases),
+		GreenSeconds:     plan.GreenSeconds,
+		MinGreenSeconds:  plan.MinGreenSeconds,
+		MaxGreenSeconds:  plan.MaxGreenSeconds,
+		ClearanceSeconds: plan.ClearanceSeconds,
+		OffsetSeconds:    plan.OffsetSeconds,
+	}
+	plan.withDefaults()
+
+	approaches := c.approaches(nodeID)
+	if len(approaches) == 0 {
+		// Nothing arrives at the junction, so there is nothing to control.
+		delete(c.plans, nodeID)
+		delete(c.signals, nodeID)
+		return nil
+	}
+	if len(plan.Phases) == 0 {
+		plan.Phases = c.defaultPhases(nodeID, approaches, plan.Control == PriorityControl)
+	}
+	covered := make(map[int64]bool, len(approaches))
+	for i, phase := range plan.Phases {
+		if len(phase.Approaches) == 0 {
+			return fmt.Errorf("node %d: phase %d has no approaches", nodeID, i)
+		}
+		for _, segmentID := range phase.Approaches {
+			if !slices.Contains(approaches, segmentID) {
+				return fmt.Errorf("node %d: segment %d is not an approach to it", nodeID, segmentID)
+			}
+			if covered[segmentID] {
+				return fmt.Errorf("node %d: approach %d is in more than one phase", nodeID, segmentID)
+			}
+			covered[segmentID] = true
+		}
+	}
+	for _, segmentID := range approaches {
+		if !covered[segmentID] {
+			return fmt.Errorf("node %d: approach %d is in no phase", nodeID, segmentID)
+		}
+	}
+
+	c.plans[nodeID] = plan
+	delete(c.signals, nodeID)
+	if plan.Control == ActuatedSignal {
+		c.signals[nodeID] = &actuatedState{since: c.elapsed}
+	}
+	return nil
+}
+
+// Plan is how the junction at nodeID is controlled, or nil if it is not.
+func (c *IntersectionControl) Plan(nodeID int64) *SignalPlan {
+	return c.plans[nodeID]
+}
+
+// ControlledNodes lists the controlled junctions in ascending order.
+func (c *IntersectionControl) ControlledNodes() []int64 {
+	nodeIDs := make([]int64, 0, len(c.plans))
+	for nodeID := range c.plans {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	slices.Sort(nodeIDs)
+	return nodeIDs
+}
+
+// approaches lists, in ascending order, the segments at the node that may be
+// driven towards it.
+func (c *IntersectionControl) approaches(nodeID int64) []int64 {
+	var segmentIDs []int64
+	for _, segmentID := range c.grid.Adjacency[nodeID] {
+		segment, exists := c.grid.Segments[segmentID]
+		if !exists || slices.Contains(segmentIDs, segmentID) {
+			continue
+		}
+		if segment.CanLeave(segment.otherEnd(nodeID)) {
+			segmentIDs = append(segmentIDs, segmentID)
+		}
+	}
+	slices.Sort(segmentIDs)
+	return segmentIDs
+}
+
+// phaseAngle is how far apart the headings of two approaches may be for them
+// to share a phase.
+const phaseAngle = math.Pi / 6
+
+// defaultPhases groups the approaches into phases by the line they come in
+// along, so approaches from opposite sides of the junction get green
+// together. With major set the group with the most lanes comes first, or
+// on a tie the one with the highest speed limit.
+func (c *IntersectionControl) defaultPhases(nodeID int64, approaches []int64, major bool) []SignalPhase {
+	node := c.grid.Nodes[nodeID]
+	type heading struct {
+		segmentID int64
+		angle     float64
+	}
+	headings := make([]heading, 0, len(approaches))
+	for _, segmentID := range approaches {
+		segment := c.grid.Segments[segmentID]
+		from := c.grid.Nodes[segment.otherEnd(nodeID)]
+		angle := 0.0
+		if from != nil {
+			angle = math.Mod(math.Atan2(from.Pos_Y-node.Pos_Y, from.Pos_X-node.Pos_X)+math.Pi, math.Pi)
+		}
+		headings = append(headings, heading{segmentID, angle})
+	}
+	sort.SliceStable(headings, func(i, j int) bool { return headings[i].angle < headings[j].angle })
+
+	var phases []SignalPhase
+	var last float64
+	for i, h := range headings {
+		if i == 0 || h.angle-last > phaseAngle {
+			phases = append(phases, SignalPhase{})
+		}
+		phases[len(phases)-1].Approaches = append(phases[len(phases)-1].Approaches, h.segmentID)
+		last = h.angle
+	}
+	// Lines just either side of the horizontal belong together.
+	if len(phases) > 1 && headings[0].angle+math.Pi-last <= phaseAngle {
+		phases[0].Approaches = append(phases[0].Approaches, phases[len(phases)-1].Approaches...)
+		phases = phases[:len(phases)-1]
+	}
+	for i := range phases {
+		slices.Sort(phases[i].Approaches)
+	}
+
+	if major {
+		best, bestLanes, bestLimit := 0, 0, 0.0
+		for i, phase := range phases {
+			lanes, limit := 0, 0.0
+			for _, segmentID := range phase.Approaches {
+				segment := c.grid.Segments[segmentID]
+				lanes += segment.LanesFrom(segment.otherEnd(nodeID))
+				limit = math.Max(limit, segment.SpeedLimitKPH)
+			}
+			if lanes > bestLanes || (lanes == bestLanes && limit > bestLimit) {
+				best, bestLanes, bestLimit = i, lanes, limit
+			}
+		}
+		phases[0], phases[best] = phases[best], phases[0]
+	}
+	return phases
+}
+
+// Advance moves every signal on by seconds. Actuated signals change phase
+// once their green has run its minimum and either nobody waits on it any
+// more or it has run its maximum, as long as another phase has vehicles
+// waiting.
+func (c *IntersectionControl) Advance(seconds float64) {
+	if c == nil {
+		return
+	}
+	c.elapsed += seconds
+	for nodeID, state := range c.signals {
+		plan := c.plans[nodeID]
+		served := c.elapsed - state.since
+		if state.clearing {
+			if served >= plan.ClearanceSeconds {
+				state.clearing, state.since = false, c.elapsed
+			}
+			continue
+		}
+		if served < plan.MinGreenSeconds {
+			continue
+		}
+		next := -1
+		for step := 1; step < len(plan.Phases); step++ {
+			phase := (state.phase + step) % len(plan.Phases)
+			if c.demand(nodeID, plan.Phases[phase]) > 0 {
+				next = phase
+				break
+			}
+		}
+		if next < 0 {
+			continue
+		}
+		if c.demand(nodeID, plan.Phases[state.phase]) == 0 || served >= plan.MaxGreenSeconds {
+			state.phase, state.clearing, state.since = next, true, c.elapsed
+		}
+	}
+}
+
+// demand is how many vehicles wait at the stop lines of the phase.
+func (c *IntersectionControl) demand(nodeID int64, phase SignalPhase) int {
+	waiting := 0
+	for _, queued := range c.queues[nodeID] {
+		if slices.Contains(phase.Approaches, queued.segmentID) {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+// ShowsGreen reports whether the signal at nodeID shows green to vehicles
+// arriving on segmentID. Junctions without signals show green to all.
+func (c *IntersectionControl) ShowsGreen(nodeID, segmentID int64) bool {
+	plan := c.plans[nodeID]
+	if plan == nil || (plan.Control != FixedTimeSignal && plan.Control != ActuatedSignal) {
+		return true
+	}
+	phase, green := c.greenPhase(nodeID, plan)
+	return green && slices.Contains(plan.Phases[phase].Approaches, segmentID)
+}
+
+// greenPhase is the phase a signal shows green, and false while it clears
+// between phases.
+func (c *IntersectionControl) greenPhase(nodeID int64, plan *SignalPlan) (int, bool) {
+	if state := c.signals[nodeID]; state != nil {
+		return state.phase, !state.clearing
+	}
+
+	cycle := 0.0
+	for i := range plan.Phases {
+		cycle += plan.phaseGreen(i) + plan.ClearanceSeconds
+	}
+	t := math.Mod(c.elapsed+plan.OffsetSeconds, cycle)
+	if t < 0 {
+		t += cycle
+	}
+	for i := range plan.Phases {
+		if t < plan.phaseGreen(i) {
+			return i, true
+		}
+		t -= plan.phaseGreen(i) + plan.ClearanceSeconds
+		if t < 0 {
+			return i, false
+		}
+	}
+	return 0, false
+}
+
+// StopsAt reports whether a vehicle arriving at the junction at nodeID on
+// segmentID now would have to stop: at a red signal or a stop sign, or to
+// give way to traffic on the major road. It only looks, so vehicles can
+// brake for the stop line before they reach it.
+func (c *IntersectionControl) StopsAt(nodeID, segmentID int64) bool {
+	if c == nil {
+		return false
+	}
+	plan := c.plans[nodeID]
+	if plan == nil {
+		return false
+	}
+	switch plan.Control {
+	case FixedTimeSignal, ActuatedSignal:
+		return !c.ShowsGreen(nodeID, segmentID)
+	case AllWayStop:
+		return true
+	case PriorityControl:
+		if slices.Contains(plan.Phases[0].Approaches, segmentID) {
+			return false
+		}
+		at, crossed := c.lastMajor[nodeID]
+		return c.demand(nodeID, plan.Phases[0]) > 0 || !c.gapSince(at, crossed, c.CriticalGapSeconds)
+	}
+	return false
+}
+
+// MayCross reports whether the vehicle, waiting at the end of its segment,
+// may cross the junction at nodeID now. A vehicle asking for the first time
+// joins the queue at the stop line, and stays in it until it crosses or is
+// withdrawn.
+func (c *IntersectionControl) MayCross(vehicle *Vehicle, nodeID int64) bool {
+	if c == nil {
+		return true
+	}
+	plan := c.plans[nodeID]
+	if plan == nil {
+		return true
+	}
+
+	queued := c.enqueue(vehicle, nodeID)
+	for _, ahead := range c.queues[nodeID] {
+		if ahead.vehicleID == vehicle.ID {
+			break
+		}
+		if ahead.segmentID == queued.segmentID {
+			return false
+		}
+	}
+	segment := c.grid.Segments[queued.segmentID]
+	lanes := 1
+	if segment != nil {
+		lanes = segment.LanesFrom(segment.otherEnd(nodeID))
+	}
+	if at, crossed := c.lastApproach[approach{nodeID, queued.segmentID}]; !c.gapSince(at, crossed, c.SaturationHeadwaySeconds/float64(lanes)) {
+		return false
+	}
+
+	switch plan.Control {
+	case FixedTimeSignal, ActuatedSignal:
+		return c.ShowsGreen(nodeID, queued.segmentID)
+	case AllWayStop:
+		at, crossed := c.lastNode[nodeID]
+		return c.elapsed-queued.arrived >= c.StopSeconds &&
+			c.queues[nodeID][0].vehicleID == vehicle.ID &&
+			c.gapSince(at, crossed, c.SaturationHeadwaySeconds)
+	case PriorityControl:
+		if slices.Contains(plan.Phases[0].Approaches, queued.segmentID) {
+			return true
+		}
+		at, crossed := c.lastMajor[nodeID]
+		return c.demand(nodeID, plan.Phases[0]) == 0 && c.gapSince(at, crossed, c.CriticalGapSeconds)
+	}
+	return true
+}
+
+// gapSince reports whether at least gap seconds have passed since a crossing
+// at the given time, if there was one.
+func (c *IntersectionControl) gapSince(at float64, crossed bool, gap float64) bool {
+	return !crossed || c.elapsed-at >= gap
+}
+
+// enqueue is the vehicle's place in the queue at nodeID, which it joins
+// behind those already there if it is not waiting yet.
+func (c *IntersectionControl) enqueue(vehicle *Vehicle, nodeID int64) queuedVehicle {
+	if waitingAt, waiting := c.queuedAt[vehicle.ID]; waiting {
+		for _, queued := range c.queues[waitingAt] {
+			if waitingAt == nodeID && queued.vehicleID == vehicle.ID && queued.segmentID == vehicle.CurrentSegmentID {
+				return queued
+			}
+		}
+		c.dequeue(vehicle)
+	}
+	queued := queuedVehicle{vehicleID: vehicle.ID, segmentID: vehicle.CurrentSegmentID, arrived: c.elapsed}
+	c.queues[nodeID] = append(c.queues[nodeID], queued)
+	c.queuedAt[vehicle.ID] = nodeID
+	return queued
+}
+
+// Crossed takes the vehicle out of the queue it crossed from, adding the time
+// it waited there to its own and the junction's.
+func (c *IntersectionControl) Crossed(vehicle *Vehicle) {
+	if c == nil {
+		return
+	}
+	queued, nodeID, ok := c.dequeue(vehicle)
+	if !ok {
+		return
+	}
+	wait := c.elapsed - queued.arrived
+	vehicle.IntersectionWaitSeconds += wait
+	c.waited[nodeID] += wait
+	c.crossings[nodeID]++
+	c.lastApproach[approach{nodeID, queued.segmentID}] = c.elapsed
+	c.lastNode[nodeID] = c.elapsed
+	if plan := c.plans[nodeID]; plan != nil && slices.Contains(plan.Phases[0].Approaches, queued.segmentID) {
+		c.lastMajor[nodeID] = c.elapsed
+	}
+}
+
+// Withdraw takes the vehicle out of the queue it waits in without crossing,
+// as when it finds it has nowhere to go.
+func (c *IntersectionControl) Withdraw(vehicle *Vehicle) {
+	if c == nil {
+		return
+	}
+	c.dequeue(vehicle)
+}
+
+func (c *IntersectionControl) dequeue(vehicle *Vehicle) (queuedVehicle, int64, bool) {
+	nodeID, waiting := c.queuedAt[vehicle.ID]
+	if !waiting {
+		return queuedVehicle{}, 0, false
+	}
+	delete(c.queuedAt, vehicle.ID)
+	queue := c.queues[nodeID]
+	for i, queued := range queue {
+		if queued.vehicleID == vehicle.ID {
+			c.queues[nodeID] = slices.Delete(queue, i, i+1)
+			return queued, nodeID, true
+		}
+	}
+	return queuedVehicle{}, nodeID, false
+}
+
+// Waits lists how long vehicles have waited at each controlled junction,
+// longest first.
+func (c *IntersectionControl) Waits() []IntersectionWait {
+	waits := make([]IntersectionWait, 0, len(c.plans))
+	for _, nodeID := range c.ControlledNodes() {
+		wait := IntersectionWait{
+			NodeID:      nodeID,
+			Control:     c.plans[nodeID].Control,
+			Crossings:   c.crossings[nodeID],
+			WaitSeconds: c.waited[nodeID],
+			Queued:      len(c.queues[nodeID]),
+		}
+		for _, queued := range c.queues[nodeID] {
+			wait.WaitSeconds += c.elapsed - queued.arrived
+		}
+		waits = append(waits, wait)
+	}
+	sort.SliceStable(waits, func(i, j int) bool { return waits[i].WaitSeconds > waits[j].WaitSeconds })
+	return waits
+}
+
+// otherEnd is the end of the segment that is not nodeID.
+func (s *RoadSegment) otherEnd(nodeID int64) int64 {
+	if s.StartNode == nodeID {
+		return s.EndNode
+	}
+	return s.StartNode
+}
@@ -0,0 +1,271 @@
+package coremodels_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/segmentio/ksuid"
+
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// crossroads is a junction at node 0 with an arm from each side: segment 1
+// from the west, 2 from the east, 3 from the south and 4 from the north, each
+// starting at the far end and driven both ways.
+func crossroads() *coremodels.Grid {
+	grid := &coremodels.Grid{
+		Segments:  make(map[int64]*coremodels.RoadSegment),
+		Adjacency: make(map[int64][]int64),
+		Nodes:     map[int64]*coremodels.Node{0: {ID: 0}},
+	}
+	for id, position := range map[int64][2]float64{1: {-1, 0}, 2: {1, 0}, 3: {0, -1}, 4: {0, 1}} {
+		grid.Nodes[id] = &coremodels.Node{ID: id, Pos_X: position[0], Pos_Y: position[1]}
+		grid.Segments[id] = &coremodels.RoadSegment{ID: id, StartNode: id, EndNode: 0, LengthKM: 0.1, CongestionFactor: 1}
+		grid.Adjacency[id] = []int64{id}
+		grid.Adjacency[0] = append(grid.Adjacency[0], id)
+	}
+	slices.Sort(grid.Adjacency[0])
+	return grid
+}
+
+// arriving is a vehicle waiting at the junction at the end of segmentID.
+func arriving(segmentID int64) *coremodels.Vehicle {
+	return &coremodels.Vehicle{
+		ID:               ksuid.New(),
+		CurrentSegmentID: segmentID,
+		SegmentProgress:  1,
+		TargetNodeID:     99,
+		Status:           coremodels.StatusMoving,
+		TravelDirection:  1,
+		PreviousNodeID:   segmentID,
+	}
+}
+
+func controlled(t *testing.T, plan coremodels.SignalPlan) *coremodels.IntersectionControl {
+	t.Helper()
+	control := coremodels.NewIntersectionControl(crossroads(), coremodels.Uncontrolled)
+	if err := control.SetPlan(0, &plan); err != nil {
+		t.Fatalf("SetPlan: %v", err)
+	}
+	return control
+}
+
+// green lists the approaches the junction shows green.
+func green(control *coremodels.IntersectionControl) []int64 {
+	var approaches []int64
+	for segmentID := int64(1); segmentID <= 4; segmentID++ {
+		if control.ShowsGreen(0, segmentID) {
+			approaches = append(approaches, segmentID)
+		}
+	}
+	return approaches
+}
+
+func checkGreen(t *testing.T, control *coremodels.IntersectionControl, when string, want ...int64) {
+	t.Helper()
+	if got := green(control); !slices.Equal(got, want) {
+		t.Errorf("%s: green to %v, want %v", when, got, want)
+	}
+}
+
+func TestFixedTimeSignalCycles(t *testing.T) {
+	control := controlled(t, coremodels.SignalPlan{
+		Control:          coremodels.FixedTimeSignal,
+		GreenSeconds:     10,
+		ClearanceSeconds: 2,
+	})
+	// Approaches from opposite sides share a phase.
+	if phases := control.Plan(0).Phases; len(phases) != 2 ||
+		!slices.Equal(phases[0].Approaches, []int64{1, 2}) || !slices.Equal(phases[1].Approaches, []int64{3, 4}) {
+		t.Fatalf("default phases %v, want east-west then north-south", phases)
+	}
+
+	checkGreen(t, control, "at the start", 1, 2)
+	control.Advance(10)
+	checkGreen(t, control, "clearing after the first phase")
+	control.Advance(2)
+	checkGreen(t, control, "in the second phase", 3, 4)
+	control.Advance(10)
+	checkGreen(t, control, "clearing after the second phase")
+	control.Advance(2)
+	checkGreen(t, control, "a cycle later", 1, 2)
+
+	offset := controlled(t, coremodels.SignalPlan{
+		Control:          coremodels.FixedTimeSignal,
+		GreenSeconds:     10,
+		ClearanceSeconds: 2,
+		OffsetSeconds:    12,
+	})
+	checkGreen(t, offset, "offset by a phase", 3, 4)
+
+	short := controlled(t, coremodels.SignalPlan{
+		Control:          coremodels.FixedTimeSignal,
+		Phases:           []coremodels.SignalPhase{{Approaches: []int64{1, 2}, GreenSeconds: 5}, {Approaches: []int64{3, 4}}},
+		GreenSeconds:     10,
+		ClearanceSeconds: 2,
+	})
+	short.Advance(5)
+	checkGreen(t, short, "after a phase's own green time")
+	short.Advance(2)
+	checkGreen(t, short, "after the short phase", 3, 4)
+}
+
+func TestActuatedSignalFollowsDemand(t *testing.T) {
+	control := controlled(t, coremodels.SignalPlan{
+		Control:          coremodels.ActuatedSignal,
+		MinGreenSeconds:  5,
+		MaxGreenSeconds:  15,
+		ClearanceSeconds: 2,
+	})
+
+	control.Advance(30)
+	checkGreen(t, control, "with nobody waiting", 1, 2)
+
+	south := arriving(3)
+	if control.MayCross(south, 0) {
+		t.Fatal("crossed on red")
+	}
+	// The green has run its minimum and nobody waits on it.
+	control.Advance(1)
+	checkGreen(t, control, "changing for a vehicle waiting on red")
+	control.Advance(2)
+	checkGreen(t, control, "after clearing", 3, 4)
+
+	// A vehicle waiting on the green phase holds it until its maximum, even
+	// with another waiting on red; the green south vehicle stays queued.
+	if !control.MayCross(south, 0) {
+		t.Fatal("vehicle on green may not cross")
+	}
+	west := arriving(1)
+	control.MayCross(west, 0)
+	control.Advance(14)
+	checkGreen(t, control, "before the maximum green", 3, 4)
+	control.Advance(1)
+	checkGreen(t, control, "at the maximum green")
+	control.Advance(2)
+	checkGreen(t, control, "after the maximum green", 1, 2)
+
+	// Once the green phase empties it gives way as soon as its minimum has
+	// run.
+	control.Crossed(west)
+	control.MayCross(arriving(4), 0)
+	control.Advance(4)
+	checkGreen(t, control, "before the minimum green", 1, 2)
+	control.Advance(1)
+	checkGreen(t, control, "at the minimum green")
+}
+
+func TestAllWayStopGoesInArrivalOrder(t *testing.T) {
+	control := controlled(t, coremodels.SignalPlan{Control: coremodels.AllWayStop})
+	control.StopSeconds = 2
+	control.SaturationHeadwaySeconds = 2
+
+	first, second, third := arriving(3), arriving(1), arriving(2)
+	for _, vehicle := range []*coremodels.Vehicle{first, second, third} {
+		if !control.StopsAt(0, vehicle.CurrentSegmentID) {
+			t.Errorf("segment %d does not stop", vehicle.CurrentSegmentID)
+		}
+		if control.MayCross(vehicle, 0) {
+			t.Fatal("crossed without stopping")
+		}
+	}
+
+	control.Advance(2)
+	if control.MayCross(second, 0) || control.MayCross(third, 0) {
+		t.Fatal("a later arrival went first")
+	}
+	if !control.MayCross(first, 0) {
+		t.Fatal("first arrival may not cross after stopping")
+	}
+	control.Crossed(first)
+
+	for i, next := range []*coremodels.Vehicle{second, third} {
+		if control.MayCross(next, 0) {
+			t.Fatalf("arrival %d crossed right behind the one before", i+2)
+		}
+		control.Advance(2)
+		if i == 0 && control.MayCross(third, 0) {
+			t.Fatal("third arrival went before the second")
+		}
+		if !control.MayCross(next, 0) {
+			t.Fatalf("arrival %d may not cross in its turn", i+2)
+		}
+		control.Crossed(next)
+	}
+	if waits := control.Waits(); waits[0].Crossings != 3 || waits[0].Queued != 0 || waits[0].WaitSeconds != 2+4+6 {
+		t.Errorf("recorded %+v, want 3 crossings and 12s waited", waits[0])
+	}
+}
+
+func TestPriorityControlWaitsForAGap(t *testing.T) {
+	control := controlled(t, coremodels.SignalPlan{
+		Control: coremodels.PriorityControl,
+		Phases:  []coremodels.SignalPhase{{Approaches: []int64{1, 2}}, {Approaches: []int64{3, 4}}},
+	})
+	control.CriticalGapSeconds = 4
+
+	major, minor := arriving(1), arriving(3)
+	if control.StopsAt(0, major.CurrentSegmentID) {
+		t.Error("the major road stops")
+	}
+	if !control.MayCross(major, 0) {
+		t.Fatal("the major road may not cross")
+	}
+	if !control.StopsAt(0, minor.CurrentSegmentID) || control.MayCross(minor, 0) {
+		t.Fatal("the minor road goes ahead of major road traffic waiting to cross")
+	}
+
+	control.Crossed(major)
+	control.Advance(3)
+	if control.MayCross(minor, 0) {
+		t.Fatal("the minor road took a gap shorter than the critical gap")
+	}
+	control.Advance(1)
+	if control.StopsAt(0, minor.CurrentSegmentID) || !control.MayCross(minor, 0) {
+		t.Error("the minor road may not take a gap of the critical gap")
+	}
+
+	// Left to itself the major road is the one with the most lanes.
+	grid := crossroads()
+	grid.Segments[3].LanesForward = 2
+	grid.Segments[4].LanesForward = 2
+	widest := coremodels.NewIntersectionControl(grid, coremodels.PriorityControl)
+	if plan := widest.Plan(0); !slices.Equal(plan.Phases[0].Approaches, []int64{3, 4}) {
+		t.Errorf("major road %v, want the two-lane north-south road", plan.Phases[0].Approaches)
+	}
+}
+
+func TestSetPlanRejectsBadPlans(t *testing.T) {
+	control := coremodels.NewIntersectionControl(crossroads(), coremodels.Uncontrolled)
+	phases := func(groups ...[]int64) coremodels.SignalPlan {
+		plan := coremodels.SignalPlan{Control: coremodels.FixedTimeSignal}
+		for _, approaches := range groups {
+			plan.Phases = append(plan.Phases, coremodels.SignalPhase{Approaches: approaches})
+		}
+		return plan
+	}
+	for name, plan := range map[string]coremodels.SignalPlan{
+		"empty phase":         phases([]int64{1, 2, 3, 4}, nil),
+		"not an approach":     phases([]int64{1, 2}, []int64{3, 4, 99}),
+		"approach twice":      phases([]int64{1, 2, 3}, []int64{3, 4}),
+		"approach left out":   phases([]int64{1, 2}, []int64{3}),
+		"valid phases though": phases([]int64{1, 2}, []int64{3, 4}),
+	} {
+		err := control.SetPlan(0, &plan)
+		if valid := name == "valid phases though"; valid != (err == nil) {
+			t.Errorf("%s: SetPlan returned %v", name, err)
+		}
+	}
+	if err := control.SetPlan(0, nil); err == nil {
+		t.Error("SetPlan accepted a nil plan")
+	}
+	if err := control.SetPlan(42, &coremodels.SignalPlan{Control: coremodels.AllWayStop}); err == nil {
+		t.Error("SetPlan accepted a node not in the grid")
+	}
+	if control.Plan(0) == nil {
+		t.Fatal("rejected plans lifted the valid one")
+	}
+	if err := control.SetPlan(0, &coremodels.SignalPlan{}); err != nil || control.Plan(0) != nil {
+		t.Errorf("an uncontrolled plan left %v, %v", control.Plan(0), err)
+	}
+}
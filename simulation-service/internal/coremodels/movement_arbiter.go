@@ -0,0 +1,185 @@
+package coremodels
+
+import (
+	"math"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Reasons a movement request is denied.
+const (
+	DenialCapacityFull    = "capacity_full"
+	DenialHeavyCongestion = "heavy_congestion"
+	DenialSegmentBlocked  = "segment_blocked"
+	DenialDeadEnd         = "dead_end"
+)
+
+// MovementResponse is the arbiter's answer to a vehicle's movement request.
+type MovementResponse struct {
+	RequestID            ksuid.KSUID `json:"request_id"`
+	Accepted             bool        `json:"accepted"`
+	Reason               string      `json:"reason,omitempty"`
+	AlternativeSegmentID int64       `json:"alternative_segment_id,omitempty"`
+}
+
+// MovementArbiter decides which vehicles may enter which segments. It counts
+// the vehicles driving each way along every segment and refuses entry to
+// blocked segments, to the wrong way down one-way ones, to full ones, and to
+// congested ones past the share of their capacity their congestion leaves
+// usable. Each way of a segment has the capacity of its lanes. A denial
+// suggests another segment out of the same intersection that would have been
+// granted, when there is one.
+//
+// Requests must be decided one at a time; the outcome depends on their order.
+type MovementArbiter struct {
+	// VehiclesPerKM sets the capacity of a lane from its length; no lane
+	// holds fewer than MinCapacity vehicles.
+	VehiclesPerKM float64
+	MinCapacity   int
+
+	Granted int
+	Denials map[string]int
+
+	occupancy map[carriageway]int
+	occupants map[ksuid.KSUID]carriageway
+	blocked   map[int64]bool
+}
+
+// carriageway is one way along a segment: from StartNode to EndNode when
+// forward is set, and back otherwise.
+type carriageway struct {
+	segmentID int64
+	forward   bool
+}
+
+// wayFrom is the carriageway driven away from fromNodeID. A vehicle entering
+// a loop, whose ends are the same node, drives it forward, as
+// HandleMovementResponse sets it off.
+func wayFrom(segment *RoadSegment, fromNodeID int64) carriageway {
+	return carriageway{segmentID: segment.ID, forward: segment.StartNode == fromNodeID}
+}
+
+func NewMovementArbiter() *MovementArbiter {
+	return &MovementArbiter{
+		VehiclesPerKM: 40,
+		MinCapacity:   2,
+		Denials:       make(map[string]int),
+		occupancy:     make(map[carriageway]int),
+		occupants:     make(map[ksuid.KSUID]carriageway),
+		blocked:       make(map[int64]bool),
+	}
+}
+
+// Place counts the vehicle on its current segment without asking, as when it
+// spawns there.
+func (a *MovementArbiter) Place(vehicle *Vehicle) {
+	if a == nil {
+		return
+	}
+	a.Release(vehicle)
+	way := carriageway{segmentID: vehicle.CurrentSegmentID, forward: vehicle.TravelDirection >= 0}
+	a.occupants[vehicle.ID] = way
+	a.occupancy[way]++
+}
+
+// Release stops counting the vehicle, once it has left the road.
+func (a *MovementArbiter) Release(vehicle *Vehicle) {
+	if a == nil {
+		return
+	}
+	way, exists := a.occupants[vehicle.ID]
+	if !exists {
+		return
+	}
+	delete(a.occupants, vehicle.ID)
+	if a.occupancy[way] <= 1 {
+		delete(a.occupancy, way)
+	} else {
+		a.occupancy[way]--
+	}
+}
+
+func (a *MovementArbiter) Block(segmentID int64) {
+	a.blocked[segmentID] = true
+}
+
+func (a *MovementArbiter) Unblock(segmentID int64) {
+	delete(a.blocked, segmentID)
+}
+
+func (a *MovementArbiter) IsBlocked(segmentID int64) bool {
+	return a.blocked[segmentID]
+}
+
+// Occupancy is the number of vehicles on the segment, both ways together.
+func (a *MovementArbiter) Occupancy(segmentID int64) int {
+	return a.occupancy[carriageway{segmentID, true}] + a.occupancy[carriageway{segmentID, false}]
+}
+
+// Capacity is how many vehicles fit on the segment driving away from
+// fromNodeID: its lanes that way times the capacity of a lane. It is zero
+// the wrong way down a one-way segment.
+func (a *MovementArbiter) Capacity(segment *RoadSegment, fromNodeID int64) int {
+	return segment.LanesFrom(fromNodeID) * max(int(segment.LengthKM*a.VehiclesPerKM), a.MinCapacity)
+}
+
+// Decide answers the vehicle's pending request to enter NextSegmentID from
+// PreviousNodeID. A granted vehicle is counted on its new segment at once.
+func (a *MovementArbiter) Decide(vehicle *Vehicle, grid *Grid) MovementResponse {
+	response := MovementResponse{RequestID: vehicle.PendingMovementRequestID}
+
+	response.Reason = a.refusal(vehicle.NextSegmentID, vehicle.PreviousNodeID, grid)
+	if response.Reason == "" {
+		response.Accepted = true
+		a.Granted++
+		a.Release(vehicle)
+		way := wayFrom(grid.Segments[vehicle.NextSegmentID], vehicle.PreviousNodeID)
+		a.occupants[vehicle.ID] = way
+		a.occupancy[way]++
+		return response
+	}
+
+	a.Denials[response.Reason]++
+	response.AlternativeSegmentID = a.alternative(vehicle, grid)
+	return response
+}
+
+// refusal is why entering the segment from fromNodeID would be denied, or ""
+// if it would not.
+func (a *MovementArbiter) refusal(segmentID, fromNodeID int64, grid *Grid) string {
+	segment, exists := grid.Segments[segmentID]
+	if !exists || a.blocked[segmentID] || !segment.CanLeave(fromNodeID) {
+		return DenialSegmentBlocked
+	}
+
+	capacity := a.Capacity(segment, fromNodeID)
+	occupancy := a.occupancy[wayFrom(segment, fromNodeID)]
+	if occupancy >= capacity {
+		return DenialCapacityFull
+	}
+	if segment.CongestionFactor > 1 && float64(occupancy) >= math.Ceil(float64(capacity)/segment.CongestionFactor) {
+		return DenialHeavyCongestion
+	}
+	return ""
+}
+
+// alternative is the least occupied segment out of the vehicle's intersection
+// that would be granted, other than the one it asked for and the one it is on.
+func (a *MovementArbiter) alternative(vehicle *Vehicle, grid *Grid) int64 {
+	var best int64
+	bestLoad := math.Inf(1)
+	for _, segmentID := range grid.Adjacency[vehicle.PreviousNodeID] {
+		if segmentID == vehicle.NextSegmentID || segmentID == vehicle.CurrentSegmentID {
+			continue
+		}
+		if a.refusal(segmentID, vehicle.PreviousNodeID, grid) != "" {
+			continue
+		}
+		segment := grid.Segments[segmentID]
+		load := float64(a.occupancy[wayFrom(segment, vehicle.PreviousNodeID)]) / float64(a.Capacity(segment, vehicle.PreviousNodeID))
+		if load < bestLoad {
+			best, bestLoad = segmentID, load
+		}
+	}
+	return best
+}
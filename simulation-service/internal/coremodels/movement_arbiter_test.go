@@ -0,0 +1,210 @@
+package coremodels_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"owenvi.com/roadgraph/simclock"
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// junction joins segments at their shared nodes. Each is 100m long, enough
+// for four vehicles a lane at the arbiter's defaults.
+func junction(segments ...*coremodels.RoadSegment) *coremodels.Grid {
+	grid := &coremodels.Grid{
+		Segments:  make(map[int64]*coremodels.RoadSegment),
+		Adjacency: make(map[int64][]int64),
+	}
+	for _, segment := range segments {
+		segment.LengthKM = 0.1
+		if segment.CongestionFactor == 0 {
+			segment.CongestionFactor = 1
+		}
+		grid.Segments[segment.ID] = segment
+		grid.Adjacency[segment.StartNode] = append(grid.Adjacency[segment.StartNode], segment.ID)
+		grid.Adjacency[segment.EndNode] = append(grid.Adjacency[segment.EndNode], segment.ID)
+	}
+	return grid
+}
+
+// asking is a vehicle that drove segment 1 up to node 1 and asks to enter
+// segmentID from there.
+func asking(segmentID int64) *coremodels.Vehicle {
+	return &coremodels.Vehicle{ID: ksuid.New(), CurrentSegmentID: 1, NextSegmentID: segmentID, PreviousNodeID: 1}
+}
+
+// admit grants count vehicles onto segmentID from node 1.
+func admit(t *testing.T, arbiter *coremodels.MovementArbiter, grid *coremodels.Grid, segmentID int64, count int) {
+	t.Helper()
+	for range count {
+		if response := arbiter.Decide(asking(segmentID), grid); !response.Accepted {
+			t.Fatalf("refused onto segment %d: %s", segmentID, response.Reason)
+		}
+	}
+}
+
+// A loop starts and ends at the same node, so the vehicles granted onto it
+// have to be counted on the carriageway the arbiter checks the next request
+// against.
+func TestArbiterFillsLoopSegments(t *testing.T) {
+	loop := &coremodels.RoadSegment{ID: 1, StartNode: 7, EndNode: 7, LengthKM: 0.01}
+	grid := &coremodels.Grid{
+		Segments:  map[int64]*coremodels.RoadSegment{loop.ID: loop},
+		Adjacency: map[int64][]int64{7: {loop.ID}},
+	}
+	arbiter := coremodels.NewMovementArbiter()
+	capacity := arbiter.Capacity(loop, 7)
+
+	for i := range capacity + 1 {
+		vehicle := &coremodels.Vehicle{ID: ksuid.New(), NextSegmentID: loop.ID, PreviousNodeID: 7}
+		response := arbiter.Decide(vehicle, grid)
+		if i < capacity && !response.Accepted {
+			t.Fatalf("vehicle %d of %d refused onto the loop: %s", i+1, capacity, response.Reason)
+		}
+		if i == capacity && response.Reason != coremodels.DenialCapacityFull {
+			t.Errorf("vehicle past the loop's capacity of %d got %q, want %q",
+				capacity, response.Reason, coremodels.DenialCapacityFull)
+		}
+	}
+}
+
+func TestArbiterRefusesBlockedSegments(t *testing.T) {
+	grid := junction(
+		&coremodels.RoadSegment{ID: 1, StartNode: 0, EndNode: 1},
+		&coremodels.RoadSegment{ID: 2, StartNode: 1, EndNode: 2},
+	)
+	arbiter := coremodels.NewMovementArbiter()
+
+	arbiter.Block(2)
+	if response := arbiter.Decide(asking(2), grid); response.Accepted || response.Reason != coremodels.DenialSegmentBlocked {
+		t.Errorf("entering a blocked segment: accepted %v, %q", response.Accepted, response.Reason)
+	}
+	arbiter.Unblock(2)
+	if response := arbiter.Decide(asking(2), grid); !response.Accepted {
+		t.Errorf("refused onto an unblocked segment: %s", response.Reason)
+	}
+	if arbiter.Denials[coremodels.DenialSegmentBlocked] != 1 || arbiter.Granted != 1 {
+		t.Errorf("counted %d granted and denials %v", arbiter.Granted, arbiter.Denials)
+	}
+}
+
+// A congested segment only takes the share of its capacity its congestion
+// factor leaves usable.
+func TestArbiterRefusesCongestedSegments(t *testing.T) {
+	grid := junction(
+		&coremodels.RoadSegment{ID: 1, StartNode: 0, EndNode: 1},
+		&coremodels.RoadSegment{ID: 2, StartNode: 1, EndNode: 2, CongestionFactor: 2},
+	)
+	arbiter := coremodels.NewMovementArbiter()
+	if capacity := arbiter.Capacity(grid.Segments[2], 1); capacity != 4 {
+		t.Fatalf("capacity %d, want 4", capacity)
+	}
+
+	admit(t, arbiter, grid, 2, 2)
+	if response := arbiter.Decide(asking(2), grid); response.Reason != coremodels.DenialHeavyCongestion {
+		t.Errorf("past half the capacity of a segment congested twice over: %q, want %q",
+			response.Reason, coremodels.DenialHeavyCongestion)
+	}
+
+	grid.SetCongestionFactor(2, 1)
+	admit(t, arbiter, grid, 2, 2)
+	if response := arbiter.Decide(asking(2), grid); response.Reason != coremodels.DenialCapacityFull {
+		t.Errorf("past the capacity once congestion clears: %q, want %q", response.Reason, coremodels.DenialCapacityFull)
+	}
+}
+
+func TestArbiterRefusesTheWrongWayDownOneWaySegments(t *testing.T) {
+	grid := junction(
+		&coremodels.RoadSegment{ID: 1, StartNode: 0, EndNode: 1},
+		// Segment 2 is driven from node 2 towards node 1 only.
+		&coremodels.RoadSegment{ID: 2, StartNode: 2, EndNode: 1, Direction: coremodels.ForwardOnly},
+		&coremodels.RoadSegment{ID: 3, StartNode: 3, EndNode: 1, Direction: coremodels.BackwardOnly},
+	)
+	arbiter := coremodels.NewMovementArbiter()
+
+	if capacity := arbiter.Capacity(grid.Segments[2], 1); capacity != 0 {
+		t.Errorf("capacity %d the wrong way down a one-way segment, want 0", capacity)
+	}
+	response := arbiter.Decide(asking(2), grid)
+	if response.Accepted || response.Reason != coremodels.DenialSegmentBlocked {
+		t.Errorf("wrong way down a one-way segment: accepted %v, %q", response.Accepted, response.Reason)
+	}
+	// The way out the segment allows is suggested instead.
+	if response.AlternativeSegmentID != 3 {
+		t.Errorf("suggested segment %d, want 3", response.AlternativeSegmentID)
+	}
+	admit(t, arbiter, grid, 3, 1)
+}
+
+func TestArbiterSuggestsTheLeastOccupiedWayOut(t *testing.T) {
+	grid := junction(
+		&coremodels.RoadSegment{ID: 1, StartNode: 0, EndNode: 1},
+		&coremodels.RoadSegment{ID: 2, StartNode: 1, EndNode: 2},
+		&coremodels.RoadSegment{ID: 3, StartNode: 1, EndNode: 3},
+		&coremodels.RoadSegment{ID: 4, StartNode: 1, EndNode: 4},
+	)
+	arbiter := coremodels.NewMovementArbiter()
+	admit(t, arbiter, grid, 2, 4)
+	admit(t, arbiter, grid, 3, 2)
+	admit(t, arbiter, grid, 4, 1)
+
+	if response := arbiter.Decide(asking(2), grid); response.AlternativeSegmentID != 4 {
+		t.Errorf("suggested segment %d, want the emptiest, 4", response.AlternativeSegmentID)
+	}
+	admit(t, arbiter, grid, 4, 3)
+	admit(t, arbiter, grid, 3, 2)
+	// Nothing out of the junction but the way back is free, and the vehicle
+	// is not sent back where it came from.
+	if response := arbiter.Decide(asking(2), grid); response.AlternativeSegmentID != 0 {
+		t.Errorf("suggested segment %d with every way on full", response.AlternativeSegmentID)
+	}
+}
+
+// Each denial lengthens the wait before the vehicle may ask again, and
+// enough of them mark it stuck.
+func TestDenialsBackOffUntilTheVehicleIsStuck(t *testing.T) {
+	grid := junction(
+		&coremodels.RoadSegment{ID: 1, StartNode: 0, EndNode: 1},
+		&coremodels.RoadSegment{ID: 2, StartNode: 1, EndNode: 2},
+	)
+	arbiter := coremodels.NewMovementArbiter()
+	admit(t, arbiter, grid, 2, 4)
+
+	clock := simclock.NewAsFastAsPossible(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	vehicle := &coremodels.Vehicle{
+		ID: ksuid.New(), CurrentSegmentID: 1, SegmentProgress: 1, TravelDirection: 1,
+		Status: coremodels.StatusMoving, Clock: clock, LastUpdate: clock.Now(),
+	}
+	for denials := 1; denials <= 6; denials++ {
+		if vehicle.IsStuck() {
+			t.Fatalf("stuck after %d denials", denials-1)
+		}
+		vehicle.PrepareMovementRequest(2, 1)
+		if vehicle.CanMakeMovementRequest() {
+			t.Fatal("may ask again while waiting for an answer")
+		}
+		response := arbiter.Decide(vehicle, grid)
+		vehicle.HandleMovementResponse(response.Accepted, response.Reason, response.AlternativeSegmentID, grid)
+		if vehicle.MovementDenialCount != denials || vehicle.Status != coremodels.StatusMoving {
+			t.Fatalf("after denial %d: %d denials counted, %s", denials, vehicle.MovementDenialCount, vehicle.Status)
+		}
+
+		backoff := time.Duration(denials*100) * time.Millisecond
+		clock.Advance(backoff)
+		if vehicle.CanMakeMovementRequest() {
+			t.Fatalf("may ask again %v after denial %d", backoff, denials)
+		}
+		clock.Advance(time.Millisecond)
+		if !vehicle.CanMakeMovementRequest() {
+			t.Fatalf("may not ask again once the backoff after denial %d has passed", denials)
+		}
+	}
+	if !vehicle.IsStuck() {
+		t.Error("not stuck after six denials")
+	}
+	if vehicle.NextSegmentID != 0 {
+		t.Errorf("asks for segment %d with no way on", vehicle.NextSegmentID)
+	}
+}
@@ -0,0 +1,480 @@
+package coremodels
+
+import (
+	"math"
+	"slices"
+)
+
+// defaultLandmarks is how many landmarks a route index picks. More landmarks
+// tighten the search at the cost of memory and build time.
+const defaultLandmarks = 8
+
+// RouteIndex answers shortest-path queries on one grid with ALT: A* guided by
+// precomputed costs to a few landmark nodes, which bound the remaining cost
+// far more tightly than straight-line distance. Costs follow the router
+// weights the index was built with, and one-way segments are only routed the
+// way they may be driven.
+//
+// When a segment's cost rises the index is patched in place; when one falls
+// or the road network itself changes, the precomputed costs are rebuilt.
+// Congestion changed with Grid.SetCongestionFactor is picked up before the
+// next query. Changes made any other way need UpdateSegment, or Refresh to
+// pick up every change.
+//
+// Queries reuse scratch space, so an index must not be queried concurrently.
+type RouteIndex struct {
+	grid             *Grid
+	distanceWeight   float64
+	congestionWeight float64
+
+	nodeIDs   []int64
+	nodeIndex map[int64]int32
+	component []int32
+
+	// The edges leaving node i are edges[offsets[i]:offsets[i+1]]; every
+	// segment has an edge for each way it may be driven. The edges reaching
+	// node i are listed by index in arrivals[arrivalOffsets[i]:arrivalOffsets[i+1]].
+	offsets        []int32
+	edges          []indexEdge
+	arrivalOffsets []int32
+	arrivals       []int32
+	segmentEdges   map[int64][]int32
+	segmentCount   int
+	// revision is the grid's cost revision the edge costs are current with.
+	revision uint64
+	// directed is set when some segment is one-way, so costs to a node may
+	// differ from costs back from it.
+	directed bool
+
+	// landmarkFrom[k][i] is the cost from landmark k to node i and
+	// landmarkTo[k][i] the cost from node i to landmark k, computed with each
+	// edge at its landmarkCost. Without one-way segments they are the same.
+	landmarkFrom  [][]float64
+	landmarkTo    [][]float64
+	staleLandmark bool
+
+	gScore     []float64
+	fScore     []float64
+	parentEdge []int32
+	parentNode []int32
+	visited    []uint32
+	visit      uint32
+	open       indexQueue
+}
+
+type indexEdge struct {
+	from      int32
+	to        int32
+	segmentID int64
+	cost      float64
+	// landmarkCost is the cost the landmark costs were computed with. They
+	// stay valid lower bounds for as long as no edge gets cheaper than that.
+	landmarkCost float64
+}
+
+// NewRouteIndex indexes grid for routes costed like a router with the given
+// weights.
+func NewRouteIndex(grid *Grid, distanceWeight, congestionWeight float64) *RouteIndex {
+	ix := &RouteIndex{
+		grid:             grid,
+		distanceWeight:   distanceWeight,
+		congestionWeight: congestionWeight,
+	}
+	ix.build()
+	return ix
+}
+
+// Grid is the grid the index answers for.
+func (ix *RouteIndex) Grid() *Grid {
+	return ix.grid
+}
+
+func (ix *RouteIndex) build() {
+	g := ix.grid
+	ix.nodeIDs = make([]int64, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ix.nodeIDs = append(ix.nodeIDs, id)
+	}
+	slices.Sort(ix.nodeIDs)
+	ix.nodeIndex = make(map[int64]int32, len(ix.nodeIDs))
+	for i, id := range ix.nodeIDs {
+		ix.nodeIndex[id] = int32(i)
+	}
+
+	ix.offsets = make([]int32, len(ix.nodeIDs)+1)
+	ix.edges = ix.edges[:0]
+	ix.segmentEdges = make(map[int64][]int32, len(g.Segments))
+	ix.directed = false
+	for i, id := range ix.nodeIDs {
+		ix.offsets[i] = int32(len(ix.edges))
+		for _, segmentID := range g.Adjacency[id] {
+			segment, exists := g.Segments[segmentID]
+			if !exists || !segment.CanLeave(id) {
+				continue
+			}
+			if segment.OneWay() {
+				ix.directed = true
+			}
+			other := segment.EndNode
+			if other == id {
+				other = segment.StartNode
+			}
+			to, exists := ix.nodeIndex[other]
+			if !exists {
+				continue
+			}
+			cost := ix.segmentCost(segment)
+			ix.segmentEdges[segmentID] = append(ix.segmentEdges[segmentID], int32(len(ix.edges)))
+			ix.edges = append(ix.edges, indexEdge{from: int32(i), to: to, segmentID: segmentID, cost: cost, landmarkCost: cost})
+		}
+	}
+	ix.offsets[len(ix.nodeIDs)] = int32(len(ix.edges))
+	ix.segmentCount = len(g.Segments)
+	ix.revision = g.costRevision
+
+	ix.arrivalOffsets = make([]int32, len(ix.nodeIDs)+1)
+	for _, edge := range ix.edges {
+		ix.arrivalOffsets[edge.to+1]++
+	}
+	for i := range ix.nodeIDs {
+		ix.arrivalOffsets[i+1] += ix.arrivalOffsets[i]
+	}
+	ix.arrivals = make([]int32, len(ix.edges))
+	filled := slices.Clone(ix.arrivalOffsets[:len(ix.nodeIDs)])
+	for e, edge := range ix.edges {
+		ix.arrivals[filled[edge.to]] = int32(e)
+		filled[edge.to]++
+	}
+
+	n := len(ix.nodeIDs)
+	ix.gScore = make([]float64, n)
+	ix.fScore = make([]float64, n)
+	ix.parentEdge = make([]int32, n)
+	ix.parentNode = make([]int32, n)
+	ix.visited = make([]uint32, n)
+	ix.visit = 0
+
+	ix.labelComponents()
+	ix.buildLandmarks()
+}
+
+func (ix *RouteIndex) segmentCost(segment *RoadSegment) float64 {
+	return ix.distanceWeight*segment.LengthKM + ix.congestionWeight*segment.CongestionFactor*segment.LengthKM
+}
+
+// labelComponents numbers the connected parts of the network, whichever way
+// their roads run, so queries between them fail at once instead of searching
+// a whole component.
+func (ix *RouteIndex) labelComponents() {
+	ix.component = make([]int32, len(ix.nodeIDs))
+	for i := range ix.component {
+		ix.component[i] = -1
+	}
+	var label int32
+	var stack []int32
+	for start := range ix.component {
+		if ix.component[start] >= 0 {
+			continue
+		}
+		ix.component[start] = label
+		stack = append(stack[:0], int32(start))
+		for len(stack) > 0 {
+			u := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, edge := range ix.edges[ix.offsets[u]:ix.offsets[u+1]] {
+				if ix.component[edge.to] < 0 {
+					ix.component[edge.to] = label
+					stack = append(stack, edge.to)
+				}
+			}
+			for _, e := range ix.arrivals[ix.arrivalOffsets[u]:ix.arrivalOffsets[u+1]] {
+				if from := ix.edges[e].from; ix.component[from] < 0 {
+					ix.component[from] = label
+					stack = append(stack, from)
+				}
+			}
+		}
+		label++
+	}
+}
+
+// buildLandmarks picks landmarks by farthest-point selection, each new one
+// as far as possible from those already picked, and records the cost from
+// each to every node and, on a grid with one-way segments, back.
+func (ix *RouteIndex) buildLandmarks() {
+	for i := range ix.edges {
+		ix.edges[i].landmarkCost = ix.edges[i].cost
+	}
+	ix.staleLandmark = false
+	ix.landmarkFrom = ix.landmarkFrom[:0]
+	ix.landmarkTo = ix.landmarkTo[:0]
+	if len(ix.nodeIDs) == 0 {
+		return
+	}
+
+	nearest := make([]float64, len(ix.nodeIDs))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	next := int32(0)
+	for k := 0; k < min(defaultLandmarks, len(ix.nodeIDs)); k++ {
+		costs := ix.costsFrom(next)
+		ix.landmarkFrom = append(ix.landmarkFrom, costs)
+		if ix.directed {
+			ix.landmarkTo = append(ix.landmarkTo, ix.costsTo(next))
+		} else {
+			ix.landmarkTo = append(ix.landmarkTo, costs)
+		}
+
+		farthest, farthestCost := int32(-1), -1.0
+		for i, cost := range costs {
+			if cost < nearest[i] {
+				nearest[i] = cost
+			}
+			// Nodes no landmark reaches yet come first, so every
+			// component gets one.
+			score := nearest[i]
+			if math.IsInf(score, 1) {
+				score = math.MaxFloat64
+			}
+			if score > farthestCost {
+				farthest, farthestCost = int32(i), score
+			}
+		}
+		if farthestCost <= 0 {
+			break
+		}
+		next = farthest
+	}
+}
+
+// costsFrom runs Dijkstra from node source over the landmark costs.
+func (ix *RouteIndex) costsFrom(source int32) []float64 {
+	costs := make([]float64, len(ix.nodeIDs))
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	costs[source] = 0
+	queue := indexQueue{{node: source}}
+	for len(queue) > 0 {
+		item := queue.pop()
+		if item.priority > costs[item.node] {
+			continue
+		}
+		for _, edge := range ix.edges[ix.offsets[item.node]:ix.offsets[item.node+1]] {
+			if cost := item.priority + edge.landmarkCost; cost < costs[edge.to] {
+				costs[edge.to] = cost
+				queue.push(indexItem{node: edge.to, priority: cost})
+			}
+		}
+	}
+	return costs
+}
+
+// costsTo runs Dijkstra to node target over the landmark costs, following
+// edges backwards.
+func (ix *RouteIndex) costsTo(target int32) []float64 {
+	costs := make([]float64, len(ix.nodeIDs))
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	costs[target] = 0
+	queue := indexQueue{{node: target}}
+	for len(queue) > 0 {
+		item := queue.pop()
+		if item.priority > costs[item.node] {
+			continue
+		}
+		for _, e := range ix.arrivals[ix.arrivalOffsets[item.node]:ix.arrivalOffsets[item.node+1]] {
+			edge := &ix.edges[e]
+			if cost := item.priority + edge.landmarkCost; cost < costs[edge.from] {
+				costs[edge.from] = cost
+				queue.push(indexItem{node: edge.from, priority: cost})
+			}
+		}
+	}
+	return costs
+}
+
+// UpdateSegment brings one segment's cost up to date after it changed, or
+// takes it out of routes if it no longer exists.
+func (ix *RouteIndex) UpdateSegment(segmentID int64) {
+	cost := math.Inf(1)
+	if segment, exists := ix.grid.Segments[segmentID]; exists {
+		cost = ix.segmentCost(segment)
+	}
+	for _, e := range ix.segmentEdges[segmentID] {
+		edge := &ix.edges[e]
+		edge.cost = cost
+		if cost < edge.landmarkCost {
+			ix.staleLandmark = true
+		}
+	}
+}
+
+// Refresh compares the index against the grid and brings it up to date,
+// rebuilding it if segments were added or the nodes changed. It reports
+// whether anything had changed.
+func (ix *RouteIndex) Refresh() bool {
+	ix.revision = ix.grid.costRevision
+	if len(ix.grid.Nodes) != len(ix.nodeIDs) || len(ix.grid.Segments) != ix.segmentCount {
+		ix.build()
+		return true
+	}
+	changed := false
+	for segmentID, edges := range ix.segmentEdges {
+		segment, exists := ix.grid.Segments[segmentID]
+		if !exists {
+			ix.build()
+			return true
+		}
+		if len(edges) > 0 && ix.edges[edges[0]].cost != ix.segmentCost(segment) {
+			ix.UpdateSegment(segmentID)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Route returns the cheapest route from fromNodeID to toNodeID, leaving out
+// any segment skip reports for the node it would be taken from, along with
+// its cost. It returns nil when there is no such route.
+func (ix *RouteIndex) Route(fromNodeID, toNodeID int64, skip func(atNodeID, segmentID int64) bool) ([]RouteStep, float64) {
+	if ix.revision != ix.grid.costRevision {
+		ix.Refresh()
+	}
+	if ix.staleLandmark {
+		ix.buildLandmarks()
+	}
+	source, sourceExists := ix.nodeIndex[fromNodeID]
+	target, targetExists := ix.nodeIndex[toNodeID]
+	if !sourceExists || !targetExists || source == target || ix.component[source] != ix.component[target] {
+		return nil, math.Inf(1)
+	}
+
+	ix.visit++
+	if ix.visit == 0 {
+		clear(ix.visited)
+		ix.visit = 1
+	}
+	ix.reach(source, 0, -1, -1, target)
+	ix.open = append(ix.open[:0], indexItem{node: source, priority: ix.fScore[source]})
+
+	for len(ix.open) > 0 {
+		item := ix.open.pop()
+		u := item.node
+		if item.priority > ix.fScore[u] {
+			continue
+		}
+		if u == target {
+			return ix.routeTo(target), ix.gScore[target]
+		}
+
+		atNodeID := ix.nodeIDs[u]
+		for e := ix.offsets[u]; e < ix.offsets[u+1]; e++ {
+			edge := &ix.edges[e]
+			if math.IsInf(edge.cost, 1) || (skip != nil && skip(atNodeID, edge.segmentID)) {
+				continue
+			}
+			cost := ix.gScore[u] + edge.cost
+			if ix.visited[edge.to] == ix.visit && cost >= ix.gScore[edge.to] {
+				continue
+			}
+			ix.reach(edge.to, cost, u, e, target)
+			ix.open.push(indexItem{node: edge.to, priority: ix.fScore[edge.to]})
+		}
+	}
+	return nil, math.Inf(1)
+}
+
+func (ix *RouteIndex) reach(node int32, cost float64, from, viaEdge int32, target int32) {
+	ix.visited[node] = ix.visit
+	ix.gScore[node] = cost
+	ix.fScore[node] = cost + ix.lowerBound(node, target)
+	ix.parentNode[node] = from
+	ix.parentEdge[node] = viaEdge
+}
+
+// lowerBound is the ALT bound on the cost from node to target: by the
+// triangle inequality no route can be cheaper than the landmark's cost to
+// target less its cost to node, nor than node's cost to the landmark less
+// target's.
+func (ix *RouteIndex) lowerBound(node, target int32) float64 {
+	bound := 0.0
+	for k, from := range ix.landmarkFrom {
+		to := ix.landmarkTo[k]
+		for _, d := range [2]float64{from[target] - from[node], to[node] - to[target]} {
+			if d > bound && !math.IsInf(d, 0) && !math.IsNaN(d) {
+				bound = d
+			}
+		}
+	}
+	return bound
+}
+
+func (ix *RouteIndex) routeTo(target int32) []RouteStep {
+	var route []RouteStep
+	for node := target; ix.parentEdge[node] >= 0; node = ix.parentNode[node] {
+		segmentID := ix.edges[ix.parentEdge[node]].segmentID
+		route = append(route, RouteStep{
+			SegmentID:        segmentID,
+			NodeID:           ix.nodeIDs[node],
+			CongestionFactor: ix.grid.Segments[segmentID].CongestionFactor,
+		})
+	}
+	slices.Reverse(route)
+	return route
+}
+
+type indexItem struct {
+	node     int32
+	priority float64
+}
+
+// indexQueue is a binary min-heap of search items. It is kept apart from
+// container/heap so pushes and pops do not box every item.
+type indexQueue []indexItem
+
+func (q indexQueue) less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].node < q[j].node
+}
+
+func (q *indexQueue) push(item indexItem) {
+	*q = append(*q, item)
+	h := *q
+	for i := len(h) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			break
+		}
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+func (q *indexQueue) pop() indexItem {
+	h := *q
+	top := h[0]
+	last := len(h) - 1
+	h[0] = h[last]
+	h = h[:last]
+	for i := 0; ; {
+		smallest, left, right := i, 2*i+1, 2*i+2
+		if left < len(h) && h.less(left, smallest) {
+			smallest = left
+		}
+		if right < len(h) && h.less(right, smallest) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+	*q = h
+	return top
+}
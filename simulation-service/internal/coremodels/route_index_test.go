@@ -0,0 +1,142 @@
+package coremodels_test
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/gridengine"
+)
+
+func routingGrid(t testing.TB, oneWay bool) *coremodels.Grid {
+	t.Helper()
+	opts := []gridengine.GridOption{
+		gridengine.WithDimensions(30, 30),
+		gridengine.WithAlgorithm(coremodels.Suburban),
+		gridengine.WithSeed(gridengine.SeedFromInt64(5)),
+	}
+	if oneWay {
+		opts = append(opts, gridengine.WithOneWayStreets())
+	}
+	grid := gridengine.NewGrid(opts...)
+	if len(grid.Segments) == 0 {
+		t.Fatal("generated grid has no segments")
+	}
+	return grid
+}
+
+func sortedIDs[V any](m map[int64]V) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func nodePairs(grid *coremodels.Grid, count int, rng *rand.Rand) [][2]int64 {
+	nodeIDs := sortedIDs(grid.Nodes)
+	pairs := make([][2]int64, count)
+	for i := range pairs {
+		pairs[i] = [2]int64{nodeIDs[rng.Intn(len(nodeIDs))], nodeIDs[rng.Intn(len(nodeIDs))]}
+	}
+	return pairs
+}
+
+func routeCost(router *coremodels.VehicleRouter, grid *coremodels.Grid, route []coremodels.RouteStep) float64 {
+	cost := 0.0
+	for _, step := range route {
+		segment := grid.Segments[step.SegmentID]
+		cost += router.DistanceWeight*segment.LengthKM + router.CongestionWeight*segment.CongestionFactor*segment.LengthKM
+	}
+	return cost
+}
+
+// checkAgainstAStar checks the index finds a route wherever A* does, at no
+// greater cost. A* steers by straight-line distance, which can overestimate
+// on cheap roads, so it may miss the best route where the index does not.
+func checkAgainstAStar(t *testing.T, grid *coremodels.Grid, astar, indexed *coremodels.VehicleRouter, pairs [][2]int64) {
+	t.Helper()
+	for _, pair := range pairs {
+		want := astar.PlanRoute(pair[0], pair[1], grid)
+		got := indexed.PlanRoute(pair[0], pair[1], grid)
+		if (want == nil) != (got == nil) {
+			t.Fatalf("route from %d to %d: A* and the index disagree on whether one exists", pair[0], pair[1])
+		}
+		if wantCost, gotCost := routeCost(astar, grid, want), routeCost(indexed, grid, got); gotCost > wantCost*(1+1e-9) {
+			t.Fatalf("route from %d to %d: index cost %.6f exceeds A* cost %.6f", pair[0], pair[1], gotCost, wantCost)
+		}
+	}
+}
+
+func TestRouteIndexAgreesWithAStar(t *testing.T) {
+	for name, oneWay := range map[string]bool{"two-way": false, "one-way": true} {
+		t.Run(name, func(t *testing.T) {
+			grid := routingGrid(t, oneWay)
+			astar := coremodels.NewVehicleRouter(1)
+			indexed := coremodels.NewVehicleRouter(1)
+			indexed.BuildIndex(grid)
+			checkAgainstAStar(t, grid, astar, indexed, nodePairs(grid, 300, rand.New(rand.NewSource(1))))
+		})
+	}
+}
+
+// Raising and lowering costs exercises both ways the index stays current:
+// patching edges in place, and recomputing landmark costs. Either way it must
+// route as an index built from scratch would.
+func TestRouteIndexFollowsCongestionChanges(t *testing.T) {
+	grid := routingGrid(t, true)
+	indexed := coremodels.NewVehicleRouter(1)
+	indexed.BuildIndex(grid)
+	rng := rand.New(rand.NewSource(2))
+	pairs := nodePairs(grid, 200, rng)
+	for _, pair := range pairs {
+		indexed.PlanRoute(pair[0], pair[1], grid)
+	}
+
+	segmentIDs := sortedIDs(grid.Segments)
+	for range len(segmentIDs) / 20 {
+		segment := grid.Segments[segmentIDs[rng.Intn(len(segmentIDs))]]
+		grid.SetCongestionFactor(segment.ID, segment.CongestionFactor*(0.1+rng.Float64()*10))
+	}
+
+	fresh := coremodels.NewRouteIndex(grid, indexed.DistanceWeight, indexed.CongestionWeight)
+	for _, pair := range pairs {
+		_, want := fresh.Route(pair[0], pair[1], nil)
+		got := routeCost(indexed, grid, indexed.PlanRoute(pair[0], pair[1], grid))
+		if math.IsInf(want, 1) {
+			want = 0
+		}
+		if math.Abs(got-want) > 1e-9*max(1, want) {
+			t.Fatalf("route from %d to %d costs %.6f after the change, %.6f with a fresh index", pair[0], pair[1], got, want)
+		}
+	}
+}
+
+func BenchmarkPlanRoute(b *testing.B) {
+	grid := routingGrid(b, false)
+	pairs := nodePairs(grid, 500, rand.New(rand.NewSource(1)))
+	indexed := coremodels.NewVehicleRouter(1)
+	indexed.BuildIndex(grid)
+	routers := map[string]*coremodels.VehicleRouter{
+		"astar": coremodels.NewVehicleRouter(1),
+		"index": indexed,
+	}
+	for name, router := range routers {
+		b.Run(name, func(b *testing.B) {
+			for i := range b.N {
+				pair := pairs[i%len(pairs)]
+				router.PlanRoute(pair[0], pair[1], grid)
+			}
+		})
+	}
+}
+
+func BenchmarkBuildRouteIndex(b *testing.B) {
+	grid := routingGrid(b, false)
+	for range b.N {
+		coremodels.NewRouteIndex(grid, 0.6, 0.4)
+	}
+}
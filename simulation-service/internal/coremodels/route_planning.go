@@ -0,0 +1,184 @@
+package coremodels
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+type RoutingMode int
+
+const (
+	// RoutingGreedy picks the cheapest segment out of each intersection as
+	// the vehicle reaches it.
+	RoutingGreedy RoutingMode = iota
+	// RoutingPathPlanning plans the whole path to the target with A* and
+	// follows it, planning again when it goes stale.
+	RoutingPathPlanning
+)
+
+func (m RoutingMode) String() string {
+	switch m {
+	case RoutingGreedy:
+		return "greedy"
+	case RoutingPathPlanning:
+		return "astar"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseRoutingMode(name string) (RoutingMode, error) {
+	switch strings.ToLower(name) {
+	case "greedy", "nexthop":
+		return RoutingGreedy, nil
+	case "astar", "path":
+		return RoutingPathPlanning, nil
+	}
+	return 0, fmt.Errorf("unknown routing mode %q", name)
+}
+
+// RouteStep is one leg of a planned route: the segment to take and the node
+// it leads to, with the congestion factor the segment had when planned.
+type RouteStep struct {
+	SegmentID        int64   `json:"segment_id"`
+	NodeID           int64   `json:"node_id"`
+	CongestionFactor float64 `json:"congestion_factor"`
+}
+
+// replanLookahead is how many segments ahead a route is checked for closures
+// and congestion before each turn. Changes further along are picked up as the
+// vehicle gets closer, which keeps the check cheap on long routes.
+const replanLookahead = 16
+
+// SegmentClosures reports segments that cannot be entered.
+type SegmentClosures interface {
+	IsBlocked(segmentID int64) bool
+}
+
+// followRoute takes the vehicle's next planned segment out of fromNodeID. The
+// route is planned again if the vehicle has strayed from it or it has gone
+// stale, counting as a route change when the new route takes other segments
+// than the one it replaces. A vehicle with no way to its target falls back to
+// the greedy pick.
+func (r *VehicleRouter) followRoute(vehicle *Vehicle, fromNodeID int64, candidates []*RoadSegment, grid *Grid) *RoutingDecision {
+	if r.rng.Float64() < r.ExplorationRate && len(candidates) > 1 {
+		decision := r.createDecision(candidates[r.rng.Intn(len(candidates))], fromNodeID, vehicle.TargetNodeID, grid)
+		decision.Reason = "exploration"
+		return decision
+	}
+
+	vehicle.PlannedRoute = routeFrom(vehicle.PlannedRoute, fromNodeID)
+	if !r.routeIsCurrent(vehicle.PlannedRoute, fromNodeID, grid) {
+		previous := vehicle.PlannedRoute
+		vehicle.PlannedRoute = r.planRoute(vehicle, fromNodeID, grid, false)
+		if len(vehicle.PlannedRoute) == 0 {
+			// The target may lie back the way the vehicle came.
+			vehicle.PlannedRoute = r.planRoute(vehicle, fromNodeID, grid, true)
+		}
+		if len(vehicle.PlannedRoute) == 0 {
+			return r.evaluateSegments(candidates, fromNodeID, vehicle.TargetNodeID, grid)
+		}
+		if len(previous) > 0 && !sameSegments(previous, vehicle.PlannedRoute) {
+			vehicle.RouteChanges++
+		}
+	}
+
+	decision := r.createDecision(grid.Segments[vehicle.PlannedRoute[0].SegmentID], fromNodeID, vehicle.TargetNodeID, grid)
+	decision.Reason = "optimal"
+	return decision
+}
+
+// planRoute plans the vehicle's route from fromNodeID to its target around
+// closed segments. Unless uTurn is set it only turns back along its own
+// segment where there is no other way on.
+func (r *VehicleRouter) planRoute(vehicle *Vehicle, fromNodeID int64, grid *Grid, uTurn bool) []RouteStep {
+	return r.route(fromNodeID, vehicle.TargetNodeID, grid, func(atNodeID, segmentID int64) bool {
+		if r.isClosed(segmentID) {
+			return true
+		}
+		return !uTurn && segmentID == vehicle.CurrentSegmentID && hasOtherExit(grid, atNodeID, segmentID)
+	})
+}
+
+// PlanRoute plans the cheapest route between two nodes around closed
+// segments. It returns nil when there is none.
+func (r *VehicleRouter) PlanRoute(fromNodeID, toNodeID int64, grid *Grid) []RouteStep {
+	return r.route(fromNodeID, toNodeID, grid, func(_, segmentID int64) bool {
+		return r.isClosed(segmentID)
+	})
+}
+
+// BuildIndex precomputes a route index for grid, which answers the router's
+// route queries on it from then on.
+func (r *VehicleRouter) BuildIndex(grid *Grid) *RouteIndex {
+	r.Index = NewRouteIndex(grid, r.DistanceWeight, r.CongestionWeight)
+	return r.Index
+}
+
+// route answers from the router's index when it covers grid, and runs A*
+// otherwise.
+func (r *VehicleRouter) route(fromNodeID, toNodeID int64, grid *Grid, skip func(atNodeID, segmentID int64) bool) []RouteStep {
+	if r.Index != nil && r.Index.Grid() == grid {
+		route, _ := r.Index.Route(fromNodeID, toNodeID, skip)
+		return route
+	}
+	return r.astarRoute(fromNodeID, toNodeID, grid, skip)
+}
+
+// hasOtherExit reports whether a segment other than segmentID may be driven
+// away from nodeID.
+func hasOtherExit(grid *Grid, nodeID, segmentID int64) bool {
+	for _, id := range grid.Adjacency[nodeID] {
+		if segment, exists := grid.Segments[id]; exists && id != segmentID && segment.CanLeave(nodeID) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeFrom drops the steps of route up to and including the one reaching
+// nodeID, leaving the steps still ahead of a vehicle at that node. A route
+// that does not pass through nodeID is returned whole.
+func routeFrom(route []RouteStep, nodeID int64) []RouteStep {
+	for i, step := range route {
+		if step.NodeID == nodeID {
+			return route[i+1:]
+		}
+	}
+	return route
+}
+
+// sameSegments reports whether two routes take the same segments in the same
+// order, whatever congestion they were planned with.
+func sameSegments(a, b []RouteStep) bool {
+	return slices.EqualFunc(a, b, func(x, y RouteStep) bool { return x.SegmentID == y.SegmentID })
+}
+
+// routeIsCurrent reports whether route still leaves from fromNodeID and the
+// segments coming up on it are open with congestion factors close to the
+// planned ones.
+func (r *VehicleRouter) routeIsCurrent(route []RouteStep, fromNodeID int64, grid *Grid) bool {
+	if len(route) == 0 {
+		return false
+	}
+	if first, exists := grid.Segments[route[0].SegmentID]; !exists || !first.CanLeave(fromNodeID) {
+		return false
+	}
+
+	for _, step := range route[:min(len(route), replanLookahead)] {
+		segment, exists := grid.Segments[step.SegmentID]
+		if !exists || r.isClosed(step.SegmentID) {
+			return false
+		}
+		if math.Abs(segment.CongestionFactor-step.CongestionFactor) > r.ReplanThreshold*step.CongestionFactor {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *VehicleRouter) isClosed(segmentID int64) bool {
+	return r.Closures != nil && r.Closures.IsBlocked(segmentID)
+}
@@ -0,0 +1,135 @@
+package coremodels_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/segmentio/ksuid"
+
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// forkedRoad leads from node 0 to a fork at node 1, where a short way round
+// through node 2 (segments 11 and 12) and a long one through node 3
+// (segments 13 and 14) rejoin at node 4.
+func forkedRoad() *coremodels.Grid {
+	grid := &coremodels.Grid{
+		Segments:  make(map[int64]*coremodels.RoadSegment),
+		Adjacency: make(map[int64][]int64),
+		Nodes: map[int64]*coremodels.Node{
+			0: {ID: 0, Pos_X: -1000},
+			1: {ID: 1},
+			2: {ID: 2, Pos_X: 1000, Pos_Y: 1000},
+			3: {ID: 3, Pos_X: 1000, Pos_Y: -1500},
+			4: {ID: 4, Pos_X: 2000},
+		},
+	}
+	for _, segment := range []*coremodels.RoadSegment{
+		{ID: 10, StartNode: 0, EndNode: 1, LengthKM: 1},
+		{ID: 11, StartNode: 1, EndNode: 2, LengthKM: 1.42},
+		{ID: 12, StartNode: 2, EndNode: 4, LengthKM: 1.42},
+		{ID: 13, StartNode: 1, EndNode: 3, LengthKM: 1.81},
+		{ID: 14, StartNode: 3, EndNode: 4, LengthKM: 1.81},
+	} {
+		segment.CongestionFactor = 1
+		grid.Segments[segment.ID] = segment
+		grid.Adjacency[segment.StartNode] = append(grid.Adjacency[segment.StartNode], segment.ID)
+		grid.Adjacency[segment.EndNode] = append(grid.Adjacency[segment.EndNode], segment.ID)
+	}
+	return grid
+}
+
+// plannedSegments lists the segments of the vehicle's planned route.
+func plannedSegments(vehicle *coremodels.Vehicle) []int64 {
+	var segmentIDs []int64
+	for _, step := range vehicle.PlannedRoute {
+		segmentIDs = append(segmentIDs, step.SegmentID)
+	}
+	return segmentIDs
+}
+
+// approachingFork plans the route of a vehicle about to reach the fork from
+// node 0 and checks it takes the short way round.
+func approachingFork(t *testing.T, grid *coremodels.Grid, router *coremodels.VehicleRouter) *coremodels.Vehicle {
+	t.Helper()
+	vehicle := &coremodels.Vehicle{
+		ID:               ksuid.New(),
+		CurrentSegmentID: 10,
+		SegmentProgress:  1,
+		TargetNodeID:     4,
+		Status:           coremodels.StatusMoving,
+		TravelDirection:  1,
+		PreviousNodeID:   0,
+	}
+	decideAtFork(t, grid, router, vehicle, 11)
+	if got := plannedSegments(vehicle); !slices.Equal(got, []int64{11, 12}) {
+		t.Fatalf("planned %v, want the short way round", got)
+	}
+	return vehicle
+}
+
+func decideAtFork(t *testing.T, grid *coremodels.Grid, router *coremodels.VehicleRouter, vehicle *coremodels.Vehicle, want int64) {
+	t.Helper()
+	decision, err := router.GetNextSegment(vehicle, grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.ToSegmentID != want {
+		t.Fatalf("took segment %d out of the fork (%s), want %d", decision.ToSegmentID, decision.Reason, want)
+	}
+}
+
+func pathPlanningRouter() *coremodels.VehicleRouter {
+	router := coremodels.NewVehicleRouter(1)
+	router.Mode = coremodels.RoutingPathPlanning
+	router.ExplorationRate = 0
+	return router
+}
+
+func TestRouteIsPlannedAgainAroundAClosure(t *testing.T) {
+	grid := forkedRoad()
+	router := pathPlanningRouter()
+	arbiter := coremodels.NewMovementArbiter()
+	router.Closures = arbiter
+	vehicle := approachingFork(t, grid, router)
+
+	arbiter.Block(12)
+	decideAtFork(t, grid, router, vehicle, 13)
+	decideAtFork(t, grid, router, vehicle, 13)
+	if got := plannedSegments(vehicle); !slices.Equal(got, []int64{13, 14}) {
+		t.Errorf("planned %v around the closure, want the long way round", got)
+	}
+	if vehicle.RouteChanges != 1 {
+		t.Errorf("counted %d route changes, want 1", vehicle.RouteChanges)
+	}
+}
+
+func TestRouteIsPlannedAgainAroundCongestion(t *testing.T) {
+	grid := forkedRoad()
+	router := pathPlanningRouter()
+	vehicle := approachingFork(t, grid, router)
+
+	grid.SetCongestionFactor(11, 4)
+	decideAtFork(t, grid, router, vehicle, 13)
+	decideAtFork(t, grid, router, vehicle, 13)
+	if vehicle.RouteChanges != 1 {
+		t.Errorf("counted %d route changes, want 1", vehicle.RouteChanges)
+	}
+}
+
+// Congestion that makes a route stale without making another better has it
+// planned again, but it stays the same route.
+func TestReplanningOntoTheSameRouteIsNoChange(t *testing.T) {
+	grid := forkedRoad()
+	router := pathPlanningRouter()
+	vehicle := approachingFork(t, grid, router)
+
+	grid.SetCongestionFactor(12, 1.5)
+	decideAtFork(t, grid, router, vehicle, 11)
+	if got := vehicle.PlannedRoute[1].CongestionFactor; got != 1.5 {
+		t.Errorf("route through segment 12 still planned with congestion %g, want 1.5", got)
+	}
+	if vehicle.RouteChanges != 0 {
+		t.Errorf("counted %d route changes for the same route", vehicle.RouteChanges)
+	}
+}
@@ -2,12 +2,15 @@ package coremodels
 
 import (
 	"container/heap"
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/segmentio/ksuid"
+	"owenvi.com/roadgraph/simclock"
 )
 
 type VehicleStatus int
@@ -57,29 +60,59 @@ type Vehicle struct {
 
 	NextSegmentID     int64 `json:"next_segment_id,omitempty"`
 	RoutingDecisionID int64 `json:"routing_decision_id,omitempty"`
+	// PlannedRoute is what remains of the path a path-planning router laid
+	// out to the target.
+	PlannedRoute []RouteStep `json:"planned_route,omitempty"`
 
 	PendingMovementRequestID ksuid.KSUID `json:"pending_movement_request_id,omitempty"`
 	LastMovementRequest      time.Time   `json:"last_movement_request"`
 	MovementDenialCount      int         `json:"movement_denial_count"`
+	// MovementRequestCount is how many movement requests the vehicle has
+	// made; request IDs are derived from it.
+	MovementRequestCount int `json:"movement_request_count"`
 
 	SpawnTime       time.Time `json:"spawn_time"`
 	LastUpdate      time.Time `json:"last_update"`
 	TotalDistanceKM float64   `json:"total_distance_km"`
 
-	AverageSpeedKPH     float64 `json:"average_speed_kph"`
-	TimeStuckSeconds    int64   `json:"time_stuck_seconds"`
-	RouteChanges        int     `json:"route_changes"`
-	IntersectionsCrossed int    `json:"intersections_crossed"`
+	AverageSpeedKPH      float64 `json:"average_speed_kph"`
+	TimeStuckSeconds     int64   `json:"time_stuck_seconds"`
+	RouteChanges         int     `json:"route_changes"`
+	IntersectionsCrossed int     `json:"intersections_crossed"`
+	// IntersectionWaitSeconds is how long the vehicle has waited at the stop
+	// lines of controlled junctions it crossed.
+	IntersectionWaitSeconds float64 `json:"intersection_wait_seconds"`
 
 	TravelDirection int64 `json:"travel_direction"`
 	PreviousNodeID  int64 `json:"previous_node_id"`
 
+	// AccelerationMPS2, ComfortableDecelerationMPS2, MinimumGapM and
+	// TimeHeadwaySeconds are how the vehicle drives under the car-following
+	// model: how hard it speeds up and likes to brake, how close it stops
+	// behind the vehicle ahead and how far behind it keeps in time. Zero
+	// takes the model's defaults.
+	AccelerationMPS2            float64 `json:"acceleration_mps2,omitempty"`
+	ComfortableDecelerationMPS2 float64 `json:"comfortable_deceleration_mps2,omitempty"`
+	MinimumGapM                 float64 `json:"minimum_gap_m,omitempty"`
+	TimeHeadwaySeconds          float64 `json:"time_headway_seconds,omitempty"`
+
 	RecentPositions []Position `json:"recent_positions"`
 	MaxTrailLength  int        `json:"max_trail_length"`
+
+	// Clock is the simulation's time; timestamps and request backoff follow it.
+	// Without one the vehicle falls back to wall time.
+	Clock simclock.Clock `json:"-"`
+}
+
+func (v *Vehicle) now() time.Time {
+	if v.Clock == nil {
+		return time.Now()
+	}
+	return v.Clock.Now()
 }
 
 func (v *Vehicle) recordPosition(x, y float64) {
-	v.RecentPositions = append(v.RecentPositions, Position{X: x, Y: y, T: time.Now()})
+	v.RecentPositions = append(v.RecentPositions, Position{X: x, Y: y, T: v.now()})
 	if len(v.RecentPositions) > v.MaxTrailLength {
 		v.RecentPositions = v.RecentPositions[len(v.RecentPositions)-v.MaxTrailLength:]
 	}
@@ -111,14 +144,19 @@ func (v *Vehicle) GetCurrentPosition(grid *Grid) (float64, float64, error) {
 	return x, y, nil
 }
 func (v *Vehicle) GetCurrentEffectiveSpeed(grid *Grid) float64 {
+	effectiveSpeed := v.effectiveSpeed(grid)
+	v.CurrentSpeedKPH = effectiveSpeed
+	return effectiveSpeed
+}
+
+// effectiveSpeed is how fast the vehicle would drive its current segment
+// given the segment's congestion.
+func (v *Vehicle) effectiveSpeed(grid *Grid) float64 {
 	segment, exists := grid.Segments[v.CurrentSegmentID]
 	if !exists {
 		return v.BaseSpeedKPH
 	}
-
-	effectiveSpeed := v.BaseSpeedKPH / segment.CongestionFactor
-	v.CurrentSpeedKPH = effectiveSpeed
-	return effectiveSpeed
+	return v.BaseSpeedKPH / segment.CongestionFactor
 }
 func (v *Vehicle) GetNextNodeID(grid *Grid) (int64, error) {
 	segment, exists := grid.Segments[v.CurrentSegmentID]
@@ -159,7 +197,7 @@ func (v *Vehicle) CanMakeMovementRequest() bool {
 		return false
 	}
 
-	timeSinceLastRequest := time.Since(v.LastMovementRequest)
+	timeSinceLastRequest := v.now().Sub(v.LastMovementRequest)
 	backoffDuration := time.Duration(v.MovementDenialCount*100) * time.Millisecond
 	minWaitTime := 50 * time.Millisecond
 
@@ -170,19 +208,55 @@ func (v *Vehicle) CanMakeMovementRequest() bool {
 	return timeSinceLastRequest > minWaitTime
 }
 
-func (v *Vehicle) UpdateProgress(deltaTimeSeconds float64, grid *Grid, router *VehicleRouter) error {
+// UpdateProgress advances the vehicle and, at the end of its segment, waits
+// for the junction's control to let it cross and asks the arbiter to let it
+// onto the next segment. Without control every junction may be crossed, and
+// without an arbiter every request is granted.
+func (v *Vehicle) UpdateProgress(deltaTimeSeconds float64, grid *Grid, router *VehicleRouter, arbiter *MovementArbiter, control *IntersectionControl) error {
+	atEnd, err := v.AdvanceOnSegment(deltaTimeSeconds, grid)
+	if err != nil || !atEnd {
+		return err
+	}
+	v.CrossIntersection(grid, router, arbiter, control)
+	return nil
+}
+
+// AdvanceOnSegment moves the vehicle along its current segment and reports
+// whether it reached the end, in which case CrossIntersection must follow to
+// finish the update. It only touches the vehicle itself, so vehicles can
+// advance concurrently; crossing draws on the shared router.
+func (v *Vehicle) AdvanceOnSegment(deltaTimeSeconds float64, grid *Grid) (bool, error) {
+	segment, moving, err := v.segmentToAdvance(grid)
+	if err != nil || !moving {
+		return false, err
+	}
+
+	if v.isHeldAtSegmentEnd() {
+		v.CurrentSpeedKPH = 0
+		return true, nil
+	}
+
+	effectiveSpeed := v.GetCurrentEffectiveSpeed(grid)
+	return v.moveAlong(segment, (effectiveSpeed/3600.0)*deltaTimeSeconds), nil
+}
+
+// segmentToAdvance is the segment a moving vehicle is on, and false if the
+// vehicle is not moving.
+func (v *Vehicle) segmentToAdvance(grid *Grid) (*RoadSegment, bool, error) {
 	if v.Status != StatusMoving {
-		return nil
+		return nil, false, nil
 	}
 
 	segment, exists := grid.Segments[v.CurrentSegmentID]
 	if !exists {
-		return fmt.Errorf("cannot update progress: segment %d not found", v.CurrentSegmentID)
+		return nil, false, fmt.Errorf("cannot update progress: segment %d not found", v.CurrentSegmentID)
 	}
+	return segment, true, nil
+}
 
-	effectiveSpeed := v.GetCurrentEffectiveSpeed(grid)
-	distanceMovedKM := (effectiveSpeed / 3600.0) * deltaTimeSeconds
-
+// moveAlong drives the vehicle distanceMovedKM further along segment and
+// reports whether that took it to the end.
+func (v *Vehicle) moveAlong(segment *RoadSegment, distanceMovedKM float64) bool {
 	if segment.LengthKM > 0 {
 		progressDelta := distanceMovedKM / segment.LengthKM
 		if v.TravelDirection >= 0 {
@@ -193,19 +267,36 @@ func (v *Vehicle) UpdateProgress(deltaTimeSeconds float64, grid *Grid, router *V
 		v.TotalDistanceKM += distanceMovedKM
 	}
 
-	if v.SegmentProgress >= 1.0 || v.SegmentProgress <= 0.0 {
-		nextNode, _ := v.GetNextNodeID(grid)
-		if nextNode == v.TargetNodeID {
-			v.Status = StatusReachedDestination
-			return nil
-		}
-		decision, err := router.GetNextSegment(v, grid)
-		if err != nil || decision.Reason == "dead_end" {
-			v.Status = StatusDeadEnd
-			return nil
-		}
-		v.PrepareMovementRequest(decision.ToSegmentID, nextNode)
-		v.HandleMovementResponse(true, "", 0, grid)
+	// A vehicle that could not move, stuck behind another, has not reached
+	// the end it started from.
+	if segment.LengthKM <= 0 || v.isHeldAtSegmentEnd() {
+		return true
+	}
+
+	v.LastUpdate = v.now()
+	return false
+}
+
+// isHeldAtSegmentEnd reports whether the vehicle is waiting at the end of its
+// segment after being denied entry to the next one.
+func (v *Vehicle) isHeldAtSegmentEnd() bool {
+	if v.TravelDirection >= 0 {
+		return v.SegmentProgress >= 1.0
+	}
+	return v.SegmentProgress <= 0.0
+}
+
+// CrossIntersection finishes an update that left the vehicle at the end of its
+// segment: it arrives, hits a dead end, or asks to turn onto the next segment.
+// That is the alternative suggested by the last denial if there is one, and
+// the router's pick otherwise. A vehicle the junction's control holds, or
+// whose request is denied, waits at the end of its segment and tries again on
+// its next update; a denied one only once its backoff has passed.
+func (v *Vehicle) CrossIntersection(grid *Grid, router *VehicleRouter, arbiter *MovementArbiter, control *IntersectionControl) {
+	nextNode, _ := v.GetNextNodeID(grid)
+	if nextNode == v.TargetNodeID {
+		v.Status = StatusReachedDestination
+		return
 	}
 
 	if v.SegmentProgress > 1.0 {
@@ -215,18 +306,65 @@ func (v *Vehicle) UpdateProgress(deltaTimeSeconds float64, grid *Grid, router *V
 		v.SegmentProgress = 0.0
 	}
 
-	v.LastUpdate = time.Now()
-	return nil
+	if !control.MayCross(v, nextNode) {
+		v.CurrentSpeedKPH = 0
+		return
+	}
+	if arbiter != nil && !v.CanMakeMovementRequest() {
+		return
+	}
+
+	targetSegmentID := v.NextSegmentID
+	if targetSegmentID == 0 {
+		decision, err := router.GetNextSegment(v, grid)
+		if err != nil || decision.Reason == "dead_end" {
+			v.Status = StatusDeadEnd
+			control.Withdraw(v)
+			return
+		}
+		targetSegmentID = decision.ToSegmentID
+	}
+	v.PrepareMovementRequest(targetSegmentID, nextNode)
+
+	accepted := true
+	if arbiter == nil {
+		v.HandleMovementResponse(true, "", 0, grid)
+	} else {
+		response := arbiter.Decide(v, grid)
+		accepted = response.Accepted
+		v.HandleMovementResponse(response.Accepted, response.Reason, response.AlternativeSegmentID, grid)
+	}
+	switch {
+	case accepted:
+		control.Crossed(v)
+	case v.Status == StatusDeadEnd:
+		control.Withdraw(v)
+	}
 }
 
 func (v *Vehicle) PrepareMovementRequest(targetSegmentID int64, fromNodeID int64) {
 	v.NextSegmentID = targetSegmentID
 	v.Status = StatusWaitingForPermission
-	v.PendingMovementRequestID = ksuid.New()
-	v.LastMovementRequest = time.Now()
+	v.PendingMovementRequestID = v.nextMovementRequestID()
+	v.LastMovementRequest = v.now()
 	v.PreviousNodeID = fromNodeID
 }
 
+// nextMovementRequestID names the vehicle's next movement request after the
+// vehicle and how many requests it has made, so runs from one seed name them
+// the same way.
+func (v *Vehicle) nextMovementRequestID() ksuid.KSUID {
+	v.MovementRequestCount++
+	payload := v.ID.Payload()
+	tail := binary.BigEndian.Uint64(payload[8:]) + uint64(v.MovementRequestCount)
+	binary.BigEndian.PutUint64(payload[8:], tail)
+	id, err := ksuid.FromParts(v.ID.Time(), payload)
+	if err != nil {
+		return ksuid.Nil
+	}
+	return id
+}
+
 func (v *Vehicle) HandleMovementResponse(accepted bool, reason string, alternativeSegmentID int64, grid *Grid) {
 	if accepted {
 		v.CurrentSegmentID = v.NextSegmentID
@@ -253,24 +391,29 @@ func (v *Vehicle) HandleMovementResponse(accepted bool, reason string, alternati
 	} else {
 		v.MovementDenialCount++
 		v.Status = StatusMoving
+		// The vehicle has been held since its last update, which was either
+		// its last step of movement or its previous denial.
+		v.TimeStuckSeconds += int64(v.now().Sub(v.LastUpdate).Seconds())
+		v.NextSegmentID = 0
 
 		switch reason {
-		case "dead_end":
+		case DenialDeadEnd:
 			v.Status = StatusDeadEnd
-		case "capacity_full", "heavy_congestion":
+		case DenialCapacityFull, DenialHeavyCongestion, DenialSegmentBlocked:
 			if alternativeSegmentID != 0 {
 				v.NextSegmentID = alternativeSegmentID
-				v.RouteChanges++
+				// A vehicle following a planned route counts the replan
+				// the detour leads to instead.
+				if len(v.PlannedRoute) == 0 {
+					v.RouteChanges++
+				}
 			}
-		case "segment_blocked":
-			v.TimeStuckSeconds += int64(time.Since(v.LastMovementRequest).Seconds())
 		}
 
-		v.NextSegmentID = 0
 		v.PendingMovementRequestID = ksuid.Nil
 	}
 
-	v.LastUpdate = time.Now()
+	v.LastUpdate = v.now()
 }
 
 func (v *Vehicle) UpdateAverageSpeed() {
@@ -295,7 +438,7 @@ func (v *Vehicle) GetDistanceToTarget(grid *Grid) (float64, error) {
 
 	dx := targetNode.Pos_X - currentX
 	dy := targetNode.Pos_Y - currentY
-	return math.Sqrt(dx*dx + dy*dy) / 1000.0, nil
+	return math.Sqrt(dx*dx+dy*dy) / 1000.0, nil
 }
 
 func (v *Vehicle) IsStuck() bool {
@@ -313,9 +456,11 @@ func (v *Vehicle) Clone() *Vehicle {
 		Status:                   v.Status,
 		NextSegmentID:            v.NextSegmentID,
 		RoutingDecisionID:        v.RoutingDecisionID,
+		PlannedRoute:             slices.Clone(v.PlannedRoute),
 		PendingMovementRequestID: v.PendingMovementRequestID,
 		LastMovementRequest:      v.LastMovementRequest,
 		MovementDenialCount:      v.MovementDenialCount,
+		MovementRequestCount:     v.MovementRequestCount,
 		SpawnTime:                v.SpawnTime,
 		LastUpdate:               v.LastUpdate,
 		TotalDistanceKM:          v.TotalDistanceKM,
@@ -323,8 +468,15 @@ func (v *Vehicle) Clone() *Vehicle {
 		TimeStuckSeconds:         v.TimeStuckSeconds,
 		RouteChanges:             v.RouteChanges,
 		IntersectionsCrossed:     v.IntersectionsCrossed,
+		IntersectionWaitSeconds:  v.IntersectionWaitSeconds,
 		TravelDirection:          v.TravelDirection,
 		PreviousNodeID:           v.PreviousNodeID,
+		Clock:                    v.Clock,
+
+		AccelerationMPS2:            v.AccelerationMPS2,
+		ComfortableDecelerationMPS2: v.ComfortableDecelerationMPS2,
+		MinimumGapM:                 v.MinimumGapM,
+		TimeHeadwaySeconds:          v.TimeHeadwaySeconds,
 	}
 }
 
@@ -339,16 +491,32 @@ type RoutingDecision struct {
 }
 
 type VehicleRouter struct {
-	DistanceWeight    float64
-	CongestionWeight  float64
-	ExplorationRate   float64
+	DistanceWeight   float64
+	CongestionWeight float64
+	ExplorationRate  float64
+
+	Mode RoutingMode
+	// ReplanThreshold is how far, relative to what was planned with, a
+	// segment's congestion factor may drift before the route through it is
+	// planned again.
+	ReplanThreshold float64
+	// Closures, when set, names segments routes must avoid.
+	Closures SegmentClosures
+	// Index, when set, answers route queries on its grid in place of A*.
+	Index *RouteIndex
+
+	// rng drives exploration, so a router built from the same seed makes the
+	// same detours.
+	rng *rand.Rand
 }
 
-func NewVehicleRouter() *VehicleRouter {
+func NewVehicleRouter(seed int64) *VehicleRouter {
 	return &VehicleRouter{
 		DistanceWeight:   0.6,
 		CongestionWeight: 0.4,
 		ExplorationRate:  0.15,
+		ReplanThreshold:  0.25,
+		rng:              rand.New(rand.NewSource(seed)),
 	}
 }
 
@@ -373,10 +541,14 @@ func (r *VehicleRouter) GetNextSegment(vehicle *Vehicle, grid *Grid) (*RoutingDe
 		}, nil
 	}
 
+	if r.Mode == RoutingPathPlanning {
+		return r.followRoute(vehicle, currentNode, candidateSegments, grid), nil
+	}
+
 	bestDecision := r.evaluateSegments(candidateSegments, currentNode, vehicle.TargetNodeID, grid)
 
-	if rand.Float64() < r.ExplorationRate && len(candidateSegments) > 1 {
-		randomIdx := rand.Intn(len(candidateSegments))
+	if r.rng.Float64() < r.ExplorationRate && len(candidateSegments) > 1 {
+		randomIdx := r.rng.Intn(len(candidateSegments))
 		randomSeg := candidateSegments[randomIdx]
 		bestDecision = r.createDecision(randomSeg, currentNode, vehicle.TargetNodeID, grid)
 		bestDecision.Reason = "exploration"
@@ -396,14 +568,14 @@ func (r *VehicleRouter) getCandidateSegments(fromNodeID int64, grid *Grid, vehic
 		if segID == vehicle.CurrentSegmentID {
 			continue
 		}
-		if segment, exists := grid.Segments[segID]; exists {
+		if segment, exists := grid.Segments[segID]; exists && segment.CanLeave(fromNodeID) {
 			candidates = append(candidates, segment)
 		}
 	}
 
 	if len(candidates) == 0 {
 		for _, segID := range segmentIDs {
-			if segment, exists := grid.Segments[segID]; exists {
+			if segment, exists := grid.Segments[segID]; exists && segment.CanLeave(fromNodeID) {
 				candidates = append(candidates, segment)
 			}
 		}
@@ -467,31 +639,44 @@ func (r *VehicleRouter) calculateDistanceToTarget(fromNodeID, targetNodeID int64
 
 	dx := targetNode.Pos_X - fromNode.Pos_X
 	dy := targetNode.Pos_Y - fromNode.Pos_Y
-	return math.Sqrt(dx*dx + dy*dy) / 1000.0
+	return math.Sqrt(dx*dx+dy*dy) / 1000.0
 }
 
-
 type astarItem struct {
-	node    int64
+	node     int64
 	priority float64
-	index   int
+	index    int
 }
 
 type priorityQueue []*astarItem
 
-func (pq priorityQueue) Len() int { return len(pq) }
+func (pq priorityQueue) Len() int           { return len(pq) }
 func (pq priorityQueue) Less(i, j int) bool { return pq[i].priority < pq[j].priority }
-func (pq priorityQueue) Swap(i, j int) { pq[i], pq[j] = pq[j], pq[i]; pq[i].index = i; pq[j].index = j }
-func (pq *priorityQueue) Push(x interface{}) { item := x.(*astarItem); item.index = len(*pq); *pq = append(*pq, item) }
-func (pq *priorityQueue) Pop() interface{} { old := *pq; n := len(old); item := old[n-1]; old[n-1] = nil; *pq = old[0 : n-1]; return item }
+func (pq priorityQueue) Swap(i, j int)      { pq[i], pq[j] = pq[j], pq[i]; pq[i].index = i; pq[j].index = j }
+func (pq *priorityQueue) Push(x interface{}) {
+	item := x.(*astarItem)
+	item.index = len(*pq)
+	*pq = append(*pq, item)
+}
+func (pq *priorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*pq = old[0 : n-1]
+	return item
+}
 
-func (r *VehicleRouter) astarNextSegment(vehicle *Vehicle, startNodeID, goalNodeID int64, grid *Grid) *RoutingDecision {
+// astarRoute runs A* from startNodeID to goalNodeID over the distance and
+// congestion cost of each segment, leaving out the segments skip reports. It
+// returns nil when the goal cannot be reached.
+func (r *VehicleRouter) astarRoute(startNodeID, goalNodeID int64, grid *Grid, skip func(atNodeID, segmentID int64) bool) []RouteStep {
 	_, ok := grid.Nodes[startNodeID]
 	if !ok {
 		return nil
 	}
 	_, ok = grid.Nodes[goalNodeID]
-	if !ok {
+	if !ok || startNodeID == goalNodeID {
 		return nil
 	}
 
@@ -499,61 +684,39 @@ func (r *VehicleRouter) astarNextSegment(vehicle *Vehicle, startNodeID, goalNode
 	heap.Init(openSet)
 	heap.Push(openSet, &astarItem{node: startNodeID, priority: 0})
 
+	// Only the nodes the search reaches get scores; the others are at
+	// infinity.
 	cameFromNode := make(map[int64]int64)
 	cameFromSeg := make(map[int64]int64)
 	gScore := make(map[int64]float64)
 	fScore := make(map[int64]float64)
-
-	for nid := range grid.Nodes {
-		gScore[nid] = math.Inf(1)
-		fScore[nid] = math.Inf(1)
-	}
 	gScore[startNodeID] = 0
 	fScore[startNodeID] = r.heuristic(startNodeID, goalNodeID, grid)
 
 	for openSet.Len() > 0 {
 		item := heap.Pop(openSet).(*astarItem)
 		current := item.node
+		if item.priority > fScore[current] {
+			continue
+		}
 		if current == goalNodeID {
-			
-			path := []int64{current}
-			for path[len(path)-1] != startNodeID {
-				prev := cameFromNode[path[len(path)-1]]
-				path = append(path, prev)
-			}
-			
-			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-				path[i], path[j] = path[j], path[i]
-			}
-			if len(path) < 2 {
-				return nil
-			}
-			nextNode := path[1]
-			segID := cameFromSeg[nextNode]
-			segment, exists := grid.Segments[segID]
-			if !exists {
-				
-				for _, sid := range grid.Adjacency[startNodeID] {
-					s := grid.Segments[sid]
-					if (s.StartNode == startNodeID && s.EndNode == nextNode) || (s.EndNode == startNodeID && s.StartNode == nextNode) {
-						segID = s.ID
-						segment = s
-						break
-					}
-				}
+			var route []RouteStep
+			for node := goalNodeID; node != startNodeID; node = cameFromNode[node] {
+				segment := grid.Segments[cameFromSeg[node]]
+				route = append(route, RouteStep{
+					SegmentID:        segment.ID,
+					NodeID:           node,
+					CongestionFactor: segment.CongestionFactor,
+				})
 			}
-			decision := r.createDecision(segment, startNodeID, goalNodeID, grid)
-			decision.ToSegmentID = segID
-			decision.ToNodeID = nextNode
-			decision.Reason = "optimal"
-			decision.TotalCost = gScore[goalNodeID]
-			return decision
+			slices.Reverse(route)
+			return route
 		}
 
 		adjSegIDs := grid.Adjacency[current]
 		for _, segID := range adjSegIDs {
 			seg, exists := grid.Segments[segID]
-			if !exists {
+			if !exists || !seg.CanLeave(current) || skip(current, segID) {
 				continue
 			}
 			var neighbor int64
@@ -562,22 +725,10 @@ func (r *VehicleRouter) astarNextSegment(vehicle *Vehicle, startNodeID, goalNode
 			} else {
 				neighbor = seg.StartNode
 			}
-			if segID == vehicle.CurrentSegmentID {
-				
-				otherCount := 0
-				for _, sID := range grid.Adjacency[current] {
-					if sID != vehicle.CurrentSegmentID {
-						otherCount++
-					}
-				}
-				if otherCount > 0 {
-					continue
-				}
-			}
 
 			edgeCost := (r.DistanceWeight * seg.LengthKM) + (r.CongestionWeight * seg.CongestionFactor * seg.LengthKM)
 			tentativeG := gScore[current] + edgeCost
-			if tentativeG < gScore[neighbor] {
+			if best, reached := gScore[neighbor]; !reached || tentativeG < best {
 				cameFromNode[neighbor] = current
 				cameFromSeg[neighbor] = segID
 				gScore[neighbor] = tentativeG
@@ -599,4 +750,4 @@ func (r *VehicleRouter) heuristic(fromNodeID, targetNodeID int64, grid *Grid) fl
 	dx := targetNode.Pos_X - fromNode.Pos_X
 	dy := targetNode.Pos_Y - fromNode.Pos_Y
 	return math.Sqrt(dx*dx+dy*dy) / 1000.0
-}
\ No newline at end of file
+}
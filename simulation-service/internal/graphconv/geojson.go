@@ -0,0 +1,195 @@
+package graphconv
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/segmentio/ksuid"
+	"owenvi.com/roadgraph"
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// geoJSONGrid is the foreign member of a grid's GeoJSON.
+type geoJSONGrid struct {
+	ID     string               `json:"id,omitempty"`
+	DimX   int64                `json:"dimX"`
+	DimY   int64                `json:"dimY"`
+	Origin roadgraph.Projection `json:"origin"`
+}
+
+type geoJSONNode struct {
+	Kind string `json:"kind"`
+	ID   int64  `json:"id"`
+}
+
+type geoJSONSegment struct {
+	Kind             string  `json:"kind"`
+	ID               int64   `json:"id"`
+	StartNode        int64   `json:"start_node"`
+	EndNode          int64   `json:"end_node"`
+	LengthKM         float64 `json:"length_km"`
+	CongestionFactor float64 `json:"congestion_factor"`
+	SpeedLimitKPH    float64 `json:"speed_limit_kph,omitempty"`
+	Direction        string  `json:"direction,omitempty"`
+	LanesForward     int     `json:"lanes_forward,omitempty"`
+	LanesBackward    int     `json:"lanes_backward,omitempty"`
+	Class            string  `json:"class,omitempty"`
+}
+
+// SaveGeoJSON writes grid as GeoJSON for GIS tools, placed on the globe by
+// projection. Nodes are Points and segments LineStrings between their nodes,
+// both listed by ID.
+func SaveGeoJSON(grid *coremodels.Grid, path string, projection roadgraph.Projection) error {
+	meta := geoJSONGrid{DimX: grid.DimX, DimY: grid.DimY, Origin: projection}
+	if grid.ID != ksuid.Nil {
+		meta.ID = grid.ID.String()
+	}
+	fc, err := roadgraph.NewFeatureCollection(meta)
+	if err != nil {
+		return err
+	}
+	at := func(node *coremodels.Node) [2]float64 {
+		return projection.ToLonLat(node.Pos_X/1000, node.Pos_Y/1000)
+	}
+
+	for _, id := range sortedKeys(grid.Nodes) {
+		if err := fc.Add(roadgraph.Point(at(grid.Nodes[id])), geoJSONNode{Kind: "node", ID: id}); err != nil {
+			return err
+		}
+	}
+	for _, id := range sortedKeys(grid.Segments) {
+		segment := grid.Segments[id]
+		start, end := grid.Nodes[segment.StartNode], grid.Nodes[segment.EndNode]
+		if start == nil || end == nil {
+			return fmt.Errorf("segment %d joins a missing node", id)
+		}
+		props := geoJSONSegment{
+			Kind:             "segment",
+			ID:               id,
+			StartNode:        segment.StartNode,
+			EndNode:          segment.EndNode,
+			LengthKM:         segment.LengthKM,
+			CongestionFactor: segment.CongestionFactor,
+			SpeedLimitKPH:    segment.SpeedLimitKPH,
+			LanesForward:     segment.LanesForward,
+			LanesBackward:    segment.LanesBackward,
+			Class:            segment.Class,
+		}
+		if segment.OneWay() {
+			props.Direction = segment.Direction.String()
+		}
+		if err := fc.Add(roadgraph.LineString(at(start), at(end)), props); err != nil {
+			return err
+		}
+	}
+	return fc.Save(path)
+}
+
+// LoadGeoJSON reads a grid written by SaveGeoJSON. Nodes sit where their
+// Points are, projected back around the file's origin, so nodes moved in a
+// GIS tool move on the grid; segment geometry is only drawn and not read.
+// Dimensions the file no longer carries are taken from the nodes' extent, and
+// adjacency is rebuilt in segment ID order.
+func LoadGeoJSON(path string) (*coremodels.Grid, error) {
+	fc, err := roadgraph.LoadGeoJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta geoJSONGrid
+	if len(fc.Properties) > 0 {
+		if err := json.Unmarshal(fc.Properties, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse grid properties of %s: %w", path, err)
+		}
+	}
+
+	grid := &coremodels.Grid{
+		DimX:      meta.DimX,
+		DimY:      meta.DimY,
+		Segments:  make(map[int64]*coremodels.RoadSegment),
+		Adjacency: make(map[int64][]int64),
+		Nodes:     make(map[int64]*coremodels.Node),
+	}
+	if id, err := ksuid.Parse(meta.ID); err == nil {
+		grid.ID = id
+	}
+
+	var segments []geoJSONSegment
+	var extentX, extentY int64
+	for i, feature := range fc.Features {
+		switch feature.Kind() {
+		case "node":
+			var node geoJSONNode
+			if err := json.Unmarshal(feature.Properties, &node); err != nil {
+				return nil, fmt.Errorf("feature %d of %s: %w", i, path, err)
+			}
+			position, err := feature.Geometry.Point()
+			if err != nil {
+				return nil, fmt.Errorf("node %d of %s: %w", node.ID, path, err)
+			}
+			if _, exists := grid.Nodes[node.ID]; exists {
+				return nil, fmt.Errorf("%s has node %d twice", path, node.ID)
+			}
+			x, y := meta.Origin.FromLonLat(position)
+			grid.Nodes[node.ID] = &coremodels.Node{ID: node.ID, Pos_X: x * 1000, Pos_Y: y * 1000}
+			extentX = max(extentX, int64(math.Ceil(x*1000/metersPerDim-1e-9)))
+			extentY = max(extentY, int64(math.Ceil(y*1000/metersPerDim-1e-9)))
+		case "segment":
+			var segment geoJSONSegment
+			if err := json.Unmarshal(feature.Properties, &segment); err != nil {
+				return nil, fmt.Errorf("feature %d of %s: %w", i, path, err)
+			}
+			segments = append(segments, segment)
+		}
+	}
+	if grid.DimX <= 0 || grid.DimY <= 0 {
+		grid.DimX, grid.DimY = extentX, extentY
+	}
+	if grid.DimX <= 0 || grid.DimY <= 0 {
+		return nil, fmt.Errorf("%s has no nodes", path)
+	}
+
+	slices.SortFunc(segments, func(a, b geoJSONSegment) int { return cmp.Compare(a.ID, b.ID) })
+	for _, segment := range segments {
+		if _, exists := grid.Segments[segment.ID]; exists {
+			return nil, fmt.Errorf("%s has segment %d twice", path, segment.ID)
+		}
+		if grid.Nodes[segment.StartNode] == nil || grid.Nodes[segment.EndNode] == nil {
+			return nil, fmt.Errorf("segment %d of %s joins a missing node", segment.ID, path)
+		}
+		congestion := segment.CongestionFactor
+		if congestion <= 0 {
+			congestion = 1
+		}
+		direction, err := coremodels.ParseSegmentDirection(segment.Direction)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d of %s: %w", segment.ID, path, err)
+		}
+		grid.Segments[segment.ID] = &coremodels.RoadSegment{
+			ID:               segment.ID,
+			StartNode:        segment.StartNode,
+			EndNode:          segment.EndNode,
+			LengthKM:         segment.LengthKM,
+			CongestionFactor: congestion,
+			SpeedLimitKPH:    segment.SpeedLimitKPH,
+			Direction:        direction,
+			LanesForward:     segment.LanesForward,
+			LanesBackward:    segment.LanesBackward,
+			Class:            segment.Class,
+		}
+		grid.Adjacency[segment.StartNode] = append(grid.Adjacency[segment.StartNode], segment.ID)
+		grid.Adjacency[segment.EndNode] = append(grid.Adjacency[segment.EndNode], segment.ID)
+	}
+	return grid, nil
+}
+
+func sortedKeys[V any](m map[int64]V) []int64 {
+	keys := make([]int64, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
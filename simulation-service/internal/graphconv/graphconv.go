@@ -0,0 +1,131 @@
+// Package graphconv converts simsim grids to and from the road graph shared
+// with fleetsim, and to and from GeoJSON.
+package graphconv
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/segmentio/ksuid"
+	"owenvi.com/roadgraph"
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// metersPerDim is how far one unit of a grid's dimensions reaches: the
+// generators lay a DimX by DimY grid out over DimX*100 by DimY*100 metres.
+const metersPerDim = 100.0
+
+// ToGraph converts grid to a road graph, positions turning from metres to
+// kilometres. Nodes and segments are listed by ID. A road graph's one-way
+// segments run from From to To, so segments driven only from EndNode to
+// StartNode are turned round.
+func ToGraph(grid *coremodels.Grid) *roadgraph.Graph {
+	g := &roadgraph.Graph{
+		WidthKM:  float64(grid.DimX) * metersPerDim / 1000,
+		HeightKM: float64(grid.DimY) * metersPerDim / 1000,
+		Nodes:    make([]roadgraph.Node, 0, len(grid.Nodes)),
+		Segments: make([]roadgraph.Segment, 0, len(grid.Segments)),
+	}
+	if grid.ID != ksuid.Nil {
+		g.ID = grid.ID.String()
+	}
+
+	for _, node := range grid.Nodes {
+		g.Nodes = append(g.Nodes, roadgraph.Node{ID: node.ID, X: node.Pos_X / 1000, Y: node.Pos_Y / 1000})
+	}
+	slices.SortFunc(g.Nodes, func(a, b roadgraph.Node) int { return cmp.Compare(a.ID, b.ID) })
+
+	for _, segment := range grid.Segments {
+		s := roadgraph.Segment{
+			ID:               segment.ID,
+			From:             segment.StartNode,
+			To:               segment.EndNode,
+			LengthKM:         segment.LengthKM,
+			SpeedLimit:       speedLimit(segment.SpeedLimitKPH),
+			OneWay:           segment.OneWay(),
+			LanesForward:     segment.LanesForward,
+			LanesBackward:    segment.LanesBackward,
+			Class:            segment.Class,
+			CongestionFactor: segment.CongestionFactor,
+		}
+		if segment.Direction == coremodels.BackwardOnly {
+			s.From, s.To = s.To, s.From
+			s.LanesForward = segment.LanesBackward
+		}
+		if s.OneWay {
+			s.LanesBackward = 0
+		}
+		g.Segments = append(g.Segments, s)
+	}
+	slices.SortFunc(g.Segments, func(a, b roadgraph.Segment) int { return cmp.Compare(a.ID, b.ID) })
+	return g
+}
+
+// FromGraph builds a grid from a road graph. The grid takes the graph's ID
+// when it is a KSUID and the nil KSUID otherwise. Segments without a
+// congestion factor flow freely. Node kinds and segment speeds, capacities
+// and closures have no place on a grid and are dropped; speed limits, one-way
+// roads, lanes and road classes are kept.
+func FromGraph(g *roadgraph.Graph) (*coremodels.Grid, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	grid := &coremodels.Grid{
+		DimX:      int64(math.Ceil(g.WidthKM*1000/metersPerDim - 1e-9)),
+		DimY:      int64(math.Ceil(g.HeightKM*1000/metersPerDim - 1e-9)),
+		Segments:  make(map[int64]*coremodels.RoadSegment, len(g.Segments)),
+		Adjacency: make(map[int64][]int64, len(g.Nodes)),
+		Nodes:     make(map[int64]*coremodels.Node, len(g.Nodes)),
+	}
+	if grid.DimX <= 0 || grid.DimY <= 0 {
+		return nil, fmt.Errorf("road graph extent %gx%g km is empty", g.WidthKM, g.HeightKM)
+	}
+	if id, err := ksuid.Parse(g.ID); err == nil {
+		grid.ID = id
+	}
+
+	for _, node := range g.Nodes {
+		grid.Nodes[node.ID] = &coremodels.Node{ID: node.ID, Pos_X: node.X * 1000, Pos_Y: node.Y * 1000}
+	}
+
+	segments := slices.Clone(g.Segments)
+	slices.SortFunc(segments, func(a, b roadgraph.Segment) int { return cmp.Compare(a.ID, b.ID) })
+	for _, segment := range segments {
+		congestion := segment.CongestionFactor
+		if congestion <= 0 {
+			congestion = 1
+		}
+		grid.Segments[segment.ID] = &coremodels.RoadSegment{
+			ID:               segment.ID,
+			StartNode:        segment.From,
+			EndNode:          segment.To,
+			LengthKM:         segment.LengthKM,
+			CongestionFactor: congestion,
+			LanesForward:     segment.LanesForward,
+			LanesBackward:    segment.LanesBackward,
+			Class:            segment.Class,
+		}
+		if segment.OneWay {
+			grid.Segments[segment.ID].Direction = coremodels.ForwardOnly
+		}
+		if segment.SpeedLimit != nil {
+			grid.Segments[segment.ID].SpeedLimitKPH = float64(*segment.SpeedLimit)
+		}
+		grid.Adjacency[segment.From] = append(grid.Adjacency[segment.From], segment.ID)
+		grid.Adjacency[segment.To] = append(grid.Adjacency[segment.To], segment.ID)
+	}
+	return grid, nil
+}
+
+// speedLimit is a grid's speed limit as the road graph keeps it, in whole
+// kilometres per hour.
+func speedLimit(kph float64) *int64 {
+	if kph <= 0 {
+		return nil
+	}
+	limit := int64(math.Round(kph))
+	return &limit
+}
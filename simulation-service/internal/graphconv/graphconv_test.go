@@ -0,0 +1,189 @@
+package graphconv_test
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"owenvi.com/roadgraph"
+	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/graphconv"
+	"owenvi.com/simsim/internal/gridengine"
+)
+
+// checkGridRoundTrip converts grid to a road graph, through JSON, and back,
+// then writes it as GeoJSON and reads that back.
+func checkGridRoundTrip(t *testing.T, grid *coremodels.Grid) {
+	t.Helper()
+	data, err := json.Marshal(graphconv.ToGraph(grid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g roadgraph.Graph
+	if err := json.Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	converted, err := graphconv.FromGraph(&g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameGrid(t, grid, converted, 1e-6)
+
+	path := filepath.Join(t.TempDir(), "grid.geojson")
+	if err := graphconv.SaveGeoJSON(grid, path, roadgraph.Projection{OriginLon: 13.4, OriginLat: 52.5}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := graphconv.LoadGeoJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Projecting to degrees and back moves nodes by far less than a millimetre.
+	checkSameGrid(t, grid, loaded, 1e-3)
+}
+
+// checkSameGrid fails at the first difference between two grids. Adjacency is
+// compared as the segments each node is an end of: the lattice generators
+// leave some nodes listing segments that do not touch them, which a converted
+// grid does not carry over. Nodes may move by up to within metres.
+func checkSameGrid(t *testing.T, want, got *coremodels.Grid, within float64) {
+	t.Helper()
+	if want.ID != got.ID || want.DimX != got.DimX || want.DimY != got.DimY {
+		t.Fatalf("grid %s (%dx%d) came back as %s (%dx%d)", want.ID, want.DimX, want.DimY, got.ID, got.DimX, got.DimY)
+	}
+	if len(want.Nodes) != len(got.Nodes) || len(want.Segments) != len(got.Segments) {
+		t.Fatalf("%d nodes and %d segments came back as %d and %d",
+			len(want.Nodes), len(want.Segments), len(got.Nodes), len(got.Segments))
+	}
+	for id, node := range want.Nodes {
+		other, exists := got.Nodes[id]
+		if !exists || math.Abs(node.Pos_X-other.Pos_X) > within || math.Abs(node.Pos_Y-other.Pos_Y) > within {
+			t.Fatalf("node %d changed", id)
+		}
+		if !slices.Equal(incidentSegments(want, id), incidentSegments(got, id)) {
+			t.Fatalf("segments at node %d changed", id)
+		}
+	}
+	for id, segment := range want.Segments {
+		if other, exists := got.Segments[id]; !exists || *other != *segment {
+			t.Fatalf("segment %d changed", id)
+		}
+	}
+}
+
+func incidentSegments(grid *coremodels.Grid, nodeID int64) []int64 {
+	var incident []int64
+	for _, segmentID := range grid.Adjacency[nodeID] {
+		if segment, exists := grid.Segments[segmentID]; exists && (segment.StartNode == nodeID || segment.EndNode == nodeID) {
+			incident = append(incident, segmentID)
+		}
+	}
+	slices.Sort(incident)
+	return slices.Compact(incident)
+}
+
+func TestGeneratedGridsRoundTrip(t *testing.T) {
+	for algo := coremodels.Varonoi; algo < coremodels.Imported; algo++ {
+		for seed := int64(1); seed <= 3; seed++ {
+			grid := gridengine.NewGrid(
+				gridengine.WithDimensions(10, 10),
+				gridengine.WithAlgorithm(algo),
+				gridengine.WithSeed(gridengine.SeedFromInt64(seed)),
+			)
+			t.Run(algo.String(), func(t *testing.T) {
+				checkGridRoundTrip(t, grid)
+			})
+		}
+	}
+}
+
+func TestLatticeGridsWithOneWayStreetsRoundTrip(t *testing.T) {
+	for _, algo := range []coremodels.GenerationAlgorithmType{coremodels.LForm, coremodels.Hierarchical, coremodels.Suburban} {
+		grid := gridengine.NewGrid(
+			gridengine.WithDimensions(12, 12),
+			gridengine.WithAlgorithm(algo),
+			gridengine.WithSeed(gridengine.SeedFromInt64(1)),
+			gridengine.WithOneWayStreets(),
+			gridengine.WithDividedArterials(2),
+		)
+		t.Run(algo.String(), func(t *testing.T) {
+			checkGridRoundTrip(t, grid)
+		})
+	}
+}
+
+func TestOSMGridsRoundTrip(t *testing.T) {
+	for _, name := range []string{"town.osm", "town.osm.pbf"} {
+		t.Run(name, func(t *testing.T) {
+			g, err := roadgraph.LoadOSM(filepath.Join("..", "..", "..", "roadgraph", "testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			grid, err := graphconv.FromGraph(g.LargestComponent())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGridRoundTrip(t, grid)
+		})
+	}
+}
+
+// A road graph with free positions, such as one fleetsim exports from a grid
+// it imported, must keep its layout as a grid: node positions, and the ends
+// and lengths of segments. Node kinds and segment speeds, capacities and
+// closures have no place on a grid.
+func TestGraphKeepsItsLayout(t *testing.T) {
+	want := &roadgraph.Graph{
+		WidthKM:  3,
+		HeightKM: 2,
+		Nodes: []roadgraph.Node{
+			{ID: 1, X: 0.1, Y: 0.1},
+			{ID: 2, X: 1.3, Y: 0.2, Kind: roadgraph.KindRefuel},
+			{ID: 3, X: 2.6, Y: 0.4},
+			{ID: 4, X: 2.4, Y: 1.7},
+		},
+		Segments: []roadgraph.Segment{
+			{ID: 10, From: 1, To: 2, LengthKM: 1.2},
+			{ID: 11, From: 2, To: 3, LengthKM: 1.3, OneWay: true},
+			{ID: 12, From: 3, To: 4, LengthKM: 1.3, Closed: true},
+			{ID: 13, From: 4, To: 1, LengthKM: 2.7},
+		},
+	}
+	grid, err := graphconv.FromGraph(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := graphconv.ToGraph(grid)
+
+	if len(want.Nodes) != len(got.Nodes) || len(want.Segments) != len(got.Segments) {
+		t.Fatalf("%d nodes and %d segments came back as %d and %d",
+			len(want.Nodes), len(want.Segments), len(got.Nodes), len(got.Segments))
+	}
+	nodes := make(map[int64]roadgraph.Node, len(got.Nodes))
+	for _, node := range got.Nodes {
+		nodes[node.ID] = node
+	}
+	for _, node := range want.Nodes {
+		other, exists := nodes[node.ID]
+		if !exists || !near(node.X, other.X) || !near(node.Y, other.Y) {
+			t.Errorf("node %d changed", node.ID)
+		}
+	}
+	segments := make(map[int64]roadgraph.Segment, len(got.Segments))
+	for _, segment := range got.Segments {
+		segments[segment.ID] = segment
+	}
+	for _, segment := range want.Segments {
+		other, exists := segments[segment.ID]
+		if !exists || other.From != segment.From || other.To != segment.To || other.LengthKM != segment.LengthKM || other.OneWay != segment.OneWay {
+			t.Errorf("segment %d changed", segment.ID)
+		}
+	}
+}
+
+// near allows for the rounding of converting positions between metres and
+// kilometres.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*max(1, math.Abs(a))
+}
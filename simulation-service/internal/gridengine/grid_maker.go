@@ -3,6 +3,7 @@ package gridengine
 import (
 	"github.com/segmentio/ksuid"
 	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/graphconv"
 )
 
 type GridOption func(*coremodels.GridConfig)
@@ -14,7 +15,9 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 		DimX: 10,
 		DimY: 10,
 		Algo: coremodels.Varonoi,
-		Seed: ksuid.New(),
+		// Without WithSeed every grid comes from the same seed, so it is as
+		// reproducible as one seeded explicitly.
+		Seed: SeedFromInt64(0),
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -30,7 +33,7 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 	}
 
 	r := NewRandFromSeed(*cfg)
-	
+
 	baseParams := BaseParams{
 		BoxWidth:  float64(cfg.DimX) * 100.0,
 		BoxHeight: float64(cfg.DimY) * 100.0,
@@ -46,68 +49,77 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 			Sites:      int(4*(cfg.DimX+cfg.DimY)/2 + 12),
 			K:          5,
 		})
-		
+
 	case coremodels.LForm:
 		GenerateLattice(g, r, LatticeParams{
-			BaseParams:   baseParams,
-			CellSize:    80.0,
-			DeleteProb:   0.1,
-			AddDiagonals: false,
-			TwoWay:       true,
+			BaseParams:    baseParams,
+			CellSize:      80.0,
+			DeleteProb:    0.1,
+			AddDiagonals:  false,
+			TwoWay:        !cfg.OneWayStreets,
+			ArterialEvery: arterialEvery,
+			ArterialLanes: cfg.ArterialLanes,
 		})
-		
+
 		GenerateRadial(g, r, RadialParams{
 			BaseParams:  baseParams,
 			NumRays:     8,
 			NumRings:    2,
 			RingSpacing: 120.0,
 		})
-		
+
 	case coremodels.Space:
 		GenerateSpaceColonization(g, r, SpaceColonizationParams{
-        BaseParams:    BaseParams{
-            BoxWidth:  float64(cfg.DimX) * 80.0,  
-            BoxHeight: float64(cfg.DimY) * 80.0,  
-            CenterX:   float64(cfg.DimX) * 40,    
-            CenterY:   float64(cfg.DimY) * 40,
-            JitterMax: 15.0,
-        },
-		Attractions:   int(float64(cfg.DimX*cfg.DimY) * 0.6), 
-        StepSize:      25,   
-        CaptureRadius: 80,   
-    })
+			BaseParams: BaseParams{
+				BoxWidth:  float64(cfg.DimX) * 80.0,
+				BoxHeight: float64(cfg.DimY) * 80.0,
+				CenterX:   float64(cfg.DimX) * 40,
+				CenterY:   float64(cfg.DimY) * 40,
+				JitterMax: 15.0,
+			},
+			Attractions:   int(float64(cfg.DimX*cfg.DimY) * 0.6),
+			StepSize:      25,
+			CaptureRadius: 80,
+		})
 	case coremodels.CityLike:
-    GenerateRadial(g, r, RadialParams{
-        BaseParams:  baseParams,
-        NumRays:     8,
-        NumRings:    4,
-        RingSpacing: 200.0,
-    })
+		GenerateRadial(g, r, RadialParams{
+			BaseParams:  baseParams,
+			NumRays:     8,
+			NumRings:    4,
+			RingSpacing: 200.0,
+		})
 	case coremodels.Hierarchical:
-    
-    GenerateLattice(g, r, LatticeParams{
-        BaseParams:   baseParams,
-        CellSize:     200.0,  
-        DeleteProb:   0.1,    
-        AddDiagonals: false,
-    })
-
-    
-    
-    GenerateLattice(g, r, LatticeParams{
-        BaseParams:   baseParams,
-        CellSize:    60.0, 
-        DeleteProb:   0.3,    
-        AddDiagonals: true,
-    })
+
+		GenerateLattice(g, r, LatticeParams{
+			BaseParams:    baseParams,
+			CellSize:      200.0,
+			DeleteProb:    0.1,
+			AddDiagonals:  false,
+			TwoWay:        !cfg.OneWayStreets,
+			ArterialEvery: arterialEvery,
+			ArterialLanes: cfg.ArterialLanes,
+		})
+
+		GenerateLattice(g, r, LatticeParams{
+			BaseParams:    baseParams,
+			CellSize:      60.0,
+			DeleteProb:    0.3,
+			AddDiagonals:  true,
+			TwoWay:        !cfg.OneWayStreets,
+			ArterialEvery: arterialEvery,
+			ArterialLanes: cfg.ArterialLanes,
+		})
 	case coremodels.Suburban:
-    GenerateLattice(g, r, LatticeParams{
-        BaseParams:   baseParams,
-        CellSize:     80.0,
-        DeleteProb:   0,  
-        AddDiagonals: false,
-    })
-		
+		GenerateLattice(g, r, LatticeParams{
+			BaseParams:    baseParams,
+			CellSize:      80.0,
+			DeleteProb:    0,
+			AddDiagonals:  false,
+			TwoWay:        !cfg.OneWayStreets,
+			ArterialEvery: arterialEvery,
+			ArterialLanes: cfg.ArterialLanes,
+		})
+
 	case coremodels.Lorenz:
 		GenerateLorenzAttractor(g, r, LorenzAttractorParams{
 			BaseParams: baseParams,
@@ -119,7 +131,7 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 			ScaleX:     10.0,
 			ScaleY:     10.0,
 		})
-		
+
 	case coremodels.LSystem:
 		GenerateLSystem(g, r, LSystemParams{
 			BaseParams: baseParams,
@@ -129,7 +141,7 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 			Angle:      25.0,
 			Length:     20.0,
 		})
-		
+
 	default:
 		GenerateRandom(g, r, RandomParams{
 			BaseParams: baseParams,
@@ -137,10 +149,33 @@ func NewGrid(opts ...GridOption) *coremodels.Grid {
 			ExtraEdges: int(cfg.DimX + cfg.DimY),
 		})
 	}
-	
+
+	openStrandingOneWays(g)
 	return g
 }
 
+// arterialEvery is how many streets apart the lattice generators lay divided
+// arterials.
+const arterialEvery = 4
+
+// openStrandingOneWays opens both ways the one-way segments a vehicle could
+// take and never drive back from, such as those a deleted street leaves
+// leading into a dead end.
+func openStrandingOneWays(g *coremodels.Grid) {
+	directed := false
+	for _, segment := range g.Segments {
+		directed = directed || segment.OneWay()
+	}
+	if !directed {
+		return
+	}
+	for _, segmentID := range graphconv.ToGraph(g).StrandingOneWays() {
+		segment := g.Segments[segmentID]
+		segment.Direction = coremodels.BothWays
+		segment.LanesBackward = segment.LanesForward
+	}
+}
+
 func WithDimensions(x, y int64) GridOption {
 	return func(cfg *coremodels.GridConfig) {
 		cfg.DimX, cfg.DimY = x, y
@@ -157,4 +192,20 @@ func WithSeed(seed ksuid.KSUID) GridOption {
 	return func(cfg *coremodels.GridConfig) {
 		cfg.Seed = seed
 	}
-}
\ No newline at end of file
+}
+
+// WithOneWayStreets makes the streets of lattice grids one-way, alternating
+// direction like a downtown grid.
+func WithOneWayStreets() GridOption {
+	return func(cfg *coremodels.GridConfig) {
+		cfg.OneWayStreets = true
+	}
+}
+
+// WithDividedArterials turns every few streets of lattice grids into divided
+// arterials with the given lanes each way.
+func WithDividedArterials(lanes int) GridOption {
+	return func(cfg *coremodels.GridConfig) {
+		cfg.ArterialLanes = lanes
+	}
+}
@@ -39,7 +39,7 @@ func PlotGridWithVehicles(g *coremodels.Grid, vehicles []*coremodels.Vehicle, fi
 
 	canvas.Def()
 	canvas.Marker("arrowhead", 4, 0, 4, 4, "auto")
-canvas.Path("M 0,0 L 0,4 L 4,2 z", "fill:#666;stroke:#666")
+	canvas.Path("M 0,0 L 0,4 L 4,2 z", "fill:#666;stroke:#666")
 	canvas.MarkerEnd()
 	canvas.DefEnd()
 
@@ -433,7 +433,7 @@ func CreateComparisonView(g *coremodels.Grid, vehicles []*coremodels.Vehicle, fi
 
 	viewWidth := int(contentWidth + padding*2)
 	viewHeight := int(contentHeight + padding*2)
-	canvasWidth := viewWidth * 2 + 40
+	canvasWidth := viewWidth*2 + 40
 	canvasHeight := viewHeight + 60
 
 	canvas := svg.New(f)
@@ -486,7 +486,7 @@ func CreateComparisonView(g *coremodels.Grid, vehicles []*coremodels.Vehicle, fi
 			continue
 		}
 
-		canvasX := int(x + offsetX) + viewWidth + 20
+		canvasX := int(x+offsetX) + viewWidth + 20
 		canvasY := int(y + offsetY)
 
 		color := getVehicleColor(vehicle.Status)
@@ -687,7 +687,7 @@ func getVehicleDirection(vehicle *coremodels.Vehicle, grid *coremodels.Grid) *Di
 }
 
 func CreateAnimatedSequence(grid *coremodels.Grid, vehicles []*coremodels.Vehicle, baseFilename string, frames int) error {
-	router := coremodels.NewVehicleRouter()
+	router := coremodels.NewVehicleRouter(SeedInt64(grid.ID))
 
 	for frame := 0; frame < frames; frame++ {
 		for _, vehicle := range vehicles {
@@ -695,7 +695,7 @@ func CreateAnimatedSequence(grid *coremodels.Grid, vehicles []*coremodels.Vehicl
 				continue
 			}
 
-			vehicle.UpdateProgress(1.0, grid, router)
+			vehicle.UpdateProgress(1.0, grid, router, nil, nil)
 			if vehicle.HasReachedTarget(grid) {
 				vehicle.Status = coremodels.StatusReachedDestination
 				continue
@@ -837,3 +837,125 @@ func getHeatmapColor(intensity float64) string {
 	}
 	return "#ff0000"
 }
+
+// PlotIntersectionControl draws the controlled junctions, shaded by how long
+// each vehicle waited there on average. Signals show which approaches have
+// green right now; stop signs are drawn as squares and give-way junctions as
+// triangles.
+func PlotIntersectionControl(g *coremodels.Grid, control *coremodels.IntersectionControl, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	minX, minY, maxX, maxY := math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
+	for _, n := range g.Nodes {
+		minX = math.Min(minX, n.Pos_X)
+		minY = math.Min(minY, n.Pos_Y)
+		maxX = math.Max(maxX, n.Pos_X)
+		maxY = math.Max(maxY, n.Pos_Y)
+	}
+
+	padding := 60.0
+	canvasWidth := int(maxX - minX + padding*2)
+	canvasHeight := int(maxY - minY + padding*2)
+
+	canvas := svg.New(f)
+	canvas.Start(canvasWidth, canvasHeight)
+	canvas.Rect(0, 0, canvasWidth, canvasHeight, "fill:#f0f0f0")
+
+	offsetX := padding - minX
+	offsetY := padding - minY
+
+	for _, seg := range g.Segments {
+		n1, ok1 := g.Nodes[seg.StartNode]
+		n2, ok2 := g.Nodes[seg.EndNode]
+		if !ok1 || !ok2 {
+			continue
+		}
+		canvas.Line(int(n1.Pos_X+offsetX), int(n1.Pos_Y+offsetY), int(n2.Pos_X+offsetX), int(n2.Pos_Y+offsetY),
+			"stroke:#bbb;stroke-width:4;stroke-linecap:round")
+	}
+
+	averageWait := make(map[int64]float64)
+	maxWait := 0.0
+	for _, wait := range control.Waits() {
+		if vehicles := wait.Crossings + wait.Queued; vehicles > 0 {
+			averageWait[wait.NodeID] = wait.WaitSeconds / float64(vehicles)
+			maxWait = math.Max(maxWait, averageWait[wait.NodeID])
+		}
+	}
+
+	const stubLength = 14.0
+	for _, nodeID := range control.ControlledNodes() {
+		n, exists := g.Nodes[nodeID]
+		if !exists {
+			continue
+		}
+		plan := control.Plan(nodeID)
+		x := n.Pos_X + offsetX
+		y := n.Pos_Y + offsetY
+
+		if plan.Control == coremodels.FixedTimeSignal || plan.Control == coremodels.ActuatedSignal {
+			for _, phase := range plan.Phases {
+				for _, segmentID := range phase.Approaches {
+					from, exists := g.Nodes[OtherNode(g.Segments[segmentID], nodeID)]
+					if !exists {
+						continue
+					}
+					dx, dy := from.Pos_X-n.Pos_X, from.Pos_Y-n.Pos_Y
+					length := math.Hypot(dx, dy)
+					if length == 0 {
+						continue
+					}
+					color := "#dc3545"
+					if control.ShowsGreen(nodeID, segmentID) {
+						color = "#28a745"
+					}
+					canvas.Line(int(x), int(y), int(x+dx/length*stubLength), int(y+dy/length*stubLength),
+						fmt.Sprintf("stroke:%s;stroke-width:4;stroke-linecap:round", color))
+				}
+			}
+		}
+
+		intensity := 0.0
+		if maxWait > 0 {
+			intensity = averageWait[nodeID] / maxWait
+		}
+		style := fmt.Sprintf("fill:%s;stroke:#333;stroke-width:1", getHeatmapColor(intensity))
+		radius := 5 + int(intensity*6)
+		switch plan.Control {
+		case coremodels.AllWayStop:
+			canvas.Rect(int(x)-radius, int(y)-radius, radius*2, radius*2, style)
+		case coremodels.PriorityControl:
+			canvas.Polygon([]int{int(x) - radius, int(x) + radius, int(x)},
+				[]int{int(y) - radius, int(y) - radius, int(y) + radius}, style)
+		default:
+			canvas.Circle(int(x), int(y), radius, style)
+		}
+
+		if wait := averageWait[nodeID]; wait >= 1 {
+			canvas.Text(int(x), int(y)-radius-4, fmt.Sprintf("%.0fs", wait),
+				"font-family:Arial;font-size:9px;fill:#333;text-anchor:middle")
+		}
+	}
+
+	canvas.Text(canvasWidth/2, 30, "Intersection Waiting Time",
+		"font-family:Arial;font-size:16px;fill:#333;text-anchor:middle;font-weight:bold")
+
+	canvas.Rect(10, 35, 150, 110, "fill:white;stroke:#ccc;stroke-width:1;opacity:0.9")
+	canvas.Circle(20, 50, 5, "fill:#cccccc;stroke:#333;stroke-width:1")
+	canvas.Text(35, 53, "Signal", "font-family:Arial;font-size:9px;fill:#333")
+	canvas.Rect(15, 65, 10, 10, "fill:#cccccc;stroke:#333;stroke-width:1")
+	canvas.Text(35, 73, "All-way stop", "font-family:Arial;font-size:9px;fill:#333")
+	canvas.Polygon([]int{15, 25, 20}, []int{85, 85, 95}, "fill:#cccccc;stroke:#333;stroke-width:1")
+	canvas.Text(35, 93, "Give way to major road", "font-family:Arial;font-size:9px;fill:#333")
+	canvas.Line(15, 110, 25, 110, "stroke:#28a745;stroke-width:4")
+	canvas.Text(35, 113, "Green approach", "font-family:Arial;font-size:9px;fill:#333")
+	canvas.Circle(20, 130, 5, "fill:#ff0000;stroke:#333;stroke-width:1")
+	canvas.Text(35, 133, "Longest average wait", "font-family:Arial;font-size:9px;fill:#333")
+
+	canvas.End()
+	return nil
+}
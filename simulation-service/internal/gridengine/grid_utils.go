@@ -4,16 +4,22 @@ import (
 	"encoding/binary"
 	"math"
 	"math/rand"
+	"sort"
+	"time"
 
+	"github.com/segmentio/ksuid"
 	"owenvi.com/simsim/internal/coremodels"
 )
 
+// seedEpoch pins the KSUID timestamp so an integer seed always maps to the same grid ID.
+const seedEpoch = 1700000000
+
 type BaseParams struct {
-	BoxWidth   float64
-	BoxHeight  float64
-	CenterX    float64
-	CenterY    float64
-	JitterMax  float64
+	BoxWidth  float64
+	BoxHeight float64
+	CenterX   float64
+	CenterY   float64
+	JitterMax float64
 }
 
 type NodeSegmentCounters struct {
@@ -39,7 +45,7 @@ type Point struct {
 func NewSpatialGrid(points []Point, cellSize float64, bounds BaseParams) *SpatialGrid {
 	cols := int(bounds.BoxWidth/cellSize) + 1
 	rows := int(bounds.BoxHeight/cellSize) + 1
-	
+
 	grid := &SpatialGrid{
 		cells:    make([][][]int, rows),
 		cellSize: cellSize,
@@ -48,11 +54,11 @@ func NewSpatialGrid(points []Point, cellSize float64, bounds BaseParams) *Spatia
 		minX:     bounds.CenterX - bounds.BoxWidth/2,
 		minY:     bounds.CenterY - bounds.BoxHeight/2,
 	}
-	
+
 	for i := range grid.cells {
 		grid.cells[i] = make([][]int, cols)
 	}
-	
+
 	grid.UpdatePoints(points)
 	return grid
 }
@@ -63,7 +69,7 @@ func (sg *SpatialGrid) UpdatePoints(points []Point) {
 			sg.cells[i][j] = sg.cells[i][j][:0]
 		}
 	}
-	
+
 	for _, p := range points {
 		if !p.Alive {
 			continue
@@ -81,7 +87,7 @@ func (sg *SpatialGrid) QueryRadius(x, y, radius float64) []int {
 	maxGx := min(sg.cols-1, int((x+radius-sg.minX)/sg.cellSize))
 	minGy := max(0, int((y-radius-sg.minY)/sg.cellSize))
 	maxGy := min(sg.rows-1, int((y+radius-sg.minY)/sg.cellSize))
-	
+
 	var result []int
 	for gy := minGy; gy <= maxGy; gy++ {
 		for gx := minGx; gx <= maxGx; gx++ {
@@ -94,26 +100,26 @@ func (sg *SpatialGrid) QueryRadius(x, y, radius float64) []int {
 func ReverseClosestLookup(sources []Point, targets []*coremodels.Node, maxDist float64) map[int]int64 {
 	result := make(map[int]int64)
 	maxDist2 := maxDist * maxDist
-	
+
 	for _, src := range sources {
 		if !src.Alive {
 			continue
 		}
-		
+
 		closest := int64(-1)
 		best2 := maxDist2
-		
+
 		for nodeID, target := range targets {
 			dx := src.X - target.Pos_X
 			dy := src.Y - target.Pos_Y
 			d2 := dx*dx + dy*dy
-			
+
 			if d2 < best2 {
 				best2 = d2
 				closest = int64(nodeID)
 			}
 		}
-		
+
 		if closest != -1 {
 			result[src.Index] = closest
 		}
@@ -151,12 +157,21 @@ func AddSegmentWithCounter(g *coremodels.Grid, from, to int64, congestion float6
 	counter.NextSeg++
 }
 
+// AddOneWaySegmentWithCounter adds a segment that may only be driven from
+// from to to, over the given number of lanes.
+func AddOneWaySegmentWithCounter(g *coremodels.Grid, from, to int64, congestion float64, lanes int, counter *NodeSegmentCounters) {
+	AddSegmentWithCounter(g, from, to, congestion, counter)
+	segment := g.Segments[counter.NextSeg-1]
+	segment.Direction = coremodels.ForwardOnly
+	segment.LanesForward = lanes
+}
+
 func AddNodesGrid(g *coremodels.Grid, rows, cols int64, cellSize, jitters float64, r *rand.Rand, counter *NodeSegmentCounters) [][]int64 {
 	nodeGrid := make([][]int64, rows)
 	for i := range nodeGrid {
 		nodeGrid[i] = make([]int64, cols)
 	}
-	
+
 	for y := int64(0); y < rows; y++ {
 		for x := int64(0); x < cols; x++ {
 			px := jitter(r, float64(x)*cellSize, jitters)
@@ -169,9 +184,9 @@ func AddNodesGrid(g *coremodels.Grid, rows, cols int64, cellSize, jitters float6
 
 func AddNodesRadial(g *coremodels.Grid, centerX, centerY float64, numRays, numRings int, ringSpacing, rayJitter float64, r *rand.Rand, counter *NodeSegmentCounters) (int64, [][]int64) {
 	centerIdx := AddNodeWithCounter(g, centerX, centerY, counter)
-	
+
 	ringNodes := make([][]int64, numRings)
-	
+
 	for i := 0; i < numRays; i++ {
 		theta := (2 * math.Pi * float64(i)) / float64(numRays)
 		for ring := 1; ring <= numRings; ring++ {
@@ -188,12 +203,29 @@ func AddNodesRadial(g *coremodels.Grid, centerX, centerY float64, numRays, numRi
 }
 
 func NewRandFromSeed(seed coremodels.GridConfig) *rand.Rand {
+	return rand.New(rand.NewSource(SeedInt64(seed.Seed)))
+}
+
+// SeedInt64 is the integer a KSUID seed stands for; it is what every seeded
+// source in a run (grid, spawner, router) is built from. It inverts
+// SeedFromInt64.
+func SeedInt64(seed ksuid.KSUID) int64 {
 	var s uint64
-	idBytes := seed.Seed.Bytes()
+	idBytes := seed.Bytes()
 	if len(idBytes) >= 8 {
 		s = binary.BigEndian.Uint64(idBytes[len(idBytes)-8:])
 	}
-	return rand.New(rand.NewSource(int64(s)))
+	return int64(s)
+}
+
+func SeedFromInt64(seed int64) ksuid.KSUID {
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload[8:], uint64(seed))
+	id, err := ksuid.FromParts(time.Unix(seedEpoch, 0), payload)
+	if err != nil {
+		return ksuid.Nil
+	}
+	return id
 }
 
 func addNode(g *coremodels.Grid, x, y float64, nextNode *int64) int64 {
@@ -249,12 +281,16 @@ func clamp(v, lo, hi float64) float64 {
 }
 
 func max(a, b int) int {
-	if a > b { return a }
+	if a > b {
+		return a
+	}
 	return b
 }
 
 func min(a, b int) int {
-	if a < b { return a }
+	if a < b {
+		return a
+	}
 	return b
 }
 
@@ -262,6 +298,17 @@ func isBoundary(x, y, maxX, maxY int64) bool {
 	return x == 0 || y == 0 || x == maxX || y == maxY
 }
 
+// SortedNodeIDs lists the grid's node IDs in ascending order, for generators
+// that must walk every node without depending on map order.
+func SortedNodeIDs(g *coremodels.Grid) []int64 {
+	ids := make([]int64, 0, len(g.Nodes))
+	for nodeID := range g.Nodes {
+		ids = append(ids, nodeID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 func NeighborNodes(g *coremodels.Grid, nodeID int64) []int64 {
 	segIDs := g.Adjacency[nodeID]
 	if len(segIDs) == 0 {
@@ -321,4 +368,4 @@ func RandomNeighbor(g *coremodels.Grid, nodeID int64, randFn func(int) int) (int
 	idx := randFn(len(segIDs))
 	seg := g.Segments[segIDs[idx]]
 	return OtherNode(seg, nodeID), true
-}
\ No newline at end of file
+}
@@ -0,0 +1,111 @@
+package simengine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"owenvi.com/roadgraph/simclock"
+	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/gridengine"
+	"owenvi.com/simsim/internal/vehicleengine"
+)
+
+// scenario is a seeded world and the rules it runs under.
+type scenario struct {
+	algo         coremodels.GenerationAlgorithmType
+	dim          int64
+	seed         int64
+	vehicles     int
+	routing      coremodels.RoutingMode
+	control      coremodels.ControlKind
+	carFollowing bool
+	oneWay       bool
+}
+
+// newScenarioSimulation builds s the way simsim simulate does.
+func newScenarioSimulation(t testing.TB, s scenario, workers int) *Simulation {
+	t.Helper()
+	opts := []gridengine.GridOption{
+		gridengine.WithDimensions(s.dim, s.dim),
+		gridengine.WithAlgorithm(s.algo),
+		gridengine.WithSeed(gridengine.SeedFromInt64(s.seed)),
+	}
+	if s.oneWay {
+		opts = append(opts, gridengine.WithOneWayStreets())
+	}
+	grid := gridengine.NewGrid(opts...)
+	if len(grid.Segments) == 0 {
+		t.Fatalf("%v grid with seed %d has no segments", s.algo, s.seed)
+	}
+
+	clock := simclock.NewAsFastAsPossible(grid.ID.Time())
+	vehicles, err := vehicleengine.NewVehicleSpawner(grid, gridengine.SeedInt64(grid.ID), clock).SpawnMultipleVehicles(s.vehicles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := coremodels.NewVehicleRouter(gridengine.SeedInt64(grid.ID))
+	router.Mode = s.routing
+	if s.routing == coremodels.RoutingPathPlanning {
+		router.ExplorationRate = 0
+	}
+
+	simOpts := []SimulationOption{WithClock(clock), WithRouter(router), WithWorkers(workers)}
+	if s.control != coremodels.Uncontrolled {
+		simOpts = append(simOpts, WithIntersectionControl(coremodels.NewIntersectionControl(grid, s.control)))
+	}
+	if s.carFollowing {
+		simOpts = append(simOpts, WithCarFollowing())
+	}
+	return NewSimulation(grid, vehicles, simOpts...)
+}
+
+// replay runs s for steps and fingerprints the grid as generated and every
+// vehicle's segment, progress and status after each step.
+func replay(t testing.TB, s scenario, steps, workers int) (grid, trajectories string) {
+	t.Helper()
+	sim := newScenarioSimulation(t, s, workers)
+	gridJSON, err := json.Marshal(sim.Grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gridHash := sha256.Sum256(gridJSON)
+
+	h := sha256.New()
+	err = sim.Run(steps, nil, func(step int, sim *Simulation) error {
+		for _, v := range sim.Vehicles {
+			fmt.Fprintf(h, "%d %s %d %x %d %x %x %s\n",
+				step, v.ID, v.CurrentSegmentID, v.SegmentProgress, v.TravelDirection, v.CurrentSpeedKPH, v.TotalDistanceKM, v.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(gridHash[:]), hex.EncodeToString(h.Sum(nil))
+}
+
+var replayScenarios = map[string]scenario{
+	"greedy": {algo: coremodels.Varonoi, dim: 12, seed: 7, vehicles: 30},
+	"path planning with signals": {algo: coremodels.Suburban, dim: 12, seed: 7, vehicles: 30,
+		routing: coremodels.RoutingPathPlanning, control: coremodels.ActuatedSignal},
+	"car following on one-way streets": {algo: coremodels.Hierarchical, dim: 12, seed: 3, vehicles: 30,
+		routing: coremodels.RoutingPathPlanning, control: coremodels.AllWayStop, carFollowing: true, oneWay: true},
+}
+
+func TestSameSeedReplaysIdentically(t *testing.T) {
+	for name, s := range replayScenarios {
+		t.Run(name, func(t *testing.T) {
+			firstGrid, firstRun := replay(t, s, 300, 1)
+			secondGrid, secondRun := replay(t, s, 300, 1)
+			if firstGrid != secondGrid {
+				t.Error("grids generated from the same seed differ")
+			}
+			if firstRun != secondRun {
+				t.Error("trajectories from the same seed differ")
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package simengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/gridengine"
+)
+
+type SummaryReport struct {
+	GridID    string `json:"grid_id"`
+	Algorithm string `json:"algorithm"`
+	DimX      int64  `json:"dim_x"`
+	DimY      int64  `json:"dim_y"`
+	Nodes     int    `json:"nodes"`
+	Segments  int    `json:"segments"`
+
+	Vehicles         int            `json:"vehicles"`
+	StepsRun         int            `json:"steps_run"`
+	TimeStepSeconds  float64        `json:"time_step_seconds"`
+	SimulatedSeconds float64        `json:"simulated_seconds"`
+	WallTimeMs       int64          `json:"wall_time_ms"`
+	StatusCounts     map[string]int `json:"status_counts"`
+	StepErrors       int            `json:"step_errors"`
+
+	TotalDistanceKM      float64 `json:"total_distance_km"`
+	AverageDistanceKM    float64 `json:"average_distance_km"`
+	AverageSpeedKPH      float64 `json:"average_speed_kph"`
+	IntersectionsCrossed int     `json:"intersections_crossed"`
+	RouteChanges         int     `json:"route_changes"`
+	StuckVehicles        int     `json:"stuck_vehicles"`
+
+	MovementsGranted int            `json:"movements_granted"`
+	MovementDenials  map[string]int `json:"movement_denials,omitempty"`
+
+	// ControlledIntersections is how many junctions have signals or stop or
+	// give-way rules. Waiting time covers vehicles still queued at their stop
+	// lines, and the average is per vehicle that crossed or waits.
+	ControlledIntersections        int                           `json:"controlled_intersections,omitempty"`
+	IntersectionWaitSeconds        float64                       `json:"intersection_wait_seconds,omitempty"`
+	AverageIntersectionWaitSeconds float64                       `json:"average_intersection_wait_seconds,omitempty"`
+	LongestIntersectionWaits       []coremodels.IntersectionWait `json:"longest_intersection_waits,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// longestWaitsReported is how many of the junctions vehicles waited longest
+// at a report lists.
+const longestWaitsReported = 5
+
+func (sim *Simulation) Report(algo coremodels.GenerationAlgorithmType) SummaryReport {
+	report := SummaryReport{
+		GridID:           sim.Grid.ID.String(),
+		Algorithm:        algo.String(),
+		DimX:             sim.Grid.DimX,
+		DimY:             sim.Grid.DimY,
+		Nodes:            len(sim.Grid.Nodes),
+		Segments:         len(sim.Grid.Segments),
+		Vehicles:         len(sim.Vehicles),
+		StepsRun:         sim.StepsRun,
+		TimeStepSeconds:  sim.TimeStepSeconds,
+		SimulatedSeconds: sim.SimulatedSeconds(),
+		WallTimeMs:       sim.WallTime().Milliseconds(),
+		StatusCounts:     make(map[string]int),
+		StepErrors:       sim.StepErrors,
+		Warnings:         gridengine.ValidateVisualization(sim.Grid, sim.Vehicles),
+	}
+
+	speedTotal := 0.0
+	speedCount := 0
+	for _, vehicle := range sim.Vehicles {
+		report.StatusCounts[vehicle.Status.String()]++
+		report.TotalDistanceKM += vehicle.TotalDistanceKM
+		report.IntersectionsCrossed += vehicle.IntersectionsCrossed
+		report.RouteChanges += vehicle.RouteChanges
+		if vehicle.IsStuck() {
+			report.StuckVehicles++
+		}
+		if vehicle.AverageSpeedKPH > 0 {
+			speedTotal += vehicle.AverageSpeedKPH
+			speedCount++
+		}
+	}
+
+	if sim.Arbiter != nil {
+		report.MovementsGranted = sim.Arbiter.Granted
+		report.MovementDenials = make(map[string]int, len(sim.Arbiter.Denials))
+		for reason, count := range sim.Arbiter.Denials {
+			report.MovementDenials[reason] = count
+		}
+	}
+
+	if sim.Control != nil {
+		waits := sim.Control.Waits()
+		report.ControlledIntersections = len(waits)
+		vehiclesWaited := 0
+		for _, wait := range waits {
+			report.IntersectionWaitSeconds += wait.WaitSeconds
+			vehiclesWaited += wait.Crossings + wait.Queued
+		}
+		if vehiclesWaited > 0 {
+			report.AverageIntersectionWaitSeconds = report.IntersectionWaitSeconds / float64(vehiclesWaited)
+		}
+		report.LongestIntersectionWaits = waits[:min(len(waits), longestWaitsReported)]
+	}
+
+	if len(sim.Vehicles) > 0 {
+		report.AverageDistanceKM = report.TotalDistanceKM / float64(len(sim.Vehicles))
+	}
+	if speedCount > 0 {
+		report.AverageSpeedKPH = speedTotal / float64(speedCount)
+	}
+
+	return report
+}
+
+func (r SummaryReport) WriteJSON(filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", filename, err)
+	}
+	return nil
+}
+
+func (r SummaryReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "=== Simulation Summary ===\n")
+	fmt.Fprintf(w, "Grid %s (%s, %dx%d): %d nodes, %d segments\n",
+		r.GridID, r.Algorithm, r.DimX, r.DimY, r.Nodes, r.Segments)
+	fmt.Fprintf(w, "Ran %d steps of %.2fs (%.0fs simulated) in %d ms\n",
+		r.StepsRun, r.TimeStepSeconds, r.SimulatedSeconds, r.WallTimeMs)
+	fmt.Fprintf(w, "Vehicles: %d\n", r.Vehicles)
+	statuses := make([]string, 0, len(r.StatusCounts))
+	for status := range r.StatusCounts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %s: %d\n", status, r.StatusCounts[status])
+	}
+	fmt.Fprintf(w, "Distance: %.2f km total, %.2f km per vehicle\n", r.TotalDistanceKM, r.AverageDistanceKM)
+	fmt.Fprintf(w, "Average speed: %.1f km/h\n", r.AverageSpeedKPH)
+	fmt.Fprintf(w, "Intersections crossed: %d, route changes: %d, stuck: %d\n",
+		r.IntersectionsCrossed, r.RouteChanges, r.StuckVehicles)
+	if len(r.MovementDenials) > 0 {
+		reasons := make([]string, 0, len(r.MovementDenials))
+		for reason := range r.MovementDenials {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		fmt.Fprintf(w, "Movement requests: %d granted, denied:", r.MovementsGranted)
+		for _, reason := range reasons {
+			fmt.Fprintf(w, " %s %d", reason, r.MovementDenials[reason])
+		}
+		fmt.Fprintln(w)
+	}
+	if r.ControlledIntersections > 0 {
+		fmt.Fprintf(w, "Intersection control: %d junctions, %.0fs waiting, %.1fs per crossing\n",
+			r.ControlledIntersections, r.IntersectionWaitSeconds, r.AverageIntersectionWaitSeconds)
+		for _, wait := range r.LongestIntersectionWaits {
+			fmt.Fprintf(w, "  node %d (%s): %.0fs over %d crossings, %d queued\n",
+				wait.NodeID, wait.Control, wait.WaitSeconds, wait.Crossings, wait.Queued)
+		}
+	}
+	if r.StepErrors > 0 {
+		fmt.Fprintf(w, "Step errors: %d\n", r.StepErrors)
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,93 @@
+package simengine
+
+import (
+	"sync"
+
+	"owenvi.com/simsim/internal/coremodels"
+)
+
+// minVehiclesPerShard keeps shards large enough that handing one to a worker
+// costs less than moving its vehicles.
+const minVehiclesPerShard = 64
+
+func (sim *Simulation) movingVehicles() []*coremodels.Vehicle {
+	moving := make([]*coremodels.Vehicle, 0, len(sim.Vehicles))
+	for _, vehicle := range sim.Vehicles {
+		if vehicle.Status == coremodels.StatusMoving {
+			moving = append(moving, vehicle)
+		}
+	}
+	return moving
+}
+
+func (sim *Simulation) shardCount(vehicles int) int {
+	return max(min(sim.Workers, vehicles/minVehiclesPerShard), 1)
+}
+
+// stepSharded runs one step in three phases. Workers advance every vehicle
+// along its segment, which touches nothing but the vehicle; under car
+// following each reads only where its leader was before the step. Vehicles
+// that reached the end of their segment then cross the intersection one by
+// one in slice order, so the router's exploration draws and the decisions of
+// the arbiter and the intersection control happen in the same order as in
+// the serial loop. Finally workers sample speeds and positions again. The
+// vehicles end up exactly where the serial loop would leave them.
+func (sim *Simulation) stepSharded(moving []*coremodels.Vehicle, leaders map[*coremodels.Vehicle]coremodels.Leader) {
+	shards := sim.shardCount(len(moving))
+	atEnd := make([]bool, len(moving))
+	failed := make([]bool, len(moving))
+
+	forEachShard(len(moving), shards, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			end, err := sim.advance(moving[i], leaders)
+			atEnd[i], failed[i] = end, err != nil
+		}
+	})
+
+	for i, vehicle := range moving {
+		switch {
+		case failed[i]:
+			vehicle.Status = coremodels.StatusError
+			sim.StepErrors++
+		case atEnd[i]:
+			vehicle.CrossIntersection(sim.Grid, sim.Router, sim.Arbiter, sim.Control)
+		}
+	}
+
+	forEachShard(len(moving), shards, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			if failed[i] {
+				continue
+			}
+			vehicle := moving[i]
+			vehicle.UpdateAverageSpeed()
+			if vehicle.Status == coremodels.StatusMoving && vehicle.HasReachedTarget(sim.Grid) {
+				vehicle.Status = coremodels.StatusReachedDestination
+			}
+			_, _, _ = vehicle.GetCurrentPosition(sim.Grid)
+		}
+	})
+
+	sim.releaseStopped(moving)
+}
+
+// forEachShard splits n items into shards contiguous ranges and runs fn on
+// each in its own goroutine, returning once all of them are done.
+func forEachShard(n, shards int, fn func(lo, hi int)) {
+	if shards <= 1 {
+		fn(0, n)
+		return
+	}
+
+	var wg sync.WaitGroup
+	size := (n + shards - 1) / shards
+	for lo := 0; lo < n; lo += size {
+		hi := min(lo+size, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,46 @@
+package simengine
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The sharded step must leave every vehicle exactly where the serial loop
+// does. Run with -race to check the workers for data races as well.
+func TestShardedStepMatchesSerial(t *testing.T) {
+	for name, s := range replayScenarios {
+		t.Run(name, func(t *testing.T) {
+			// Enough vehicles for four shards.
+			s.dim, s.vehicles = 20, 4*minVehiclesPerShard
+			_, serial := replay(t, s, 200, 1)
+			for _, workers := range []int{2, 4} {
+				if _, sharded := replay(t, s, 200, workers); sharded != serial {
+					t.Errorf("trajectories with %d workers differ from the serial loop", workers)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	for name, s := range replayScenarios {
+		s.dim, s.vehicles = 40, 3000
+		for _, workers := range []int{1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(b *testing.B) {
+				var sim *Simulation
+				for i := range b.N {
+					// Vehicles finish their trips, so the fleet is respawned
+					// every few hundred steps to keep the load steady.
+					if i%benchmarkStepsPerFleet == 0 {
+						b.StopTimer()
+						sim = newScenarioSimulation(b, s, workers)
+						b.StartTimer()
+					}
+					sim.Step()
+				}
+			})
+		}
+	}
+}
+
+const benchmarkStepsPerFleet = 200
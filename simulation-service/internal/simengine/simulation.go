@@ -0,0 +1,258 @@
+package simengine
+
+import (
+	"fmt"
+	"time"
+
+	"owenvi.com/roadgraph/simclock"
+	"owenvi.com/simsim/internal/coremodels"
+	"owenvi.com/simsim/internal/gridengine"
+)
+
+type StepHook func(step int, sim *Simulation) error
+
+type Simulation struct {
+	Grid     *coremodels.Grid
+	Vehicles []*coremodels.Vehicle
+	Router   *coremodels.VehicleRouter
+	// Arbiter grants or denies every move onto a new segment. Without one
+	// every move is granted.
+	Arbiter *coremodels.MovementArbiter
+	// Control holds vehicles at junctions until their signal or the right of
+	// way lets them cross. Without it every junction is uncontrolled.
+	Control *coremodels.IntersectionControl
+	// CarFollowing moves vehicles under the car-following model, speeding up
+	// and braking behind the vehicle ahead, rather than at their effective
+	// speed from the start.
+	CarFollowing bool
+	// Clock is the simulation's time. Every vehicle keeps time by it and each
+	// step advances it by TimeStepSeconds.
+	Clock simclock.Clock
+
+	TimeStepSeconds float64
+	StepsRun        int
+	StepErrors      int
+	// Workers is how many goroutines share each step; results are the same
+	// for any count.
+	Workers int
+
+	startedAt time.Time
+}
+
+type SimulationOption func(*Simulation)
+
+func NewSimulation(grid *coremodels.Grid, vehicles []*coremodels.Vehicle, opts ...SimulationOption) *Simulation {
+	sim := &Simulation{
+		Grid:            grid,
+		Vehicles:        vehicles,
+		Router:          coremodels.NewVehicleRouter(gridengine.SeedInt64(grid.ID)),
+		Arbiter:         coremodels.NewMovementArbiter(),
+		TimeStepSeconds: 1.0,
+		Workers:         1,
+	}
+	for _, opt := range opts {
+		opt(sim)
+	}
+	if sim.Clock == nil {
+		sim.Clock = simclock.NewAsFastAsPossible(grid.ID.Time())
+	}
+	// Routes steer clear of the segments the arbiter has blocked.
+	if sim.Arbiter != nil && sim.Router.Closures == nil {
+		sim.Router.Closures = sim.Arbiter
+	}
+	if sim.Router.Mode == coremodels.RoutingPathPlanning && (sim.Router.Index == nil || sim.Router.Index.Grid() != grid) {
+		sim.Router.BuildIndex(grid)
+	}
+	for _, vehicle := range vehicles {
+		vehicle.Clock = sim.Clock
+		if vehicle.Status == coremodels.StatusMoving || vehicle.Status == coremodels.StatusWaitingForPermission {
+			sim.Arbiter.Place(vehicle)
+		}
+	}
+	return sim
+}
+
+// WithClock runs the simulation on clock; the default runs as fast as
+// possible from the grid's timestamp.
+func WithClock(clock simclock.Clock) SimulationOption {
+	return func(sim *Simulation) {
+		sim.Clock = clock
+	}
+}
+
+// WithArbiter decides movement requests with arbiter; nil grants them all.
+func WithArbiter(arbiter *coremodels.MovementArbiter) SimulationOption {
+	return func(sim *Simulation) {
+		sim.Arbiter = arbiter
+	}
+}
+
+// WithIntersectionControl controls the grid's junctions with control.
+func WithIntersectionControl(control *coremodels.IntersectionControl) SimulationOption {
+	return func(sim *Simulation) {
+		sim.Control = control
+	}
+}
+
+// WithCarFollowing moves vehicles under the car-following model.
+func WithCarFollowing() SimulationOption {
+	return func(sim *Simulation) {
+		sim.CarFollowing = true
+	}
+}
+
+func WithRouter(router *coremodels.VehicleRouter) SimulationOption {
+	return func(sim *Simulation) {
+		sim.Router = router
+	}
+}
+
+// WithWorkers spreads each step over up to workers goroutines.
+func WithWorkers(workers int) SimulationOption {
+	return func(sim *Simulation) {
+		if workers > 0 {
+			sim.Workers = workers
+		}
+	}
+}
+
+func WithTimeStep(seconds float64) SimulationOption {
+	return func(sim *Simulation) {
+		if seconds > 0 {
+			sim.TimeStepSeconds = seconds
+		}
+	}
+}
+
+func (sim *Simulation) Step() {
+	if sim.startedAt.IsZero() {
+		sim.startedAt = time.Now()
+	}
+	sim.Clock.Advance(sim.step())
+	sim.Control.Advance(sim.TimeStepSeconds)
+
+	// Every vehicle follows its leader as it was before anyone moved.
+	var leaders map[*coremodels.Vehicle]coremodels.Leader
+	if sim.CarFollowing {
+		leaders = coremodels.FindLeaders(sim.Vehicles, sim.Grid, sim.Control)
+	}
+
+	if sim.Workers > 1 {
+		if moving := sim.movingVehicles(); sim.shardCount(len(moving)) > 1 {
+			sim.stepSharded(moving, leaders)
+			sim.StepsRun++
+			return
+		}
+	}
+
+	moved := make([]*coremodels.Vehicle, 0, len(sim.Vehicles))
+	for _, vehicle := range sim.Vehicles {
+		if vehicle.Status != coremodels.StatusMoving {
+			continue
+		}
+		moved = append(moved, vehicle)
+
+		atEnd, err := sim.advance(vehicle, leaders)
+		if err != nil {
+			vehicle.Status = coremodels.StatusError
+			sim.StepErrors++
+			continue
+		}
+		if atEnd {
+			vehicle.CrossIntersection(sim.Grid, sim.Router, sim.Arbiter, sim.Control)
+		}
+		vehicle.UpdateAverageSpeed()
+
+		if vehicle.Status == coremodels.StatusMoving && vehicle.HasReachedTarget(sim.Grid) {
+			vehicle.Status = coremodels.StatusReachedDestination
+		}
+
+		// Sampling the position also feeds the vehicle's trail for rendering.
+		_, _, _ = vehicle.GetCurrentPosition(sim.Grid)
+	}
+	sim.releaseStopped(moved)
+
+	sim.StepsRun++
+}
+
+// Run advances the simulation until maxSteps is reached, every vehicle has
+// stopped moving, or stop is closed. The hook runs after each step.
+func (sim *Simulation) Run(maxSteps int, stop <-chan struct{}, hook StepHook) error {
+	for step := 0; step < maxSteps; step++ {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		sim.Step()
+
+		if wait := sim.Clock.Wait(sim.step()); wait > 0 {
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(wait):
+			}
+		}
+
+		if hook != nil {
+			if err := hook(sim.StepsRun, sim); err != nil {
+				return fmt.Errorf("step hook failed at step %d: %w", sim.StepsRun, err)
+			}
+		}
+
+		if sim.ActiveVehicleCount() == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// advance moves the vehicle along its segment, behind its leader when the
+// car-following model is on, and reports whether it reached the end.
+func (sim *Simulation) advance(vehicle *coremodels.Vehicle, leaders map[*coremodels.Vehicle]coremodels.Leader) (bool, error) {
+	if !sim.CarFollowing {
+		return vehicle.AdvanceOnSegment(sim.TimeStepSeconds, sim.Grid)
+	}
+	var leader *coremodels.Leader
+	if ahead, exists := leaders[vehicle]; exists {
+		leader = &ahead
+	}
+	return vehicle.FollowOnSegment(sim.TimeStepSeconds, sim.Grid, leader)
+}
+
+// releaseStopped takes vehicles that stopped moving this step off the
+// arbiter's count. It runs once the whole step is done so the space they free
+// is only granted from the next step on, however the step was split up.
+func (sim *Simulation) releaseStopped(vehicles []*coremodels.Vehicle) {
+	for _, vehicle := range vehicles {
+		if vehicle.Status != coremodels.StatusMoving {
+			sim.Arbiter.Release(vehicle)
+		}
+	}
+}
+
+func (sim *Simulation) ActiveVehicleCount() int {
+	active := 0
+	for _, vehicle := range sim.Vehicles {
+		if vehicle.Status == coremodels.StatusMoving || vehicle.Status == coremodels.StatusWaitingForPermission {
+			active++
+		}
+	}
+	return active
+}
+
+func (sim *Simulation) SimulatedSeconds() float64 {
+	return float64(sim.StepsRun) * sim.TimeStepSeconds
+}
+
+func (sim *Simulation) step() time.Duration {
+	return time.Duration(sim.TimeStepSeconds * float64(time.Second))
+}
+
+func (sim *Simulation) WallTime() time.Duration {
+	if sim.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(sim.startedAt)
+}
@@ -3,9 +3,10 @@ package vehicleengine
 import (
 	"fmt"
 	"math/rand"
-	"time"
+	"sort"
 
 	"github.com/segmentio/ksuid"
+	"owenvi.com/roadgraph/simclock"
 	"owenvi.com/simsim/internal/coremodels"
 )
 
@@ -13,13 +14,16 @@ type VehicleSpawner struct {
 	grid   *coremodels.Grid
 	router *coremodels.VehicleRouter
 	rng    *rand.Rand
+	clock  simclock.Clock
 }
 
-func NewVehicleSpawner(grid *coremodels.Grid) *VehicleSpawner {
+// NewVehicleSpawner spawns vehicles on grid that keep time by clock.
+func NewVehicleSpawner(grid *coremodels.Grid, seed int64, clock simclock.Clock) *VehicleSpawner {
 	return &VehicleSpawner{
 		grid:   grid,
-		router: coremodels.NewVehicleRouter(),
-		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		router: coremodels.NewVehicleRouter(seed),
+		rng:    rand.New(rand.NewSource(seed)),
+		clock:  clock,
 	}
 }
 
@@ -35,29 +39,61 @@ func (vs *VehicleSpawner) SpawnVehicle() (*coremodels.Vehicle, error) {
 	}
 
 	baseSpeed := 30 + vs.rng.Float64()*50
+	temperament := (baseSpeed - 30) / 50
 
 	direction := int64(1)
 	if vs.rng.Intn(2) == 0 {
 		direction = -1
 	}
+	switch spawnSegment.Direction {
+	case coremodels.ForwardOnly:
+		direction = 1
+	case coremodels.BackwardOnly:
+		direction = -1
+	}
+
+	id, err := vs.newVehicleID()
+	if err != nil {
+		return nil, err
+	}
 
 	vehicle := &coremodels.Vehicle{
-		ID:               ksuid.New(),
+		ID:               id,
 		CurrentSegmentID: spawnSegment.ID,
 		SegmentProgress:  vs.rng.Float64() * 0.2,
 		TargetNodeID:     targetNode,
 		BaseSpeedKPH:     baseSpeed,
 		CurrentSpeedKPH:  baseSpeed,
 		Status:           coremodels.StatusMoving,
-		SpawnTime:        time.Now(),
-		LastUpdate:       time.Now(),
+		SpawnTime:        vs.clock.Now(),
+		LastUpdate:       vs.clock.Now(),
 		TravelDirection:  direction,
 		MaxTrailLength:   15,
+		Clock:            vs.clock,
+
+		// Drivers who want to go faster also speed up harder and follow
+		// closer.
+		AccelerationMPS2:            1.0 + temperament,
+		ComfortableDecelerationMPS2: coremodels.DefaultComfortableDecelerationMPS2,
+		MinimumGapM:                 coremodels.DefaultMinimumGapM,
+		TimeHeadwaySeconds:          1.8 - 0.6*temperament,
 	}
 
 	return vehicle, nil
 }
 
+// newVehicleID draws the KSUID payload from the spawner's rng and stamps it
+// with the grid's timestamp, so the same seed names vehicles the same way.
+func (vs *VehicleSpawner) newVehicleID() (ksuid.KSUID, error) {
+	payload := make([]byte, 16)
+	vs.rng.Read(payload)
+	id, err := ksuid.FromParts(vs.grid.ID.Time(), payload)
+	if err != nil {
+		return ksuid.Nil, fmt.Errorf("failed to build vehicle ID: %w", err)
+	}
+	return id, nil
+}
+
 func (vs *VehicleSpawner) SpawnMultipleVehicles(count int) ([]*coremodels.Vehicle, error) {
 	vehicles := make([]*coremodels.Vehicle, 0, count)
 
@@ -85,11 +121,11 @@ func (vs *VehicleSpawner) selectRandomSegment() *coremodels.RoadSegment {
 	for _, segment := range vs.grid.Segments {
 		segmentList = append(segmentList, segment)
 	}
+	sort.Slice(segmentList, func(i, j int) bool { return segmentList[i].ID < segmentList[j].ID })
 
 	return segmentList[vs.rng.Intn(len(segmentList))]
 }
 
-
 func (vs *VehicleSpawner) selectRandomTargetNode(excludeSegment *coremodels.RoadSegment) int64 {
 	if len(vs.grid.Nodes) < 3 {
 		return -1
@@ -107,6 +143,7 @@ func (vs *VehicleSpawner) selectRandomTargetNode(excludeSegment *coremodels.Road
 	if len(nodeList) == 0 {
 		return -1
 	}
+	sort.Slice(nodeList, func(i, j int) bool { return nodeList[i] < nodeList[j] })
 
 	return nodeList[vs.rng.Intn(len(nodeList))]
-}
\ No newline at end of file
+}